@@ -354,8 +354,11 @@ type Metadata struct {
 	ReceiverName string `protobuf:"bytes,6,opt,name=receiver_name,json=receiverName,proto3" json:"receiver_name,omitempty"`
 	// Version of receiver (e.g., 1.26.2).
 	ReceiverVersion string `protobuf:"bytes,7,opt,name=receiver_version,json=receiverVersion,proto3" json:"receiver_version,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	// How the event was sampled by SentryFlow. Not set if no sample processor
+	// selected the event.
+	Sampling      *SamplingDecision `protobuf:"bytes,8,opt,name=sampling,proto3" json:"sampling,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Metadata) Reset() {
@@ -438,6 +441,79 @@ func (x *Metadata) GetReceiverVersion() string {
 	return ""
 }
 
+func (x *Metadata) GetSampling() *SamplingDecision {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+// SamplingDecision describes why a sampled API event was kept.
+type SamplingDecision struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// `always` if an always-keep rule kept the event, otherwise `sampled`.
+	Decision string `protobuf:"bytes,1,opt,name=decision,proto3" json:"decision,omitempty"`
+	// The always-keep rule that kept the event: `status`, `latency` or
+	// `namespace`.
+	Rule string `protobuf:"bytes,2,opt,name=rule,proto3" json:"rule,omitempty"`
+	// The probability the event had of being kept. Each kept event stands for
+	// 1/rate events, e.g. when counting them.
+	Rate          float64 `protobuf:"fixed64,3,opt,name=rate,proto3" json:"rate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SamplingDecision) Reset() {
+	*x = SamplingDecision{}
+	mi := &file_sentryflow_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SamplingDecision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SamplingDecision) ProtoMessage() {}
+
+func (x *SamplingDecision) ProtoReflect() protoreflect.Message {
+	mi := &file_sentryflow_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SamplingDecision.ProtoReflect.Descriptor instead.
+func (*SamplingDecision) Descriptor() ([]byte, []int) {
+	return file_sentryflow_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SamplingDecision) GetDecision() string {
+	if x != nil {
+		return x.Decision
+	}
+	return ""
+}
+
+func (x *SamplingDecision) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *SamplingDecision) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
 // Workload represents a generic entity that can be either a Kubernetes or
 // non-Kubernetes resource. It serves as a source or destination for access
 // within a system.
@@ -451,14 +527,23 @@ type Workload struct {
 	// The IP address of the workload.
 	Ip string `protobuf:"bytes,3,opt,name=ip,proto3" json:"ip,omitempty"`
 	// The port number used by the workload.
-	Port          int32 `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	Port int32 `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	// The labels of the workload's pod or service. Only set for Kubernetes
+	// workloads.
+	Labels map[string]string `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The kind of the workload, e.g. `Deployment`, `StatefulSet`, `DaemonSet`,
+	// `Pod` or `Service`. Only set for Kubernetes workloads.
+	Kind string `protobuf:"bytes,6,opt,name=kind,proto3" json:"kind,omitempty"`
+	// The name of the node where the workload is running. Only set for
+	// Kubernetes workloads.
+	NodeName      string `protobuf:"bytes,7,opt,name=node_name,json=nodeName,proto3" json:"node_name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Workload) Reset() {
 	*x = Workload{}
-	mi := &file_sentryflow_proto_msgTypes[4]
+	mi := &file_sentryflow_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -470,7 +555,7 @@ func (x *Workload) String() string {
 func (*Workload) ProtoMessage() {}
 
 func (x *Workload) ProtoReflect() protoreflect.Message {
-	mi := &file_sentryflow_proto_msgTypes[4]
+	mi := &file_sentryflow_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -483,7 +568,7 @@ func (x *Workload) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Workload.ProtoReflect.Descriptor instead.
 func (*Workload) Descriptor() ([]byte, []int) {
-	return file_sentryflow_proto_rawDescGZIP(), []int{4}
+	return file_sentryflow_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *Workload) GetName() string {
@@ -514,18 +599,42 @@ func (x *Workload) GetPort() int32 {
 	return 0
 }
 
+func (x *Workload) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Workload) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Workload) GetNodeName() string {
+	if x != nil {
+		return x.NodeName
+	}
+	return ""
+}
+
 // Request represents an incoming HTTP request.
 type Request struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Headers       map[string]string      `protobuf:"bytes,1,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Headers map[string]string      `protobuf:"bytes,1,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Body    string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	// The templated route of the request path, e.g. `/orders/{id}/items/{id}` for
+	// `/orders/8812/items/3`. Set by SentryFlow when path normalization is enabled.
+	Route         string `protobuf:"bytes,3,opt,name=route,proto3" json:"route,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Request) Reset() {
 	*x = Request{}
-	mi := &file_sentryflow_proto_msgTypes[5]
+	mi := &file_sentryflow_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -537,7 +646,7 @@ func (x *Request) String() string {
 func (*Request) ProtoMessage() {}
 
 func (x *Request) ProtoReflect() protoreflect.Message {
-	mi := &file_sentryflow_proto_msgTypes[5]
+	mi := &file_sentryflow_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -550,7 +659,7 @@ func (x *Request) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Request.ProtoReflect.Descriptor instead.
 func (*Request) Descriptor() ([]byte, []int) {
-	return file_sentryflow_proto_rawDescGZIP(), []int{5}
+	return file_sentryflow_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Request) GetHeaders() map[string]string {
@@ -567,6 +676,13 @@ func (x *Request) GetBody() string {
 	return ""
 }
 
+func (x *Request) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
 // Response represents an outgoing HTTP response.
 type Response struct {
 	state                 protoimpl.MessageState `protogen:"open.v1"`
@@ -579,7 +695,7 @@ type Response struct {
 
 func (x *Response) Reset() {
 	*x = Response{}
-	mi := &file_sentryflow_proto_msgTypes[6]
+	mi := &file_sentryflow_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -591,7 +707,7 @@ func (x *Response) String() string {
 func (*Response) ProtoMessage() {}
 
 func (x *Response) ProtoReflect() protoreflect.Message {
-	mi := &file_sentryflow_proto_msgTypes[6]
+	mi := &file_sentryflow_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -604,7 +720,7 @@ func (x *Response) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Response.ProtoReflect.Descriptor instead.
 func (*Response) Descriptor() ([]byte, []int) {
-	return file_sentryflow_proto_rawDescGZIP(), []int{6}
+	return file_sentryflow_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Response) GetHeaders() map[string]string {
@@ -637,7 +753,7 @@ type APIMetrics struct {
 
 func (x *APIMetrics) Reset() {
 	*x = APIMetrics{}
-	mi := &file_sentryflow_proto_msgTypes[7]
+	mi := &file_sentryflow_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -649,7 +765,7 @@ func (x *APIMetrics) String() string {
 func (*APIMetrics) ProtoMessage() {}
 
 func (x *APIMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_sentryflow_proto_msgTypes[7]
+	mi := &file_sentryflow_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -662,7 +778,7 @@ func (x *APIMetrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use APIMetrics.ProtoReflect.Descriptor instead.
 func (*APIMetrics) Descriptor() ([]byte, []int) {
-	return file_sentryflow_proto_rawDescGZIP(), []int{7}
+	return file_sentryflow_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *APIMetrics) GetPerAPICounts() map[string]uint64 {
@@ -681,7 +797,7 @@ type MetricValue struct {
 
 func (x *MetricValue) Reset() {
 	*x = MetricValue{}
-	mi := &file_sentryflow_proto_msgTypes[8]
+	mi := &file_sentryflow_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -693,7 +809,7 @@ func (x *MetricValue) String() string {
 func (*MetricValue) ProtoMessage() {}
 
 func (x *MetricValue) ProtoReflect() protoreflect.Message {
-	mi := &file_sentryflow_proto_msgTypes[8]
+	mi := &file_sentryflow_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -706,7 +822,7 @@ func (x *MetricValue) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MetricValue.ProtoReflect.Descriptor instead.
 func (*MetricValue) Descriptor() ([]byte, []int) {
-	return file_sentryflow_proto_rawDescGZIP(), []int{8}
+	return file_sentryflow_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *MetricValue) GetValue() map[string]string {
@@ -730,7 +846,7 @@ type EnvoyMetrics struct {
 
 func (x *EnvoyMetrics) Reset() {
 	*x = EnvoyMetrics{}
-	mi := &file_sentryflow_proto_msgTypes[9]
+	mi := &file_sentryflow_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -742,7 +858,7 @@ func (x *EnvoyMetrics) String() string {
 func (*EnvoyMetrics) ProtoMessage() {}
 
 func (x *EnvoyMetrics) ProtoReflect() protoreflect.Message {
-	mi := &file_sentryflow_proto_msgTypes[9]
+	mi := &file_sentryflow_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -755,7 +871,7 @@ func (x *EnvoyMetrics) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use EnvoyMetrics.ProtoReflect.Descriptor instead.
 func (*EnvoyMetrics) Descriptor() ([]byte, []int) {
-	return file_sentryflow_proto_rawDescGZIP(), []int{9}
+	return file_sentryflow_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *EnvoyMetrics) GetTimeStamp() string {
@@ -840,7 +956,7 @@ const file_sentryflow_proto_rawDesc = "" +
 	"\vdestination\x18\x04 \x01(\v2\x12.protobuf.WorkloadR\vdestination\x12+\n" +
 	"\arequest\x18\x05 \x01(\v2\x11.protobuf.RequestR\arequest\x12.\n" +
 	"\bresponse\x18\x06 \x01(\v2\x12.protobuf.ResponseR\bresponse\x12\x1a\n" +
-	"\bprotocol\x18\a \x01(\tR\bprotocol\"\xf6\x01\n" +
+	"\bprotocol\x18\a \x01(\tR\bprotocol\"\xae\x02\n" +
 	"\bMetadata\x12\x1d\n" +
 	"\n" +
 	"context_id\x18\x01 \x01(\rR\tcontextId\x12\x1c\n" +
@@ -849,15 +965,27 @@ const file_sentryflow_proto_rawDesc = "" +
 	"\amesh_id\x18\x04 \x01(\tR\x06meshId\x12\x1b\n" +
 	"\tnode_name\x18\x05 \x01(\tR\bnodeName\x12#\n" +
 	"\rreceiver_name\x18\x06 \x01(\tR\freceiverName\x12)\n" +
-	"\x10receiver_version\x18\a \x01(\tR\x0freceiverVersion\"`\n" +
+	"\x10receiver_version\x18\a \x01(\tR\x0freceiverVersion\x126\n" +
+	"\bsampling\x18\b \x01(\v2\x1a.protobuf.SamplingDecisionR\bsampling\"V\n" +
+	"\x10SamplingDecision\x12\x1a\n" +
+	"\bdecision\x18\x01 \x01(\tR\bdecision\x12\x12\n" +
+	"\x04rule\x18\x02 \x01(\tR\x04rule\x12\x12\n" +
+	"\x04rate\x18\x03 \x01(\x01R\x04rate\"\x84\x02\n" +
 	"\bWorkload\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1c\n" +
 	"\tnamespace\x18\x02 \x01(\tR\tnamespace\x12\x0e\n" +
 	"\x02ip\x18\x03 \x01(\tR\x02ip\x12\x12\n" +
-	"\x04port\x18\x04 \x01(\x05R\x04port\"\x93\x01\n" +
+	"\x04port\x18\x04 \x01(\x05R\x04port\x126\n" +
+	"\x06labels\x18\x05 \x03(\v2\x1e.protobuf.Workload.LabelsEntryR\x06labels\x12\x12\n" +
+	"\x04kind\x18\x06 \x01(\tR\x04kind\x12\x1b\n" +
+	"\tnode_name\x18\a \x01(\tR\bnodeName\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xa9\x01\n" +
 	"\aRequest\x128\n" +
 	"\aheaders\x18\x01 \x03(\v2\x1e.protobuf.Request.HeadersEntryR\aheaders\x12\x12\n" +
-	"\x04body\x18\x02 \x01(\tR\x04body\x1a:\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x12\x14\n" +
+	"\x05route\x18\x03 \x01(\tR\x05route\x1a:\n" +
 	"\fHeadersEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xce\x01\n" +
@@ -913,57 +1041,61 @@ func file_sentryflow_proto_rawDescGZIP() []byte {
 	return file_sentryflow_proto_rawDescData
 }
 
-var file_sentryflow_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_sentryflow_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_sentryflow_proto_goTypes = []any{
-	(*ClientInfo)(nil),   // 0: protobuf.ClientInfo
-	(*APILog)(nil),       // 1: protobuf.APILog
-	(*APIEvent)(nil),     // 2: protobuf.APIEvent
-	(*Metadata)(nil),     // 3: protobuf.Metadata
-	(*Workload)(nil),     // 4: protobuf.Workload
-	(*Request)(nil),      // 5: protobuf.Request
-	(*Response)(nil),     // 6: protobuf.Response
-	(*APIMetrics)(nil),   // 7: protobuf.APIMetrics
-	(*MetricValue)(nil),  // 8: protobuf.MetricValue
-	(*EnvoyMetrics)(nil), // 9: protobuf.EnvoyMetrics
-	nil,                  // 10: protobuf.APILog.SrcLabelEntry
-	nil,                  // 11: protobuf.APILog.DstLabelEntry
-	nil,                  // 12: protobuf.Request.HeadersEntry
-	nil,                  // 13: protobuf.Response.HeadersEntry
-	nil,                  // 14: protobuf.APIMetrics.PerAPICountsEntry
-	nil,                  // 15: protobuf.MetricValue.ValueEntry
-	nil,                  // 16: protobuf.EnvoyMetrics.LabelsEntry
-	nil,                  // 17: protobuf.EnvoyMetrics.MetricsEntry
+	(*ClientInfo)(nil),       // 0: protobuf.ClientInfo
+	(*APILog)(nil),           // 1: protobuf.APILog
+	(*APIEvent)(nil),         // 2: protobuf.APIEvent
+	(*Metadata)(nil),         // 3: protobuf.Metadata
+	(*SamplingDecision)(nil), // 4: protobuf.SamplingDecision
+	(*Workload)(nil),         // 5: protobuf.Workload
+	(*Request)(nil),          // 6: protobuf.Request
+	(*Response)(nil),         // 7: protobuf.Response
+	(*APIMetrics)(nil),       // 8: protobuf.APIMetrics
+	(*MetricValue)(nil),      // 9: protobuf.MetricValue
+	(*EnvoyMetrics)(nil),     // 10: protobuf.EnvoyMetrics
+	nil,                      // 11: protobuf.APILog.SrcLabelEntry
+	nil,                      // 12: protobuf.APILog.DstLabelEntry
+	nil,                      // 13: protobuf.Workload.LabelsEntry
+	nil,                      // 14: protobuf.Request.HeadersEntry
+	nil,                      // 15: protobuf.Response.HeadersEntry
+	nil,                      // 16: protobuf.APIMetrics.PerAPICountsEntry
+	nil,                      // 17: protobuf.MetricValue.ValueEntry
+	nil,                      // 18: protobuf.EnvoyMetrics.LabelsEntry
+	nil,                      // 19: protobuf.EnvoyMetrics.MetricsEntry
 }
 var file_sentryflow_proto_depIdxs = []int32{
-	10, // 0: protobuf.APILog.srcLabel:type_name -> protobuf.APILog.SrcLabelEntry
-	11, // 1: protobuf.APILog.dstLabel:type_name -> protobuf.APILog.DstLabelEntry
+	11, // 0: protobuf.APILog.srcLabel:type_name -> protobuf.APILog.SrcLabelEntry
+	12, // 1: protobuf.APILog.dstLabel:type_name -> protobuf.APILog.DstLabelEntry
 	3,  // 2: protobuf.APIEvent.metadata:type_name -> protobuf.Metadata
-	4,  // 3: protobuf.APIEvent.source:type_name -> protobuf.Workload
-	4,  // 4: protobuf.APIEvent.destination:type_name -> protobuf.Workload
-	5,  // 5: protobuf.APIEvent.request:type_name -> protobuf.Request
-	6,  // 6: protobuf.APIEvent.response:type_name -> protobuf.Response
-	12, // 7: protobuf.Request.headers:type_name -> protobuf.Request.HeadersEntry
-	13, // 8: protobuf.Response.headers:type_name -> protobuf.Response.HeadersEntry
-	14, // 9: protobuf.APIMetrics.perAPICounts:type_name -> protobuf.APIMetrics.PerAPICountsEntry
-	15, // 10: protobuf.MetricValue.value:type_name -> protobuf.MetricValue.ValueEntry
-	16, // 11: protobuf.EnvoyMetrics.labels:type_name -> protobuf.EnvoyMetrics.LabelsEntry
-	17, // 12: protobuf.EnvoyMetrics.metrics:type_name -> protobuf.EnvoyMetrics.MetricsEntry
-	8,  // 13: protobuf.EnvoyMetrics.MetricsEntry.value:type_name -> protobuf.MetricValue
-	0,  // 14: protobuf.SentryFlow.GetAPILog:input_type -> protobuf.ClientInfo
-	0,  // 15: protobuf.SentryFlow.GetAPIEvent:input_type -> protobuf.ClientInfo
-	2,  // 16: protobuf.SentryFlow.SendAPIEvent:input_type -> protobuf.APIEvent
-	0,  // 17: protobuf.SentryFlow.GetAPIMetrics:input_type -> protobuf.ClientInfo
-	0,  // 18: protobuf.SentryFlow.GetEnvoyMetrics:input_type -> protobuf.ClientInfo
-	1,  // 19: protobuf.SentryFlow.GetAPILog:output_type -> protobuf.APILog
-	2,  // 20: protobuf.SentryFlow.GetAPIEvent:output_type -> protobuf.APIEvent
-	2,  // 21: protobuf.SentryFlow.SendAPIEvent:output_type -> protobuf.APIEvent
-	7,  // 22: protobuf.SentryFlow.GetAPIMetrics:output_type -> protobuf.APIMetrics
-	9,  // 23: protobuf.SentryFlow.GetEnvoyMetrics:output_type -> protobuf.EnvoyMetrics
-	19, // [19:24] is the sub-list for method output_type
-	14, // [14:19] is the sub-list for method input_type
-	14, // [14:14] is the sub-list for extension type_name
-	14, // [14:14] is the sub-list for extension extendee
-	0,  // [0:14] is the sub-list for field type_name
+	5,  // 3: protobuf.APIEvent.source:type_name -> protobuf.Workload
+	5,  // 4: protobuf.APIEvent.destination:type_name -> protobuf.Workload
+	6,  // 5: protobuf.APIEvent.request:type_name -> protobuf.Request
+	7,  // 6: protobuf.APIEvent.response:type_name -> protobuf.Response
+	4,  // 7: protobuf.Metadata.sampling:type_name -> protobuf.SamplingDecision
+	13, // 8: protobuf.Workload.labels:type_name -> protobuf.Workload.LabelsEntry
+	14, // 9: protobuf.Request.headers:type_name -> protobuf.Request.HeadersEntry
+	15, // 10: protobuf.Response.headers:type_name -> protobuf.Response.HeadersEntry
+	16, // 11: protobuf.APIMetrics.perAPICounts:type_name -> protobuf.APIMetrics.PerAPICountsEntry
+	17, // 12: protobuf.MetricValue.value:type_name -> protobuf.MetricValue.ValueEntry
+	18, // 13: protobuf.EnvoyMetrics.labels:type_name -> protobuf.EnvoyMetrics.LabelsEntry
+	19, // 14: protobuf.EnvoyMetrics.metrics:type_name -> protobuf.EnvoyMetrics.MetricsEntry
+	9,  // 15: protobuf.EnvoyMetrics.MetricsEntry.value:type_name -> protobuf.MetricValue
+	0,  // 16: protobuf.SentryFlow.GetAPILog:input_type -> protobuf.ClientInfo
+	0,  // 17: protobuf.SentryFlow.GetAPIEvent:input_type -> protobuf.ClientInfo
+	2,  // 18: protobuf.SentryFlow.SendAPIEvent:input_type -> protobuf.APIEvent
+	0,  // 19: protobuf.SentryFlow.GetAPIMetrics:input_type -> protobuf.ClientInfo
+	0,  // 20: protobuf.SentryFlow.GetEnvoyMetrics:input_type -> protobuf.ClientInfo
+	1,  // 21: protobuf.SentryFlow.GetAPILog:output_type -> protobuf.APILog
+	2,  // 22: protobuf.SentryFlow.GetAPIEvent:output_type -> protobuf.APIEvent
+	2,  // 23: protobuf.SentryFlow.SendAPIEvent:output_type -> protobuf.APIEvent
+	8,  // 24: protobuf.SentryFlow.GetAPIMetrics:output_type -> protobuf.APIMetrics
+	10, // 25: protobuf.SentryFlow.GetEnvoyMetrics:output_type -> protobuf.EnvoyMetrics
+	21, // [21:26] is the sub-list for method output_type
+	16, // [16:21] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_sentryflow_proto_init() }
@@ -977,7 +1109,7 @@ func file_sentryflow_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sentryflow_proto_rawDesc), len(file_sentryflow_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   18,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -127,13 +127,14 @@ class EnvoyMetrics(_message.Message):
     def __init__(self, timeStamp: _Optional[str] = ..., namespace: _Optional[str] = ..., name: _Optional[str] = ..., IPAddress: _Optional[str] = ..., labels: _Optional[_Mapping[str, str]] = ..., metrics: _Optional[_Mapping[str, MetricValue]] = ...) -> None: ...
 
 class Metadata(_message.Message):
-    __slots__ = ["context_id", "istio_version", "mesh_id", "node_name", "receiver_name", "receiver_version", "timestamp"]
+    __slots__ = ["context_id", "istio_version", "mesh_id", "node_name", "receiver_name", "receiver_version", "sampling", "timestamp"]
     CONTEXT_ID_FIELD_NUMBER: _ClassVar[int]
     ISTIO_VERSION_FIELD_NUMBER: _ClassVar[int]
     MESH_ID_FIELD_NUMBER: _ClassVar[int]
     NODE_NAME_FIELD_NUMBER: _ClassVar[int]
     RECEIVER_NAME_FIELD_NUMBER: _ClassVar[int]
     RECEIVER_VERSION_FIELD_NUMBER: _ClassVar[int]
+    SAMPLING_FIELD_NUMBER: _ClassVar[int]
     TIMESTAMP_FIELD_NUMBER: _ClassVar[int]
     context_id: int
     istio_version: str
@@ -141,8 +142,9 @@ class Metadata(_message.Message):
     node_name: str
     receiver_name: str
     receiver_version: str
+    sampling: SamplingDecision
     timestamp: int
-    def __init__(self, context_id: _Optional[int] = ..., timestamp: _Optional[int] = ..., istio_version: _Optional[str] = ..., mesh_id: _Optional[str] = ..., node_name: _Optional[str] = ..., receiver_name: _Optional[str] = ..., receiver_version: _Optional[str] = ...) -> None: ...
+    def __init__(self, context_id: _Optional[int] = ..., timestamp: _Optional[int] = ..., istio_version: _Optional[str] = ..., mesh_id: _Optional[str] = ..., node_name: _Optional[str] = ..., receiver_name: _Optional[str] = ..., receiver_version: _Optional[str] = ..., sampling: _Optional[_Union[SamplingDecision, _Mapping]] = ...) -> None: ...
 
 class MetricValue(_message.Message):
     __slots__ = ["value"]
@@ -158,7 +160,7 @@ class MetricValue(_message.Message):
     def __init__(self, value: _Optional[_Mapping[str, str]] = ...) -> None: ...
 
 class Request(_message.Message):
-    __slots__ = ["body", "headers"]
+    __slots__ = ["body", "headers", "route"]
     class HeadersEntry(_message.Message):
         __slots__ = ["key", "value"]
         KEY_FIELD_NUMBER: _ClassVar[int]
@@ -168,9 +170,11 @@ class Request(_message.Message):
         def __init__(self, key: _Optional[str] = ..., value: _Optional[str] = ...) -> None: ...
     BODY_FIELD_NUMBER: _ClassVar[int]
     HEADERS_FIELD_NUMBER: _ClassVar[int]
+    ROUTE_FIELD_NUMBER: _ClassVar[int]
     body: str
     headers: _containers.ScalarMap[str, str]
-    def __init__(self, headers: _Optional[_Mapping[str, str]] = ..., body: _Optional[str] = ...) -> None: ...
+    route: str
+    def __init__(self, headers: _Optional[_Mapping[str, str]] = ..., body: _Optional[str] = ..., route: _Optional[str] = ...) -> None: ...
 
 class Response(_message.Message):
     __slots__ = ["backend_latency_in_nanos", "body", "headers"]
@@ -189,14 +193,37 @@ class Response(_message.Message):
     headers: _containers.ScalarMap[str, str]
     def __init__(self, headers: _Optional[_Mapping[str, str]] = ..., body: _Optional[str] = ..., backend_latency_in_nanos: _Optional[int] = ...) -> None: ...
 
+class SamplingDecision(_message.Message):
+    __slots__ = ["decision", "rate", "rule"]
+    DECISION_FIELD_NUMBER: _ClassVar[int]
+    RATE_FIELD_NUMBER: _ClassVar[int]
+    RULE_FIELD_NUMBER: _ClassVar[int]
+    decision: str
+    rate: float
+    rule: str
+    def __init__(self, decision: _Optional[str] = ..., rule: _Optional[str] = ..., rate: _Optional[float] = ...) -> None: ...
+
 class Workload(_message.Message):
-    __slots__ = ["ip", "name", "namespace", "port"]
+    __slots__ = ["ip", "kind", "labels", "name", "namespace", "node_name", "port"]
+    class LabelsEntry(_message.Message):
+        __slots__ = ["key", "value"]
+        KEY_FIELD_NUMBER: _ClassVar[int]
+        VALUE_FIELD_NUMBER: _ClassVar[int]
+        key: str
+        value: str
+        def __init__(self, key: _Optional[str] = ..., value: _Optional[str] = ...) -> None: ...
     IP_FIELD_NUMBER: _ClassVar[int]
+    KIND_FIELD_NUMBER: _ClassVar[int]
+    LABELS_FIELD_NUMBER: _ClassVar[int]
     NAMESPACE_FIELD_NUMBER: _ClassVar[int]
     NAME_FIELD_NUMBER: _ClassVar[int]
+    NODE_NAME_FIELD_NUMBER: _ClassVar[int]
     PORT_FIELD_NUMBER: _ClassVar[int]
     ip: str
+    kind: str
+    labels: _containers.ScalarMap[str, str]
     name: str
     namespace: str
+    node_name: str
     port: int
-    def __init__(self, name: _Optional[str] = ..., namespace: _Optional[str] = ..., ip: _Optional[str] = ..., port: _Optional[int] = ...) -> None: ...
+    def __init__(self, name: _Optional[str] = ..., namespace: _Optional[str] = ..., ip: _Optional[str] = ..., port: _Optional[int] = ..., labels: _Optional[_Mapping[str, str]] = ..., kind: _Optional[str] = ..., node_name: _Optional[str] = ...) -> None: ...
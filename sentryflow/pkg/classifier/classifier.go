@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package classifier clusters raw API paths into templated endpoints, e.g.
+// `/users/123` and `/users/456` into `/users/{id}`.
+package classifier
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+const (
+	// IDPlaceholder replaces path segments that look like identifiers.
+	IDPlaceholder = "{id}"
+
+	// ParamPlaceholder replaces path segments that take too many distinct
+	// values to be part of an endpoint's name.
+	ParamPlaceholder = "{param}"
+
+	// DefaultMaxChildren is the number of distinct segments allowed after a
+	// common prefix before they are merged into ParamPlaceholder.
+	DefaultMaxChildren = 10
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Normalize strips the query string and fragment from path and replaces the
+// segments that look like identifiers, i.e. numbers, UUIDs, hex encoded hashes
+// and long random tokens, with IDPlaceholder. Empty segments and trailing
+// slashes are dropped.
+func Normalize(path string) string {
+	return "/" + strings.Join(normalizedSegments(path), "/")
+}
+
+func normalizedSegments(path string) []string {
+	path = util.TrimQuery(path)
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if IsID(segment) {
+			segment = IDPlaceholder
+		}
+		segments = append(segments, segment)
+	}
+	return segments
+}
+
+// IsID reports whether a path segment looks like an identifier rather than
+// part of an endpoint's name.
+func IsID(segment string) bool {
+	switch {
+	case isDigits(segment):
+		return true
+	case uuidPattern.MatchString(segment):
+		return true
+	case len(segment) >= 16 && isHex(segment):
+		return true
+	case len(segment) >= 24 && isToken(segment):
+		return true
+	}
+	return false
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !unicode.Is(unicode.ASCII_Hex_Digit, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isToken reports whether s looks like a random token, e.g. base64url, made of
+// both letters and digits.
+func isToken(s string) bool {
+	var letters, digits bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			letters = true
+		case r == '-' || r == '_':
+		default:
+			return false
+		}
+	}
+	return letters && digits
+}
+
+// Classifier clusters paths into templated endpoints. It first normalizes each
+// path, then merges the segments following a common prefix into
+// ParamPlaceholder once there are more than MaxChildren distinct ones.
+type Classifier struct {
+	// MaxChildren is the number of distinct segments allowed after a common
+	// prefix. Defaults to DefaultMaxChildren.
+	MaxChildren int
+
+	root *node
+}
+
+// New returns a Classifier that uses DefaultMaxChildren.
+func New() *Classifier {
+	return &Classifier{MaxChildren: DefaultMaxChildren}
+}
+
+// Add counts a call to path.
+func (c *Classifier) Add(path string) {
+	if c.root == nil {
+		c.root = newNode()
+	}
+	c.root.insert(normalizedSegments(path))
+}
+
+// Counts returns the number of calls per templated endpoint.
+func (c *Classifier) Counts() map[string]uint64 {
+	counts := make(map[string]uint64)
+	if c.root == nil {
+		return counts
+	}
+
+	maxChildren := c.MaxChildren
+	if maxChildren <= 0 {
+		maxChildren = DefaultMaxChildren
+	}
+	c.root.collapse(maxChildren)
+	c.root.walk(nil, counts)
+	return counts
+}
+
+// Classify returns the number of calls per templated endpoint of paths.
+func Classify(paths []string) map[string]uint64 {
+	c := New()
+	for _, path := range paths {
+		c.Add(path)
+	}
+	return c.Counts()
+}
+
+type node struct {
+	children map[string]*node
+	count    uint64
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+func (n *node) child(segment string) *node {
+	child, exists := n.children[segment]
+	if !exists {
+		child = newNode()
+		n.children[segment] = child
+	}
+	return child
+}
+
+func (n *node) insert(segments []string) {
+	if len(segments) == 0 {
+		n.count++
+		return
+	}
+	n.child(segments[0]).insert(segments[1:])
+}
+
+// collapse merges the children of every node that has more than maxChildren of
+// them into a single ParamPlaceholder child.
+func (n *node) collapse(maxChildren int) {
+	if len(n.children) > maxChildren {
+		param := newNode()
+		for _, child := range n.children {
+			param.merge(child)
+		}
+		n.children = map[string]*node{ParamPlaceholder: param}
+	}
+	for _, child := range n.children {
+		child.collapse(maxChildren)
+	}
+}
+
+func (n *node) merge(other *node) {
+	n.count += other.count
+	for segment, child := range other.children {
+		n.child(segment).merge(child)
+	}
+}
+
+func (n *node) walk(prefix []string, counts map[string]uint64) {
+	if n.count > 0 {
+		counts["/"+strings.Join(prefix, "/")] += n.count
+	}
+
+	segments := make([]string, 0, len(n.children))
+	for segment := range n.children {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		n.children[segment].walk(append(prefix, segment), counts)
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package classifier
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "root", path: "/", want: "/"},
+		{name: "empty", path: "", want: "/"},
+		{name: "static path", path: "/api/v1/users", want: "/api/v1/users"},
+		{name: "numeric id", path: "/users/123", want: "/users/{id}"},
+		{name: "query and fragment", path: "/users/123?expand=true#top", want: "/users/{id}"},
+		{name: "trailing and duplicate slashes", path: "//users//123/", want: "/users/{id}"},
+		{name: "uuid", path: "/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301/items", want: "/orders/{id}/items"},
+		{name: "sha1 hash", path: "/commits/2fd4e1c67a2d28fced849ee1bb76e7391b93eb12", want: "/commits/{id}"},
+		{name: "object id", path: "/docs/507f1f77bcf86cd799439011", want: "/docs/{id}"},
+		{name: "token", path: "/share/aZ3_kP9-qL2xW8mN4vB7tY1r", want: "/share/{id}"},
+		{name: "short hex word", path: "/feed/cafe", want: "/feed/cafe"},
+		{name: "long word", path: "/internationalization-settings", want: "/internationalization-settings"},
+		{name: "version", path: "/v2/items", want: "/v2/items"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.path); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	manyUsers := make([]string, 0, DefaultMaxChildren+1)
+	for i := 0; i <= DefaultMaxChildren; i++ {
+		manyUsers = append(manyUsers, fmt.Sprintf("/users/user%c/profile", 'a'+i))
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  map[string]uint64
+	}{
+		{
+			name:  "no paths",
+			paths: nil,
+			want:  map[string]uint64{},
+		},
+		{
+			name:  "ids are templated",
+			paths: []string{"/users/1", "/users/2?x=y", "/users/3/orders", "/users"},
+			want: map[string]uint64{
+				"/users":             1,
+				"/users/{id}":        2,
+				"/users/{id}/orders": 1,
+			},
+		},
+		{
+			name:  "few distinct names are kept",
+			paths: []string{"/users/alice", "/users/bob", "/users/alice"},
+			want: map[string]uint64{
+				"/users/alice": 2,
+				"/users/bob":   1,
+			},
+		},
+		{
+			name:  "many distinct names are merged",
+			paths: append(manyUsers, "/health"),
+			want: map[string]uint64{
+				"/users/{param}/profile": uint64(len(manyUsers)),
+				"/health":                1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
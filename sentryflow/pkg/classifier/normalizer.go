@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package classifier
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// Normalizer derives the templated route of request paths from, in order, the
+// configured rules, the path templates of OpenAPI documents and the built-in
+// heuristics of Normalize.
+type Normalizer struct {
+	rules     []rule
+	templates []template
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	route   string
+}
+
+// template is a path template of an OpenAPI document.
+type template struct {
+	route    string
+	pattern  *regexp.Regexp
+	segments int
+	literals int
+}
+
+// NewNormalizer returns a Normalizer for cfg. It reads the OpenAPI documents
+// listed in cfg.
+func NewNormalizer(cfg *config.NormalizationConfig) (*Normalizer, error) {
+	n := &Normalizer{}
+	for _, r := range cfg.Rules {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid normalization rule pattern, %v", err)
+		}
+		n.rules = append(n.rules, rule{pattern: pattern, route: r.Route})
+	}
+
+	seen := make(map[string]bool)
+	for _, spec := range cfg.OpenAPISpecs {
+		routes, err := readOpenAPIRoutes(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			if seen[route] {
+				continue
+			}
+			seen[route] = true
+			n.templates = append(n.templates, newTemplate(route))
+		}
+	}
+
+	// The most specific template wins, e.g. `/users/me` over `/users/{id}`.
+	sort.SliceStable(n.templates, func(i, j int) bool {
+		return n.templates[i].literals > n.templates[j].literals
+	})
+	return n, nil
+}
+
+// Route returns the templated route of path.
+func (n *Normalizer) Route(path string) string {
+	path = util.TrimQuery(path)
+
+	for _, r := range n.rules {
+		if r.pattern.MatchString(path) {
+			return r.pattern.ReplaceAllString(path, r.route)
+		}
+	}
+
+	if len(n.templates) > 0 {
+		trimmed := "/" + strings.Trim(path, "/")
+		segments := strings.Count(trimmed, "/")
+		for _, t := range n.templates {
+			if t.segments == segments && t.pattern.MatchString(trimmed) {
+				return t.route
+			}
+		}
+	}
+
+	return Normalize(path)
+}
+
+var templateParam = regexp.MustCompile(`\{[^/{}]+\}`)
+
+func newTemplate(route string) template {
+	trimmed := "/" + strings.Trim(route, "/")
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range templateParam.FindAllStringIndex(trimmed, -1) {
+		pattern.WriteString(regexp.QuoteMeta(trimmed[last:loc[0]]))
+		pattern.WriteString("[^/]+")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(trimmed[last:]))
+	pattern.WriteString("$")
+
+	t := template{
+		route:    route,
+		pattern:  regexp.MustCompile(pattern.String()),
+		segments: strings.Count(trimmed, "/"),
+	}
+	for _, segment := range strings.Split(trimmed, "/") {
+		if !strings.Contains(segment, "{") {
+			t.literals++
+		}
+	}
+	return t
+}
+
+// openAPIDocument is the part of an OpenAPI 3 or Swagger 2 document routes are
+// read from.
+type openAPIDocument struct {
+	BasePath string `json:"basePath"`
+	Servers  []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths map[string]any `json:"paths"`
+}
+
+// readOpenAPIRoutes returns the path templates of the OpenAPI document at
+// path, prefixed with the base path of each of its servers.
+func readOpenAPIRoutes(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read openAPI spec: %w", err)
+	}
+
+	// JSON is a subset of YAML, so both formats are handled alike.
+	doc := &openAPIDocument{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse openAPI spec %s: %w", path, err)
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("no paths found in openAPI spec, %s", path)
+	}
+
+	prefixes := []string{strings.TrimSuffix(doc.BasePath, "/")}
+	if len(doc.Servers) > 0 {
+		prefixes = nil
+		for _, server := range doc.Servers {
+			prefixes = append(prefixes, serverBasePath(server.URL))
+		}
+	}
+
+	var routes []string
+	for _, prefix := range prefixes {
+		for template := range doc.Paths {
+			routes = append(routes, prefix+"/"+strings.TrimPrefix(template, "/"))
+		}
+	}
+	sort.Strings(routes)
+	return routes, nil
+}
+
+// serverBasePath returns the path of an OpenAPI server URL without the trailing
+// slash. The URL may be relative and its host may contain variables, so it
+// isn't parsed with net/url.
+func serverBasePath(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+len("://"):]
+		if j := strings.Index(url, "/"); j >= 0 {
+			url = url[j:]
+		} else {
+			url = ""
+		}
+	}
+	return strings.TrimSuffix(url, "/")
+}
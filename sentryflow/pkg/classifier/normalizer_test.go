@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package classifier
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+const openAPI3Spec = `
+openapi: 3.0.0
+servers:
+  - url: https://{environment}.example.com/shop/
+paths:
+  /users/{userId}:
+    get: {}
+  /users/me:
+    get: {}
+  /orders/{orderId}/items/{itemId}:
+    get: {}
+  /files/{name}.json:
+    get: {}
+`
+
+const swagger2Spec = `{
+  "swagger": "2.0",
+  "basePath": "/v1",
+  "paths": {
+    "/pets/{petId}": {"get": {}}
+  }
+}`
+
+func TestNormalizer_Route(t *testing.T) {
+	dir := t.TempDir()
+	openAPI3 := writeSpec(t, dir, "openapi.yaml", openAPI3Spec)
+	swagger2 := writeSpec(t, dir, "swagger.json", swagger2Spec)
+
+	normalizer, err := NewNormalizer(&config.NormalizationConfig{
+		Rules: []config.NormalizationRule{
+			{Pattern: `^/static/.*$`, Route: "/static/{file}"},
+			{Pattern: `^/tenants/([a-z]+)/.*$`, Route: "/tenants/$1/{path}"},
+		},
+		OpenAPISpecs: []string{openAPI3, swagger2},
+	})
+	if err != nil {
+		t.Fatalf("NewNormalizer() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "rule", path: "/static/css/main.css?v=2", want: "/static/{file}"},
+		{name: "rule with submatch", path: "/tenants/acme/orders/7", want: "/tenants/acme/{path}"},
+		{name: "openAPI template", path: "/shop/orders/8812/items/3", want: "/shop/orders/{orderId}/items/{itemId}"},
+		{name: "more specific openAPI template", path: "/shop/users/me", want: "/shop/users/me"},
+		{name: "less specific openAPI template", path: "/shop/users/alice/", want: "/shop/users/{userId}"},
+		{name: "openAPI template within a segment", path: "/shop/files/report.json", want: "/shop/files/{name}.json"},
+		{name: "swagger template", path: "/v1/pets/9", want: "/v1/pets/{petId}"},
+		{name: "heuristics", path: "/orders/8812/items/3", want: "/orders/{id}/items/{id}"},
+		{name: "static path", path: "/healthz", want: "/healthz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizer.Route(tt.path); got != tt.want {
+				t.Errorf("Route(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNormalizer(t *testing.T) {
+	dir := t.TempDir()
+	noPaths := writeSpec(t, dir, "empty.yaml", "openapi: 3.0.0\n")
+	invalid := writeSpec(t, dir, "invalid.yaml", "paths: [")
+
+	tests := []struct {
+		name string
+		cfg  *config.NormalizationConfig
+	}{
+		{name: "invalid pattern", cfg: &config.NormalizationConfig{Rules: []config.NormalizationRule{{Pattern: "(", Route: "/"}}}},
+		{name: "missing spec", cfg: &config.NormalizationConfig{OpenAPISpecs: []string{filepath.Join(dir, "missing.yaml")}}},
+		{name: "spec without paths", cfg: &config.NormalizationConfig{OpenAPISpecs: []string{noPaths}}},
+		{name: "invalid spec", cfg: &config.NormalizationConfig{OpenAPISpecs: []string{invalid}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewNormalizer(tt.cfg); err == nil {
+				t.Errorf("NewNormalizer() expected error")
+			}
+		})
+	}
+}
+
+func Test_readOpenAPIRoutes(t *testing.T) {
+	spec := writeSpec(t, t.TempDir(), "openapi.yaml", `
+servers:
+  - url: /api
+  - url: http://localhost:8080
+paths:
+  /items: {}
+`)
+	got, err := readOpenAPIRoutes(spec)
+	if err != nil {
+		t.Fatalf("readOpenAPIRoutes() error = %v", err)
+	}
+	if want := []string{"/api/items", "/items"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readOpenAPIRoutes() = %v, want %v", got, want)
+	}
+}
+
+func writeSpec(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package classifier
+
+import (
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+// Server implements the APIClassifier gRPC service.
+type Server struct {
+	protobuf.UnimplementedAPIClassifierServer
+}
+
+// ClassifyAPIs responds to every list of paths it receives with the number of
+// paths per templated endpoint.
+func (s *Server) ClassifyAPIs(stream grpc.BidiStreamingServer[protobuf.APIClassifierRequest, protobuf.APIClassifierResponse]) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(&protobuf.APIClassifierResponse{APIs: Classify(req.API)}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package classifier
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+func TestServer_ClassifyAPIs(t *testing.T) {
+	// Given
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	protobuf.RegisterAPIClassifierServer(server, &Server{})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := protobuf.NewAPIClassifierClient(conn).ClassifyAPIs(ctx)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+
+	requests := []struct {
+		apis []string
+		want map[string]uint64
+	}{
+		{
+			apis: []string{"/users/1", "/users/2", "/health"},
+			want: map[string]uint64{"/users/{id}": 2, "/health": 1},
+		},
+		{
+			apis: []string{"/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+			want: map[string]uint64{"/orders/{id}": 1},
+		},
+	}
+
+	for _, req := range requests {
+		// When
+		if err := stream.Send(&protobuf.APIClassifierRequest{API: req.apis}); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.Recv()
+
+		// Then
+		if err != nil {
+			t.Fatalf("failed to receive response: %v", err)
+		}
+		if !reflect.DeepEqual(resp.APIs, req.want) {
+			t.Errorf("ClassifyAPIs() = %v, want %v", resp.APIs, req.want)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Errorf("failed to close stream: %v", err)
+	}
+}
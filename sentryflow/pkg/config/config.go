@@ -6,6 +6,8 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -20,14 +22,56 @@ const (
 	DefaultRateLimitServiceURL      = "security-gatekeeper.accuknox-api-security"
 	DefaultRateLimitServicePort     = uint16(8082)
 	DefaultRateLimitServicePath     = "/allowed"
+	DefaultApiMetricsMaxLabelValues = 1000
+	DefaultGrpcAPIMetricsWindow     = time.Minute
+	DefaultGrpcAPIMetricsInterval   = 10 * time.Second
+	DefaultGrpcAPIMetricsMaxAPIs    = 10000
+	DefaultEnvoyMetricsInterval     = 30 * time.Second
+	DefaultEnvoyMetricsPort         = uint16(15090)
+	DefaultEnvoyMetricsPath         = "/stats/prometheus"
 )
 
+// Labels API traffic metrics can be broken down by.
+const (
+	ApiMetricsLabelSourceWorkload       = "source_workload"
+	ApiMetricsLabelSourceNamespace      = "source_namespace"
+	ApiMetricsLabelDestinationWorkload  = "destination_workload"
+	ApiMetricsLabelDestinationNamespace = "destination_namespace"
+	ApiMetricsLabelMethod               = "method"
+	ApiMetricsLabelPath                 = "path"
+)
+
+// ApiMetricsLabels lists all labels API traffic metrics can be broken down by,
+// in the order they are used when none are configured.
+var ApiMetricsLabels = []string{
+	ApiMetricsLabelSourceWorkload,
+	ApiMetricsLabelSourceNamespace,
+	ApiMetricsLabelDestinationWorkload,
+	ApiMetricsLabelDestinationNamespace,
+	ApiMetricsLabelMethod,
+	ApiMetricsLabelPath,
+}
+
 type meshConfig struct {
 	Name         string              `json:"name"`
 	Namespace    string              `json:"namespace,omitempty"`
 	RateLimiting rateLimitingConfigs `json:"rateLimiting,omitempty"`
+	EnvoyMetrics EnvoyMetricsConfig  `json:"envoyMetrics,omitempty"`
 }
 
+// EnvoyMetricsConfig configures scraping the stats of the Envoy proxies an
+// istio receiver observes.
+type EnvoyMetricsConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval,omitempty"`
+	Port     uint16        `json:"port,omitempty"`
+	Path     string        `json:"path,omitempty"`
+}
+
+// ReceiverConfig is the configuration of a single entry of the `receivers`
+// section.
+type ReceiverConfig = meshConfig
+
 type rateLimitingConfigs struct {
 	Enabled bool   `json:"enabled"`
 	Url     string `json:"url"`
@@ -51,26 +95,70 @@ type server struct {
 	Port uint16 `json:"port"`
 }
 
-type HttpConfig struct {
-	Enabled        bool            `json:"enabled"`
-	TimeoutSeconds uint32          `json:"timeoutSeconds"`
-	Webhooks       []WebhookConfig `json:"webhooks"`
+// TLSConfig configures the TLS connections of an exporter, e.g. to a webhook
+// or to the Kafka brokers. Certificates are loaded again when their files
+// change.
+type TLSConfig struct {
+	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"`
+	CACertPath         string `mapstructure:"caCertPath"`
+	ClientCertPath     string `mapstructure:"clientCertPath"`
+	ClientKeyPath      string `mapstructure:"clientKeyPath"`
 }
 
-type WebhookConfig struct {
-	Name    string            `mapstructure:"name"`
-	URL     string            `mapstructure:"url"`
-	Method  string            `mapstructure:"method"`
-	Headers map[string]string `mapstructure:"headers"`
+// ApiMetricsConfig configures the RED metrics computed from captured API events.
+type ApiMetricsConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Labels the metrics are broken down by. Defaults to all ApiMetricsLabels.
+	Labels []string `json:"labels,omitempty"`
+
+	// MaxLabelValues is the number of distinct values kept per label. Further
+	// values are reported as `other`. Defaults to
+	// DefaultApiMetricsMaxLabelValues.
+	MaxLabelValues int `json:"maxLabelValues,omitempty"`
 
-	TLS *WebhookTLSConfig `mapstructure:"tls,omitempty"`
+	// LabelLimits overrides MaxLabelValues for individual labels.
+	LabelLimits map[string]int `json:"labelLimits,omitempty"`
 }
 
-type WebhookTLSConfig struct {
-	InsecureSkipVerify bool   `mapstructure:"insecureSkipVerify"`
-	CACertPath         string `mapstructure:"caCertPath"`
-	ClientCertPath     string `mapstructure:"clientCertPath"`
-	ClientKeyPath      string `mapstructure:"clientKeyPath"`
+// LabelLimit returns the number of distinct values kept for label.
+func (a *ApiMetricsConfig) LabelLimit(label string) int {
+	if limit, exists := a.LabelLimits[label]; exists {
+		return limit
+	}
+	return a.MaxLabelValues
+}
+
+func (a *ApiMetricsConfig) validate() error {
+	if len(a.Labels) == 0 {
+		a.Labels = slices.Clone(ApiMetricsLabels)
+	}
+	if a.MaxLabelValues == 0 {
+		a.MaxLabelValues = DefaultApiMetricsMaxLabelValues
+	}
+	if a.MaxLabelValues < 0 {
+		return fmt.Errorf("invalid api metrics maxLabelValues, %v", a.MaxLabelValues)
+	}
+
+	seen := make(map[string]bool)
+	for _, label := range a.Labels {
+		if !slices.Contains(ApiMetricsLabels, label) {
+			return fmt.Errorf("unsupported api metrics label, %v", label)
+		}
+		if seen[label] {
+			return fmt.Errorf("duplicate api metrics label, %v", label)
+		}
+		seen[label] = true
+	}
+	for label, limit := range a.LabelLimits {
+		if !slices.Contains(ApiMetricsLabels, label) {
+			return fmt.Errorf("unsupported api metrics label, %v", label)
+		}
+		if limit <= 0 {
+			return fmt.Errorf("invalid api metrics label limit for %v, %v", label, limit)
+		}
+	}
+	return nil
 }
 
 type nginxIngressConfig struct {
@@ -91,15 +179,65 @@ type filters struct {
 	TCPServer    *server             `json:"tcpServer,omitempty"`
 }
 
+// GrpcConfig configures the gRPC exporter.
+type GrpcConfig struct {
+	Port uint16 `json:"port"`
+
+	// APIMetricsWindow is the sliding window GetAPIMetrics counts API calls
+	// over. Defaults to DefaultGrpcAPIMetricsWindow.
+	APIMetricsWindow time.Duration `json:"apiMetricsWindow,omitempty"`
+
+	// APIMetricsInterval is how often GetAPIMetrics streams the counts to its
+	// clients. Defaults to DefaultGrpcAPIMetricsInterval.
+	APIMetricsInterval time.Duration `json:"apiMetricsInterval,omitempty"`
+
+	// APIMetricsMaxAPIs is how many distinct APIs GetAPIMetrics counts calls
+	// of over the window. Calls to further APIs are counted under `other`.
+	// Defaults to DefaultGrpcAPIMetricsMaxAPIs.
+	APIMetricsMaxAPIs int `json:"apiMetricsMaxAPIs,omitempty"`
+
+	// Redact redacts the API events streamed by GetAPIEvent.
+	Redact *RedactConfig `json:"redact,omitempty"`
+}
+
 type ExporterConfig struct {
-	Grpc *server     `json:"grpc"`
-	HTTP *HttpConfig `json:"http"`
+	Grpc  *GrpcConfig  `json:"grpc"`
+	HTTP  *HttpConfig  `json:"http"`
+	Kafka *KafkaConfig `json:"kafka,omitempty"`
+	NATS  *NATSConfig  `json:"nats,omitempty"`
+	OTLP  *OTLPConfig  `json:"otlp,omitempty"`
+
+	Elasticsearch *ElasticsearchConfig `json:"elasticsearch,omitempty"`
+
+	ApiMetrics *ApiMetricsConfig `json:"apiMetrics,omitempty"`
 }
 
 type Config struct {
 	Filters   *filters        `json:"filters"`
 	Receivers *receivers      `json:"receivers"`
 	Exporter  *ExporterConfig `json:"exporter"`
+	Pipeline  *PipelineConfig `json:"pipeline,omitempty"`
+	Queues    *QueuesConfig   `json:"queues,omitempty"`
+	WAL       *WALConfig      `json:"wal,omitempty"`
+}
+
+// Receiver returns the entry of the named receiver, or nil if the receiver
+// isn't configured.
+func (c *Config) Receiver(name string) *ReceiverConfig {
+	if c.Receivers == nil {
+		return nil
+	}
+	for _, svcMesh := range c.Receivers.ServiceMeshes {
+		if svcMesh.Name == name {
+			return svcMesh
+		}
+	}
+	for _, other := range c.Receivers.Others {
+		if other.Name == name {
+			return other
+		}
+	}
+	return nil
 }
 
 func (c *Config) validate() error {
@@ -121,6 +259,69 @@ func (c *Config) validate() error {
 	if c.Exporter.Grpc != nil && c.Exporter.Grpc.Port == 0 {
 		return fmt.Errorf("no exporter's gRPC port provided")
 	}
+	if c.Exporter.Grpc.APIMetricsWindow < 0 {
+		return fmt.Errorf("invalid exporter's gRPC apiMetricsWindow, %v", c.Exporter.Grpc.APIMetricsWindow)
+	}
+	if c.Exporter.Grpc.APIMetricsInterval < 0 {
+		return fmt.Errorf("invalid exporter's gRPC apiMetricsInterval, %v", c.Exporter.Grpc.APIMetricsInterval)
+	}
+	if c.Exporter.Grpc.APIMetricsWindow == 0 {
+		c.Exporter.Grpc.APIMetricsWindow = DefaultGrpcAPIMetricsWindow
+	}
+	if c.Exporter.Grpc.APIMetricsInterval == 0 {
+		c.Exporter.Grpc.APIMetricsInterval = DefaultGrpcAPIMetricsInterval
+	}
+	if c.Exporter.Grpc.Redact != nil {
+		if err := c.Exporter.Grpc.Redact.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's gRPC redact configuration: %w", err)
+		}
+	}
+	if c.Exporter.HTTP != nil {
+		if err := c.Exporter.HTTP.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Exporter.Kafka != nil && c.Exporter.Kafka.Enabled {
+		if err := c.Exporter.Kafka.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Exporter.NATS != nil && c.Exporter.NATS.Enabled {
+		if err := c.Exporter.NATS.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Exporter.OTLP != nil && c.Exporter.OTLP.Enabled {
+		if err := c.Exporter.OTLP.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Exporter.Elasticsearch != nil && c.Exporter.Elasticsearch.Enabled {
+		if err := c.Exporter.Elasticsearch.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Exporter.ApiMetrics != nil && c.Exporter.ApiMetrics.Enabled {
+		if err := c.Exporter.ApiMetrics.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Pipeline != nil {
+		if err := c.Pipeline.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Queues != nil {
+		if err := c.Queues.validate(); err != nil {
+			return err
+		}
+	}
+	if c.WAL != nil && c.WAL.Enabled {
+		if err := c.WAL.validate(); err != nil {
+			return err
+		}
+	}
 
 	if c.Receivers == nil {
 		return fmt.Errorf("no receiver configuration provided")
@@ -148,6 +349,20 @@ func (c *Config) validate() error {
 		if svcMesh.Name == util.ServiceMeshIstioGateway && svcMesh.RateLimiting.Enabled && c.Filters.Envoy.GatewayWithRatelimitTag == "" {
 			return fmt.Errorf("no gatewayWithRatelimitTag provided for istio gateway with rate limiting servicemesh")
 		}
+		if svcMesh.EnvoyMetrics.Enabled {
+			if svcMesh.EnvoyMetrics.Interval < 0 {
+				return fmt.Errorf("invalid envoy metrics interval, %v", svcMesh.EnvoyMetrics.Interval)
+			}
+			if svcMesh.EnvoyMetrics.Interval == 0 {
+				svcMesh.EnvoyMetrics.Interval = DefaultEnvoyMetricsInterval
+			}
+			if svcMesh.EnvoyMetrics.Port == 0 {
+				svcMesh.EnvoyMetrics.Port = DefaultEnvoyMetricsPort
+			}
+			if svcMesh.EnvoyMetrics.Path == "" {
+				svcMesh.EnvoyMetrics.Path = DefaultEnvoyMetricsPath
+			}
+		}
 		if svcMesh.Name == util.ServiceMeshIstioGateway && svcMesh.RateLimiting.Enabled {
 			if svcMesh.RateLimiting.Url == "" {
 				svcMesh.RateLimiting.Url = DefaultRateLimitServiceURL
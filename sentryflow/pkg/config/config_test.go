@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -39,7 +40,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -64,7 +65,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -148,7 +149,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{},
+					Grpc: &GrpcConfig{},
 				},
 			},
 			wantErr:            true,
@@ -169,7 +170,7 @@ func TestConfig_validate(t *testing.T) {
 				},
 				Receivers: nil,
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -195,7 +196,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -224,7 +225,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -253,7 +254,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -283,7 +284,7 @@ func TestConfig_validate(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
+					Grpc: &GrpcConfig{
 						Port: 11111,
 					},
 				},
@@ -367,8 +368,10 @@ func TestNew(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
-						Port: 8080,
+					Grpc: &GrpcConfig{
+						Port:               8080,
+						APIMetricsWindow:   DefaultGrpcAPIMetricsWindow,
+						APIMetricsInterval: DefaultGrpcAPIMetricsInterval,
 					},
 				},
 			},
@@ -412,8 +415,10 @@ func TestNew(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
-						Port: 8080,
+					Grpc: &GrpcConfig{
+						Port:               8080,
+						APIMetricsWindow:   DefaultGrpcAPIMetricsWindow,
+						APIMetricsInterval: DefaultGrpcAPIMetricsInterval,
 					},
 				},
 			},
@@ -448,8 +453,10 @@ func TestNew(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
-						Port: 8080,
+					Grpc: &GrpcConfig{
+						Port:               8080,
+						APIMetricsWindow:   DefaultGrpcAPIMetricsWindow,
+						APIMetricsInterval: DefaultGrpcAPIMetricsInterval,
 					},
 				},
 			},
@@ -489,8 +496,10 @@ func TestNew(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
-						Port: 8080,
+					Grpc: &GrpcConfig{
+						Port:               8080,
+						APIMetricsWindow:   DefaultGrpcAPIMetricsWindow,
+						APIMetricsInterval: DefaultGrpcAPIMetricsInterval,
 					},
 				},
 			},
@@ -527,8 +536,10 @@ func TestNew(t *testing.T) {
 					},
 				},
 				Exporter: &ExporterConfig{
-					Grpc: &server{
-						Port: 8080,
+					Grpc: &GrpcConfig{
+						Port:               8080,
+						APIMetricsWindow:   DefaultGrpcAPIMetricsWindow,
+						APIMetricsInterval: DefaultGrpcAPIMetricsInterval,
 					},
 				},
 			},
@@ -560,3 +571,212 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestApiMetricsConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		apiMetrics         *ApiMetricsConfig
+		want               *ApiMetricsConfig
+		expectedErrMessage string
+	}{
+		{
+			name:       "with no labels and limits should use defaults",
+			apiMetrics: &ApiMetricsConfig{Enabled: true},
+			want: &ApiMetricsConfig{
+				Enabled:        true,
+				Labels:         ApiMetricsLabels,
+				MaxLabelValues: DefaultApiMetricsMaxLabelValues,
+			},
+		},
+		{
+			name: "with unsupported label should return error",
+			apiMetrics: &ApiMetricsConfig{
+				Enabled: true,
+				Labels:  []string{ApiMetricsLabelPath, "user_id"},
+			},
+			expectedErrMessage: "unsupported api metrics label, user_id",
+		},
+		{
+			name: "with duplicate label should return error",
+			apiMetrics: &ApiMetricsConfig{
+				Enabled: true,
+				Labels:  []string{ApiMetricsLabelPath, ApiMetricsLabelPath},
+			},
+			expectedErrMessage: "duplicate api metrics label, path",
+		},
+		{
+			name: "with negative max label values should return error",
+			apiMetrics: &ApiMetricsConfig{
+				Enabled:        true,
+				MaxLabelValues: -1,
+			},
+			expectedErrMessage: "invalid api metrics maxLabelValues, -1",
+		},
+		{
+			name: "with invalid label limit should return error",
+			apiMetrics: &ApiMetricsConfig{
+				Enabled:     true,
+				LabelLimits: map[string]int{ApiMetricsLabelPath: 0},
+			},
+			expectedErrMessage: "invalid api metrics label limit for path, 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				Filters:   &filters{},
+				Receivers: &receivers{},
+				Exporter: &ExporterConfig{
+					Grpc:       &GrpcConfig{Port: 8080},
+					ApiMetrics: tt.apiMetrics,
+				},
+			}
+
+			err := c.validate()
+
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.apiMetrics, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.apiMetrics, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_validate_grpcAPIMetrics(t *testing.T) {
+	tests := []struct {
+		name               string
+		grpc               *GrpcConfig
+		want               *GrpcConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with no window and interval should use defaults",
+			grpc: &GrpcConfig{Port: 8080},
+			want: &GrpcConfig{
+				Port:               8080,
+				APIMetricsWindow:   DefaultGrpcAPIMetricsWindow,
+				APIMetricsInterval: DefaultGrpcAPIMetricsInterval,
+			},
+		},
+		{
+			name: "with negative window should return error",
+			grpc: &GrpcConfig{
+				Port:             8080,
+				APIMetricsWindow: -time.Second,
+			},
+			expectedErrMessage: "invalid exporter's gRPC apiMetricsWindow, -1s",
+		},
+		{
+			name: "with negative interval should return error",
+			grpc: &GrpcConfig{
+				Port:               8080,
+				APIMetricsInterval: -time.Second,
+			},
+			expectedErrMessage: "invalid exporter's gRPC apiMetricsInterval, -1s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				Filters:   &filters{},
+				Receivers: &receivers{},
+				Exporter:  &ExporterConfig{Grpc: tt.grpc},
+			}
+
+			err := c.validate()
+
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.grpc, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.grpc, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_validate_envoyMetrics(t *testing.T) {
+	t.Run("with envoy metrics enabled should use defaults", func(t *testing.T) {
+		c := &Config{
+			Filters: &filters{
+				Envoy: &envoyFilterConfig{Uri: "public.ecr.aws/k9v9d5v2/sentryflow-httpfilter", SidecarTag: "latest-sidecar"},
+			},
+			Receivers: &receivers{
+				ServiceMeshes: []*meshConfig{
+					{
+						Name:         "istio-sidecar",
+						Namespace:    "istio-system",
+						EnvoyMetrics: EnvoyMetricsConfig{Enabled: true},
+					},
+				},
+			},
+			Exporter: &ExporterConfig{Grpc: &GrpcConfig{Port: 8080}},
+		}
+
+		if err := c.validate(); err != nil {
+			t.Fatalf("validate() expected no error but got error = %v", err)
+		}
+
+		want := EnvoyMetricsConfig{
+			Enabled:  true,
+			Interval: DefaultEnvoyMetricsInterval,
+			Port:     DefaultEnvoyMetricsPort,
+			Path:     DefaultEnvoyMetricsPath,
+		}
+		if got := c.Receivers.ServiceMeshes[0].EnvoyMetrics; got != want {
+			t.Errorf("validate() got = %+v, want = %+v", got, want)
+		}
+	})
+
+	t.Run("with negative interval should return error", func(t *testing.T) {
+		c := &Config{
+			Filters: &filters{
+				Envoy: &envoyFilterConfig{Uri: "public.ecr.aws/k9v9d5v2/sentryflow-httpfilter", SidecarTag: "latest-sidecar"},
+			},
+			Receivers: &receivers{
+				ServiceMeshes: []*meshConfig{
+					{
+						Name:         "istio-sidecar",
+						Namespace:    "istio-system",
+						EnvoyMetrics: EnvoyMetricsConfig{Enabled: true, Interval: -time.Second},
+					},
+				},
+			},
+			Exporter: &ExporterConfig{Grpc: &GrpcConfig{Port: 8080}},
+		}
+
+		if err := c.validate(); err == nil || err.Error() != "invalid envoy metrics interval, -1s" {
+			t.Errorf("validate() expected error message to be invalid envoy metrics interval but got %v", err)
+		}
+	})
+}
+
+func TestApiMetricsConfig_LabelLimit(t *testing.T) {
+	a := &ApiMetricsConfig{
+		MaxLabelValues: 100,
+		LabelLimits:    map[string]int{ApiMetricsLabelPath: 10},
+	}
+
+	if got := a.LabelLimit(ApiMetricsLabelPath); got != 10 {
+		t.Errorf("LabelLimit(%v) = %v, want 10", ApiMetricsLabelPath, got)
+	}
+	if got := a.LabelLimit(ApiMetricsLabelMethod); got != 100 {
+		t.Errorf("LabelLimit(%v) = %v, want 100", ApiMetricsLabelMethod, got)
+	}
+}
@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// ElasticsearchConfig configures the Elasticsearch exporter, which writes API
+// events to Elasticsearch or OpenSearch with the bulk API, into an index per
+// day.
+type ElasticsearchConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// URLs are the URLs of the nodes, e.g. `https://elasticsearch:9200`. Bulk
+	// requests go to the next node when one can't be reached.
+	URLs []string `json:"urls"`
+
+	// Distribution is ElasticsearchDistributionElasticsearch or
+	// ElasticsearchDistributionOpenSearch, which differ in their field types
+	// and index lifecycle APIs. Defaults to
+	// ElasticsearchDistributionElasticsearch.
+	Distribution string `json:"distribution,omitempty"`
+
+	// Index is the prefix of the indices events are written to, followed by
+	// the date of the event formatted with the Go layout IndexDateFormat,
+	// e.g. `sentryflow-api-events-2024.03.01`. Default to
+	// DefaultElasticsearchIndex and DefaultElasticsearchIndexDateFormat.
+	Index           string `json:"index,omitempty"`
+	IndexDateFormat string `json:"indexDateFormat,omitempty"`
+
+	// Username and Password authenticate the requests with HTTP basic
+	// authentication, APIKey with an Elasticsearch API key.
+	Username string        `json:"username,omitempty"`
+	Password *SecretConfig `json:"password,omitempty"`
+	APIKey   *SecretConfig `json:"apiKey,omitempty"`
+
+	// TLS configures the connections to https URLs, with the system's CAs if
+	// it's empty.
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// Timeout is the timeout of every bulk request. Defaults to
+	// DefaultElasticsearchTimeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// MaxBatchSize and MaxBatchBytes are the number of events, and the size
+	// of their documents, written with a bulk request, and BatchTimeout how
+	// long the first of them waits for the others. Default to
+	// DefaultElasticsearchMaxBatchSize, DefaultElasticsearchMaxBatchBytes and
+	// DefaultElasticsearchBatchTimeout.
+	MaxBatchSize  int           `json:"maxBatchSize,omitempty"`
+	MaxBatchBytes int           `json:"maxBatchBytes,omitempty"`
+	BatchTimeout  time.Duration `json:"batchTimeout,omitempty"`
+
+	// Retry configures retrying the bulk requests that failed with a
+	// retryable error, and the documents rejected with 429 Too Many
+	// Requests, as for webhooks.
+	Retry *WebhookRetryConfig `json:"retry,omitempty"`
+
+	// Template, if set, is the index template installed for the indices
+	// before events are written.
+	Template *ElasticsearchTemplateConfig `json:"template,omitempty"`
+
+	// Lifecycle, if set, is the ILM policy, or the ISM one with OpenSearch,
+	// installed to delete old indices.
+	Lifecycle *ElasticsearchLifecycleConfig `json:"lifecycle,omitempty"`
+
+	// Match restricts the exporter to the events it matches.
+	Match *MatchConfig `json:"match,omitempty"`
+
+	// Redact redacts the API events written.
+	Redact *RedactConfig `json:"redact,omitempty"`
+}
+
+// ElasticsearchTemplateConfig configures the index template mapping the
+// fields of API events. Headers and labels are mapped as flattened objects,
+// so that their keys don't add fields to the mapping.
+type ElasticsearchTemplateConfig struct {
+	// Name is the name of the template. Defaults to the index prefix.
+	Name string `json:"name,omitempty"`
+
+	// Shards and Replicas are the numbers of shards and replicas of the
+	// indices, the cluster's defaults if they're 0.
+	Shards   int `json:"shards,omitempty"`
+	Replicas int `json:"replicas,omitempty"`
+
+	// IndexBodies makes the request and response bodies searchable. They're
+	// only stored otherwise.
+	IndexBodies bool `json:"indexBodies,omitempty"`
+}
+
+// ElasticsearchLifecycleConfig configures the policy managing the indices.
+type ElasticsearchLifecycleConfig struct {
+	// Name is the name of the policy. Defaults to the index prefix.
+	Name string `json:"name,omitempty"`
+
+	// DeleteAfter is the age at which indices are deleted.
+	DeleteAfter time.Duration `json:"deleteAfter"`
+}
+
+// Distributions of the Elasticsearch exporter.
+const (
+	ElasticsearchDistributionElasticsearch = "elasticsearch"
+	ElasticsearchDistributionOpenSearch    = "opensearch"
+)
+
+const (
+	DefaultElasticsearchIndex           = "sentryflow-api-events"
+	DefaultElasticsearchIndexDateFormat = "2006.01.02"
+	DefaultElasticsearchTimeout         = 30 * time.Second
+	DefaultElasticsearchMaxBatchSize    = 500
+	DefaultElasticsearchMaxBatchBytes   = 5 << 20
+	DefaultElasticsearchBatchTimeout    = time.Second
+)
+
+// elasticsearchIndex matches the index names that are valid in both
+// Elasticsearch and OpenSearch.
+var elasticsearchIndex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+func (e *ElasticsearchConfig) validate() error {
+	if len(e.URLs) == 0 {
+		return fmt.Errorf("no exporter's Elasticsearch urls provided")
+	}
+	for _, rawURL := range e.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid exporter's Elasticsearch url, %v", rawURL)
+		}
+	}
+	if e.Distribution == "" {
+		e.Distribution = ElasticsearchDistributionElasticsearch
+	}
+	if !slices.Contains([]string{ElasticsearchDistributionElasticsearch, ElasticsearchDistributionOpenSearch}, e.Distribution) {
+		return fmt.Errorf("unsupported exporter's Elasticsearch distribution, %v", e.Distribution)
+	}
+	if e.Index == "" {
+		e.Index = DefaultElasticsearchIndex
+	}
+	if !elasticsearchIndex.MatchString(e.Index) {
+		return fmt.Errorf("invalid exporter's Elasticsearch index, %v", e.Index)
+	}
+	if e.IndexDateFormat == "" {
+		e.IndexDateFormat = DefaultElasticsearchIndexDateFormat
+	}
+	// Layouts can't be checked directly, so a date formatted with it must
+	// make a valid index name.
+	if date := time.Date(2006, time.December, 31, 23, 59, 59, 0, time.UTC).Format(e.IndexDateFormat); !elasticsearchIndex.MatchString(e.Index + "-" + date) {
+		return fmt.Errorf("invalid exporter's Elasticsearch indexDateFormat, %v", e.IndexDateFormat)
+	}
+	if e.Username != "" {
+		if e.Password == nil {
+			return fmt.Errorf("no exporter's Elasticsearch password provided")
+		}
+		if err := e.Password.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's Elasticsearch password: %w", err)
+		}
+	}
+	if e.APIKey != nil {
+		if e.Username != "" {
+			return fmt.Errorf("exporter's Elasticsearch username and apiKey can't be provided together")
+		}
+		if err := e.APIKey.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's Elasticsearch apiKey: %w", err)
+		}
+	}
+	if e.Timeout < 0 {
+		return fmt.Errorf("invalid exporter's Elasticsearch timeout, %v", e.Timeout)
+	}
+	if e.Timeout == 0 {
+		e.Timeout = DefaultElasticsearchTimeout
+	}
+	if e.MaxBatchSize < 0 {
+		return fmt.Errorf("invalid exporter's Elasticsearch maxBatchSize, %v", e.MaxBatchSize)
+	}
+	if e.MaxBatchSize == 0 {
+		e.MaxBatchSize = DefaultElasticsearchMaxBatchSize
+	}
+	if e.MaxBatchBytes < 0 {
+		return fmt.Errorf("invalid exporter's Elasticsearch maxBatchBytes, %v", e.MaxBatchBytes)
+	}
+	if e.MaxBatchBytes == 0 {
+		e.MaxBatchBytes = DefaultElasticsearchMaxBatchBytes
+	}
+	if e.BatchTimeout < 0 {
+		return fmt.Errorf("invalid exporter's Elasticsearch batchTimeout, %v", e.BatchTimeout)
+	}
+	if e.BatchTimeout == 0 {
+		e.BatchTimeout = DefaultElasticsearchBatchTimeout
+	}
+	if e.Retry == nil {
+		e.Retry = &WebhookRetryConfig{}
+	}
+	if err := e.Retry.validate("Elasticsearch"); err != nil {
+		return err
+	}
+	if t := e.Template; t != nil {
+		if t.Name == "" {
+			t.Name = e.Index
+		}
+		if t.Shards < 0 {
+			return fmt.Errorf("invalid exporter's Elasticsearch template shards, %v", t.Shards)
+		}
+		if t.Replicas < 0 {
+			return fmt.Errorf("invalid exporter's Elasticsearch template replicas, %v", t.Replicas)
+		}
+	}
+	if l := e.Lifecycle; l != nil {
+		if l.Name == "" {
+			l.Name = e.Index
+		}
+		if l.DeleteAfter <= 0 {
+			return fmt.Errorf("invalid exporter's Elasticsearch lifecycle deleteAfter, %v", l.DeleteAfter)
+		}
+	}
+	if err := e.Match.validate(); err != nil {
+		return fmt.Errorf("invalid exporter's Elasticsearch match: %w", err)
+	}
+	if e.Redact != nil {
+		if err := e.Redact.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's Elasticsearch redact configuration: %w", err)
+		}
+	}
+	if e.TLS != nil && (e.TLS.ClientCertPath == "") != (e.TLS.ClientKeyPath == "") {
+		return fmt.Errorf("exporter's Elasticsearch TLS clientCertPath and clientKeyPath must be provided together")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestElasticsearchConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		elasticsearch      *ElasticsearchConfig
+		want               *ElasticsearchConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with urls should apply defaults",
+			elasticsearch: &ElasticsearchConfig{Enabled: true, URLs: []string{"https://elasticsearch:9200"},
+				Template: &ElasticsearchTemplateConfig{}, Lifecycle: &ElasticsearchLifecycleConfig{DeleteAfter: 720 * time.Hour}},
+			want: &ElasticsearchConfig{Enabled: true, URLs: []string{"https://elasticsearch:9200"},
+				Distribution: ElasticsearchDistributionElasticsearch, Index: DefaultElasticsearchIndex,
+				IndexDateFormat: DefaultElasticsearchIndexDateFormat, Timeout: DefaultElasticsearchTimeout,
+				MaxBatchSize: DefaultElasticsearchMaxBatchSize, MaxBatchBytes: DefaultElasticsearchMaxBatchBytes,
+				BatchTimeout: DefaultElasticsearchBatchTimeout, Retry: &WebhookRetryConfig{MaxAttempts: DefaultWebhookMaxAttempts,
+					InitialBackoff: DefaultWebhookInitialBackoff, MaxBackoff: DefaultWebhookMaxBackoff},
+				Template:  &ElasticsearchTemplateConfig{Name: DefaultElasticsearchIndex},
+				Lifecycle: &ElasticsearchLifecycleConfig{Name: DefaultElasticsearchIndex, DeleteAfter: 720 * time.Hour}},
+		},
+		{
+			name:               "without urls should return error",
+			elasticsearch:      &ElasticsearchConfig{Enabled: true},
+			expectedErrMessage: "no exporter's Elasticsearch urls provided",
+		},
+		{
+			name:               "with url without scheme should return error",
+			elasticsearch:      &ElasticsearchConfig{Enabled: true, URLs: []string{"elasticsearch:9200"}},
+			expectedErrMessage: "invalid exporter's Elasticsearch url, elasticsearch:9200",
+		},
+		{
+			name:               "with unsupported distribution should return error",
+			elasticsearch:      &ElasticsearchConfig{Enabled: true, URLs: []string{"http://solr:8983"}, Distribution: "solr"},
+			expectedErrMessage: "unsupported exporter's Elasticsearch distribution, solr",
+		},
+		{
+			name:               "with uppercase index should return error",
+			elasticsearch:      &ElasticsearchConfig{Enabled: true, URLs: []string{"http://elasticsearch:9200"}, Index: "SentryFlow"},
+			expectedErrMessage: "invalid exporter's Elasticsearch index, SentryFlow",
+		},
+		{
+			name:               "with indexDateFormat making invalid names should return error",
+			elasticsearch:      &ElasticsearchConfig{Enabled: true, URLs: []string{"http://elasticsearch:9200"}, IndexDateFormat: "Jan 2006"},
+			expectedErrMessage: "invalid exporter's Elasticsearch indexDateFormat, Jan 2006",
+		},
+		{
+			name:               "with username without password should return error",
+			elasticsearch:      &ElasticsearchConfig{Enabled: true, URLs: []string{"http://elasticsearch:9200"}, Username: "sentryflow"},
+			expectedErrMessage: "no exporter's Elasticsearch password provided",
+		},
+		{
+			name: "with username and apiKey should return error",
+			elasticsearch: &ElasticsearchConfig{Enabled: true, URLs: []string{"http://elasticsearch:9200"}, Username: "sentryflow",
+				Password: &SecretConfig{File: "/etc/password"}, APIKey: &SecretConfig{File: "/etc/api-key"}},
+			expectedErrMessage: "exporter's Elasticsearch username and apiKey can't be provided together",
+		},
+		{
+			name: "with lifecycle without deleteAfter should return error",
+			elasticsearch: &ElasticsearchConfig{Enabled: true, URLs: []string{"http://elasticsearch:9200"},
+				Lifecycle: &ElasticsearchLifecycleConfig{}},
+			expectedErrMessage: "invalid exporter's Elasticsearch lifecycle deleteAfter, 0s",
+		},
+		{
+			name: "with client certificate without key should return error",
+			elasticsearch: &ElasticsearchConfig{Enabled: true, URLs: []string{"https://elasticsearch:9200"},
+				TLS: &TLSConfig{ClientCertPath: "/etc/tls.crt"}},
+			expectedErrMessage: "exporter's Elasticsearch TLS clientCertPath and clientKeyPath must be provided together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.elasticsearch.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.elasticsearch, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.elasticsearch, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHttpConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		http               *HttpConfig
+		want               *HttpConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "without delivery configuration should apply defaults",
+			http: &HttpConfig{Enabled: true},
+			want: &HttpConfig{
+				Enabled:   true,
+				Workers:   DefaultWebhookWorkers,
+				QueueSize: DefaultWebhookQueueSize,
+				Retry: &WebhookRetryConfig{MaxAttempts: DefaultWebhookMaxAttempts, InitialBackoff: DefaultWebhookInitialBackoff,
+					MaxBackoff: DefaultWebhookMaxBackoff},
+				CircuitBreaker: &CircuitBreakerConfig{Failures: DefaultCircuitBreakerFailures,
+					OpenDuration: DefaultCircuitBreakerOpenDuration},
+			},
+		},
+		{
+			name: "with initial backoff longer than the default maximum should raise the maximum",
+			http: &HttpConfig{Retry: &WebhookRetryConfig{InitialBackoff: time.Minute}},
+			want: &HttpConfig{
+				Workers:   DefaultWebhookWorkers,
+				QueueSize: DefaultWebhookQueueSize,
+				Retry: &WebhookRetryConfig{MaxAttempts: DefaultWebhookMaxAttempts, InitialBackoff: time.Minute,
+					MaxBackoff: time.Minute},
+				CircuitBreaker: &CircuitBreakerConfig{Failures: DefaultCircuitBreakerFailures,
+					OpenDuration: DefaultCircuitBreakerOpenDuration},
+			},
+		},
+		{
+			name:               "with negative workers should return error",
+			http:               &HttpConfig{Workers: -1},
+			expectedErrMessage: "invalid exporter's HTTP workers, -1",
+		},
+		{
+			name:               "with maxBackoff shorter than initialBackoff should return error",
+			http:               &HttpConfig{Retry: &WebhookRetryConfig{InitialBackoff: time.Second, MaxBackoff: time.Millisecond}},
+			expectedErrMessage: "exporter's HTTP retry maxBackoff 1ms is shorter than initialBackoff 1s",
+		},
+		{
+			name:               "with dead letter without path should return error",
+			http:               &HttpConfig{DeadLetter: &DeadLetterConfig{}},
+			expectedErrMessage: "no exporter's HTTP deadLetter path provided",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.http.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.http, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.http, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		webhook            *WebhookConfig
+		want               *WebhookConfig
+		expectedErrMessage string
+	}{
+		{
+			name:    "without format should default to json",
+			webhook: &WebhookConfig{Name: "test"},
+			want:    &WebhookConfig{Name: "test", Format: WebhookFormatJSON},
+		},
+		{
+			name:    "with empty batch should apply defaults",
+			webhook: &WebhookConfig{Name: "test", Format: WebhookFormatNDJSON, Compression: WebhookCompressionZstd, Batch: &WebhookBatchConfig{}},
+			want: &WebhookConfig{Name: "test", Format: WebhookFormatNDJSON, Compression: WebhookCompressionZstd,
+				Batch: &WebhookBatchConfig{MaxEvents: DefaultWebhookBatchMaxEvents, MaxBytes: DefaultWebhookBatchMaxBytes,
+					MaxLinger: DefaultWebhookBatchMaxLinger}},
+		},
+		{
+			name:               "with unsupported format should return error",
+			webhook:            &WebhookConfig{Name: "test", Format: "xml"},
+			expectedErrMessage: "unsupported webhook test format, xml",
+		},
+		{
+			name:               "with unsupported compression should return error",
+			webhook:            &WebhookConfig{Name: "test", Compression: "brotli"},
+			expectedErrMessage: "unsupported webhook test compression, brotli",
+		},
+		{
+			name:               "with invalid match should return error",
+			webhook:            &WebhookConfig{Name: "test", Match: &MatchConfig{Statuses: []string{"5xx", "599-500"}}},
+			expectedErrMessage: "invalid webhook test: invalid match status, 599-500",
+		},
+		{
+			name:    "with builtin template should be valid",
+			webhook: &WebhookConfig{Name: "test", Template: &WebhookTemplateConfig{Builtin: "slack"}},
+			want:    &WebhookConfig{Name: "test", Format: WebhookFormatJSON, Template: &WebhookTemplateConfig{Builtin: "slack"}},
+		},
+		{
+			name:               "with unknown builtin template should return error",
+			webhook:            &WebhookConfig{Name: "test", Template: &WebhookTemplateConfig{Builtin: "discord"}},
+			expectedErrMessage: "invalid webhook test template: unsupported builtin template, discord",
+		},
+		{
+			name:               "with invalid template text should return error",
+			webhook:            &WebhookConfig{Name: "test", Template: &WebhookTemplateConfig{Text: "{{ .Path "}},
+			expectedErrMessage: "invalid webhook test template: invalid template, template: payload:1: unclosed action",
+		},
+		{
+			name:               "with invalid template field JSONPath should return error",
+			webhook:            &WebhookConfig{Name: "test", Template: &WebhookTemplateConfig{Fields: map[string]string{"path": "request.path"}}},
+			expectedErrMessage: "invalid webhook test template: invalid path field JSONPath, \"request.path\" doesn't start with $",
+		},
+		{
+			name:               "with template and protobuf format should return error",
+			webhook:            &WebhookConfig{Name: "test", Format: WebhookFormatProtobuf, Template: &WebhookTemplateConfig{Builtin: "flat"}},
+			expectedErrMessage: "webhook test template can't be used with the protobuf format",
+		},
+		{
+			name:    "with signing should apply default headers",
+			webhook: &WebhookConfig{Name: "test", Signing: &WebhookSigningConfig{Secret: SecretConfig{File: "/etc/key"}}},
+			want: &WebhookConfig{Name: "test", Format: WebhookFormatJSON, Signing: &WebhookSigningConfig{
+				Secret:          SecretConfig{File: "/etc/key"},
+				SignatureHeader: DefaultWebhookSignatureHeader,
+				TimestampHeader: DefaultWebhookTimestampHeader,
+			}},
+		},
+		{
+			name: "with signing secret file and secretRef should return error",
+			webhook: &WebhookConfig{Name: "test", Signing: &WebhookSigningConfig{Secret: SecretConfig{
+				File:      "/etc/key",
+				SecretRef: &SecretKeyConfig{Namespace: "sentryflow", Name: "webhook", Key: "key"},
+			}}},
+			expectedErrMessage: "invalid webhook test signing: exactly one of secret file or secretRef must be provided",
+		},
+		{
+			name: "with oauth2 secretRef without key should return error",
+			webhook: &WebhookConfig{Name: "test", OAuth2: &WebhookOAuth2Config{
+				TokenURL:     "https://auth.example.com/token",
+				ClientID:     "sentryflow",
+				ClientSecret: SecretConfig{SecretRef: &SecretKeyConfig{Namespace: "sentryflow", Name: "webhook"}},
+			}},
+			expectedErrMessage: "invalid webhook test oauth2 clientSecret: no secretRef namespace, name or key provided",
+		},
+		{
+			name:               "with oauth2 without tokenURL should return error",
+			webhook:            &WebhookConfig{Name: "test", OAuth2: &WebhookOAuth2Config{ClientID: "sentryflow"}},
+			expectedErrMessage: "no webhook test oauth2 tokenURL or clientID provided",
+		},
+		{
+			name:               "with proxy url and fromEnvironment should return error",
+			webhook:            &WebhookConfig{Name: "test", Proxy: &WebhookProxyConfig{URL: "http://proxy:3128", FromEnvironment: true}},
+			expectedErrMessage: "exactly one of webhook test proxy url or fromEnvironment must be provided",
+		},
+		{
+			name:               "with unsupported proxy scheme should return error",
+			webhook:            &WebhookConfig{Name: "test", Proxy: &WebhookProxyConfig{URL: "ftp://proxy:21"}},
+			expectedErrMessage: "invalid webhook test proxy url, ftp://proxy:21",
+		},
+		{
+			name:               "with client certificate without key should return error",
+			webhook:            &WebhookConfig{Name: "test", TLS: &TLSConfig{ClientCertPath: "/etc/tls.crt"}},
+			expectedErrMessage: "webhook test TLS clientCertPath and clientKeyPath must be provided together",
+		},
+		{
+			name:               "with negative batch maxEvents should return error",
+			webhook:            &WebhookConfig{Name: "test", Batch: &WebhookBatchConfig{MaxEvents: -1}},
+			expectedErrMessage: "invalid webhook test batch maxEvents, -1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.webhook.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.webhook, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.webhook, tt.want)
+			}
+		})
+	}
+}
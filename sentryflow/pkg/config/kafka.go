@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/payload"
+)
+
+// KafkaConfig configures the Kafka exporter.
+type KafkaConfig struct {
+	Enabled bool     `json:"enabled"`
+	Brokers []string `json:"brokers"`
+
+	// Topic is a Go template rendered with a payload.Event, e.g.
+	// `sentryflow.{{ .DestinationNamespace }}` for a topic per namespace.
+	// Characters that aren't allowed in topic names are replaced with `_`.
+	Topic string `json:"topic"`
+
+	// AutoCreateTopics asks the brokers to create the topics that don't
+	// exist, if they allow it.
+	AutoCreateTopics bool `json:"autoCreateTopics,omitempty"`
+
+	// Key is what records are keyed with, one of the KafkaKey constants, so
+	// that the events with the same key go to the same partition. Records
+	// aren't keyed if it's empty.
+	Key string `json:"key,omitempty"`
+
+	// Format is the format of the record values, KafkaFormatJSON or
+	// KafkaFormatProtobuf. Defaults to KafkaFormatJSON.
+	Format string `json:"format,omitempty"`
+
+	// Compression is the compression of record batches, one of the
+	// KafkaCompression constants. Batches aren't compressed if it's empty.
+	Compression string `json:"compression,omitempty"`
+
+	// Acks is the number of replicas that must have written a record, one of
+	// the KafkaAcks constants. Defaults to KafkaAcksAll. Records are written
+	// by an idempotent producer, exactly once per partition even when
+	// retried, unless DisableIdempotence is set. Idempotence requires
+	// KafkaAcksAll.
+	Acks               string `json:"acks,omitempty"`
+	DisableIdempotence bool   `json:"disableIdempotence,omitempty"`
+
+	// Linger is how long records wait for more records to be batched with.
+	Linger time.Duration `json:"linger,omitempty"`
+
+	// DeliveryTimeout is how long a record is retried before it's given up
+	// on. Defaults to DefaultKafkaDeliveryTimeout.
+	DeliveryTimeout time.Duration `json:"deliveryTimeout,omitempty"`
+
+	// MaxBufferedRecords is the number of records waiting to be written
+	// before the exporter stops taking events from its queue. Defaults to
+	// DefaultKafkaMaxBufferedRecords.
+	MaxBufferedRecords int `json:"maxBufferedRecords,omitempty"`
+
+	// Match restricts the exporter to the events it matches.
+	Match *MatchConfig `json:"match,omitempty"`
+
+	// Redact redacts the API events produced to Kafka.
+	Redact *RedactConfig `json:"redact,omitempty"`
+
+	// SASL authenticates the connections to the brokers. TLS configures them
+	// to use TLS, with the system's CAs if it's empty.
+	SASL *KafkaSASLConfig `json:"sasl,omitempty"`
+	TLS  *TLSConfig       `json:"tls,omitempty"`
+}
+
+// KafkaSASLConfig authenticates the connections to the Kafka brokers.
+type KafkaSASLConfig struct {
+	// Mechanism is one of the KafkaSASL constants.
+	Mechanism string       `json:"mechanism"`
+	Username  string       `json:"username"`
+	Password  SecretConfig `json:"password"`
+}
+
+// Keys of Kafka records.
+const (
+	// KafkaKeyDestinationWorkload keys records with the destination's
+	// `namespace/name`.
+	KafkaKeyDestinationWorkload = "destinationWorkload"
+	// KafkaKeyDestinationNamespace keys records with the destination's
+	// namespace.
+	KafkaKeyDestinationNamespace = "destinationNamespace"
+	// KafkaKeySourceWorkload keys records with the source's `namespace/name`.
+	KafkaKeySourceWorkload = "sourceWorkload"
+	// KafkaKeySourceIP keys records with the source's IP address.
+	KafkaKeySourceIP = "sourceIP"
+)
+
+// KafkaKeys lists all keys of Kafka records.
+var KafkaKeys = []string{
+	KafkaKeyDestinationWorkload,
+	KafkaKeyDestinationNamespace,
+	KafkaKeySourceWorkload,
+	KafkaKeySourceIP,
+}
+
+// Formats of Kafka record values.
+const (
+	// KafkaFormatJSON produces protojson encoded events.
+	KafkaFormatJSON = "json"
+	// KafkaFormatProtobuf produces binary protobuf encoded events.
+	KafkaFormatProtobuf = "protobuf"
+)
+
+// Compressions of Kafka record batches.
+const (
+	KafkaCompressionGzip   = "gzip"
+	KafkaCompressionSnappy = "snappy"
+	KafkaCompressionLZ4    = "lz4"
+	KafkaCompressionZstd   = "zstd"
+)
+
+// Acknowledgements Kafka brokers send for written records.
+const (
+	// KafkaAcksAll waits for all in-sync replicas to write a record.
+	KafkaAcksAll = "all"
+	// KafkaAcksLeader waits for the partition's leader only.
+	KafkaAcksLeader = "leader"
+	// KafkaAcksNone doesn't wait for records to be written.
+	KafkaAcksNone = "none"
+)
+
+// SASL mechanisms of Kafka brokers.
+const (
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLScramSHA256 = "SCRAM-SHA-256"
+	KafkaSASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+const (
+	DefaultKafkaDeliveryTimeout    = 2 * time.Minute
+	DefaultKafkaMaxBufferedRecords = 10000
+)
+
+func (k *KafkaConfig) validate() error {
+	if len(k.Brokers) == 0 {
+		return fmt.Errorf("no exporter's Kafka brokers provided")
+	}
+	if k.Topic == "" {
+		return fmt.Errorf("no exporter's Kafka topic provided")
+	}
+	if _, err := payload.New("", k.Topic, nil); err != nil {
+		return fmt.Errorf("invalid exporter's Kafka topic: %w", err)
+	}
+	if k.Key != "" && !slices.Contains(KafkaKeys, k.Key) {
+		return fmt.Errorf("unsupported exporter's Kafka key, %v", k.Key)
+	}
+	if k.Format == "" {
+		k.Format = KafkaFormatJSON
+	}
+	if !slices.Contains([]string{KafkaFormatJSON, KafkaFormatProtobuf}, k.Format) {
+		return fmt.Errorf("unsupported exporter's Kafka format, %v", k.Format)
+	}
+	if k.Compression != "" && !slices.Contains([]string{KafkaCompressionGzip, KafkaCompressionSnappy, KafkaCompressionLZ4, KafkaCompressionZstd}, k.Compression) {
+		return fmt.Errorf("unsupported exporter's Kafka compression, %v", k.Compression)
+	}
+	if k.Acks == "" {
+		k.Acks = KafkaAcksAll
+	}
+	if !slices.Contains([]string{KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone}, k.Acks) {
+		return fmt.Errorf("unsupported exporter's Kafka acks, %v", k.Acks)
+	}
+	if k.Acks != KafkaAcksAll && !k.DisableIdempotence {
+		return fmt.Errorf("exporter's Kafka acks %v requires disableIdempotence", k.Acks)
+	}
+	if k.Linger < 0 {
+		return fmt.Errorf("invalid exporter's Kafka linger, %v", k.Linger)
+	}
+	if k.DeliveryTimeout < 0 {
+		return fmt.Errorf("invalid exporter's Kafka deliveryTimeout, %v", k.DeliveryTimeout)
+	}
+	if k.DeliveryTimeout == 0 {
+		k.DeliveryTimeout = DefaultKafkaDeliveryTimeout
+	}
+	if k.MaxBufferedRecords < 0 {
+		return fmt.Errorf("invalid exporter's Kafka maxBufferedRecords, %v", k.MaxBufferedRecords)
+	}
+	if k.MaxBufferedRecords == 0 {
+		k.MaxBufferedRecords = DefaultKafkaMaxBufferedRecords
+	}
+	if err := k.Match.validate(); err != nil {
+		return fmt.Errorf("invalid exporter's Kafka match: %w", err)
+	}
+	if k.Redact != nil {
+		if err := k.Redact.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's Kafka redact configuration: %w", err)
+		}
+	}
+	if k.SASL != nil {
+		if !slices.Contains([]string{KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512}, k.SASL.Mechanism) {
+			return fmt.Errorf("unsupported exporter's Kafka SASL mechanism, %v", k.SASL.Mechanism)
+		}
+		if k.SASL.Username == "" {
+			return fmt.Errorf("no exporter's Kafka SASL username provided")
+		}
+		if err := k.SASL.Password.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's Kafka SASL password: %w", err)
+		}
+	}
+	if k.TLS != nil && (k.TLS.ClientCertPath == "") != (k.TLS.ClientKeyPath == "") {
+		return fmt.Errorf("exporter's Kafka TLS clientCertPath and clientKeyPath must be provided together")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKafkaConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		kafka              *KafkaConfig
+		want               *KafkaConfig
+		expectedErrMessage string
+	}{
+		{
+			name:  "with brokers and topic should apply defaults",
+			kafka: &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow.{{ .DestinationNamespace }}"},
+			want: &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow.{{ .DestinationNamespace }}",
+				Format: KafkaFormatJSON, Acks: KafkaAcksAll, DeliveryTimeout: DefaultKafkaDeliveryTimeout,
+				MaxBufferedRecords: DefaultKafkaMaxBufferedRecords},
+		},
+		{
+			name:               "without brokers should return error",
+			kafka:              &KafkaConfig{Enabled: true, Topic: "sentryflow"},
+			expectedErrMessage: "no exporter's Kafka brokers provided",
+		},
+		{
+			name:               "without topic should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}},
+			expectedErrMessage: "no exporter's Kafka topic provided",
+		},
+		{
+			name:               "with invalid topic template should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow.{{ .DestinationNamespace"},
+			expectedErrMessage: "invalid exporter's Kafka topic: invalid template, template: payload:1: unclosed action",
+		},
+		{
+			name:               "with unsupported key should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow", Key: "path"},
+			expectedErrMessage: "unsupported exporter's Kafka key, path",
+		},
+		{
+			name:               "with unsupported format should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow", Format: "avro"},
+			expectedErrMessage: "unsupported exporter's Kafka format, avro",
+		},
+		{
+			name:               "with unsupported compression should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow", Compression: "brotli"},
+			expectedErrMessage: "unsupported exporter's Kafka compression, brotli",
+		},
+		{
+			name:               "with leader acks and idempotence should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow", Acks: KafkaAcksLeader},
+			expectedErrMessage: "exporter's Kafka acks leader requires disableIdempotence",
+		},
+		{
+			name:               "with negative linger should return error",
+			kafka:              &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow", Linger: -time.Second},
+			expectedErrMessage: "invalid exporter's Kafka linger, -1s",
+		},
+		{
+			name: "with unsupported SASL mechanism should return error",
+			kafka: &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow",
+				SASL: &KafkaSASLConfig{Mechanism: "GSSAPI", Username: "sentryflow", Password: SecretConfig{File: "/etc/password"}}},
+			expectedErrMessage: "unsupported exporter's Kafka SASL mechanism, GSSAPI",
+		},
+		{
+			name: "with SASL without password should return error",
+			kafka: &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow",
+				SASL: &KafkaSASLConfig{Mechanism: KafkaSASLScramSHA512, Username: "sentryflow"}},
+			expectedErrMessage: "invalid exporter's Kafka SASL password: exactly one of secret file or secretRef must be provided",
+		},
+		{
+			name: "with client certificate without key should return error",
+			kafka: &KafkaConfig{Enabled: true, Brokers: []string{"kafka:9092"}, Topic: "sentryflow",
+				TLS: &TLSConfig{ClientCertPath: "/etc/tls.crt"}},
+			expectedErrMessage: "exporter's Kafka TLS clientCertPath and clientKeyPath must be provided together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.kafka.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.kafka, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.kafka, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MatchConfig selects API events. An event matches if it matches every
+// non-empty field, and it matches a field if it matches any of its values.
+type MatchConfig struct {
+	Receivers []string `json:"receivers,omitempty"`
+	Methods   []string `json:"methods,omitempty"`
+
+	// Paths are regular expressions matched against the request path without
+	// the query string.
+	Paths []string `json:"paths,omitempty"`
+
+	// PathGlobs are globs matched against the request path without the query
+	// string, in which `*` matches within a path segment and `**` across
+	// segments, e.g. `/api/*/orders/**`.
+	PathGlobs []string `json:"pathGlobs,omitempty"`
+
+	// Statuses are response status codes, e.g. `404`, classes, e.g. `5xx`, or
+	// ranges, e.g. `500-504`.
+	Statuses []string `json:"statuses,omitempty"`
+
+	// Namespaces match the namespace of either the source or the destination.
+	Namespaces            []string `json:"namespaces,omitempty"`
+	SourceNamespaces      []string `json:"sourceNamespaces,omitempty"`
+	DestinationNamespaces []string `json:"destinationNamespaces,omitempty"`
+
+	// Headers are the names of request headers of which one must be present.
+	Headers []string `json:"headers,omitempty"`
+
+	// MinLatency and MaxLatency bound the backend latency of the events, if
+	// set.
+	MinLatency time.Duration `json:"minLatency,omitempty"`
+	MaxLatency time.Duration `json:"maxLatency,omitempty"`
+}
+
+func (m *MatchConfig) validate() error {
+	if m == nil {
+		return nil
+	}
+	for _, path := range m.Paths {
+		if _, err := regexp.Compile(path); err != nil {
+			return fmt.Errorf("invalid match path, %v", err)
+		}
+	}
+	for _, status := range m.Statuses {
+		if !MatchStatusPattern.MatchString(status) {
+			return fmt.Errorf("invalid match status, %v", status)
+		}
+		if from, to, found := strings.Cut(status, "-"); found && from > to {
+			return fmt.Errorf("invalid match status, %v", status)
+		}
+	}
+	if m.MinLatency < 0 {
+		return fmt.Errorf("invalid match minLatency, %v", m.MinLatency)
+	}
+	if m.MaxLatency < 0 || (m.MaxLatency > 0 && m.MaxLatency < m.MinLatency) {
+		return fmt.Errorf("invalid match maxLatency, %v", m.MaxLatency)
+	}
+	return nil
+}
+
+// MatchStatusPattern matches the statuses events can be matched on: a status
+// code, e.g. `404`, class, e.g. `5xx`, or range, e.g. `500-504`.
+var MatchStatusPattern = regexp.MustCompile(`^([1-5]xx|[1-5][0-9]{2}(-[1-5][0-9]{2})?)$`)
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/payload"
+)
+
+// NATSConfig configures the NATS exporter.
+type NATSConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// URLs are the URLs of the NATS servers, e.g. `nats://nats:4222`.
+	URLs []string `json:"urls"`
+
+	// Subject is a Go template rendered with a payload.Event, e.g.
+	// `sentryflow.{{ .DestinationNamespace }}.{{ .DestinationName }}`.
+	// Characters that aren't allowed in subjects are replaced with `_`, as
+	// are empty tokens.
+	Subject string `json:"subject"`
+
+	// Format is the format of the messages, NATSFormatJSON or
+	// NATSFormatProtobuf. Defaults to NATSFormatJSON.
+	Format string `json:"format,omitempty"`
+
+	// JetStream publishes the events into a JetStream stream, which
+	// acknowledges every message once it's stored. Events are published with
+	// core NATS, without acknowledgements, if it isn't set.
+	JetStream *NATSJetStreamConfig `json:"jetStream,omitempty"`
+
+	// ReconnectWait is how long the exporter waits between attempts to
+	// reconnect. Defaults to DefaultNATSReconnectWait.
+	ReconnectWait time.Duration `json:"reconnectWait,omitempty"`
+
+	// ReconnectBufferSize is the size of the messages buffered while
+	// reconnecting, beyond which they're dropped. Defaults to
+	// DefaultNATSReconnectBufferSize.
+	ReconnectBufferSize int `json:"reconnectBufferSize,omitempty"`
+
+	// CredentialsFile is the file of the user JWT and NKey seed connections
+	// are authenticated with. TLS configures them to use TLS, with the
+	// system's CAs if it's empty.
+	CredentialsFile string     `json:"credentialsFile,omitempty"`
+	TLS             *TLSConfig `json:"tls,omitempty"`
+
+	// Match restricts the exporter to the events it matches.
+	Match *MatchConfig `json:"match,omitempty"`
+
+	// Redact redacts the API events published to NATS.
+	Redact *RedactConfig `json:"redact,omitempty"`
+}
+
+// NATSJetStreamConfig configures publishing events into a JetStream stream.
+type NATSJetStreamConfig struct {
+	// Stream is the name of the stream. Messages the server would store in
+	// another stream are rejected.
+	Stream string `json:"stream"`
+
+	// Subjects are the subjects of the stream, e.g. `sentryflow.>`. If set,
+	// the stream is created when it doesn't exist, with Storage, MaxAge,
+	// MaxBytes and Replicas.
+	Subjects []string `json:"subjects,omitempty"`
+
+	// Storage is NATSStorageFile or NATSStorageMemory. Defaults to
+	// NATSStorageFile.
+	Storage string `json:"storage,omitempty"`
+
+	// MaxAge and MaxBytes limit the messages kept by the stream, if set.
+	MaxAge   time.Duration `json:"maxAge,omitempty"`
+	MaxBytes int64         `json:"maxBytes,omitempty"`
+
+	// Replicas defaults to 1.
+	Replicas int `json:"replicas,omitempty"`
+
+	// MaxPending is the number of messages waiting for their
+	// acknowledgement before the exporter stops taking events from its
+	// queue. Defaults to DefaultNATSMaxPending.
+	MaxPending int `json:"maxPending,omitempty"`
+}
+
+// Formats of NATS messages.
+const (
+	// NATSFormatJSON publishes protojson encoded events.
+	NATSFormatJSON = "json"
+	// NATSFormatProtobuf publishes binary protobuf encoded events.
+	NATSFormatProtobuf = "protobuf"
+)
+
+// Storages of JetStream streams.
+const (
+	NATSStorageFile   = "file"
+	NATSStorageMemory = "memory"
+)
+
+const (
+	DefaultNATSReconnectWait       = 2 * time.Second
+	DefaultNATSReconnectBufferSize = 8 << 20
+	DefaultNATSMaxPending          = 4000
+)
+
+func (n *NATSConfig) validate() error {
+	if len(n.URLs) == 0 {
+		return fmt.Errorf("no exporter's NATS urls provided")
+	}
+	if n.Subject == "" {
+		return fmt.Errorf("no exporter's NATS subject provided")
+	}
+	if _, err := payload.New("", n.Subject, nil); err != nil {
+		return fmt.Errorf("invalid exporter's NATS subject: %w", err)
+	}
+	if n.Format == "" {
+		n.Format = NATSFormatJSON
+	}
+	if !slices.Contains([]string{NATSFormatJSON, NATSFormatProtobuf}, n.Format) {
+		return fmt.Errorf("unsupported exporter's NATS format, %v", n.Format)
+	}
+	if n.ReconnectWait < 0 {
+		return fmt.Errorf("invalid exporter's NATS reconnectWait, %v", n.ReconnectWait)
+	}
+	if n.ReconnectWait == 0 {
+		n.ReconnectWait = DefaultNATSReconnectWait
+	}
+	if n.ReconnectBufferSize < 0 {
+		return fmt.Errorf("invalid exporter's NATS reconnectBufferSize, %v", n.ReconnectBufferSize)
+	}
+	if n.ReconnectBufferSize == 0 {
+		n.ReconnectBufferSize = DefaultNATSReconnectBufferSize
+	}
+	if err := n.Match.validate(); err != nil {
+		return fmt.Errorf("invalid exporter's NATS match: %w", err)
+	}
+	if n.Redact != nil {
+		if err := n.Redact.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's NATS redact configuration: %w", err)
+		}
+	}
+	if n.TLS != nil && (n.TLS.ClientCertPath == "") != (n.TLS.ClientKeyPath == "") {
+		return fmt.Errorf("exporter's NATS TLS clientCertPath and clientKeyPath must be provided together")
+	}
+
+	js := n.JetStream
+	if js == nil {
+		return nil
+	}
+	if js.Stream == "" {
+		return fmt.Errorf("no exporter's NATS jetStream stream provided")
+	}
+	if js.Storage == "" {
+		js.Storage = NATSStorageFile
+	}
+	if !slices.Contains([]string{NATSStorageFile, NATSStorageMemory}, js.Storage) {
+		return fmt.Errorf("unsupported exporter's NATS jetStream storage, %v", js.Storage)
+	}
+	if js.MaxAge < 0 {
+		return fmt.Errorf("invalid exporter's NATS jetStream maxAge, %v", js.MaxAge)
+	}
+	if js.MaxBytes < 0 {
+		return fmt.Errorf("invalid exporter's NATS jetStream maxBytes, %v", js.MaxBytes)
+	}
+	if js.Replicas < 0 {
+		return fmt.Errorf("invalid exporter's NATS jetStream replicas, %v", js.Replicas)
+	}
+	if js.Replicas == 0 {
+		js.Replicas = 1
+	}
+	if js.MaxPending < 0 {
+		return fmt.Errorf("invalid exporter's NATS jetStream maxPending, %v", js.MaxPending)
+	}
+	if js.MaxPending == 0 {
+		js.MaxPending = DefaultNATSMaxPending
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNATSConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		nats               *NATSConfig
+		want               *NATSConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with urls and subject should apply defaults",
+			nats: &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow.{{ .DestinationNamespace }}",
+				JetStream: &NATSJetStreamConfig{Stream: "SENTRYFLOW", Subjects: []string{"sentryflow.>"}}},
+			want: &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow.{{ .DestinationNamespace }}",
+				Format: NATSFormatJSON, ReconnectWait: DefaultNATSReconnectWait, ReconnectBufferSize: DefaultNATSReconnectBufferSize,
+				JetStream: &NATSJetStreamConfig{Stream: "SENTRYFLOW", Subjects: []string{"sentryflow.>"}, Storage: NATSStorageFile,
+					Replicas: 1, MaxPending: DefaultNATSMaxPending}},
+		},
+		{
+			name:               "without urls should return error",
+			nats:               &NATSConfig{Enabled: true, Subject: "sentryflow"},
+			expectedErrMessage: "no exporter's NATS urls provided",
+		},
+		{
+			name:               "without subject should return error",
+			nats:               &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}},
+			expectedErrMessage: "no exporter's NATS subject provided",
+		},
+		{
+			name:               "with invalid subject template should return error",
+			nats:               &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow.{{ .DestinationNamespace"},
+			expectedErrMessage: "invalid exporter's NATS subject: invalid template, template: payload:1: unclosed action",
+		},
+		{
+			name:               "with unsupported format should return error",
+			nats:               &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow", Format: "avro"},
+			expectedErrMessage: "unsupported exporter's NATS format, avro",
+		},
+		{
+			name:               "with negative reconnectWait should return error",
+			nats:               &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow", ReconnectWait: -time.Second},
+			expectedErrMessage: "invalid exporter's NATS reconnectWait, -1s",
+		},
+		{
+			name:               "with JetStream without stream should return error",
+			nats:               &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow", JetStream: &NATSJetStreamConfig{}},
+			expectedErrMessage: "no exporter's NATS jetStream stream provided",
+		},
+		{
+			name: "with unsupported JetStream storage should return error",
+			nats: &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow",
+				JetStream: &NATSJetStreamConfig{Stream: "SENTRYFLOW", Storage: "disk"}},
+			expectedErrMessage: "unsupported exporter's NATS jetStream storage, disk",
+		},
+		{
+			name: "with client certificate without key should return error",
+			nats: &NATSConfig{Enabled: true, URLs: []string{"nats://nats:4222"}, Subject: "sentryflow",
+				TLS: &TLSConfig{ClientCertPath: "/etc/tls.crt"}},
+			expectedErrMessage: "exporter's NATS TLS clientCertPath and clientKeyPath must be provided together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.nats.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.nats, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.nats, tt.want)
+			}
+		})
+	}
+}
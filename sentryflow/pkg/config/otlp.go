@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// OTLPConfig configures the OpenTelemetry exporter, which exports API events
+// as spans and log records with the OpenTelemetry protocol.
+type OTLPConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Endpoint is the `host:port` of the OTLP/gRPC server, or the base URL of
+	// the OTLP/HTTP one, e.g. `http://otel-collector:4318`, to which the
+	// `/v1/traces` and `/v1/logs` paths are added.
+	Endpoint string `json:"endpoint"`
+
+	// Protocol is OTLPProtocolGRPC or OTLPProtocolHTTP. Defaults to
+	// OTLPProtocolGRPC.
+	Protocol string `json:"protocol,omitempty"`
+
+	// Signals are what events are exported as, OTLPSignalTraces and
+	// OTLPSignalLogs. Defaults to OTLPSignalTraces.
+	Signals []string `json:"signals,omitempty"`
+
+	// Headers are sent with every export, e.g. to authenticate them.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Insecure connects to the OTLP/gRPC server without TLS. OTLP/HTTP uses
+	// TLS with an https endpoint. TLS configures it, with the system's CAs if
+	// it's empty.
+	Insecure bool       `json:"insecure,omitempty"`
+	TLS      *TLSConfig `json:"tls,omitempty"`
+
+	// Compression is OTLPCompressionGzip, or empty not to compress exports.
+	Compression string `json:"compression,omitempty"`
+
+	// ResourceAttributes are added to the resource of every span and log
+	// record, replacing the ones derived from the events' destination, e.g.
+	// `service.name`.
+	ResourceAttributes map[string]string `json:"resourceAttributes,omitempty"`
+
+	// Timeout is the timeout of every export. Defaults to DefaultOTLPTimeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// MaxBatchSize is the number of events exported together, and
+	// BatchTimeout how long the first of them waits for the others. Default
+	// to DefaultOTLPMaxBatchSize and DefaultOTLPBatchTimeout.
+	MaxBatchSize int           `json:"maxBatchSize,omitempty"`
+	BatchTimeout time.Duration `json:"batchTimeout,omitempty"`
+
+	// Retry configures retrying the exports that failed with a retryable
+	// error, as for webhooks.
+	Retry *WebhookRetryConfig `json:"retry,omitempty"`
+
+	// Match restricts the exporter to the events it matches.
+	Match *MatchConfig `json:"match,omitempty"`
+
+	// Redact redacts the API events exported.
+	Redact *RedactConfig `json:"redact,omitempty"`
+}
+
+// Protocols of the OTLP exporter.
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+// Signals of the OTLP exporter.
+const (
+	// OTLPSignalTraces exports every event as a server span.
+	OTLPSignalTraces = "traces"
+	// OTLPSignalLogs exports every event as a log record.
+	OTLPSignalLogs = "logs"
+)
+
+const OTLPCompressionGzip = "gzip"
+
+const (
+	DefaultOTLPTimeout      = 10 * time.Second
+	DefaultOTLPMaxBatchSize = 512
+	DefaultOTLPBatchTimeout = time.Second
+)
+
+func (o *OTLPConfig) validate() error {
+	if o.Endpoint == "" {
+		return fmt.Errorf("no exporter's OTLP endpoint provided")
+	}
+	if o.Protocol == "" {
+		o.Protocol = OTLPProtocolGRPC
+	}
+	switch o.Protocol {
+	case OTLPProtocolGRPC:
+	case OTLPProtocolHTTP:
+		u, err := url.Parse(o.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid exporter's OTLP endpoint, %v", o.Endpoint)
+		}
+	default:
+		return fmt.Errorf("unsupported exporter's OTLP protocol, %v", o.Protocol)
+	}
+	if len(o.Signals) == 0 {
+		o.Signals = []string{OTLPSignalTraces}
+	}
+	for _, signal := range o.Signals {
+		if !slices.Contains([]string{OTLPSignalTraces, OTLPSignalLogs}, signal) {
+			return fmt.Errorf("unsupported exporter's OTLP signal, %v", signal)
+		}
+	}
+	if o.Compression != "" && o.Compression != OTLPCompressionGzip {
+		return fmt.Errorf("unsupported exporter's OTLP compression, %v", o.Compression)
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("invalid exporter's OTLP timeout, %v", o.Timeout)
+	}
+	if o.Timeout == 0 {
+		o.Timeout = DefaultOTLPTimeout
+	}
+	if o.MaxBatchSize < 0 {
+		return fmt.Errorf("invalid exporter's OTLP maxBatchSize, %v", o.MaxBatchSize)
+	}
+	if o.MaxBatchSize == 0 {
+		o.MaxBatchSize = DefaultOTLPMaxBatchSize
+	}
+	if o.BatchTimeout < 0 {
+		return fmt.Errorf("invalid exporter's OTLP batchTimeout, %v", o.BatchTimeout)
+	}
+	if o.BatchTimeout == 0 {
+		o.BatchTimeout = DefaultOTLPBatchTimeout
+	}
+	if o.Retry == nil {
+		o.Retry = &WebhookRetryConfig{}
+	}
+	if err := o.Retry.validate("OTLP"); err != nil {
+		return err
+	}
+	if err := o.Match.validate(); err != nil {
+		return fmt.Errorf("invalid exporter's OTLP match: %w", err)
+	}
+	if o.Redact != nil {
+		if err := o.Redact.validate(); err != nil {
+			return fmt.Errorf("invalid exporter's OTLP redact configuration: %w", err)
+		}
+	}
+	if o.TLS != nil && (o.TLS.ClientCertPath == "") != (o.TLS.ClientKeyPath == "") {
+		return fmt.Errorf("exporter's OTLP TLS clientCertPath and clientKeyPath must be provided together")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOTLPConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		otlp               *OTLPConfig
+		want               *OTLPConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with endpoint should apply defaults",
+			otlp: &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317"},
+			want: &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", Protocol: OTLPProtocolGRPC,
+				Signals: []string{OTLPSignalTraces}, Timeout: DefaultOTLPTimeout, MaxBatchSize: DefaultOTLPMaxBatchSize,
+				BatchTimeout: DefaultOTLPBatchTimeout, Retry: &WebhookRetryConfig{MaxAttempts: DefaultWebhookMaxAttempts,
+					InitialBackoff: DefaultWebhookInitialBackoff, MaxBackoff: DefaultWebhookMaxBackoff}},
+		},
+		{
+			name:               "without endpoint should return error",
+			otlp:               &OTLPConfig{Enabled: true},
+			expectedErrMessage: "no exporter's OTLP endpoint provided",
+		},
+		{
+			name:               "with HTTP protocol and endpoint without scheme should return error",
+			otlp:               &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4318", Protocol: OTLPProtocolHTTP},
+			expectedErrMessage: "invalid exporter's OTLP endpoint, otel-collector:4318",
+		},
+		{
+			name:               "with unsupported protocol should return error",
+			otlp:               &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", Protocol: "http/json"},
+			expectedErrMessage: "unsupported exporter's OTLP protocol, http/json",
+		},
+		{
+			name:               "with unsupported signal should return error",
+			otlp:               &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", Signals: []string{"metrics"}},
+			expectedErrMessage: "unsupported exporter's OTLP signal, metrics",
+		},
+		{
+			name:               "with unsupported compression should return error",
+			otlp:               &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", Compression: "zstd"},
+			expectedErrMessage: "unsupported exporter's OTLP compression, zstd",
+		},
+		{
+			name:               "with negative maxBatchSize should return error",
+			otlp:               &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", MaxBatchSize: -1},
+			expectedErrMessage: "invalid exporter's OTLP maxBatchSize, -1",
+		},
+		{
+			name:               "with negative retry maxAttempts should return error",
+			otlp:               &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317", Retry: &WebhookRetryConfig{MaxAttempts: -1}},
+			expectedErrMessage: "invalid exporter's OTLP retry maxAttempts, -1",
+		},
+		{
+			name: "with client certificate without key should return error",
+			otlp: &OTLPConfig{Enabled: true, Endpoint: "otel-collector:4317",
+				TLS: &TLSConfig{ClientCertPath: "/etc/tls.crt"}},
+			expectedErrMessage: "exporter's OTLP TLS clientCertPath and clientKeyPath must be provided together",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.otlp.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.otlp, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.otlp, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Types of pipeline processors.
+const (
+	ProcessorFilter    = "filter"
+	ProcessorRedact    = "redact"
+	ProcessorEnrich    = "enrich"
+	ProcessorSample    = "sample"
+	ProcessorNormalize = "normalize"
+	ProcessorDrop      = "drop"
+)
+
+// PipelineConfig configures the processors API events go through, in order,
+// between the receivers and the exporters.
+type PipelineConfig struct {
+	Processors []*ProcessorConfig `json:"processors,omitempty"`
+}
+
+// ProcessorConfig is the configuration of a single pipeline processor. Only the
+// block named after its type is used.
+type ProcessorConfig struct {
+	// Name identifies the processor in logs and metrics. Defaults to its type.
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+
+	// Match restricts the processor to the events it matches. Other events
+	// are passed on untouched.
+	Match *MatchConfig `json:"match,omitempty"`
+
+	Filter    *FilterConfig        `json:"filter,omitempty"`
+	Redact    *RedactConfig        `json:"redact,omitempty"`
+	Enrich    *EnrichConfig        `json:"enrich,omitempty"`
+	Sample    *SampleConfig        `json:"sample,omitempty"`
+	Normalize *NormalizationConfig `json:"normalize,omitempty"`
+}
+
+// FilterConfig keeps the events matching Include, if set, unless they match
+// Exclude.
+type FilterConfig struct {
+	Include *MatchConfig `json:"include,omitempty"`
+	Exclude *MatchConfig `json:"exclude,omitempty"`
+}
+
+// Redaction modes.
+const (
+	RedactModeMask = "mask"
+	RedactModeHash = "hash"
+	RedactModeDrop = "drop"
+)
+
+// Built-in redaction detectors.
+const (
+	RedactDetectorCredentialHeaders = "credentialHeaders"
+	RedactDetectorJWT               = "jwt"
+	RedactDetectorBearer            = "bearer"
+	RedactDetectorAPIKey            = "apiKey"
+	RedactDetectorEmail             = "email"
+	RedactDetectorCreditCard        = "creditCard"
+	RedactDetectorSSN               = "ssn"
+	RedactDetectorPhone             = "phone"
+
+	// RedactDetectorNone disables the built-in detectors.
+	RedactDetectorNone = "none"
+)
+
+// RedactDetectors lists all built-in redaction detectors.
+var RedactDetectors = []string{
+	RedactDetectorCredentialHeaders,
+	RedactDetectorJWT,
+	RedactDetectorBearer,
+	RedactDetectorAPIKey,
+	RedactDetectorEmail,
+	RedactDetectorCreditCard,
+	RedactDetectorSSN,
+	RedactDetectorPhone,
+}
+
+// RedactConfig configures redacting sensitive values from the headers and
+// bodies of API events.
+type RedactConfig struct {
+	// Mode is how sensitive values are redacted: replaced with a mask, replaced
+	// with a keyed hash so that they can still be joined on, or dropped.
+	// Defaults to RedactModeMask.
+	Mode string `json:"mode,omitempty"`
+
+	// HashKey or the content of HashKeyFile is the HMAC key of the hash mode.
+	HashKey     string `json:"-"`
+	HashKeyFile string `json:"hashKeyFile,omitempty"`
+
+	// Detectors are the built-in detectors used. Defaults to all of
+	// RedactDetectors.
+	Detectors []string `json:"detectors,omitempty"`
+
+	// Headers whose values are redacted, case-insensitively.
+	Headers []string `json:"headers,omitempty"`
+
+	// JSONPaths of JSON body fields that are redacted, e.g. `$.user.password`,
+	// `$.cards[*].number` or `$..token`.
+	JSONPaths []string `json:"jsonPaths,omitempty"`
+
+	// Patterns are regular expressions whose matches in header values and
+	// bodies are redacted.
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+func (r *RedactConfig) validate() error {
+	if r.Mode == "" {
+		r.Mode = RedactModeMask
+	}
+	if !slices.Contains([]string{RedactModeMask, RedactModeHash, RedactModeDrop}, r.Mode) {
+		return fmt.Errorf("unsupported redact mode, %v", r.Mode)
+	}
+	if r.Mode == RedactModeHash && r.HashKey == "" && r.HashKeyFile == "" {
+		return fmt.Errorf("no redact hashKey or hashKeyFile provided for hash mode")
+	}
+
+	if len(r.Detectors) == 0 {
+		r.Detectors = slices.Clone(RedactDetectors)
+	}
+	for _, detector := range r.Detectors {
+		if detector == RedactDetectorNone && len(r.Detectors) == 1 {
+			continue
+		}
+		if !slices.Contains(RedactDetectors, detector) {
+			return fmt.Errorf("unsupported redact detector, %v", detector)
+		}
+	}
+
+	for _, path := range r.JSONPaths {
+		if !strings.HasPrefix(path, "$") {
+			return fmt.Errorf("invalid redact jsonPath, %v", path)
+		}
+	}
+	for _, pattern := range r.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redact pattern, %v", err)
+		}
+	}
+	return nil
+}
+
+// EnrichConfig configures filling in the workloads of API events.
+type EnrichConfig struct {
+	// Kubernetes fills in the name, namespace, kind, labels and node name of
+	// the source and destination workloads from the Pods, Services and
+	// EndpointSlices of the cluster SentryFlow runs in. Workloads are looked up
+	// first.
+	Kubernetes bool `json:"kubernetes,omitempty"`
+
+	// Workloads are the known workloads by IP address. They name the source
+	// and destination of events that don't have a name yet.
+	Workloads []EnrichWorkload `json:"workloads,omitempty"`
+}
+
+// EnrichWorkload names the workload with the given IP address.
+type EnrichWorkload struct {
+	IP        string `json:"ip"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Sampling defaults.
+const (
+	DefaultSampleBudgetWindow = time.Second
+	DefaultSampleMaxEndpoints = 10000
+)
+
+// SampleConfig configures keeping a fraction of API events. Events selected by
+// AlwaysKeep are kept. Other events are kept with probability Rate and then,
+// if EndpointBudget is set, within the budget of their endpoint.
+type SampleConfig struct {
+	// Rate is the fraction of events kept, between 0 and 1. Defaults to 1.
+	Rate *float64 `json:"rate,omitempty"`
+
+	// EndpointBudget is the number of events kept per endpoint, i.e. method
+	// and route, and BudgetWindow. Above it, events are kept with a
+	// probability derived from the endpoint's rate in the previous window.
+	// Disabled if zero.
+	EndpointBudget int           `json:"endpointBudget,omitempty"`
+	BudgetWindow   time.Duration `json:"budgetWindow,omitempty"`
+
+	// MaxEndpoints is the number of endpoints budgets are tracked for. Further
+	// endpoints share a single budget. Defaults to DefaultSampleMaxEndpoints.
+	MaxEndpoints int `json:"maxEndpoints,omitempty"`
+
+	AlwaysKeep *SampleKeepConfig `json:"alwaysKeep,omitempty"`
+}
+
+// SampleKeepConfig selects the events that are kept regardless of the rate
+// and budgets.
+type SampleKeepConfig struct {
+	// Statuses are response statuses, e.g. `429` or `5xx`.
+	Statuses []string `json:"statuses,omitempty"`
+
+	// MinLatency keeps the events whose backend latency is at least
+	// MinLatency. Disabled if zero.
+	MinLatency time.Duration `json:"minLatency,omitempty"`
+
+	// Namespaces keeps the events whose source or destination is in one of
+	// Namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+func (s *SampleConfig) validate() error {
+	if s.Rate == nil {
+		if s.EndpointBudget == 0 {
+			return fmt.Errorf("no sample rate or endpointBudget provided")
+		}
+		rate := 1.0
+		s.Rate = &rate
+	}
+	if *s.Rate < 0 || *s.Rate > 1 {
+		return fmt.Errorf("invalid sample rate, %v", *s.Rate)
+	}
+	if s.EndpointBudget < 0 {
+		return fmt.Errorf("invalid sample endpointBudget, %v", s.EndpointBudget)
+	}
+	if s.BudgetWindow < 0 {
+		return fmt.Errorf("invalid sample budgetWindow, %v", s.BudgetWindow)
+	}
+	if s.BudgetWindow == 0 {
+		s.BudgetWindow = DefaultSampleBudgetWindow
+	}
+	if s.MaxEndpoints < 0 {
+		return fmt.Errorf("invalid sample maxEndpoints, %v", s.MaxEndpoints)
+	}
+	if s.MaxEndpoints == 0 {
+		s.MaxEndpoints = DefaultSampleMaxEndpoints
+	}
+	if s.AlwaysKeep != nil {
+		for _, status := range s.AlwaysKeep.Statuses {
+			if !statusPattern.MatchString(status) {
+				return fmt.Errorf("invalid sample alwaysKeep status, %v", status)
+			}
+		}
+		if s.AlwaysKeep.MinLatency < 0 {
+			return fmt.Errorf("invalid sample alwaysKeep minLatency, %v", s.AlwaysKeep.MinLatency)
+		}
+	}
+	return nil
+}
+
+// NormalizationConfig configures deriving the templated route of every API
+// event's request path, e.g. `/orders/{id}` for `/orders/8812`.
+type NormalizationConfig struct {
+	// Rules are tried in order before the OpenAPI templates and the built-in
+	// heuristics. The route of a path matching a rule is the path with the
+	// match replaced by the rule's Route.
+	Rules []NormalizationRule `json:"rules,omitempty"`
+
+	// OpenAPISpecs are paths to OpenAPI or Swagger documents, in JSON or YAML,
+	// whose path templates are used as routes.
+	OpenAPISpecs []string `json:"openAPISpecs,omitempty"`
+}
+
+// NormalizationRule maps request paths matching Pattern to Route. Route may
+// refer to the submatches of Pattern, e.g. `$1`.
+type NormalizationRule struct {
+	Pattern string `json:"pattern"`
+	Route   string `json:"route"`
+}
+
+// KubernetesEnrichment reports whether a processor enriches events from the
+// Kubernetes workloads.
+func (p *PipelineConfig) KubernetesEnrichment() bool {
+	if p == nil {
+		return false
+	}
+	for _, proc := range p.Processors {
+		if proc.Type == ProcessorEnrich && proc.Enrich != nil && proc.Enrich.Kubernetes {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PipelineConfig) validate() error {
+	names := make(map[string]bool)
+	for _, proc := range p.Processors {
+		if proc.Type == "" {
+			return fmt.Errorf("no pipeline processor type provided")
+		}
+		if proc.Name == "" {
+			proc.Name = proc.Type
+		}
+		if names[proc.Name] {
+			return fmt.Errorf("duplicate pipeline processor name, %v", proc.Name)
+		}
+		names[proc.Name] = true
+
+		if err := proc.validate(); err != nil {
+			return fmt.Errorf("invalid %v pipeline processor: %w", proc.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *ProcessorConfig) validate() error {
+	if err := p.Match.validate(); err != nil {
+		return err
+	}
+
+	switch p.Type {
+	case ProcessorFilter:
+		if p.Filter == nil || (p.Filter.Include == nil && p.Filter.Exclude == nil) {
+			return fmt.Errorf("no filter include or exclude provided")
+		}
+		if err := p.Filter.Include.validate(); err != nil {
+			return err
+		}
+		return p.Filter.Exclude.validate()
+	case ProcessorRedact:
+		if p.Redact == nil {
+			p.Redact = &RedactConfig{}
+		}
+		return p.Redact.validate()
+	case ProcessorEnrich:
+		if p.Enrich == nil || (!p.Enrich.Kubernetes && len(p.Enrich.Workloads) == 0) {
+			return fmt.Errorf("no enrich workloads provided and kubernetes enrichment disabled")
+		}
+		for _, workload := range p.Enrich.Workloads {
+			if workload.IP == "" || workload.Name == "" {
+				return fmt.Errorf("no enrich workload ip or name provided")
+			}
+		}
+	case ProcessorSample:
+		if p.Sample == nil {
+			return fmt.Errorf("no sample rate or endpointBudget provided")
+		}
+		return p.Sample.validate()
+	case ProcessorNormalize:
+		if p.Normalize == nil {
+			p.Normalize = &NormalizationConfig{}
+		}
+		return p.Normalize.validate()
+	case ProcessorDrop:
+	default:
+		return fmt.Errorf("unsupported pipeline processor type, %v", p.Type)
+	}
+	return nil
+}
+
+var statusPattern = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+
+func (n *NormalizationConfig) validate() error {
+	for _, rule := range n.Rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("no normalization rule pattern provided")
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid normalization rule pattern, %v", err)
+		}
+		if rule.Route == "" {
+			return fmt.Errorf("no normalization rule route provided for pattern, %v", rule.Pattern)
+		}
+	}
+	for _, spec := range n.OpenAPISpecs {
+		if spec == "" {
+			return fmt.Errorf("no normalization openAPI spec path provided")
+		}
+	}
+	return nil
+}
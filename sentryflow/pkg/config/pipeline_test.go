@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPipelineConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		pipeline           *PipelineConfig
+		want               *PipelineConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "without names should default them to the types",
+			pipeline: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Type: ProcessorDrop, Match: &MatchConfig{Paths: []string{"^/healthz$"}, Statuses: []string{"200", "2xx"}}},
+				{Type: ProcessorNormalize},
+			}},
+			want: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Name: ProcessorDrop, Type: ProcessorDrop, Match: &MatchConfig{Paths: []string{"^/healthz$"}, Statuses: []string{"200", "2xx"}}},
+				{Name: ProcessorNormalize, Type: ProcessorNormalize, Normalize: &NormalizationConfig{}},
+			}},
+		},
+		{
+			name:               "without type should return error",
+			pipeline:           &PipelineConfig{Processors: []*ProcessorConfig{{Name: "drop-all"}}},
+			expectedErrMessage: "no pipeline processor type provided",
+		},
+		{
+			name:               "with unsupported type should return error",
+			pipeline:           &PipelineConfig{Processors: []*ProcessorConfig{{Type: "encrypt"}}},
+			expectedErrMessage: "invalid encrypt pipeline processor: unsupported pipeline processor type, encrypt",
+		},
+		{
+			name: "with duplicate names should return error",
+			pipeline: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Type: ProcessorDrop},
+				{Type: ProcessorDrop},
+			}},
+			expectedErrMessage: "duplicate pipeline processor name, drop",
+		},
+		{
+			name: "with invalid match path should return error",
+			pipeline: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Type: ProcessorDrop, Match: &MatchConfig{Paths: []string{"("}}},
+			}},
+			expectedErrMessage: "invalid drop pipeline processor: invalid match path, error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name: "with invalid match status should return error",
+			pipeline: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Type: ProcessorDrop, Match: &MatchConfig{Statuses: []string{"6xx"}}},
+			}},
+			expectedErrMessage: "invalid drop pipeline processor: invalid match status, 6xx",
+		},
+		{
+			name:               "with filter without include or exclude should return error",
+			pipeline:           &PipelineConfig{Processors: []*ProcessorConfig{{Type: ProcessorFilter, Filter: &FilterConfig{}}}},
+			expectedErrMessage: "invalid filter pipeline processor: no filter include or exclude provided",
+		},
+		{
+			name:               "with redact in hash mode without key should return error",
+			pipeline:           &PipelineConfig{Processors: []*ProcessorConfig{{Type: ProcessorRedact, Redact: &RedactConfig{Mode: RedactModeHash}}}},
+			expectedErrMessage: "invalid redact pipeline processor: no redact hashKey or hashKeyFile provided for hash mode",
+		},
+		{
+			name:               "with enrich without workloads or kubernetes should return error",
+			pipeline:           &PipelineConfig{Processors: []*ProcessorConfig{{Type: ProcessorEnrich, Enrich: &EnrichConfig{}}}},
+			expectedErrMessage: "invalid enrich pipeline processor: no enrich workloads provided and kubernetes enrichment disabled",
+		},
+		{
+			name:     "with kubernetes enrich should not return error",
+			pipeline: &PipelineConfig{Processors: []*ProcessorConfig{{Type: ProcessorEnrich, Enrich: &EnrichConfig{Kubernetes: true}}}},
+			want: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Name: ProcessorEnrich, Type: ProcessorEnrich, Enrich: &EnrichConfig{Kubernetes: true}},
+			}},
+		},
+		{
+			name: "with enrich workload without name should return error",
+			pipeline: &PipelineConfig{Processors: []*ProcessorConfig{
+				{Type: ProcessorEnrich, Enrich: &EnrichConfig{Workloads: []EnrichWorkload{{IP: "10.0.0.1"}}}},
+			}},
+			expectedErrMessage: "invalid enrich pipeline processor: no enrich workload ip or name provided",
+		},
+		{
+			name:               "with sample rate above one should return error",
+			pipeline:           &PipelineConfig{Processors: []*ProcessorConfig{{Type: ProcessorSample, Sample: &SampleConfig{Rate: ptrTo(1.5)}}}},
+			expectedErrMessage: "invalid sample pipeline processor: invalid sample rate, 1.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pipeline.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.pipeline, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.pipeline, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizationConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		normalization      *NormalizationConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with valid rules and specs should not return error",
+			normalization: &NormalizationConfig{
+				Rules:        []NormalizationRule{{Pattern: `^/static/.*$`, Route: "/static/{file}"}},
+				OpenAPISpecs: []string{"/etc/sentryflow/openapi.yaml"},
+			},
+		},
+		{
+			name: "with invalid pattern should return error",
+			normalization: &NormalizationConfig{
+				Rules: []NormalizationRule{{Pattern: "(", Route: "/"}},
+			},
+			expectedErrMessage: "invalid normalization rule pattern, error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name: "with no pattern should return error",
+			normalization: &NormalizationConfig{
+				Rules: []NormalizationRule{{Route: "/"}},
+			},
+			expectedErrMessage: "no normalization rule pattern provided",
+		},
+		{
+			name: "with no route should return error",
+			normalization: &NormalizationConfig{
+				Rules: []NormalizationRule{{Pattern: "^/static/"}},
+			},
+			expectedErrMessage: "no normalization rule route provided for pattern, ^/static/",
+		},
+		{
+			name: "with empty spec path should return error",
+			normalization: &NormalizationConfig{
+				OpenAPISpecs: []string{""},
+			},
+			expectedErrMessage: "no normalization openAPI spec path provided",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.normalization.validate()
+			if tt.expectedErrMessage == "" {
+				if err != nil {
+					t.Errorf("validate() expected no error but got error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedErrMessage {
+				t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+			}
+		})
+	}
+}
+
+func TestRedactConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		redact             *RedactConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with valid config should not return error",
+			redact: &RedactConfig{
+				Mode:      RedactModeHash,
+				HashKey:   "s3cret",
+				Detectors: []string{RedactDetectorEmail, RedactDetectorCreditCard},
+				Headers:   []string{"x-session"},
+				JSONPaths: []string{"$.user.password"},
+				Patterns:  []string{`order-[0-9]+`},
+			},
+		},
+		{
+			name:   "with none detector should not return error",
+			redact: &RedactConfig{Detectors: []string{RedactDetectorNone}, Headers: []string{"x-session"}},
+		},
+		{
+			name:               "with unsupported mode should return error",
+			redact:             &RedactConfig{Mode: "scramble"},
+			expectedErrMessage: "unsupported redact mode, scramble",
+		},
+		{
+			name:               "with hash mode and no key should return error",
+			redact:             &RedactConfig{Mode: RedactModeHash},
+			expectedErrMessage: "no redact hashKey or hashKeyFile provided for hash mode",
+		},
+		{
+			name:               "with unsupported detector should return error",
+			redact:             &RedactConfig{Detectors: []string{RedactDetectorEmail, "iban"}},
+			expectedErrMessage: "unsupported redact detector, iban",
+		},
+		{
+			name:               "with none and other detectors should return error",
+			redact:             &RedactConfig{Detectors: []string{RedactDetectorNone, RedactDetectorEmail}},
+			expectedErrMessage: "unsupported redact detector, none",
+		},
+		{
+			name:               "with JSON path without root should return error",
+			redact:             &RedactConfig{JSONPaths: []string{"user.password"}},
+			expectedErrMessage: "invalid redact jsonPath, user.password",
+		},
+		{
+			name:               "with invalid pattern should return error",
+			redact:             &RedactConfig{Patterns: []string{"("}},
+			expectedErrMessage: "invalid redact pattern, error parsing regexp: missing closing ): `(`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.redact.validate()
+			if tt.expectedErrMessage == "" {
+				if err != nil {
+					t.Errorf("validate() expected no error but got error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.expectedErrMessage {
+				t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+			}
+		})
+	}
+}
+
+func TestRedactConfig_validate_defaults(t *testing.T) {
+	redact := &RedactConfig{}
+
+	if err := redact.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	if redact.Mode != RedactModeMask {
+		t.Errorf("validate() Mode = %v, want %v", redact.Mode, RedactModeMask)
+	}
+	if !reflect.DeepEqual(redact.Detectors, RedactDetectors) {
+		t.Errorf("validate() Detectors = %v, want %v", redact.Detectors, RedactDetectors)
+	}
+}
+
+func TestSampleConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		sample             *SampleConfig
+		want               *SampleConfig
+		expectedErrMessage string
+	}{
+		{
+			name:   "with rate should apply defaults",
+			sample: &SampleConfig{Rate: ptrTo(0.1)},
+			want:   &SampleConfig{Rate: ptrTo(0.1), BudgetWindow: DefaultSampleBudgetWindow, MaxEndpoints: DefaultSampleMaxEndpoints},
+		},
+		{
+			name:   "with endpoint budget only should keep every event within budget",
+			sample: &SampleConfig{EndpointBudget: 10, BudgetWindow: time.Minute, MaxEndpoints: 100},
+			want:   &SampleConfig{Rate: ptrTo(1.0), EndpointBudget: 10, BudgetWindow: time.Minute, MaxEndpoints: 100},
+		},
+		{
+			name:               "without rate or endpoint budget should return error",
+			sample:             &SampleConfig{AlwaysKeep: &SampleKeepConfig{Statuses: []string{"5xx"}}},
+			expectedErrMessage: "no sample rate or endpointBudget provided",
+		},
+		{
+			name:               "with negative endpoint budget should return error",
+			sample:             &SampleConfig{Rate: ptrTo(1.0), EndpointBudget: -1},
+			expectedErrMessage: "invalid sample endpointBudget, -1",
+		},
+		{
+			name:               "with invalid always keep status should return error",
+			sample:             &SampleConfig{Rate: ptrTo(0.1), AlwaysKeep: &SampleKeepConfig{Statuses: []string{"6xx"}}},
+			expectedErrMessage: "invalid sample alwaysKeep status, 6xx",
+		},
+		{
+			name:               "with negative always keep latency should return error",
+			sample:             &SampleConfig{Rate: ptrTo(0.1), AlwaysKeep: &SampleKeepConfig{MinLatency: -time.Second}},
+			expectedErrMessage: "invalid sample alwaysKeep minLatency, -1s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sample.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.sample, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.sample, tt.want)
+			}
+		})
+	}
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
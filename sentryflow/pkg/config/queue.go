@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Names of the queues API events go through.
+const (
+	// QueueIngest is the queue of the events received by the ingest endpoint
+	// and the receivers, in front of the pipeline.
+	QueueIngest = "ingest"
+
+	// QueueGrpc, QueueHTTP, QueueKafka, QueueNATS, QueueOTLP,
+	// QueueElasticsearch and QueueMetrics are the queues of the exporters.
+	QueueGrpc          = "grpc"
+	QueueHTTP          = "http"
+	QueueKafka         = "kafka"
+	QueueNATS          = "nats"
+	QueueOTLP          = "otlp"
+	QueueElasticsearch = "elasticsearch"
+	QueueMetrics       = "metrics"
+
+	// QueueGrpcClients is the queue of every client streaming API events from
+	// the gRPC exporter.
+	QueueGrpcClients = "grpcClients"
+)
+
+// Backpressure policies, i.e. what a full queue does with new events.
+const (
+	// QueuePolicyBlock waits up to the queue's timeout for room, and then drops
+	// the event.
+	QueuePolicyBlock = "block"
+
+	// QueuePolicyDropNewest drops the new event.
+	QueuePolicyDropNewest = "dropNewest"
+
+	// QueuePolicyDropOldest drops the oldest queued event to make room.
+	QueuePolicyDropOldest = "dropOldest"
+
+	// QueuePolicySpill writes the event to a file in the queue's spill
+	// directory, from which it's queued again once there is room.
+	QueuePolicySpill = "spill"
+)
+
+const (
+	DefaultQueueBufferSize       = 10240
+	DefaultGrpcClientsBufferSize = 1000
+	DefaultQueueTimeout          = time.Second
+	DefaultQueueMaxSpillBytes    = int64(1 << 30)
+)
+
+// QueuesConfig configures the queues API events go through. Queues that
+// aren't configured use their default configuration, see QueuesConfig.Queue.
+type QueuesConfig struct {
+	Ingest        *QueueConfig `json:"ingest,omitempty"`
+	Grpc          *QueueConfig `json:"grpc,omitempty"`
+	HTTP          *QueueConfig `json:"http,omitempty"`
+	Kafka         *QueueConfig `json:"kafka,omitempty"`
+	NATS          *QueueConfig `json:"nats,omitempty"`
+	OTLP          *QueueConfig `json:"otlp,omitempty"`
+	Elasticsearch *QueueConfig `json:"elasticsearch,omitempty"`
+	Metrics       *QueueConfig `json:"metrics,omitempty"`
+	GrpcClients   *QueueConfig `json:"grpcClients,omitempty"`
+}
+
+// QueueConfig configures the size of a queue and its backpressure policy.
+type QueueConfig struct {
+	// Policy is one of the QueuePolicy constants. Defaults to
+	// QueuePolicyBlock for the ingest queue, QueuePolicyDropOldest for the
+	// gRPC clients and QueuePolicyDropNewest for the exporters.
+	Policy string `json:"policy,omitempty"`
+
+	// BufferSize is the number of events queued in memory. Defaults to
+	// DefaultGrpcClientsBufferSize for the gRPC clients and
+	// DefaultQueueBufferSize for the other queues.
+	BufferSize int `json:"bufferSize,omitempty"`
+
+	// Timeout is how long the block policy waits for room. Defaults to
+	// DefaultQueueTimeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// SpillDir is the directory the spill policy writes events to, and
+	// MaxSpillBytes the size the spill file can grow to before events are
+	// dropped. MaxSpillBytes defaults to DefaultQueueMaxSpillBytes.
+	SpillDir      string `json:"spillDir,omitempty"`
+	MaxSpillBytes int64  `json:"maxSpillBytes,omitempty"`
+}
+
+// Queue returns the configuration of the named queue, with defaults applied.
+func (q *QueuesConfig) Queue(name string) *QueueConfig {
+	if queue := q.configured(name); queue != nil {
+		return queue
+	}
+	queue := &QueueConfig{}
+	// The defaults are always valid.
+	_ = queue.validate(name)
+	return queue
+}
+
+// configured returns the configuration of the named queue, or nil if it isn't
+// configured.
+func (q *QueuesConfig) configured(name string) *QueueConfig {
+	if q == nil {
+		return nil
+	}
+	switch name {
+	case QueueIngest:
+		return q.Ingest
+	case QueueGrpc:
+		return q.Grpc
+	case QueueHTTP:
+		return q.HTTP
+	case QueueKafka:
+		return q.Kafka
+	case QueueNATS:
+		return q.NATS
+	case QueueOTLP:
+		return q.OTLP
+	case QueueElasticsearch:
+		return q.Elasticsearch
+	case QueueMetrics:
+		return q.Metrics
+	case QueueGrpcClients:
+		return q.GrpcClients
+	}
+	return nil
+}
+
+func (q *QueuesConfig) validate() error {
+	for _, name := range []string{QueueIngest, QueueGrpc, QueueHTTP, QueueKafka, QueueNATS, QueueOTLP, QueueElasticsearch, QueueMetrics, QueueGrpcClients} {
+		queue := q.configured(name)
+		if queue == nil {
+			continue
+		}
+		if err := queue.validate(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *QueueConfig) validate(name string) error {
+	if q.Policy == "" {
+		switch name {
+		case QueueIngest:
+			q.Policy = QueuePolicyBlock
+		case QueueGrpcClients:
+			q.Policy = QueuePolicyDropOldest
+		default:
+			q.Policy = QueuePolicyDropNewest
+		}
+	}
+	if !slices.Contains([]string{QueuePolicyBlock, QueuePolicyDropNewest, QueuePolicyDropOldest, QueuePolicySpill}, q.Policy) {
+		return fmt.Errorf("invalid %s queue policy, %v", name, q.Policy)
+	}
+	if q.BufferSize < 0 {
+		return fmt.Errorf("invalid %s queue bufferSize, %v", name, q.BufferSize)
+	}
+	if q.BufferSize == 0 {
+		q.BufferSize = DefaultQueueBufferSize
+		if name == QueueGrpcClients {
+			q.BufferSize = DefaultGrpcClientsBufferSize
+		}
+	}
+	if q.Timeout < 0 {
+		return fmt.Errorf("invalid %s queue timeout, %v", name, q.Timeout)
+	}
+	if q.Timeout == 0 && q.Policy == QueuePolicyBlock {
+		q.Timeout = DefaultQueueTimeout
+	}
+	// Every gRPC client has its own queue: they can't share a spill file, and
+	// a slow client must not hold back the others.
+	if name == QueueGrpcClients && (q.Policy == QueuePolicySpill || q.Policy == QueuePolicyBlock) {
+		return fmt.Errorf("unsupported %s queue policy, %v", name, q.Policy)
+	}
+	if q.Policy == QueuePolicySpill {
+		if q.SpillDir == "" {
+			return fmt.Errorf("no %s queue spillDir provided", name)
+		}
+		if q.MaxSpillBytes < 0 {
+			return fmt.Errorf("invalid %s queue maxSpillBytes, %v", name, q.MaxSpillBytes)
+		}
+		if q.MaxSpillBytes == 0 {
+			q.MaxSpillBytes = DefaultQueueMaxSpillBytes
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueuesConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		queues             *QueuesConfig
+		want               *QueuesConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with policies should apply defaults",
+			queues: &QueuesConfig{
+				Ingest: &QueueConfig{},
+				HTTP:   &QueueConfig{Policy: QueuePolicySpill, SpillDir: "/var/lib/sentryflow"},
+			},
+			want: &QueuesConfig{
+				Ingest: &QueueConfig{Policy: QueuePolicyBlock, BufferSize: DefaultQueueBufferSize, Timeout: DefaultQueueTimeout},
+				HTTP: &QueueConfig{Policy: QueuePolicySpill, BufferSize: DefaultQueueBufferSize, SpillDir: "/var/lib/sentryflow",
+					MaxSpillBytes: DefaultQueueMaxSpillBytes},
+			},
+		},
+		{
+			name:               "with unknown policy should return error",
+			queues:             &QueuesConfig{Grpc: &QueueConfig{Policy: "drop"}},
+			expectedErrMessage: "invalid grpc queue policy, drop",
+		},
+		{
+			name:               "with negative buffer size should return error",
+			queues:             &QueuesConfig{Metrics: &QueueConfig{BufferSize: -1}},
+			expectedErrMessage: "invalid metrics queue bufferSize, -1",
+		},
+		{
+			name:               "with negative timeout should return error",
+			queues:             &QueuesConfig{Ingest: &QueueConfig{Timeout: -time.Second}},
+			expectedErrMessage: "invalid ingest queue timeout, -1s",
+		},
+		{
+			name:               "with spill policy without directory should return error",
+			queues:             &QueuesConfig{HTTP: &QueueConfig{Policy: QueuePolicySpill}},
+			expectedErrMessage: "no http queue spillDir provided",
+		},
+		{
+			name:               "with spill policy for gRPC clients should return error",
+			queues:             &QueuesConfig{GrpcClients: &QueueConfig{Policy: QueuePolicySpill, SpillDir: "/tmp"}},
+			expectedErrMessage: "unsupported grpcClients queue policy, spill",
+		},
+		{
+			name:               "with block policy for gRPC clients should return error",
+			queues:             &QueuesConfig{GrpcClients: &QueueConfig{Policy: QueuePolicyBlock}},
+			expectedErrMessage: "unsupported grpcClients queue policy, block",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.queues.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.queues, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.queues, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueuesConfig_Queue(t *testing.T) {
+	var queues *QueuesConfig
+	tests := []struct {
+		name string
+		want QueueConfig
+	}{
+		{name: QueueIngest, want: QueueConfig{Policy: QueuePolicyBlock, BufferSize: DefaultQueueBufferSize, Timeout: DefaultQueueTimeout}},
+		{name: QueueHTTP, want: QueueConfig{Policy: QueuePolicyDropNewest, BufferSize: DefaultQueueBufferSize}},
+		{name: QueueGrpcClients, want: QueueConfig{Policy: QueuePolicyDropOldest, BufferSize: DefaultGrpcClientsBufferSize}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queues.Queue(tt.name); *got != tt.want {
+				t.Errorf("Queue() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// WAL sync policies, i.e. when the events appended to the write-ahead log are
+// flushed to disk.
+const (
+	// WALSyncAlways flushes every event before it's handed to the exporters.
+	WALSyncAlways = "always"
+
+	// WALSyncInterval flushes the events every sync interval.
+	WALSyncInterval = "interval"
+
+	// WALSyncNone leaves flushing to the operating system.
+	WALSyncNone = "none"
+)
+
+const (
+	DefaultWALSegmentBytes = int64(64 << 20)
+	DefaultWALMaxBytes     = int64(1 << 30)
+	DefaultWALSyncInterval = time.Second
+	DefaultWALMaxReplays   = 10
+)
+
+// WALExporters lists the exporters that can read their events from the
+// write-ahead log.
+var WALExporters = []string{QueueHTTP, QueueKafka, QueueNATS, QueueOTLP, QueueElasticsearch}
+
+// WALConfig configures the write-ahead log API events are written to between
+// the pipeline and the exporters reading from it. The events of an exporter
+// are kept until it's done with them, or until they're removed by the
+// retention limits, and replayed after a restart. The log is only configured
+// at startup.
+type WALConfig struct {
+	Enabled bool   `json:"enabled"`
+	Dir     string `json:"dir"`
+
+	// Exporters read their events from the log instead of their queue, see
+	// WALExporters. Defaults to all of WALExporters.
+	Exporters []string `json:"exporters,omitempty"`
+
+	// SegmentBytes is the size of the segment files the log is split into.
+	// Defaults to DefaultWALSegmentBytes.
+	SegmentBytes int64 `json:"segmentBytes,omitempty"`
+
+	// MaxBytes is the size the log can grow to before its oldest segments are
+	// removed, even if not every exporter is done with them. Defaults to
+	// DefaultWALMaxBytes.
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+
+	// MaxAge is how long a segment is kept after its last event was written.
+	// Disabled if zero.
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+
+	// Sync is one of the WALSync constants. Defaults to WALSyncInterval.
+	Sync string `json:"sync,omitempty"`
+
+	// SyncInterval is how often the log is flushed with the interval policy,
+	// and how often the exporters' cursors are saved. Defaults to
+	// DefaultWALSyncInterval.
+	SyncInterval time.Duration `json:"syncInterval,omitempty"`
+
+	// MaxReplays is how many times an event an exporter couldn't deliver is
+	// sent again before it's dropped. Defaults to DefaultWALMaxReplays.
+	MaxReplays int `json:"maxReplays,omitempty"`
+}
+
+// Reads reports whether the named exporter reads its events from the log.
+func (w *WALConfig) Reads(exporter string) bool {
+	return w != nil && w.Enabled && slices.Contains(w.Exporters, exporter)
+}
+
+func (w *WALConfig) validate() error {
+	if w.Dir == "" {
+		return fmt.Errorf("no wal dir provided")
+	}
+	if len(w.Exporters) == 0 {
+		w.Exporters = slices.Clone(WALExporters)
+	}
+	for _, exporter := range w.Exporters {
+		if !slices.Contains(WALExporters, exporter) {
+			return fmt.Errorf("unsupported wal exporter, %v", exporter)
+		}
+	}
+	if w.SegmentBytes < 0 {
+		return fmt.Errorf("invalid wal segmentBytes, %v", w.SegmentBytes)
+	}
+	if w.SegmentBytes == 0 {
+		w.SegmentBytes = DefaultWALSegmentBytes
+	}
+	if w.MaxBytes < 0 {
+		return fmt.Errorf("invalid wal maxBytes, %v", w.MaxBytes)
+	}
+	if w.MaxBytes == 0 {
+		w.MaxBytes = DefaultWALMaxBytes
+	}
+	if w.MaxBytes < w.SegmentBytes {
+		return fmt.Errorf("wal maxBytes %v is smaller than segmentBytes %v", w.MaxBytes, w.SegmentBytes)
+	}
+	if w.MaxAge < 0 {
+		return fmt.Errorf("invalid wal maxAge, %v", w.MaxAge)
+	}
+	if w.Sync == "" {
+		w.Sync = WALSyncInterval
+	}
+	if !slices.Contains([]string{WALSyncAlways, WALSyncInterval, WALSyncNone}, w.Sync) {
+		return fmt.Errorf("invalid wal sync policy, %v", w.Sync)
+	}
+	if w.SyncInterval < 0 {
+		return fmt.Errorf("invalid wal syncInterval, %v", w.SyncInterval)
+	}
+	if w.SyncInterval == 0 {
+		w.SyncInterval = DefaultWALSyncInterval
+	}
+	if w.MaxReplays < 0 {
+		return fmt.Errorf("invalid wal maxReplays, %v", w.MaxReplays)
+	}
+	if w.MaxReplays == 0 {
+		w.MaxReplays = DefaultWALMaxReplays
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWALConfig_validate(t *testing.T) {
+	tests := []struct {
+		name               string
+		wal                *WALConfig
+		want               *WALConfig
+		expectedErrMessage string
+	}{
+		{
+			name: "with directory should apply defaults",
+			wal:  &WALConfig{Enabled: true, Dir: "/var/lib/sentryflow/wal"},
+			want: &WALConfig{Enabled: true, Dir: "/var/lib/sentryflow/wal", Exporters: []string{QueueHTTP, QueueKafka, QueueNATS, QueueOTLP, QueueElasticsearch},
+				SegmentBytes: DefaultWALSegmentBytes, MaxBytes: DefaultWALMaxBytes, Sync: WALSyncInterval,
+				SyncInterval: DefaultWALSyncInterval, MaxReplays: DefaultWALMaxReplays},
+		},
+		{
+			name:               "without directory should return error",
+			wal:                &WALConfig{Enabled: true},
+			expectedErrMessage: "no wal dir provided",
+		},
+		{
+			name:               "with unsupported exporter should return error",
+			wal:                &WALConfig{Enabled: true, Dir: "/tmp", Exporters: []string{QueueGrpc}},
+			expectedErrMessage: "unsupported wal exporter, grpc",
+		},
+		{
+			name:               "with maxBytes smaller than segmentBytes should return error",
+			wal:                &WALConfig{Enabled: true, Dir: "/tmp", SegmentBytes: 2048, MaxBytes: 1024},
+			expectedErrMessage: "wal maxBytes 1024 is smaller than segmentBytes 2048",
+		},
+		{
+			name:               "with unknown sync policy should return error",
+			wal:                &WALConfig{Enabled: true, Dir: "/tmp", Sync: "sometimes"},
+			expectedErrMessage: "invalid wal sync policy, sometimes",
+		},
+		{
+			name:               "with negative maxAge should return error",
+			wal:                &WALConfig{Enabled: true, Dir: "/tmp", MaxAge: -time.Hour},
+			expectedErrMessage: "invalid wal maxAge, -1h0m0s",
+		},
+		{
+			name:               "with negative maxReplays should return error",
+			wal:                &WALConfig{Enabled: true, Dir: "/tmp", MaxReplays: -1},
+			expectedErrMessage: "invalid wal maxReplays, -1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.wal.validate()
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("validate() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() expected no error but got error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.wal, tt.want) {
+				t.Errorf("validate() got = %+v, want = %+v", tt.wal, tt.want)
+			}
+		})
+	}
+}
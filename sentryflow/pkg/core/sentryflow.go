@@ -5,6 +5,7 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sync/atomic"
 	"time"
@@ -30,8 +31,13 @@ import (
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/exporter"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/k8s"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/pipeline"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/queue"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+	_ "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/builtin"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/wal"
 )
 
 type Manager struct {
@@ -42,50 +48,80 @@ type Manager struct {
 	K8sClient           client.Client
 	Wg                  *sync.WaitGroup
 	ApiEvents           chan *protobuf.APIEvent
+	ProcessedEvents     chan *protobuf.APIEvent
 	GrpcEvents          chan *protobuf.APIEvent
 	HttpEvents          chan *protobuf.APIEvent
+	KafkaEvents         chan *protobuf.APIEvent
+	NATSEvents          chan *protobuf.APIEvent
+	OTLPEvents          chan *protobuf.APIEvent
+	ElasticsearchEvents chan *protobuf.APIEvent
+	MetricsEvents       chan *protobuf.APIEvent
+	EnvoyMetrics        chan *protobuf.EnvoyMetrics
 	configChan          chan *config.Config
 	receiversCtx        context.Context
 	receiversCancelFunc context.CancelFunc
 	receiversLock       *sync.Mutex
+	receivers           *receiver.Supervisor
+	exporters           []exporter.Reconfigurer
+	pipeline            atomic.Pointer[pipeline.Pipeline]
+	workloads           *k8s.WorkloadCache
+
+	// ingest is the queue of ApiEvents the ingest endpoint pushes to, outputs
+	// the queues of the exporters fanOutAPIEvents pushes to.
+	ingest  *queue.Queue
+	outputs []*queue.Queue
+
+	// wal is the write-ahead log fanOutAPIEvents appends to for the exporters
+	// reading from it instead of their queue, nil if it's disabled.
+	wal *wal.Log
 }
 
-type fanoutStats struct {
-	inCount  uint64
-	grpcDrop uint64
-	httpDrop uint64
-}
-
-func (m *Manager) areK8sReceivers(cfg *config.Config) bool {
-	if len(cfg.Receivers.ServiceMeshes) > 0 {
-		return true
-	}
+// exporterReloadTimeout is how long an exporter is waited for to apply a new
+// configuration, so that a slow destination doesn't hold the other reloads.
+const exporterReloadTimeout = 30 * time.Second
 
-	for _, other := range cfg.Receivers.Others {
-		if other.Name == util.NginxIncorporationIngressController || other.Name == util.KongGateway {
-			return true
-		}
-	}
+// workloadCacheSyncTimeout is how long the Kubernetes workload cache is waited
+// for to be filled.
+const workloadCacheSyncTimeout = time.Minute
 
-	return false
+type fanoutStats struct {
+	inCount uint64
 }
 
 func (m *Manager) run(cfg *config.Config, kubeConfig string) {
 	m.Ctx, _ = m.setupSignalHandler(make(chan os.Signal, 2))
 	m.GrpcServer = grpc.NewServer()
 	m.Wg = &sync.WaitGroup{}
-	m.ApiEvents = make(chan *protobuf.APIEvent, 10240)
-	m.GrpcEvents = make(chan *protobuf.APIEvent, 10240) // output for gRPC exporter
-	m.HttpEvents = make(chan *protobuf.APIEvent, 10240) // output for HTTP exporter
-
-	if m.areK8sReceivers(cfg) {
-		k8sClient, err := k8s.NewClient(registerAndGetScheme(), kubeConfig)
-		if err != nil {
-			m.Logger.Errorf("failed to create k8s client: %v", err)
-			return
-		}
-		m.K8sClient = k8sClient
+	m.ApiEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueIngest).BufferSize)
+	m.ProcessedEvents = make(chan *protobuf.APIEvent, config.DefaultQueueBufferSize)                  // output of the pipeline for fanout
+	m.GrpcEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueGrpc).BufferSize)       // output for gRPC exporter
+	m.HttpEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueHTTP).BufferSize)       // output for HTTP exporter
+	m.KafkaEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueKafka).BufferSize)     // output for Kafka exporter
+	m.NATSEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueNATS).BufferSize)       // output for NATS exporter
+	m.OTLPEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueOTLP).BufferSize)       // output for OTLP exporter
+	m.MetricsEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueMetrics).BufferSize) // output for API metrics exporter
+	m.EnvoyMetrics = make(chan *protobuf.EnvoyMetrics, 1024)                                          // output of istio receivers for gRPC exporter
+	// output for Elasticsearch exporter
+	m.ElasticsearchEvents = make(chan *protobuf.APIEvent, cfg.Queues.Queue(config.QueueElasticsearch).BufferSize)
+	metrics.TrackChannel("api", m.ApiEvents)
+	metrics.TrackChannel("processed", m.ProcessedEvents)
+	metrics.TrackChannel("grpc", m.GrpcEvents)
+	metrics.TrackChannel("http", m.HttpEvents)
+	metrics.TrackChannel("kafka", m.KafkaEvents)
+	metrics.TrackChannel("nats", m.NATSEvents)
+	metrics.TrackChannel("otlp", m.OTLPEvents)
+	metrics.TrackChannel("elasticsearch", m.ElasticsearchEvents)
+	metrics.TrackChannel("metrics", m.MetricsEvents)
+
+	if err := m.initK8sClient(cfg, kubeConfig); err != nil {
+		m.Logger.Error(err)
+		return
 	}
+	if err := m.initQueues(cfg); err != nil {
+		m.Logger.Error(err)
+		return
+	}
+	defer m.closeQueues()
 
 	m.Wg.Add(1)
 	go func() {
@@ -94,26 +130,98 @@ func (m *Manager) run(cfg *config.Config, kubeConfig string) {
 	}()
 
 	m.receiversCtx, m.receiversCancelFunc = m.setupSignalHandler(make(chan os.Signal, 2))
-	if err := receiver.Init(m.receiversCtx, m.K8sClient, cfg, m.Wg, m.receiversLock, m.ApiEvents); err != nil {
+	m.receivers = receiver.NewSupervisor(m.receiversCtx, m.Wg, m.ApiEvents)
+	if err := m.receivers.Apply(cfg, m.receiverDependencies()); err != nil {
 		m.Logger.Errorf("failed to initialize receiver: %v", err)
 		return
 	}
 
+	m.initWorkloadCache(cfg, kubeConfig)
+	if err := m.reconfigurePipeline(cfg); err != nil {
+		m.Logger.Errorf("failed to initialize pipeline: %v", err)
+		return
+	}
+
+	m.Wg.Add(1)
+	go func() {
+		defer m.Wg.Done()
+		pipeline.Run(m.Ctx, m.ApiEvents, m.ProcessedEvents, &m.pipeline)
+	}()
+
 	m.Wg.Add(1)
 	go func() {
 		defer m.Wg.Done()
-		fanOutAPIEvents(m.Ctx, m.Logger.Named("fanout"), m.ApiEvents, m.GrpcEvents, m.HttpEvents)
+		fanOutAPIEvents(m.Ctx, m.Logger.Named("fanout"), m.ProcessedEvents, m.outputs, m.wal)
 	}()
 
-	if err := exporter.InitGRPCExporter(m.Ctx, m.GrpcServer, cfg, m.GrpcEvents, m.Wg); err != nil {
+	grpcExporter, err := exporter.InitGRPCExporter(m.Ctx, m.GrpcServer, cfg, m.GrpcEvents, m.EnvoyMetrics, m.Wg)
+	if err != nil {
 		m.Logger.Errorf("failed to initialize exporter: %v", err)
 		return
 	}
+	m.exporters = append(m.exporters, grpcExporter)
 
-	if err := exporter.InitHTTPExporter(m.Ctx, cfg, m.HttpEvents, m.Wg); err != nil {
+	httpDeps := exporter.HTTPDependencies{Secrets: k8s.NewSecrets(kubeConfig)}
+	if r := m.walReader(config.QueueHTTP); r != nil {
+		httpDeps.Acks = r
+	}
+	httpExporter, err := exporter.InitHTTPExporter(m.Ctx, cfg, m.HttpEvents, httpDeps, m.Wg)
+	if err != nil {
 		m.Logger.Errorf("failed to initialize http exporter: %v", err)
 		return
 	}
+	m.exporters = append(m.exporters, httpExporter)
+
+	kafkaDeps := exporter.KafkaDependencies{Secrets: httpDeps.Secrets}
+	if r := m.walReader(config.QueueKafka); r != nil {
+		kafkaDeps.Acks = r
+	}
+	kafkaExporter, err := exporter.InitKafkaExporter(m.Ctx, cfg, m.KafkaEvents, kafkaDeps, m.Wg)
+	if err != nil {
+		m.Logger.Errorf("failed to initialize kafka exporter: %v", err)
+		return
+	}
+	m.exporters = append(m.exporters, kafkaExporter)
+
+	natsDeps := exporter.NATSDependencies{}
+	if r := m.walReader(config.QueueNATS); r != nil {
+		natsDeps.Acks = r
+	}
+	natsExporter, err := exporter.InitNATSExporter(m.Ctx, cfg, m.NATSEvents, natsDeps, m.Wg)
+	if err != nil {
+		m.Logger.Errorf("failed to initialize nats exporter: %v", err)
+		return
+	}
+	m.exporters = append(m.exporters, natsExporter)
+
+	otlpDeps := exporter.OTLPDependencies{}
+	if r := m.walReader(config.QueueOTLP); r != nil {
+		otlpDeps.Acks = r
+	}
+	otlpExporter, err := exporter.InitOTLPExporter(m.Ctx, cfg, m.OTLPEvents, otlpDeps, m.Wg)
+	if err != nil {
+		m.Logger.Errorf("failed to initialize otlp exporter: %v", err)
+		return
+	}
+	m.exporters = append(m.exporters, otlpExporter)
+
+	elasticsearchDeps := exporter.ElasticsearchDependencies{Secrets: httpDeps.Secrets}
+	if r := m.walReader(config.QueueElasticsearch); r != nil {
+		elasticsearchDeps.Acks = r
+	}
+	elasticsearchExporter, err := exporter.InitElasticsearchExporter(m.Ctx, cfg, m.ElasticsearchEvents, elasticsearchDeps, m.Wg)
+	if err != nil {
+		m.Logger.Errorf("failed to initialize elasticsearch exporter: %v", err)
+		return
+	}
+	m.exporters = append(m.exporters, elasticsearchExporter)
+
+	apiMetricsExporter, err := exporter.InitAPIMetricsExporter(m.Ctx, cfg, m.MetricsEvents, m.Wg)
+	if err != nil {
+		m.Logger.Errorf("failed to initialize api metrics exporter: %v", err)
+		return
+	}
+	m.exporters = append(m.exporters, apiMetricsExporter)
 
 	m.Wg.Add(1)
 	go func() {
@@ -132,31 +240,208 @@ func (m *Manager) run(cfg *config.Config, kubeConfig string) {
 			m.stopServers()
 			m.Wg.Wait()
 			close(m.ApiEvents)
+			close(m.ProcessedEvents)
 			close(m.GrpcEvents)
 			close(m.HttpEvents)
+			close(m.KafkaEvents)
+			close(m.NATSEvents)
+			close(m.OTLPEvents)
+			close(m.ElasticsearchEvents)
+			close(m.MetricsEvents)
+			close(m.EnvoyMetrics)
 			close(m.configChan)
 			m.Logger.Info("All workers finished. Stopped SentryFlow")
 			return
 
 		case updatedConfig := <-m.configChan:
-			m.receiversCancelFunc()
-			if m.areK8sReceivers(updatedConfig) {
-				k8sClient, err := k8s.NewClient(registerAndGetScheme(), kubeConfig)
-				if err != nil {
-					m.Logger.Errorf("failed to create k8s client: %v", err)
-					return
-				}
-				m.K8sClient = k8sClient
+			// Only the receivers whose configuration changed are restarted or
+			// reconfigured. If the new configuration can't be applied, the
+			// running receivers are kept as they are.
+			if err := m.initK8sClient(updatedConfig, kubeConfig); err != nil {
+				m.Logger.Error(err)
+				continue
 			}
-			m.receiversCtx, m.receiversCancelFunc = m.setupSignalHandler(make(chan os.Signal, 2))
-			if err := receiver.Init(m.receiversCtx, m.K8sClient, updatedConfig, m.Wg, m.receiversLock, m.ApiEvents); err != nil {
-				m.Logger.Errorf("failed to initialize receiver: %v", err)
-				return
+			if err := m.receivers.Apply(updatedConfig, m.receiverDependencies()); err != nil {
+				m.Logger.Errorf("failed to reload receivers: %v", err)
 			}
+			m.initWorkloadCache(updatedConfig, kubeConfig)
+			if err := m.reconfigurePipeline(updatedConfig); err != nil {
+				m.Logger.Errorf("failed to reload pipeline: %v", err)
+			}
+			m.reconfigureExporters(updatedConfig)
+		}
+	}
+}
+
+// initK8sClient creates the Kubernetes client if a receiver in cfg needs one
+// and it hasn't been created yet.
+func (m *Manager) initK8sClient(cfg *config.Config, kubeConfig string) error {
+	if m.K8sClient != nil || !receiver.RequiresK8s(cfg) {
+		return nil
+	}
+	k8sClient, err := k8s.NewClient(registerAndGetScheme(), kubeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %v", err)
+	}
+	m.K8sClient = k8sClient
+	return nil
+}
+
+// initQueues creates the ingest and exporter queues and starts queueing their
+// spilled events again. The exporters reading from the write-ahead log get
+// their events from it instead of a queue. Queues and the write-ahead log are
+// only configured at startup.
+func (m *Manager) initQueues(cfg *config.Config) error {
+	if cfg.WAL != nil && cfg.WAL.Enabled {
+		log, err := wal.Open(cfg.WAL)
+		if err != nil {
+			return fmt.Errorf("failed to open write-ahead log: %w", err)
+		}
+		m.wal = log
+
+		m.Wg.Add(1)
+		go func() {
+			defer m.Wg.Done()
+			log.Run(m.Ctx, m.Logger.Named("wal"))
+		}()
+	}
+
+	queues := []struct {
+		name   string
+		events chan *protobuf.APIEvent
+	}{
+		{name: config.QueueIngest, events: m.ApiEvents},
+		{name: config.QueueGrpc, events: m.GrpcEvents},
+		{name: config.QueueHTTP, events: m.HttpEvents},
+		{name: config.QueueKafka, events: m.KafkaEvents},
+		{name: config.QueueNATS, events: m.NATSEvents},
+		{name: config.QueueOTLP, events: m.OTLPEvents},
+		{name: config.QueueElasticsearch, events: m.ElasticsearchEvents},
+		{name: config.QueueMetrics, events: m.MetricsEvents},
+	}
+	for _, q := range queues {
+		if r := m.walReader(q.name); r != nil {
+			m.Wg.Add(1)
+			go func() {
+				defer m.Wg.Done()
+				r.Run(m.Ctx, q.events, m.Logger.Named("wal"))
+			}()
+			continue
+		}
+
+		created, err := queue.New(q.name, q.events, cfg.Queues.Queue(q.name))
+		if err != nil {
+			return err
+		}
+		if q.name == config.QueueIngest {
+			m.ingest = created
+		} else {
+			m.outputs = append(m.outputs, created)
+		}
+
+		m.Wg.Add(1)
+		go func() {
+			defer m.Wg.Done()
+			created.Run(m.Ctx, m.Logger.Named("queue"))
+		}()
+	}
+	return nil
+}
+
+// walReader returns the reader of the named exporter if it reads from the
+// write-ahead log, or nil.
+func (m *Manager) walReader(name string) *wal.Reader {
+	if m.wal == nil {
+		return nil
+	}
+	return m.wal.Reader(name)
+}
+
+// closeQueues removes the spill files of the queues and closes the write-ahead
+// log.
+func (m *Manager) closeQueues() {
+	if m.wal != nil {
+		if err := m.wal.Close(); err != nil {
+			m.Logger.Errorf("failed to close write-ahead log: %v", err)
+		}
+	}
+	for _, q := range append([]*queue.Queue{m.ingest}, m.outputs...) {
+		if q == nil {
+			continue
+		}
+		if err := q.Close(); err != nil {
+			m.Logger.Errorf("failed to close queue: %v", err)
 		}
 	}
 }
 
+// initWorkloadCache starts watching the Kubernetes workloads if a pipeline
+// processor in cfg enriches events from them and it hasn't been started yet.
+// Outside of Kubernetes, events are only enriched from the configured
+// workloads.
+func (m *Manager) initWorkloadCache(cfg *config.Config, kubeConfig string) {
+	if m.workloads != nil || !cfg.Pipeline.KubernetesEnrichment() {
+		return
+	}
+	clientset, err := k8s.NewClientset(kubeConfig)
+	if err != nil {
+		m.Logger.Warnf("Kubernetes workload enrichment is unavailable: %v", err)
+		return
+	}
+	workloads, err := k8s.NewWorkloadCache(clientset)
+	if err != nil {
+		m.Logger.Warnf("Kubernetes workload enrichment is unavailable: %v", err)
+		return
+	}
+	m.workloads = workloads
+
+	m.Wg.Add(1)
+	go func() {
+		defer m.Wg.Done()
+		if err := workloads.Start(m.Ctx, workloadCacheSyncTimeout); err != nil {
+			m.Logger.Warnf("Kubernetes workloads are only partially known: %v", err)
+			return
+		}
+		m.Logger.Info("Kubernetes workload cache synced")
+	}()
+}
+
+// reconfigureExporters applies cfg to every exporter, waiting for each up to
+// exporterReloadTimeout. An exporter that fails to apply it keeps running with
+// its previous configuration.
+func (m *Manager) reconfigureExporters(cfg *config.Config) {
+	for _, exp := range m.exporters {
+		ctx, cancel := context.WithTimeout(m.Ctx, exporterReloadTimeout)
+		if err := exp.Reconfigure(ctx, cfg); err != nil {
+			m.Logger.Errorf("failed to reload exporter: %v", err)
+		}
+		cancel()
+	}
+}
+
+// reconfigurePipeline replaces the pipeline with the one configured in cfg. If
+// the new pipeline can't be built, the previous one is kept.
+func (m *Manager) reconfigurePipeline(cfg *config.Config) error {
+	deps := pipeline.Dependencies{}
+	if m.workloads != nil {
+		deps.Workloads = m.workloads
+	}
+	p, err := pipeline.New(cfg.Pipeline, deps)
+	if err != nil {
+		return err
+	}
+	m.pipeline.Store(p)
+	return nil
+}
+
+func (m *Manager) receiverDependencies() receiver.Dependencies {
+	return receiver.Dependencies{
+		K8sClient:    m.K8sClient,
+		Lock:         m.receiversLock,
+		EnvoyMetrics: m.EnvoyMetrics,
+	}
+}
+
 func registerAndGetScheme() *runtime.Scheme {
 	scheme := runtime.NewScheme()
 	utilruntime.Must(networkingv1alpha3.AddToScheme(scheme))
@@ -212,27 +497,30 @@ func Run(configFilePath string, kubeConfig string, logger *zap.SugaredLogger) {
 	mgr.run(cfg, kubeConfig)
 }
 
-func fanOutAPIEvents(ctx context.Context, logger *zap.SugaredLogger, in <-chan *protobuf.APIEvent, grpcOut chan<- *protobuf.APIEvent, httpOut chan<- *protobuf.APIEvent) {
+// fanOutAPIEvents pushes every event of in to every output, each of which
+// applies its own backpressure policy when full, and appends it to log unless
+// it's nil.
+func fanOutAPIEvents(ctx context.Context, logger *zap.SugaredLogger, in <-chan *protobuf.APIEvent, outputs []*queue.Queue, log *wal.Log) {
 	stats := &fanoutStats{}
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
+	logStats := func(msg string) {
+		keysAndValues := []interface{}{"in", atomic.LoadUint64(&stats.inCount)}
+		for _, out := range outputs {
+			keysAndValues = append(keysAndValues, out.Name()+"Dropped", out.Dropped())
+		}
+		logger.Infow(msg, keysAndValues...)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Infow("fanout stopped",
-				"in", atomic.LoadUint64(&stats.inCount),
-				"grpcDropped", atomic.LoadUint64(&stats.grpcDrop),
-				"httpDropped", atomic.LoadUint64(&stats.httpDrop),
-			)
+			logStats("fanout stopped")
 			return
 
 		case <-ticker.C:
-			logger.Infow("fanout stats",
-				"in", atomic.LoadUint64(&stats.inCount),
-				"grpcDropped", atomic.LoadUint64(&stats.grpcDrop),
-				"httpDropped", atomic.LoadUint64(&stats.httpDrop),
-			)
+			logStats("fanout stats")
 
 		case ev, ok := <-in:
 			if !ok {
@@ -241,18 +529,14 @@ func fanOutAPIEvents(ctx context.Context, logger *zap.SugaredLogger, in <-chan *
 			}
 			atomic.AddUint64(&stats.inCount, 1)
 
-			// Non-blocking send to gRPC exporter
-			select {
-			case grpcOut <- ev:
-			default:
-				atomic.AddUint64(&stats.grpcDrop, 1)
+			// Dropped events are counted by the queues and the log.
+			for _, out := range outputs {
+				_ = out.Push(ctx, ev)
 			}
-
-			// Non-blocking send to HTTP exporter
-			select {
-			case httpOut <- ev:
-			default:
-				atomic.AddUint64(&stats.httpDrop, 1)
+			if log != nil {
+				if err := log.Append(ev); err != nil {
+					logger.Errorf("Failed to append event to write-ahead log: %v", err)
+				}
 			}
 		}
 	}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/queue"
+)
+
+func Test_fanOutAPIEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	in := make(chan *protobuf.APIEvent)
+	grpcOut := make(chan *protobuf.APIEvent, 1)
+	httpOut := make(chan *protobuf.APIEvent, 2)
+
+	grpcDropped := testutil.ToFloat64(metrics.EventsDropped.WithLabelValues("grpc"))
+	httpDropped := testutil.ToFloat64(metrics.EventsDropped.WithLabelValues("http"))
+
+	queues := &config.QueuesConfig{}
+	grpcQueue, _ := queue.New("grpc", grpcOut, queues.Queue(config.QueueGrpc))
+	httpQueue, _ := queue.New("http", httpOut, queues.Queue(config.QueueHTTP))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fanOutAPIEvents(ctx, zap.S(), in, []*queue.Queue{grpcQueue, httpQueue}, nil)
+	}()
+
+	// Given
+	in <- &protobuf.APIEvent{Metadata: &protobuf.Metadata{ReceiverName: "fanout-test"}}
+	in <- &protobuf.APIEvent{Metadata: &protobuf.Metadata{ReceiverName: "fanout-test"}}
+	in <- &protobuf.APIEvent{}
+
+	// When
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("fanOutAPIEvents() didn't stop")
+	}
+
+	// Then
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "dropped by grpc", got: testutil.ToFloat64(metrics.EventsDropped.WithLabelValues("grpc")) - grpcDropped, want: 2},
+		{name: "dropped by http", got: testutil.ToFloat64(metrics.EventsDropped.WithLabelValues("http")) - httpDropped, want: 1},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("fanOutAPIEvents() %s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
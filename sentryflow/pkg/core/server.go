@@ -13,9 +13,15 @@ import (
 	"time"
 
 	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/queue"
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
+// ingestRetryAfter is the Retry-After header, in seconds, of the events the
+// ingest endpoint rejects because the pipeline is saturated.
+const ingestRetryAfter = "1"
+
 func (m *Manager) startGrpcServer(port uint16) {
 	m.Logger.Info("Starting gRPC server")
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
@@ -35,6 +41,7 @@ func (m *Manager) startHttpServer(port uint16) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", m.healthzHandler)
 	mux.HandleFunc("/api/v1/events", m.eventsHandler)
+	mux.Handle("/metrics", metrics.Handler())
 
 	m.HttpServer = &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
@@ -79,7 +86,18 @@ func (m *Manager) eventsHandler(writer http.ResponseWriter, request *http.Reques
 
 	m.Logger.Debugf("Received API Event from %s. Request Body: %s, Response Body: %s",
 		apiEvent.Metadata.ReceiverName, apiEvent.Request.Body, apiEvent.Response.Body)
-	m.ApiEvents <- apiEvent
+	// The pipeline is saturated, the caller is expected to retry later rather
+	// than wait for room.
+	if err := m.ingest.Push(request.Context(), apiEvent); err != nil {
+		m.Logger.Debugf("Rejected API Event: %v", err)
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, queue.ErrFull) {
+			status = http.StatusTooManyRequests
+		}
+		writer.Header().Set("Retry-After", ingestRetryAfter)
+		http.Error(writer, err.Error(), status)
+		return
+	}
 	writer.WriteHeader(http.StatusAccepted)
 }
 
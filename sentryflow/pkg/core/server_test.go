@@ -14,6 +14,8 @@ import (
 	"go.uber.org/zap"
 
 	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/queue"
 )
 
 func Test_healthzHandler(t *testing.T) {
@@ -212,9 +214,11 @@ func TestManager_eventsHandler(t *testing.T) {
 			// prevent processing stale API events, close the `apiEvent` channel proactively.
 			defer close(tt.fields.ApiEvents)
 
+			ingest, _ := queue.New(config.QueueIngest, tt.fields.ApiEvents, (&config.QueuesConfig{}).Queue(config.QueueIngest))
 			m := &Manager{
 				Logger:    tt.fields.Logger,
 				ApiEvents: tt.fields.ApiEvents,
+				ingest:    ingest,
 			}
 
 			request := httptest.NewRequest(tt.method, "/api/v1/events", bytes.NewReader(tt.body))
@@ -234,6 +238,53 @@ func TestManager_eventsHandler(t *testing.T) {
 	}
 }
 
+func TestManager_eventsHandler_saturated(t *testing.T) {
+	tests := []struct {
+		name           string
+		queue          *config.QueueConfig
+		wantStatusCode int
+	}{
+		{
+			name:           "with dropNewest policy should return StatusTooManyRequests",
+			queue:          &config.QueueConfig{Policy: config.QueuePolicyDropNewest},
+			wantStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:           "with block policy should return StatusServiceUnavailable after the timeout",
+			queue:          &config.QueueConfig{Policy: config.QueuePolicyBlock, Timeout: 10 * time.Millisecond},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given a full ingest queue
+			apiEvents := make(chan *protobuf.APIEvent, 1)
+			apiEvents <- &protobuf.APIEvent{}
+			ingest, err := queue.New(config.QueueIngest, apiEvents, tt.queue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &Manager{Logger: zap.S(), ApiEvents: apiEvents, ingest: ingest}
+
+			// When
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/events", bytes.NewReader(getDummyValidApiEvent()))
+			response := httptest.NewRecorder()
+			m.eventsHandler(response, request)
+
+			// Then
+			if got := response.Code; got != tt.wantStatusCode {
+				t.Errorf("eventsHandler() gotStatusCode = %v, want %v", got, tt.wantStatusCode)
+			}
+			if got := response.Header().Get("Retry-After"); got != ingestRetryAfter {
+				t.Errorf("eventsHandler() Retry-After = %q, want %q", got, ingestRetryAfter)
+			}
+			if len(apiEvents) != 1 {
+				t.Errorf("eventsHandler() queued %d events, want 1", len(apiEvents))
+			}
+		})
+	}
+}
+
 func getDummyInvalidApiEvent() []byte {
 	apiEvent := `
 {
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+// apiCounts counts calls per API over a sliding window. The window is made up
+// of buckets that each span one push interval, the oldest of which is dropped
+// on every rotate. At most maxAPIs distinct APIs are counted over the window,
+// calls to further APIs are counted under otherLabelValue.
+type apiCounts struct {
+	lock    sync.Mutex
+	window  time.Duration
+	buckets []map[string]uint64
+	current int
+	maxAPIs int
+	// apis is the number of buckets counting calls to each API.
+	apis map[string]int
+}
+
+func newAPICounts(window, interval time.Duration, maxAPIs int) *apiCounts {
+	n := 1
+	if interval > 0 && window > interval {
+		n = int((window + interval - 1) / interval)
+	}
+
+	buckets := make([]map[string]uint64, n)
+	for i := range buckets {
+		buckets[i] = make(map[string]uint64)
+	}
+	return &apiCounts{window: window, buckets: buckets, maxAPIs: maxAPIs, apis: make(map[string]int)}
+}
+
+// add counts event towards its API.
+func (c *apiCounts) add(event *protobuf.APIEvent) {
+	if c == nil {
+		return
+	}
+	key := apiKey(event)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	bucket := c.buckets[c.current]
+	if _, counted := bucket[key]; !counted {
+		if _, known := c.apis[key]; !known && len(c.apis) >= c.maxAPIs {
+			key = otherLabelValue
+		}
+		if _, counted := bucket[key]; !counted {
+			c.apis[key]++
+		}
+	}
+	bucket[key]++
+}
+
+// snapshot returns the number of calls per API over the window.
+func (c *apiCounts) snapshot() map[string]uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := make(map[string]uint64)
+	for _, bucket := range c.buckets {
+		for key, count := range bucket {
+			counts[key] += count
+		}
+	}
+	return counts
+}
+
+// rotate starts a new bucket, dropping the counts of the oldest one.
+func (c *apiCounts) rotate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.current = (c.current + 1) % len(c.buckets)
+	for key := range c.buckets[c.current] {
+		if c.apis[key]--; c.apis[key] == 0 {
+			delete(c.apis, key)
+		}
+	}
+	c.buckets[c.current] = make(map[string]uint64)
+}
+
+// apiKey identifies the API called by event as `<method> <destination> <path>`,
+// e.g. `GET backend.default /users`. The destination is the workload name and
+// namespace if known, otherwise its address.
+func apiKey(event *protobuf.APIEvent) string {
+	destination := event.GetDestination()
+
+	var dest string
+	switch {
+	case destination.GetName() != "":
+		dest = destination.GetName() + "." + destination.GetNamespace()
+	case destination.GetPort() != 0:
+		dest = fmt.Sprintf("%s:%d", destination.GetIp(), destination.GetPort())
+	default:
+		dest = destination.GetIp()
+	}
+
+	return fmt.Sprintf("%s %s %s", event.GetRequest().GetHeaders()[":method"], dest, routeOf(event))
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+func Test_apiCounts(t *testing.T) {
+	// Given
+	counts := newAPICounts(3*time.Second, time.Second, 10)
+	users := getAPICountsEvent("GET", "/users?page=2")
+	orders := getAPICountsEvent("POST", "/orders")
+
+	// When
+	counts.add(users)
+	counts.add(orders)
+	counts.rotate()
+	counts.add(users)
+
+	// Then
+	want := map[string]uint64{
+		"GET backend.default /users":   2,
+		"POST backend.default /orders": 1,
+	}
+	if got := counts.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot() = %v, want %v", got, want)
+	}
+
+	t.Run("after window has passed should drop old counts", func(t *testing.T) {
+		counts.rotate()
+		counts.rotate()
+
+		want := map[string]uint64{"GET backend.default /users": 1}
+		if got := counts.snapshot(); !reflect.DeepEqual(got, want) {
+			t.Errorf("snapshot() = %v, want %v", got, want)
+		}
+
+		counts.rotate()
+		if got := counts.snapshot(); len(got) != 0 {
+			t.Errorf("snapshot() = %v, want empty", got)
+		}
+	})
+}
+
+func Test_apiCounts_maxAPIs(t *testing.T) {
+	// Given
+	counts := newAPICounts(2*time.Second, time.Second, 3)
+
+	// When
+	for i := 0; i < 100; i++ {
+		counts.add(getAPICountsEvent("GET", fmt.Sprintf("/users/%d", i)))
+	}
+
+	// Then
+	want := map[string]uint64{
+		"GET backend.default /users/0": 1,
+		"GET backend.default /users/1": 1,
+		"GET backend.default /users/2": 1,
+		"other":                        97,
+	}
+	if got := counts.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot() = %v, want %v", got, want)
+	}
+
+	t.Run("after window has passed should count new APIs", func(t *testing.T) {
+		counts.rotate()
+		counts.rotate()
+		counts.add(getAPICountsEvent("GET", "/orders"))
+
+		want := map[string]uint64{"GET backend.default /orders": 1}
+		if got := counts.snapshot(); !reflect.DeepEqual(got, want) {
+			t.Errorf("snapshot() = %v, want %v", got, want)
+		}
+	})
+}
+
+func Test_newAPICounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   time.Duration
+		interval time.Duration
+		want     int
+	}{
+		{name: "window multiple of interval", window: time.Minute, interval: 10 * time.Second, want: 6},
+		{name: "window not multiple of interval", window: 25 * time.Second, interval: 10 * time.Second, want: 3},
+		{name: "window shorter than interval", window: time.Second, interval: 10 * time.Second, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(newAPICounts(tt.window, tt.interval, 10).buckets); got != tt.want {
+				t.Errorf("newAPICounts() got %d buckets, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_apiKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *protobuf.APIEvent
+		want  string
+	}{
+		{
+			name:  "with destination workload should use its name and namespace",
+			event: getAPICountsEvent("GET", "/users/1?verbose=true"),
+			want:  "GET backend.default /users/1",
+		},
+		{
+			name: "without destination workload should use its address",
+			event: &protobuf.APIEvent{
+				Destination: &protobuf.Workload{Ip: "10.0.0.1", Port: 8080},
+				Request:     &protobuf.Request{Headers: map[string]string{":method": "GET", ":path": "/"}},
+			},
+			want: "GET 10.0.0.1:8080 /",
+		},
+		{
+			name: "with route should use it instead of the path",
+			event: &protobuf.APIEvent{
+				Destination: &protobuf.Workload{Name: "backend", Namespace: "default"},
+				Request: &protobuf.Request{
+					Headers: map[string]string{":method": "GET", ":path": "/users/1"},
+					Route:   "/users/{id}",
+				},
+			},
+			want: "GET backend.default /users/{id}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiKey(tt.event); got != tt.want {
+				t.Errorf("apiKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func getAPICountsEvent(method, path string) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Destination: &protobuf.Workload{Name: "backend", Namespace: "default"},
+		Request: &protobuf.Request{
+			Headers: map[string]string{":method": method, ":path": path},
+		},
+	}
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// otherLabelValue replaces label values beyond a label's cardinality limit.
+const otherLabelValue = "other"
+
+// APIMetricsExporter turns API events into request count and latency metrics
+// served on SentryFlow's metrics endpoint as metrics.APITraffic.
+type APIMetricsExporter struct {
+	logger *zap.SugaredLogger
+	events chan *protobuf.APIEvent
+
+	lock    sync.RWMutex
+	current *apiMetrics
+}
+
+// apiMetrics holds the metric vectors for one set of labels. It's replaced as a
+// whole when the labels or their limits change.
+type apiMetrics struct {
+	labels   []string
+	limiter  *cardinalityLimiter
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// InitAPIMetricsExporter starts the API metrics exporter. The exporter consumes
+// events even when it's disabled so that it can be enabled later with
+// Reconfigure.
+func InitAPIMetricsExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, wg *sync.WaitGroup) (*APIMetricsExporter, error) {
+	logger := util.LoggerFromCtx(ctx).Named("api-metrics-exporter")
+
+	e := &APIMetricsExporter{
+		logger:  logger,
+		events:  events,
+		current: newAPIMetrics(cfg),
+	}
+	metrics.APITraffic.Set(e.current.collector())
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.run(ctx)
+	}()
+
+	logger.Info("API metrics exporter started")
+	return e, nil
+}
+
+// Reconfigure switches to the labels and limits in cfg. Since the label set of
+// a series can't change, all API metrics start over from zero.
+func (e *APIMetricsExporter) Reconfigure(_ context.Context, cfg *config.Config) error {
+	next := newAPIMetrics(cfg)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if sameAPIMetrics(e.current, next) {
+		return nil
+	}
+	e.current = next
+	metrics.APITraffic.Set(next.collector())
+	e.logger.Info("API metrics exporter reconfigured")
+	return nil
+}
+
+func (e *APIMetricsExporter) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("API metrics exporter context cancelled")
+			return
+
+		case ev, ok := <-e.events:
+			if !ok {
+				e.logger.Warn("API metrics exporter channel closed")
+				return
+			}
+			e.observe(ev)
+		}
+	}
+}
+
+func (e *APIMetricsExporter) observe(event *protobuf.APIEvent) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	m := e.current
+	if m == nil {
+		return
+	}
+
+	values := make([]string, len(m.labels), len(m.labels)+1)
+	for i, label := range m.labels {
+		values[i] = m.limiter.value(label, apiMetricsLabelValue(event, label))
+	}
+	status := statusLabel(event.GetResponse().GetHeaders()[":status"])
+
+	// A sampled event stands for 1/rate requests.
+	weight := 1.0
+	if rate := event.GetMetadata().GetSampling().GetRate(); rate > 0 {
+		weight = 1 / rate
+	}
+	m.requests.WithLabelValues(append(values, status)...).Add(weight)
+	if latency := event.GetResponse().GetBackendLatencyInNanos(); latency > 0 {
+		m.duration.WithLabelValues(values...).Observe(time.Duration(latency).Seconds())
+	}
+}
+
+// collector returns m as a collector, or nil if m is nil so that nothing is
+// collected while API metrics are disabled.
+func (m *apiMetrics) collector() prometheus.Collector {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
+func (m *apiMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.duration.Describe(ch)
+}
+
+func (m *apiMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.duration.Collect(ch)
+}
+
+// newAPIMetrics returns the metric vectors for cfg, or nil if API metrics are
+// disabled.
+func newAPIMetrics(cfg *config.Config) *apiMetrics {
+	if cfg.Exporter == nil || cfg.Exporter.ApiMetrics == nil || !cfg.Exporter.ApiMetrics.Enabled {
+		return nil
+	}
+	apiCfg := cfg.Exporter.ApiMetrics
+
+	limits := make(map[string]int, len(apiCfg.Labels))
+	for _, label := range apiCfg.Labels {
+		limits[label] = apiCfg.LabelLimit(label)
+	}
+
+	return &apiMetrics{
+		labels:  apiCfg.Labels,
+		limiter: newCardinalityLimiter(limits),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sentryflow",
+			Subsystem: "api",
+			Name:      "requests_total",
+			Help:      "Number of API requests captured, by response status.",
+		}, append(append([]string{}, apiCfg.Labels...), "status")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sentryflow",
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Backend latency of API requests captured, in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, apiCfg.Labels),
+	}
+}
+
+func sameAPIMetrics(a, b *apiMetrics) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return slices.Equal(a.labels, b.labels) && maps.Equal(a.limiter.limits, b.limiter.limits)
+}
+
+func apiMetricsLabelValue(event *protobuf.APIEvent, label string) string {
+	switch label {
+	case config.ApiMetricsLabelSourceWorkload:
+		return event.GetSource().GetName()
+	case config.ApiMetricsLabelSourceNamespace:
+		return event.GetSource().GetNamespace()
+	case config.ApiMetricsLabelDestinationWorkload:
+		return event.GetDestination().GetName()
+	case config.ApiMetricsLabelDestinationNamespace:
+		return event.GetDestination().GetNamespace()
+	case config.ApiMetricsLabelMethod:
+		return event.GetRequest().GetHeaders()[":method"]
+	case config.ApiMetricsLabelPath:
+		return routeOf(event)
+	}
+	return ""
+}
+
+// statusLabel returns the status label for an HTTP status code, which is
+// `unknown` unless it's made of 3 digits so that the label stays bounded.
+func statusLabel(status string) string {
+	if len(status) != 3 {
+		return "unknown"
+	}
+	for _, c := range status {
+		if c < '0' || c > '9' {
+			return "unknown"
+		}
+	}
+	return status
+}
+
+// routeOf returns the route of event's request if path normalization set one,
+// or its path without the query string otherwise.
+func routeOf(event *protobuf.APIEvent) string {
+	if route := event.GetRequest().GetRoute(); route != "" {
+		return route
+	}
+	return util.TrimQuery(event.GetRequest().GetHeaders()[":path"])
+}
+
+// cardinalityLimiter bounds the number of distinct values of each label. Once
+// a label has reached its limit, values it hasn't seen before are replaced with
+// otherLabelValue.
+type cardinalityLimiter struct {
+	limits map[string]int
+	lock   sync.Mutex
+	seen   map[string]map[string]struct{}
+}
+
+func newCardinalityLimiter(limits map[string]int) *cardinalityLimiter {
+	seen := make(map[string]map[string]struct{}, len(limits))
+	for label := range limits {
+		seen[label] = make(map[string]struct{})
+	}
+	return &cardinalityLimiter{limits: limits, seen: seen}
+}
+
+func (c *cardinalityLimiter) value(label, value string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	values := c.seen[label]
+	if _, exists := values[value]; exists {
+		return value
+	}
+	if len(values) >= c.limits[label] {
+		return otherLabelValue
+	}
+	values[value] = struct{}{}
+	return value
+}
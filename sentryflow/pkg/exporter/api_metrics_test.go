@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func TestAPIMetricsExporter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, util.LoggerContextKey{}, zap.NewNop().Sugar())
+
+	events := make(chan *protobuf.APIEvent)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	cfg := getAPIMetricsConfig(&config.ApiMetricsConfig{
+		Enabled:        true,
+		Labels:         []string{config.ApiMetricsLabelDestinationWorkload, config.ApiMetricsLabelMethod, config.ApiMetricsLabelPath},
+		MaxLabelValues: 10,
+		LabelLimits:    map[string]int{config.ApiMetricsLabelPath: 2},
+	})
+	exp, err := InitAPIMetricsExporter(ctx, cfg, events, &wg)
+	if err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	t.Run("should count requests by status and observe latency", func(t *testing.T) {
+		// Given
+		events <- getAPIMetricsEvent("GET", "/users?id=1", "200", 50*time.Millisecond)
+		events <- getAPIMetricsEvent("GET", "/users", "500", 0)
+		events <- getAPIMetricsEvent("POST", "/orders", "201", 2*time.Second)
+
+		// When
+		events <- getAPIMetricsEvent("GET", "/users/1", "404", 0)
+
+		// Then
+		want := `
+# HELP sentryflow_api_requests_total Number of API requests captured, by response status.
+# TYPE sentryflow_api_requests_total counter
+sentryflow_api_requests_total{destination_workload="backend",method="GET",path="/users",status="200"} 1
+sentryflow_api_requests_total{destination_workload="backend",method="GET",path="/users",status="500"} 1
+sentryflow_api_requests_total{destination_workload="backend",method="GET",path="other",status="404"} 1
+sentryflow_api_requests_total{destination_workload="backend",method="POST",path="/orders",status="201"} 1
+`
+		waitForMetrics(t, want, "sentryflow_api_requests_total")
+
+		if got := testutil.CollectAndCount(metrics.APITraffic, "sentryflow_api_request_duration_seconds"); got != 2 {
+			t.Errorf("API metrics got %d latency series, want 2", got)
+		}
+	})
+
+	t.Run("with changed labels should start over with new labels", func(t *testing.T) {
+		// Given
+		cfg := getAPIMetricsConfig(&config.ApiMetricsConfig{
+			Enabled:        true,
+			Labels:         []string{config.ApiMetricsLabelMethod},
+			MaxLabelValues: 10,
+		})
+
+		// When
+		if err := exp.Reconfigure(ctx, cfg); err != nil {
+			t.Fatalf("Reconfigure() error = %v, wantErr = nil", err)
+		}
+		events <- getAPIMetricsEvent("DELETE", "/users/1", "204", 0)
+
+		// Then
+		want := `
+# HELP sentryflow_api_requests_total Number of API requests captured, by response status.
+# TYPE sentryflow_api_requests_total counter
+sentryflow_api_requests_total{method="DELETE",status="204"} 1
+`
+		waitForMetrics(t, want, "sentryflow_api_requests_total")
+	})
+
+	t.Run("with sampled event should count it by its rate", func(t *testing.T) {
+		// Given
+		event := getAPIMetricsEvent("PATCH", "/users/1", "200", 0)
+		event.Metadata = &protobuf.Metadata{Sampling: &protobuf.SamplingDecision{Decision: "sampled", Rate: 0.25}}
+
+		// When
+		events <- event
+
+		// Then
+		want := `
+# HELP sentryflow_api_requests_total Number of API requests captured, by response status.
+# TYPE sentryflow_api_requests_total counter
+sentryflow_api_requests_total{method="DELETE",status="204"} 1
+sentryflow_api_requests_total{method="PATCH",status="200"} 4
+`
+		waitForMetrics(t, want, "sentryflow_api_requests_total")
+	})
+
+	t.Run("when disabled should collect nothing", func(t *testing.T) {
+		if err := exp.Reconfigure(ctx, getAPIMetricsConfig(nil)); err != nil {
+			t.Fatalf("Reconfigure() error = %v, wantErr = nil", err)
+		}
+		events <- getAPIMetricsEvent("GET", "/users", "200", 0)
+
+		if got := testutil.CollectAndCount(metrics.APITraffic); got != 0 {
+			t.Errorf("API metrics got %d series, want 0", got)
+		}
+	})
+}
+
+func Test_cardinalityLimiter(t *testing.T) {
+	limiter := newCardinalityLimiter(map[string]int{"path": 2})
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "/a", want: "/a"},
+		{value: "/b", want: "/b"},
+		{value: "/c", want: otherLabelValue},
+		{value: "/a", want: "/a"},
+	}
+	for _, tt := range tests {
+		if got := limiter.value("path", tt.value); got != tt.want {
+			t.Errorf("value(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func Test_statusLabel(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{status: "200", want: "200"},
+		{status: "503", want: "503"},
+		{status: "", want: "unknown"},
+		{status: "20", want: "unknown"},
+		{status: "2000", want: "unknown"},
+		{status: "OK!", want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := statusLabel(tt.status); got != tt.want {
+			t.Errorf("statusLabel(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func waitForMetrics(t *testing.T, want string, names ...string) {
+	t.Helper()
+
+	var err error
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if err = testutil.CollectAndCompare(metrics.APITraffic, strings.NewReader(want), names...); err == nil {
+			return
+		}
+	}
+	t.Errorf("unexpected metrics: %v", err)
+}
+
+func getAPIMetricsConfig(apiMetrics *config.ApiMetricsConfig) *config.Config {
+	return &config.Config{
+		Exporter: &config.ExporterConfig{
+			ApiMetrics: apiMetrics,
+		},
+	}
+}
+
+func getAPIMetricsEvent(method, path, status string, latency time.Duration) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Source:      &protobuf.Workload{Name: "frontend", Namespace: "default"},
+		Destination: &protobuf.Workload{Name: "backend", Namespace: "default"},
+		Request: &protobuf.Request{
+			Headers: map[string]string{":method": method, ":path": path},
+		},
+		Response: &protobuf.Response{
+			Headers:               map[string]string{":status": status},
+			BackendLatencyInNanos: uint64(latency.Nanoseconds()),
+		},
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// auth authenticates the requests to a webhook by signing them and with OAuth2
+// access tokens, as configured. A nil auth leaves requests as they are.
+type auth struct {
+	signing *config.WebhookSigningConfig
+	key     []byte
+	tokens  *tokenCache
+	now     func() time.Time
+}
+
+// newAuth returns the auth of cfg, reading its secrets with secrets. It
+// returns nil if cfg doesn't authenticate requests.
+func newAuth(ctx context.Context, cfg config.WebhookConfig, client *http.Client, secrets SecretReader) (*auth, error) {
+	if cfg.Signing == nil && cfg.OAuth2 == nil {
+		return nil, nil
+	}
+
+	a := &auth{signing: cfg.Signing, now: time.Now}
+	if cfg.Signing != nil {
+		key, err := readSecret(ctx, cfg.Signing.Secret, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook %s signing secret: %w", cfg.Name, err)
+		}
+		a.key = key
+	}
+	if cfg.OAuth2 != nil {
+		clientSecret, err := readSecret(ctx, cfg.OAuth2.ClientSecret, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook %s oauth2 client secret: %w", cfg.Name, err)
+		}
+		params := url.Values{}
+		for k, v := range cfg.OAuth2.EndpointParams {
+			params.Set(k, v)
+		}
+		a.tokens = &tokenCache{
+			cfg: &clientcredentials.Config{
+				ClientID:       cfg.OAuth2.ClientID,
+				ClientSecret:   string(clientSecret),
+				TokenURL:       cfg.OAuth2.TokenURL,
+				Scopes:         cfg.OAuth2.Scopes,
+				EndpointParams: params,
+			},
+			client: client,
+		}
+	}
+	return a, nil
+}
+
+// apply sets the headers authenticating req, whose body is body.
+func (a *auth) apply(ctx context.Context, req *http.Request, body []byte) error {
+	if a == nil {
+		return nil
+	}
+
+	if a.signing != nil {
+		timestamp := strconv.FormatInt(a.now().Unix(), 10)
+		req.Header.Set(a.signing.TimestampHeader, timestamp)
+		req.Header.Set(a.signing.SignatureHeader, "sha256="+sign(a.key, timestamp, body))
+	}
+	if a.tokens != nil {
+		token, err := a.tokens.get(ctx)
+		if err != nil {
+			return err
+		}
+		token.SetAuthHeader(req)
+	}
+	return nil
+}
+
+// rejected is called when the webhook rejected the credentials of a request.
+// It returns whether the request may succeed with new credentials.
+func (a *auth) rejected() bool {
+	if a == nil || a.tokens == nil {
+		return false
+	}
+	a.tokens.invalidate()
+	return true
+}
+
+// sign returns the hex encoded HMAC-SHA256 of timestamp and body with key.
+func sign(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tokenCache caches the access token of a client credentials grant until it
+// expires or is invalidated.
+type tokenCache struct {
+	cfg    *clientcredentials.Config
+	client *http.Client
+
+	lock  sync.Mutex
+	token *oauth2.Token
+}
+
+func (c *tokenCache) get(ctx context.Context) (*oauth2.Token, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.token.Valid() {
+		return c.token, nil
+	}
+	token, err := c.cfg.Token(context.WithValue(ctx, oauth2.HTTPClient, c.client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+	c.token = token
+	return token, nil
+}
+
+func (c *tokenCache) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.token = nil
+}
+
+// readSecret returns the value of cfg, without surrounding whitespace.
+func readSecret(ctx context.Context, cfg config.SecretConfig, secrets SecretReader) ([]byte, error) {
+	var value []byte
+	var err error
+	switch {
+	case cfg.File != "":
+		value, err = os.ReadFile(cfg.File)
+	case cfg.SecretRef != nil && secrets != nil:
+		value, err = secrets.Read(ctx, cfg.SecretRef.Namespace, cfg.SecretRef.Name, cfg.SecretRef.Key)
+	case cfg.SecretRef != nil:
+		return nil, fmt.Errorf("kubernetes secrets are unavailable")
+	default:
+		return nil, fmt.Errorf("no secret file or secretRef provided")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(value), nil
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestWebhook_signing(t *testing.T) {
+	key := "signing-key"
+	verified := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(config.DefaultWebhookTimestampHeader)
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+		sent, _ := strconv.ParseInt(timestamp, 10, 64)
+		verified <- r.Header.Get(config.DefaultWebhookSignatureHeader) == want && time.Since(time.Unix(sent, 0)) < time.Minute
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Given a secret file ending with a newline
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.WebhookConfig{
+		Name:   "signing-test",
+		URL:    server.URL,
+		Method: http.MethodPost,
+		Signing: &config.WebhookSigningConfig{
+			Secret:          config.SecretConfig{File: path},
+			SignatureHeader: config.DefaultWebhookSignatureHeader,
+			TimestampHeader: config.DefaultWebhookTimestampHeader,
+		},
+	}
+	w := authWebhook(t, cfg, nil)
+
+	// When
+	if !w.deliver(context.Background(), testBatch(t, w, &protobuf.APIEvent{})) {
+		t.Fatal("deliver() = false, want true")
+	}
+
+	// Then
+	if !<-verified {
+		t.Error("webhook couldn't verify the request signature")
+	}
+}
+
+func TestWebhook_oauth2(t *testing.T) {
+	var tokens atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		id, secret, _ := r.BasicAuth()
+		if r.Form.Get("grant_type") != "client_credentials" || id != "sentryflow" || secret != "client-secret" ||
+			r.Form.Get("audience") != "webhooks" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, tokens.Add(1))
+	}))
+	defer tokenServer.Close()
+
+	// Given a webhook that revokes the first token after a call
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 && r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.WebhookConfig{
+		Name:   "oauth2-test",
+		URL:    server.URL,
+		Method: http.MethodPost,
+		OAuth2: &config.WebhookOAuth2Config{
+			TokenURL:       tokenServer.URL,
+			ClientID:       "sentryflow",
+			ClientSecret:   config.SecretConfig{SecretRef: &config.SecretKeyConfig{Namespace: "sentryflow", Name: "webhook", Key: "clientSecret"}},
+			EndpointParams: map[string]string{"audience": "webhooks"},
+		},
+	}
+	w := authWebhook(t, cfg, secretMap{"sentryflow/webhook/clientSecret": "client-secret"})
+
+	// When
+	for i := 0; i < 3; i++ {
+		if !w.deliver(context.Background(), testBatch(t, w, &protobuf.APIEvent{})) {
+			t.Fatal("deliver() = false, want true")
+		}
+	}
+
+	// Then the token is cached, and renewed once rejected
+	if got := tokens.Load(); got != 2 {
+		t.Errorf("%d tokens were requested, want 2", got)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("webhook was called %d times, want 4", got)
+	}
+}
+
+func Test_readSecret(t *testing.T) {
+	secrets := secretMap{"sentryflow/webhook/key": " value\n"}
+	tests := []struct {
+		name               string
+		cfg                config.SecretConfig
+		secrets            SecretReader
+		want               string
+		expectedErrMessage string
+	}{
+		{
+			name:    "with secretRef should read the Kubernetes Secret",
+			cfg:     config.SecretConfig{SecretRef: &config.SecretKeyConfig{Namespace: "sentryflow", Name: "webhook", Key: "key"}},
+			secrets: secrets,
+			want:    "value",
+		},
+		{
+			name:               "with secretRef without Kubernetes should return error",
+			cfg:                config.SecretConfig{SecretRef: &config.SecretKeyConfig{Namespace: "sentryflow", Name: "webhook", Key: "key"}},
+			expectedErrMessage: "kubernetes secrets are unavailable",
+		},
+		{
+			name:               "with missing key should return error",
+			cfg:                config.SecretConfig{SecretRef: &config.SecretKeyConfig{Namespace: "sentryflow", Name: "webhook", Key: "other"}},
+			secrets:            secrets,
+			expectedErrMessage: "secret sentryflow/webhook has no other key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSecret(context.Background(), tt.cfg, tt.secrets)
+			if tt.expectedErrMessage != "" {
+				if err == nil || err.Error() != tt.expectedErrMessage {
+					t.Errorf("readSecret() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("readSecret() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// secretMap is a SecretReader of the values of `namespace/name/key`s.
+type secretMap map[string]string
+
+func (s secretMap) Read(_ context.Context, namespace, name, key string) ([]byte, error) {
+	value, exists := s[namespace+"/"+name+"/"+key]
+	if !exists {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", namespace, name, key)
+	}
+	return []byte(value), nil
+}
+
+func authWebhook(t *testing.T, cfg config.WebhookConfig, secrets SecretReader) *webhook {
+	t.Helper()
+	client := &http.Client{Timeout: 2 * time.Second}
+	a, err := newAuth(context.Background(), cfg, client, secrets)
+	if err != nil {
+		t.Fatalf("newAuth() error = %v", err)
+	}
+	w, err := newWebhook(cfg, client, &config.HttpConfig{Retry: &config.WebhookRetryConfig{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}}, a, nil, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("newWebhook() error = %v", err)
+	}
+	return w
+}
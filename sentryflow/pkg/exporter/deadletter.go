@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// deadLetters appends the events that couldn't be delivered to a webhook to a
+// file, one JSON object per line. A nil deadLetters drops them.
+type deadLetters struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// deadLetter is a line of the dead-letter file.
+type deadLetter struct {
+	Time     time.Time       `json:"time"`
+	Webhook  string          `json:"webhook"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Event    json.RawMessage `json:"event"`
+}
+
+func openDeadLetters(cfg *config.DeadLetterConfig) (*deadLetters, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetters{file: file}, nil
+}
+
+func (d *deadLetters) write(webhook string, event *protobuf.APIEvent, attempts int, cause error) error {
+	if d == nil {
+		return nil
+	}
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(deadLetter{
+		Time:     time.Now().UTC(),
+		Webhook:  webhook,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Event:    data,
+	})
+	if err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, err = d.file.Write(append(line, '\n'))
+	return err
+}
+
+func (d *deadLetters) close() error {
+	if d == nil {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.file.Close()
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// elasticsearchItem is an event and the lines of its bulk request action,
+// i.e. the action and the document.
+type elasticsearchItem struct {
+	event *protobuf.APIEvent
+	lines []byte
+}
+
+// newElasticsearchItem returns the item indexing doc, the document of event,
+// into the index of the event's day.
+func newElasticsearchItem(event *protobuf.APIEvent, doc *protobuf.APIEvent, cfg *config.ElasticsearchConfig) (*elasticsearchItem, error) {
+	timestamp := eventTime(event)
+	source, err := elasticsearchDocument(doc, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	action, err := json.Marshal(map[string]any{
+		"index": map[string]string{"_index": elasticsearchIndexName(cfg, timestamp)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]byte, 0, len(action)+len(source)+2)
+	lines = append(lines, action...)
+	lines = append(lines, '\n')
+	lines = append(lines, source...)
+	lines = append(lines, '\n')
+	return &elasticsearchItem{event: event, lines: lines}, nil
+}
+
+// elasticsearchIndexName returns the index of the events of the day of
+// timestamp, in UTC.
+func elasticsearchIndexName(cfg *config.ElasticsearchConfig, timestamp time.Time) string {
+	return cfg.Index + "-" + timestamp.UTC().Format(cfg.IndexDateFormat)
+}
+
+// elasticsearchDocument returns the protojson encoded event with an
+// `@timestamp` field, on a single line.
+func elasticsearchDocument(event *protobuf.APIEvent, timestamp time.Time) ([]byte, error) {
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	fields["@timestamp"], _ = json.Marshal(timestamp.UTC().Format(time.RFC3339Nano))
+	return json.Marshal(fields)
+}
+
+// eventTime returns when event was captured, or now if it doesn't say.
+func eventTime(event *protobuf.APIEvent) time.Time {
+	if timestamp := event.GetMetadata().GetTimestamp(); timestamp > 0 {
+		return time.Unix(int64(timestamp), 0)
+	}
+	return time.Now()
+}
+
+// elasticsearchTemplate returns the index template of the indices of cfg,
+// mapping the fields of the protojson encoded API events.
+func elasticsearchTemplate(cfg *config.ElasticsearchConfig) map[string]any {
+	keyword := map[string]any{"type": "keyword"}
+	// Headers and labels have arbitrary keys, which must not add fields to
+	// the mapping. OpenSearch calls flattened objects flat objects.
+	flattened := map[string]any{"type": "flattened"}
+	if cfg.Distribution == config.ElasticsearchDistributionOpenSearch {
+		flattened = map[string]any{"type": "flat_object"}
+	}
+	// Bodies are only kept in the documents' source unless they're indexed.
+	body := map[string]any{"type": "text", "index": cfg.Template.IndexBodies}
+	workload := map[string]any{
+		"properties": map[string]any{
+			"name":      keyword,
+			"namespace": keyword,
+			"ip":        map[string]any{"type": "ip", "ignore_malformed": true},
+			"port":      map[string]any{"type": "integer"},
+			"labels":    flattened,
+			"kind":      keyword,
+			"nodeName":  keyword,
+		},
+	}
+
+	settings := map[string]any{}
+	if cfg.Template.Shards > 0 {
+		settings["number_of_shards"] = cfg.Template.Shards
+	}
+	if cfg.Template.Replicas > 0 {
+		settings["number_of_replicas"] = cfg.Template.Replicas
+	}
+	// OpenSearch's ISM policies select their indices themselves.
+	if cfg.Lifecycle != nil && cfg.Distribution == config.ElasticsearchDistributionElasticsearch {
+		settings["index.lifecycle.name"] = cfg.Lifecycle.Name
+	}
+
+	return map[string]any{
+		"index_patterns": []string{cfg.Index + "-*"},
+		"priority":       100,
+		"template": map[string]any{
+			"settings": settings,
+			"mappings": map[string]any{
+				"properties": map[string]any{
+					"@timestamp": map[string]any{"type": "date"},
+					"metadata": map[string]any{
+						"properties": map[string]any{
+							"contextId":       map[string]any{"type": "long"},
+							"timestamp":       map[string]any{"type": "long"},
+							"meshId":          keyword,
+							"nodeName":        keyword,
+							"receiverName":    keyword,
+							"receiverVersion": keyword,
+							"sampling": map[string]any{
+								"properties": map[string]any{
+									"decision": keyword,
+									"rule":     keyword,
+									"rate":     map[string]any{"type": "double"},
+								},
+							},
+						},
+					},
+					"source":      workload,
+					"destination": workload,
+					"request": map[string]any{
+						"properties": map[string]any{
+							"headers": flattened,
+							"body":    body,
+							"route":   keyword,
+						},
+					},
+					"response": map[string]any{
+						"properties": map[string]any{
+							"headers":               flattened,
+							"body":                  body,
+							"backendLatencyInNanos": map[string]any{"type": "long"},
+						},
+					},
+					"protocol": keyword,
+				},
+			},
+		},
+		"_meta": map[string]any{"managed_by": "sentryflow"},
+	}
+}
+
+// elasticsearchILMPolicy returns the ILM policy deleting the indices once
+// they're older than the lifecycle's deleteAfter.
+func elasticsearchILMPolicy(l *config.ElasticsearchLifecycleConfig) map[string]any {
+	return map[string]any{
+		"policy": map[string]any{
+			"phases": map[string]any{
+				"delete": map[string]any{
+					"min_age": elasticsearchDuration(l.DeleteAfter),
+					"actions": map[string]any{"delete": map[string]any{}},
+				},
+			},
+			"_meta": map[string]any{"managed_by": "sentryflow"},
+		},
+	}
+}
+
+// opensearchISMPolicy returns the ISM policy applied to the indices of cfg,
+// deleting them once they're older than the lifecycle's deleteAfter.
+func opensearchISMPolicy(cfg *config.ElasticsearchConfig) map[string]any {
+	return map[string]any{
+		"policy": map[string]any{
+			"description":   "Deletes the API events indices of SentryFlow",
+			"default_state": "hot",
+			"states": []any{
+				map[string]any{
+					"name":    "hot",
+					"actions": []any{},
+					"transitions": []any{
+						map[string]any{
+							"state_name": "delete",
+							"conditions": map[string]any{"min_index_age": elasticsearchDuration(cfg.Lifecycle.DeleteAfter)},
+						},
+					},
+				},
+				map[string]any{
+					"name":        "delete",
+					"actions":     []any{map[string]any{"delete": map[string]any{}}},
+					"transitions": []any{},
+				},
+			},
+			"ism_template": []any{
+				map[string]any{"index_patterns": []string{cfg.Index + "-*"}, "priority": 100},
+			},
+		},
+	}
+}
+
+// elasticsearchDuration returns d in the time units of Elasticsearch and
+// OpenSearch.
+func elasticsearchDuration(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
@@ -0,0 +1,437 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// elasticsearchFlushTimeout is how long the events left are waited for to be
+// written when the exporter stops.
+const elasticsearchFlushTimeout = 10 * time.Second
+
+// ElasticsearchExporter writes API events to Elasticsearch or OpenSearch with
+// the bulk API, in batches. Its configuration can be replaced at runtime with
+// Reconfigure.
+type ElasticsearchExporter struct {
+	reloadable[*elasticsearchSender]
+
+	// The batch is only accessed by the run goroutine.
+	pending      []*elasticsearchItem
+	pendingBytes int
+}
+
+// elasticsearchSender is the client events are written with. Its client is
+// nil while the exporter is disabled.
+type elasticsearchSender struct {
+	client   *elasticsearchClient
+	cfg      *config.ElasticsearchConfig
+	match    *match.Matcher
+	redactor *redact.Redactor
+
+	// bootstrapped is set once the index template and lifecycle policy are
+	// installed.
+	bootstrapped bool
+}
+
+// ElasticsearchDependencies holds the resources the Elasticsearch exporter
+// uses.
+type ElasticsearchDependencies struct {
+	// Acks, if set, is told about every event once it was written or given
+	// up on.
+	Acks Acknowledger
+	// Secrets reads the Kubernetes Secrets the password or API key refers to,
+	// if set.
+	Secrets SecretReader
+}
+
+// InitElasticsearchExporter starts the Elasticsearch exporter. The index
+// template and lifecycle policy are installed again whenever its configuration
+// changes.
+func InitElasticsearchExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, deps ElasticsearchDependencies, wg *sync.WaitGroup) (*ElasticsearchExporter, error) {
+	exp := &ElasticsearchExporter{}
+	exp.reloadable = reloadable[*elasticsearchSender]{
+		name:         "Elasticsearch",
+		logger:       util.LoggerFromCtx(ctx).Named("elasticsearch-exporter"),
+		events:       events,
+		acks:         deps.Acks,
+		flushTimeout: elasticsearchFlushTimeout,
+		configOf: func(cfg *config.ExporterConfig) any {
+			return cfg.Elasticsearch
+		},
+		newDestination: func(ctx context.Context, cfg *config.Config) (*elasticsearchSender, error) {
+			return newElasticsearchSender(ctx, cfg, deps.Secrets)
+		},
+		send:  exp.batchEvent,
+		flush: exp.writeBatch,
+	}
+	if err := exp.start(ctx, cfg, wg); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+// batchEvent adds the document of event to the batch, and writes the batch
+// once it's full.
+func (e *ElasticsearchExporter) batchEvent(ctx context.Context, s *elasticsearchSender, event *protobuf.APIEvent) {
+	item, err := newElasticsearchItem(event, redacted(s.redactor, event), s.cfg)
+	if err != nil {
+		e.logger.Warnf("Failed to encode API event: %v", err)
+		metrics.ElasticsearchDocuments.WithLabelValues("failed").Inc()
+		e.ack(event)
+		return
+	}
+	// The batch is written before it grows beyond maxBatchBytes, unless it's
+	// a single document.
+	if len(e.pending) > 0 && e.pendingBytes+len(item.lines) > s.cfg.MaxBatchBytes {
+		e.flushBatch(ctx)
+	}
+	if len(e.pending) == 0 && s.cfg.MaxBatchSize > 1 {
+		e.startBatch(s.cfg.BatchTimeout)
+	}
+	e.pending = append(e.pending, item)
+	e.pendingBytes += len(item.lines)
+	if len(e.pending) >= s.cfg.MaxBatchSize {
+		e.flushBatch(ctx)
+	}
+}
+
+// writeBatch writes the batch of events with s, and acknowledges them once
+// they were written or given up on. The events are left unacknowledged if ctx
+// is done before, so that they're written again after a restart.
+func (e *ElasticsearchExporter) writeBatch(ctx context.Context, s *elasticsearchSender) {
+	if len(e.pending) == 0 {
+		return
+	}
+	if s.write(ctx, e.logger, e.pending) {
+		for _, item := range e.pending {
+			e.ack(item.event)
+		}
+	}
+	e.pending, e.pendingBytes = nil, 0
+}
+
+// write writes items with bulk requests. Requests that failed with a
+// retryable error are retried, as are the items rejected with 429 Too Many
+// Requests. It returns false if ctx is done before the items were written or
+// given up on.
+func (s *elasticsearchSender) write(ctx context.Context, logger *zap.SugaredLogger, items []*elasticsearchItem) bool {
+	start := time.Now()
+	defer func() {
+		metrics.ElasticsearchBulkDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		rejected, err := s.bulk(callCtx, logger, items)
+		cancel()
+		if err == nil && len(rejected) == 0 {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		var retryAfter time.Duration
+		if err != nil {
+			var callErr *callError
+			if !errors.As(err, &callErr) || !callErr.retryable || attempt >= s.cfg.Retry.MaxAttempts {
+				logger.Warnw("Failed to write to Elasticsearch", "documents", len(items), "attempts", attempt, "error", err)
+				metrics.ElasticsearchDocuments.WithLabelValues("failed").Add(float64(len(items)))
+				return true
+			}
+			retryAfter = callErr.retryAfter
+		} else {
+			if attempt >= s.cfg.Retry.MaxAttempts {
+				logger.Warnw("Elasticsearch kept rejecting documents", "documents", len(rejected), "attempts", attempt)
+				metrics.ElasticsearchDocuments.WithLabelValues("failed").Add(float64(len(rejected)))
+				return true
+			}
+			items = rejected
+		}
+
+		metrics.ElasticsearchRetries.Inc()
+		timer := time.NewTimer(retryBackoff(*s.cfg.Retry, attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// elasticsearchBulkResponse is the response of the bulk API, with an item per
+// action, in their order.
+type elasticsearchBulkResponse struct {
+	Errors bool                                 `json:"errors"`
+	Items  []map[string]elasticsearchBulkResult `json:"items"`
+}
+
+type elasticsearchBulkResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+// bulk installs the index template and lifecycle policy if they aren't yet,
+// and writes items with a bulk request. It returns the items rejected with
+// 429 Too Many Requests, to be retried. The items that failed otherwise are
+// given up on.
+func (s *elasticsearchSender) bulk(ctx context.Context, logger *zap.SugaredLogger, items []*elasticsearchItem) ([]*elasticsearchItem, error) {
+	if err := s.bootstrap(ctx); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for _, item := range items {
+		body.Write(item.lines)
+	}
+	_, data, err := s.client.request(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	resp := &elasticsearchBulkResponse{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return nil, fmt.Errorf("invalid bulk response: %w", err)
+	}
+	if len(resp.Items) != len(items) {
+		return nil, fmt.Errorf("bulk response has %d items, want %d", len(resp.Items), len(items))
+	}
+
+	var rejected []*elasticsearchItem
+	failed := 0
+	for i, result := range resp.Items {
+		for _, r := range result {
+			switch {
+			case r.Status < 300:
+				metrics.ElasticsearchDocuments.WithLabelValues("indexed").Inc()
+			case r.Status == http.StatusTooManyRequests:
+				rejected = append(rejected, items[i])
+			default:
+				// Only the first error of the batch is logged, the
+				// others are usually the same.
+				if failed == 0 && r.Error != nil {
+					logger.Warnw("Elasticsearch failed to index document", "status", r.Status, "type", r.Error.Type, "reason", r.Error.Reason)
+				}
+				failed++
+			}
+		}
+	}
+	metrics.ElasticsearchDocuments.WithLabelValues("failed").Add(float64(failed))
+	return rejected, nil
+}
+
+// bootstrap installs the lifecycle policy and then the index template, which
+// refers to it with Elasticsearch, unless they were already.
+func (s *elasticsearchSender) bootstrap(ctx context.Context) error {
+	if s.bootstrapped {
+		return nil
+	}
+	if l := s.cfg.Lifecycle; l != nil {
+		var err error
+		if s.cfg.Distribution == config.ElasticsearchDistributionOpenSearch {
+			err = s.putISMPolicy(ctx)
+		} else {
+			err = s.put(ctx, "/_ilm/policy/"+url.PathEscape(l.Name), elasticsearchILMPolicy(l))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to install lifecycle policy %s: %w", l.Name, err)
+		}
+	}
+	if t := s.cfg.Template; t != nil {
+		if err := s.put(ctx, "/_index_template/"+url.PathEscape(t.Name), elasticsearchTemplate(s.cfg)); err != nil {
+			return fmt.Errorf("failed to install index template %s: %w", t.Name, err)
+		}
+	}
+	s.bootstrapped = true
+	return nil
+}
+
+// putISMPolicy creates the ISM policy, or updates it if it exists, which
+// OpenSearch only allows for its current sequence number.
+func (s *elasticsearchSender) putISMPolicy(ctx context.Context) error {
+	path := "/_plugins/_ism/policies/" + url.PathEscape(s.cfg.Lifecycle.Name)
+	body, err := json.Marshal(opensearchISMPolicy(s.cfg))
+	if err != nil {
+		return err
+	}
+	status, _, err := s.client.request(ctx, http.MethodPut, path, "application/json", body)
+	if status != http.StatusConflict {
+		return err
+	}
+
+	_, data, err := s.client.request(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return err
+	}
+	var current struct {
+		SeqNo       int64 `json:"_seq_no"`
+		PrimaryTerm int64 `json:"_primary_term"`
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+	_, _, err = s.client.request(ctx, http.MethodPut, fmt.Sprintf("%s?if_seq_no=%d&if_primary_term=%d", path, current.SeqNo, current.PrimaryTerm), "application/json", body)
+	return err
+}
+
+// put creates or replaces the resource at path with value.
+func (s *elasticsearchSender) put(ctx context.Context, path string, value any) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.client.request(ctx, http.MethodPut, path, "application/json", body)
+	return err
+}
+
+func (s *elasticsearchSender) enabled() bool {
+	return s.client != nil
+}
+
+func (s *elasticsearchSender) matches(event *protobuf.APIEvent) bool {
+	return s.match.Matches(event)
+}
+
+// close releases the client of s. Its batch was written before.
+func (s *elasticsearchSender) close(context.Context) error {
+	if s.client != nil {
+		s.client.close()
+	}
+	return nil
+}
+
+func newElasticsearchSender(ctx context.Context, cfg *config.Config, secrets SecretReader) (*elasticsearchSender, error) {
+	if cfg.Exporter == nil || cfg.Exporter.Elasticsearch == nil || !cfg.Exporter.Elasticsearch.Enabled {
+		return &elasticsearchSender{}, nil
+	}
+	e := cfg.Exporter.Elasticsearch
+
+	r, err := newRedactor(e.Redact)
+	if err != nil {
+		return nil, err
+	}
+	m, err := match.New(e.Match)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newElasticsearchClient(ctx, e, secrets)
+	if err != nil {
+		return nil, err
+	}
+	return &elasticsearchSender{client: client, cfg: e, match: m, redactor: r}, nil
+}
+
+// elasticsearchClient sends requests to the nodes of a cluster, to the next
+// one when a node can't be reached. It's only used by the run goroutine.
+type elasticsearchClient struct {
+	client        *http.Client
+	urls          []string
+	node          int
+	authorization string
+}
+
+func newElasticsearchClient(ctx context.Context, cfg *config.ElasticsearchConfig, secrets SecretReader) (*elasticsearchClient, error) {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if cfg.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.TLS, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's Elasticsearch TLS configuration: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	c := &elasticsearchClient{client: &http.Client{Transport: transport}}
+	for _, u := range cfg.URLs {
+		c.urls = append(c.urls, strings.TrimSuffix(u, "/"))
+	}
+	switch {
+	case cfg.Username != "":
+		password, err := readSecret(ctx, *cfg.Password, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exporter's Elasticsearch password: %w", err)
+		}
+		c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Username+":"+string(password)))
+	case cfg.APIKey != nil:
+		key, err := readSecret(ctx, *cfg.APIKey, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exporter's Elasticsearch apiKey: %w", err)
+		}
+		c.authorization = "ApiKey " + string(key)
+	}
+	return c, nil
+}
+
+// request sends a request to the current node and returns the status and body
+// of its response. It returns a *callError if the node can't be reached, in
+// which case the next request goes to the next node, or if it answered with
+// an error status.
+func (c *elasticsearchClient) request(ctx context.Context, method, path, contentType string, body []byte) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.urls[c.node]+path, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request creation failed: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.node = (c.node + 1) % len(c.urls)
+		return 0, nil, &callError{err: err, retryable: true}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return resp.StatusCode, nil, &callError{err: err, retryable: true}
+	}
+
+	if resp.StatusCode < 300 {
+		return resp.StatusCode, data, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout
+	return resp.StatusCode, data, &callError{
+		err:        fmt.Errorf("%s %s returned status %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(data[:min(len(data), 512)])),
+		retryable:  retryable,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (c *elasticsearchClient) close() {
+	c.client.CloseIdleConnections()
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestElasticsearchExporter(t *testing.T) {
+	// Given
+	cluster := newFakeElasticsearch(t)
+	// The second document is rejected once, as by a node whose write queue
+	// is full.
+	cluster.reject = map[string]int{"/items": 1}
+	cfg := getElasticsearchConfig(t, cluster.server.URL)
+	cfg.Exporter.Elasticsearch.Template = &config.ElasticsearchTemplateConfig{Name: "sentryflow", IndexBodies: true}
+	cfg.Exporter.Elasticsearch.Lifecycle = &config.ElasticsearchLifecycleConfig{Name: "sentryflow", DeleteAfter: 720 * time.Hour}
+
+	events := make(chan *protobuf.APIEvent, 2)
+	acks := make(ackRecorder, 2)
+	ctx, wg := exporterContext(t)
+	if _, err := InitElasticsearchExporter(ctx, cfg, events, ElasticsearchDependencies{Acks: acks}, wg); err != nil {
+		t.Fatalf("InitElasticsearchExporter() error = %v", err)
+	}
+
+	// When
+	charge := getAPIEvent("payments", "billing", "/charges")
+	charge.Metadata.Timestamp = uint64(time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC).Unix())
+	events <- charge
+	events <- getAPIEvent("orders", "cart", "/items")
+
+	// Then
+	waitAcks(t, acks, 2)
+	requests := cluster.received()
+	want := []string{"PUT /_ilm/policy/sentryflow", "PUT /_index_template/sentryflow", "POST /_bulk", "POST /_bulk"}
+	if got := requestLines(requests); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("got requests %v, want %v", got, want)
+	}
+	if got := requests[0].header.Get("Authorization"); got != "Basic c2VudHJ5ZmxvdzpzM2NyM3Q=" {
+		t.Errorf("got Authorization %q, want the basic credentials", got)
+	}
+
+	template := requests[1].body
+	if !strings.Contains(template, `"index.lifecycle.name":"sentryflow"`) || !strings.Contains(template, `"headers":{"type":"flattened"}`) {
+		t.Errorf("template %s doesn't refer to the policy or doesn't flatten headers", template)
+	}
+	if !strings.Contains(template, `"body":{"index":true,"type":"text"}`) {
+		t.Errorf("template %s doesn't index bodies", template)
+	}
+
+	first := cluster.documents(requests[2])
+	if len(first) != 2 || first[0].index != "sentryflow-2024.03.01" {
+		t.Fatalf("got first bulk request %+v, want both events, the first into the index of its day", first)
+	}
+	if got := first[0].source["@timestamp"]; got != "2024-03-01T12:00:00Z" {
+		t.Errorf("document has @timestamp %v, want the event's", got)
+	}
+	retried := cluster.documents(requests[3])
+	if len(retried) != 1 || !strings.Contains(retried[0].line, `"/items"`) {
+		t.Errorf("got retried bulk request %+v, want only the rejected document", retried)
+	}
+}
+
+func TestElasticsearchExporter_OpenSearchPolicy(t *testing.T) {
+	// Given
+	cluster := newFakeElasticsearch(t)
+	cluster.policyExists = true
+	cfg := getElasticsearchConfig(t, cluster.server.URL)
+	cfg.Exporter.Elasticsearch.Distribution = config.ElasticsearchDistributionOpenSearch
+	cfg.Exporter.Elasticsearch.MaxBatchSize = 1
+	cfg.Exporter.Elasticsearch.Template = &config.ElasticsearchTemplateConfig{Name: "sentryflow"}
+	cfg.Exporter.Elasticsearch.Lifecycle = &config.ElasticsearchLifecycleConfig{Name: "sentryflow", DeleteAfter: 24 * time.Hour}
+
+	events := make(chan *protobuf.APIEvent, 1)
+	acks := make(ackRecorder, 1)
+	ctx, wg := exporterContext(t)
+	if _, err := InitElasticsearchExporter(ctx, cfg, events, ElasticsearchDependencies{Acks: acks}, wg); err != nil {
+		t.Fatalf("InitElasticsearchExporter() error = %v", err)
+	}
+
+	// When
+	events <- getAPIEvent("payments", "billing", "/charges")
+
+	// Then
+	waitAcks(t, acks, 1)
+	requests := cluster.received()
+	want := []string{
+		"PUT /_plugins/_ism/policies/sentryflow",
+		"GET /_plugins/_ism/policies/sentryflow",
+		"PUT /_plugins/_ism/policies/sentryflow?if_seq_no=7&if_primary_term=2",
+		"PUT /_index_template/sentryflow",
+		"POST /_bulk",
+	}
+	if got := requestLines(requests); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("got requests %v, want %v", got, want)
+	}
+	if policy := requests[2].body; !strings.Contains(policy, `"min_index_age":"86400000ms"`) || !strings.Contains(policy, `"index_patterns":["sentryflow-*"]`) {
+		t.Errorf("policy %s doesn't delete the indices after a day", policy)
+	}
+	if template := requests[3].body; strings.Contains(template, "lifecycle") || !strings.Contains(template, `"headers":{"type":"flat_object"}`) {
+		t.Errorf("template %s isn't an OpenSearch one", template)
+	}
+}
+
+func getElasticsearchConfig(t *testing.T, url string) *config.Config {
+	t.Helper()
+	password := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(password, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{
+		Exporter: &config.ExporterConfig{
+			Elasticsearch: &config.ElasticsearchConfig{
+				Enabled:         true,
+				URLs:            []string{url},
+				Distribution:    config.ElasticsearchDistributionElasticsearch,
+				Index:           "sentryflow",
+				IndexDateFormat: config.DefaultElasticsearchIndexDateFormat,
+				Username:        "sentryflow",
+				Password:        &config.SecretConfig{File: password},
+				Timeout:         5 * time.Second,
+				MaxBatchSize:    2,
+				MaxBatchBytes:   config.DefaultElasticsearchMaxBatchBytes,
+				BatchTimeout:    time.Minute,
+				Retry:           &config.WebhookRetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+			},
+		},
+	}
+}
+
+// fakeElasticsearch is a node answering the requests of the exporter.
+type fakeElasticsearch struct {
+	server *httptest.Server
+
+	// reject is the number of times the documents of events with a path are
+	// rejected with 429 Too Many Requests.
+	reject map[string]int
+	// policyExists makes ISM policies exist already.
+	policyExists bool
+
+	lock     sync.Mutex
+	requests []fakeElasticsearchRequest
+}
+
+type fakeElasticsearchRequest struct {
+	method string
+	uri    string
+	header http.Header
+	body   string
+}
+
+type fakeElasticsearchDocument struct {
+	index  string
+	line   string
+	source map[string]any
+}
+
+func newFakeElasticsearch(t *testing.T) *fakeElasticsearch {
+	t.Helper()
+	c := &fakeElasticsearch{}
+	c.server = httptest.NewServer(http.HandlerFunc(c.handle))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+func (c *fakeElasticsearch) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := fakeElasticsearchRequest{method: r.Method, uri: r.URL.RequestURI(), header: r.Header.Clone(), body: string(body)}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.requests = append(c.requests, req)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/_bulk":
+		var items []string
+		for _, doc := range c.documents(req) {
+			status := http.StatusCreated
+			for path, times := range c.reject {
+				if strings.Contains(doc.line, fmt.Sprintf("%q", path)) && times > 0 {
+					c.reject[path] = times - 1
+					status = http.StatusTooManyRequests
+				}
+			}
+			items = append(items, fmt.Sprintf(`{"index":{"_index":%q,"status":%d}}`, doc.index, status))
+		}
+		_, _ = fmt.Fprintf(w, `{"errors":false,"items":[%s]}`, strings.Join(items, ","))
+
+	case strings.HasPrefix(r.URL.Path, "/_plugins/_ism/policies/") && r.Method == http.MethodGet:
+		_, _ = w.Write([]byte(`{"_id":"sentryflow","_seq_no":7,"_primary_term":2,"policy":{}}`))
+
+	case strings.HasPrefix(r.URL.Path, "/_plugins/_ism/policies/") && c.policyExists && r.URL.Query().Get("if_seq_no") == "":
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error":{"type":"version_conflict_engine_exception"}}`))
+
+	default:
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	}
+}
+
+// documents returns the documents of a bulk request.
+func (c *fakeElasticsearch) documents(req fakeElasticsearchRequest) []fakeElasticsearchDocument {
+	var docs []fakeElasticsearchDocument
+	scanner := bufio.NewScanner(bytes.NewBufferString(req.body))
+	for scanner.Scan() {
+		var action struct {
+			Index struct {
+				Index string `json:"_index"`
+			} `json:"index"`
+		}
+		_ = json.Unmarshal(scanner.Bytes(), &action)
+		if !scanner.Scan() {
+			break
+		}
+		doc := fakeElasticsearchDocument{index: action.Index.Index, line: scanner.Text()}
+		_ = json.Unmarshal(scanner.Bytes(), &doc.source)
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func (c *fakeElasticsearch) received() []fakeElasticsearchRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.requests
+}
+
+func requestLines(requests []fakeElasticsearchRequest) []string {
+	var lines []string
+	for _, r := range requests {
+		lines = append(lines, r.method+" "+r.uri)
+	}
+	return lines
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/payload"
+)
+
+// encoder encodes the events sent to a webhook into request bodies in its
+// format and compression, transforming them with its template if any.
+type encoder struct {
+	format      string
+	compression string
+	transform   *payload.Transform
+	// batched is whether the webhook batches events, in which case bodies are
+	// always encoded as batches, even of a single event.
+	batched bool
+	zstd    *zstd.Encoder
+}
+
+func newEncoder(cfg config.WebhookConfig) (*encoder, error) {
+	e := &encoder{
+		format:      cfg.Format,
+		compression: cfg.Compression,
+		batched:     cfg.Batch != nil,
+	}
+	if e.format == "" {
+		e.format = config.WebhookFormatJSON
+	}
+	if cfg.Template != nil {
+		transform, err := payload.New(cfg.Template.Builtin, cfg.Template.Text, cfg.Template.Fields)
+		if err != nil {
+			return nil, err
+		}
+		e.transform = transform
+	}
+	if e.compression == config.WebhookCompressionZstd {
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		e.zstd = enc
+	}
+	return e, nil
+}
+
+// marshal encodes a single event of a body.
+func (e *encoder) marshal(event *protobuf.APIEvent) ([]byte, error) {
+	if e.transform != nil {
+		rendered, err := e.transform.Render(event)
+		if err != nil {
+			return nil, err
+		}
+		// JSON payloads are compacted so that they fit on a line of NDJSON
+		// bodies.
+		var buf bytes.Buffer
+		if json.Compact(&buf, rendered) != nil {
+			return rendered, nil
+		}
+		return buf.Bytes(), nil
+	}
+	if e.format == config.WebhookFormatProtobuf {
+		return proto.Marshal(event)
+	}
+	return protojson.Marshal(event)
+}
+
+// body returns the compressed request body of the encoded events, and its
+// content type.
+func (e *encoder) body(encoded [][]byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	var contentType string
+
+	switch e.format {
+	case config.WebhookFormatNDJSON:
+		contentType = "application/x-ndjson"
+		for _, event := range encoded {
+			buf.Write(event)
+			buf.WriteByte('\n')
+		}
+	case config.WebhookFormatProtobuf:
+		contentType = "application/x-protobuf"
+		if !e.batched && len(encoded) == 1 {
+			buf.Write(encoded[0])
+			break
+		}
+		for _, event := range encoded {
+			buf.Write(protowire.AppendVarint(nil, uint64(len(event))))
+			buf.Write(event)
+		}
+	default:
+		contentType = "application/json"
+		if !e.batched && len(encoded) == 1 {
+			buf.Write(encoded[0])
+			break
+		}
+		buf.WriteByte('[')
+		for i, event := range encoded {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(event)
+		}
+		buf.WriteByte(']')
+	}
+
+	body, err := e.compress(buf.Bytes())
+	return body, contentType, err
+}
+
+func (e *encoder) compress(body []byte) ([]byte, error) {
+	switch e.compression {
+	case config.WebhookCompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case config.WebhookCompressionZstd:
+		return e.zstd.EncodeAll(body, nil), nil
+	case "":
+		return body, nil
+	}
+	return nil, fmt.Errorf("unsupported compression, %v", e.compression)
+}
+
+func (e *encoder) close() {
+	if e.zstd != nil {
+		_ = e.zstd.Close()
+	}
+}
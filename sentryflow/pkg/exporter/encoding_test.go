@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestEncoder_body(t *testing.T) {
+	events := []*protobuf.APIEvent{
+		{Metadata: &protobuf.Metadata{ContextId: 1}},
+		{Metadata: &protobuf.Metadata{ContextId: 2}},
+	}
+
+	tests := []struct {
+		name            string
+		cfg             config.WebhookConfig
+		events          []*protobuf.APIEvent
+		wantContentType string
+		check           func(t *testing.T, body []byte)
+	}{
+		{
+			name:            "with single json event should send the event",
+			cfg:             config.WebhookConfig{},
+			events:          events[:1],
+			wantContentType: "application/json",
+			check: func(t *testing.T, body []byte) {
+				var event map[string]any
+				if err := json.Unmarshal(body, &event); err != nil {
+					t.Errorf("body %s isn't a JSON object: %v", body, err)
+				}
+			},
+		},
+		{
+			name:            "with batched json events should send an array",
+			cfg:             config.WebhookConfig{Format: config.WebhookFormatJSON, Batch: &config.WebhookBatchConfig{}},
+			events:          events[:1],
+			wantContentType: "application/json",
+			check: func(t *testing.T, body []byte) {
+				var batch []map[string]any
+				if err := json.Unmarshal(body, &batch); err != nil || len(batch) != 1 {
+					t.Errorf("body %s isn't a JSON array of 1 event: %v", body, err)
+				}
+			},
+		},
+		{
+			name:            "with ndjson events should send a line per event",
+			cfg:             config.WebhookConfig{Format: config.WebhookFormatNDJSON, Batch: &config.WebhookBatchConfig{}},
+			events:          events,
+			wantContentType: "application/x-ndjson",
+			check: func(t *testing.T, body []byte) {
+				lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+				if len(lines) != 2 {
+					t.Errorf("body %s has %d lines, want 2", body, len(lines))
+				}
+			},
+		},
+		{
+			name:            "with batched protobuf events should send size-delimited events",
+			cfg:             config.WebhookConfig{Format: config.WebhookFormatProtobuf, Batch: &config.WebhookBatchConfig{}},
+			events:          events,
+			wantContentType: "application/x-protobuf",
+			check: func(t *testing.T, body []byte) {
+				r := bytes.NewReader(body)
+				for _, want := range events {
+					got := &protobuf.APIEvent{}
+					if err := protodelim.UnmarshalFrom(r, got); err != nil || !proto.Equal(got, want) {
+						t.Errorf("read event %v, want %v, error = %v", got, want, err)
+					}
+				}
+			},
+		},
+		{
+			name: "with template should send compacted payloads",
+			cfg: config.WebhookConfig{Format: config.WebhookFormatNDJSON, Batch: &config.WebhookBatchConfig{},
+				Template: &config.WebhookTemplateConfig{Text: "{\n  \"id\": {{ .ContextID }}\n}"}},
+			events:          events,
+			wantContentType: "application/x-ndjson",
+			check: func(t *testing.T, body []byte) {
+				if string(body) != "{\"id\":1}\n{\"id\":2}\n" {
+					t.Errorf("body = %q, want a compacted payload per line", body)
+				}
+			},
+		},
+		{
+			name:            "with gzip compression should compress the body",
+			cfg:             config.WebhookConfig{Format: config.WebhookFormatNDJSON, Compression: config.WebhookCompressionGzip},
+			events:          events[:1],
+			wantContentType: "application/x-ndjson",
+			check: func(t *testing.T, body []byte) {
+				r, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("body isn't gzip compressed: %v", err)
+				}
+				if data, err := io.ReadAll(r); err != nil || !strings.HasSuffix(string(data), "}\n") {
+					t.Errorf("decompressed body = %s, error = %v", data, err)
+				}
+			},
+		},
+		{
+			name:            "with zstd compression should compress the body",
+			cfg:             config.WebhookConfig{Format: config.WebhookFormatProtobuf, Compression: config.WebhookCompressionZstd},
+			events:          events[:1],
+			wantContentType: "application/x-protobuf",
+			check: func(t *testing.T, body []byte) {
+				d, err := zstd.NewReader(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer d.Close()
+				data, err := d.DecodeAll(body, nil)
+				if err != nil {
+					t.Fatalf("body isn't zstd compressed: %v", err)
+				}
+				got := &protobuf.APIEvent{}
+				if err := proto.Unmarshal(data, got); err != nil || !proto.Equal(got, events[0]) {
+					t.Errorf("decompressed event = %v, want %v, error = %v", got, events[0], err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := newEncoder(tt.cfg)
+			if err != nil {
+				t.Fatalf("newEncoder() error = %v", err)
+			}
+			defer e.close()
+
+			var encoded [][]byte
+			for _, event := range tt.events {
+				data, err := e.marshal(event)
+				if err != nil {
+					t.Fatalf("marshal() error = %v", err)
+				}
+				encoded = append(encoded, data)
+			}
+
+			body, contentType, err := e.body(encoded)
+			if err != nil {
+				t.Fatalf("body() error = %v", err)
+			}
+			if contentType != tt.wantContentType {
+				t.Errorf("body() content type = %v, want %v", contentType, tt.wantContentType)
+			}
+			tt.check(t, body)
+		})
+	}
+}
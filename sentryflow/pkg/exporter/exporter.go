@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+)
+
+// Reconfigurer is implemented by exporters that can apply a changed
+// configuration while running. Reconfigure must leave the exporter running with
+// its old configuration if it returns an error.
+type Reconfigurer interface {
+	Reconfigure(ctx context.Context, cfg *config.Config) error
+}
+
+var (
+	_ Reconfigurer = (*Exporter)(nil)
+	_ Reconfigurer = (*KafkaExporter)(nil)
+	_ Reconfigurer = (*NATSExporter)(nil)
+	_ Reconfigurer = (*OTLPExporter)(nil)
+	_ Reconfigurer = (*ElasticsearchExporter)(nil)
+)
+
+// Acknowledger is told when an exporter is done with an event, i.e. it was
+// delivered or given up on, so that a durable source of events, such as the
+// write-ahead log, can move past it.
+type Acknowledger interface {
+	Ack(event *protobuf.APIEvent)
+}
+
+// Replayer is implemented by the Acknowledgers of durable sources of events
+// that can send an event the exporter couldn't deliver again, such as the
+// write-ahead log. Replay returns false if the source gave up on the event
+// instead, in which case it's acknowledged.
+type Replayer interface {
+	Replay(event *protobuf.APIEvent, delay time.Duration) bool
+}
+
+// SecretReader reads the keys of Kubernetes Secrets, see k8s.Secrets.
+type SecretReader interface {
+	Read(ctx context.Context, namespace, name, key string) ([]byte, error)
+}
+
+// newRedactor returns the redactor of an exporter's redact configuration, or
+// nil if the exporter doesn't redact.
+func newRedactor(cfg *config.RedactConfig) (*redact.Redactor, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	return redact.New(cfg)
+}
+
+// redacted returns a redacted copy of event, leaving event itself untouched
+// for the other exporters. It returns event if r is nil.
+func redacted(r *redact.Redactor, event *protobuf.APIEvent) *protobuf.APIEvent {
+	if r == nil {
+		return event
+	}
+	clone := proto.Clone(event).(*protobuf.APIEvent)
+	r.Redact(clone)
+	return clone
+}
+
+// destination is what an exporter sends events with, built from its
+// configuration, e.g. a client and the events it matches.
+type destination interface {
+	// enabled returns false if the exporter is disabled, in which case events
+	// are only acknowledged.
+	enabled() bool
+	matches(event *protobuf.APIEvent) bool
+	// close writes what's left to send until ctx is done, and releases the
+	// destination.
+	close(ctx context.Context) error
+}
+
+// reloadable runs an exporter sending events with a destination that's
+// replaced whenever its configuration changes. The exporter consumes events
+// even when it's disabled so that it can be enabled later with Reconfigure.
+type reloadable[D destination] struct {
+	// name is the name of the exporter in logs, e.g. Kafka.
+	name   string
+	logger *zap.SugaredLogger
+	events chan *protobuf.APIEvent
+	acks   Acknowledger
+	// flushTimeout is how long what's left to send is waited for to be
+	// written when a destination is closed.
+	flushTimeout time.Duration
+
+	// configOf returns the exporter's configuration, which the destinations
+	// are built from with newDestination.
+	configOf       func(cfg *config.ExporterConfig) any
+	newDestination func(ctx context.Context, cfg *config.Config) (D, error)
+	// send sends an event with a destination it matches, and acknowledges it
+	// once it's done with it.
+	send func(ctx context.Context, d D, event *protobuf.APIEvent)
+	// flush, if set, sends the batch of events of a destination. It's called
+	// once the batch timer fires, before switching destination and when the
+	// exporter stops.
+	flush func(ctx context.Context, d D)
+
+	reloads chan destinationReload[D]
+	done    chan struct{}
+
+	// applied is the configuration current was built from. It's only
+	// accessed by Reconfigure.
+	applied any
+	// current and the batch timer are only accessed by the run goroutine.
+	current D
+	batch   *time.Timer
+	batchC  <-chan time.Time
+}
+
+// destinationReload asks the run goroutine to switch to next. It replies with
+// the destination it switched from.
+type destinationReload[D destination] struct {
+	next D
+	prev chan D
+}
+
+// start builds the destination of cfg and starts consuming events until ctx
+// is done or the events channel is closed.
+func (r *reloadable[D]) start(ctx context.Context, cfg *config.Config, wg *sync.WaitGroup) error {
+	d, err := r.newDestination(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	r.reloads = make(chan destinationReload[D])
+	r.done = make(chan struct{})
+	r.applied = r.configIn(cfg)
+	r.current = d
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(r.done)
+		r.run(ctx)
+	}()
+
+	r.logger.Infow(r.name+" exporter started", "enabled", d.enabled())
+	return nil
+}
+
+// Reconfigure switches the exporter to a destination built from cfg, if the
+// exporter's configuration changed, and waits for what was sent with the old
+// one to be written. The old destination is closed even if ctx is done before.
+// If cfg can't be applied, e.g. because a password can't be read, the exporter
+// keeps its old configuration.
+func (r *reloadable[D]) Reconfigure(ctx context.Context, cfg *config.Config) error {
+	applied := r.configIn(cfg)
+	if reflect.DeepEqual(applied, r.applied) {
+		return nil
+	}
+
+	next, err := r.newDestination(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	req := destinationReload[D]{next: next, prev: make(chan D, 1)}
+	select {
+	case r.reloads <- req:
+	case <-r.done:
+		_ = next.close(ctx)
+		return fmt.Errorf("%s exporter is not running", strings.ToLower(r.name))
+	case <-ctx.Done():
+		_ = next.close(ctx)
+		return ctx.Err()
+	}
+	r.applied = applied
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		prev := <-req.prev
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.flushTimeout)
+		defer cancel()
+		if err := prev.close(flushCtx); err != nil {
+			r.logger.Warnf("Failed to write the events left before reconfiguring: %v", err)
+		}
+	}()
+
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	r.logger.Infow(r.name+" exporter reconfigured", "enabled", next.enabled())
+	return nil
+}
+
+func (r *reloadable[D]) run(ctx context.Context) {
+	// What's left to send is still written once ctx is done, up to
+	// flushTimeout.
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), r.flushTimeout)
+		defer cancel()
+		r.flushBatch(flushCtx)
+		if err := r.current.close(flushCtx); err != nil {
+			r.logger.Warnf("Failed to write the events left: %v", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info(r.name + " exporter context cancelled")
+			return
+
+		case req := <-r.reloads:
+			r.flushBatch(ctx)
+			req.prev <- r.current
+			r.current = req.next
+
+		case <-r.batchC:
+			r.flushBatch(ctx)
+
+		case ev, ok := <-r.events:
+			if !ok {
+				r.logger.Warn(r.name + " exporter channel closed")
+				return
+			}
+			if !r.current.enabled() || !r.current.matches(ev) {
+				r.ack(ev)
+				continue
+			}
+			r.send(ctx, r.current, ev)
+		}
+	}
+}
+
+// startBatch starts the timer flushing the batch after timeout.
+func (r *reloadable[D]) startBatch(timeout time.Duration) {
+	r.batch = time.NewTimer(timeout)
+	r.batchC = r.batch.C
+}
+
+// flushBatch stops the batch timer and sends the batch of the current
+// destination.
+func (r *reloadable[D]) flushBatch(ctx context.Context) {
+	if r.flush == nil {
+		return
+	}
+	if r.batch != nil {
+		r.batch.Stop()
+		r.batchC = nil
+	}
+	r.flush(ctx, r.current)
+}
+
+// configIn returns the exporter's configuration in cfg.
+func (r *reloadable[D]) configIn(cfg *config.Config) any {
+	if cfg.Exporter == nil {
+		return r.configOf(&config.ExporterConfig{})
+	}
+	return r.configOf(cfg.Exporter)
+}
+
+func (r *reloadable[D]) ack(event *protobuf.APIEvent) {
+	if r.acks != nil {
+		r.acks.Ack(event)
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func Test_reloadable_Reconfigure(t *testing.T) {
+	tests := []struct {
+		name       string
+		next       *config.HttpConfig
+		slowClose  bool
+		timeout    time.Duration
+		wantErr    error
+		wantBuilds int
+		wantClosed bool
+	}{
+		{
+			name:       "with unchanged config should keep the destination",
+			next:       &config.HttpConfig{Enabled: true, TimeoutSeconds: 5},
+			timeout:    time.Second,
+			wantBuilds: 1,
+		},
+		{
+			name:       "with changed config should close the previous destination",
+			next:       &config.HttpConfig{Enabled: true, TimeoutSeconds: 10},
+			timeout:    time.Second,
+			wantBuilds: 2,
+			wantClosed: true,
+		},
+		{
+			name:       "when timing out should still close the previous destination",
+			next:       &config.HttpConfig{Enabled: true, TimeoutSeconds: 10},
+			slowClose:  true,
+			timeout:    50 * time.Millisecond,
+			wantErr:    context.DeadlineExceeded,
+			wantBuilds: 2,
+			wantClosed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			ctx, wg := exporterContext(t)
+			release := make(chan struct{})
+			var builds int
+			r := &reloadable[*fakeDestination]{
+				name:         "Fake",
+				logger:       zap.NewNop().Sugar(),
+				events:       make(chan *protobuf.APIEvent),
+				flushTimeout: time.Second,
+				configOf: func(cfg *config.ExporterConfig) any {
+					return cfg.HTTP
+				},
+				newDestination: func(context.Context, *config.Config) (*fakeDestination, error) {
+					builds++
+					d := &fakeDestination{closed: make(chan struct{})}
+					if tt.slowClose {
+						d.release = release
+					}
+					return d, nil
+				},
+				send: func(context.Context, *fakeDestination, *protobuf.APIEvent) {},
+			}
+			cfg := &config.Config{Exporter: &config.ExporterConfig{HTTP: &config.HttpConfig{Enabled: true, TimeoutSeconds: 5}}}
+			if err := r.start(ctx, cfg, wg); err != nil {
+				t.Fatalf("start() error = %v", err)
+			}
+			prev := r.current
+
+			// When
+			reloadCtx, cancel := context.WithTimeout(ctx, tt.timeout)
+			defer cancel()
+			err := r.Reconfigure(reloadCtx, &config.Config{Exporter: &config.ExporterConfig{HTTP: tt.next}})
+
+			// Then
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Reconfigure() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if builds != tt.wantBuilds {
+				t.Errorf("Reconfigure() built %d destinations, want %d", builds, tt.wantBuilds)
+			}
+			close(release)
+			if !tt.wantClosed {
+				return
+			}
+			select {
+			case <-prev.closed:
+			case <-time.After(5 * time.Second):
+				t.Error("previous destination wasn't closed")
+			}
+		})
+	}
+}
+
+// fakeDestination is a destination whose close waits for release, if set.
+type fakeDestination struct {
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func (d *fakeDestination) enabled() bool {
+	return true
+}
+
+func (d *fakeDestination) matches(*protobuf.APIEvent) bool {
+	return true
+}
+
+func (d *fakeDestination) close(context.Context) error {
+	if d.release != nil {
+		<-d.release
+	}
+	close(d.closed)
+	return nil
+}
+
+// exporterContext returns the context exporters are started with, and the
+// wait group of their goroutines. The exporters are stopped and waited for
+// when the test ends.
+func exporterContext(t *testing.T) (context.Context, *sync.WaitGroup) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar()))
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return ctx, &wg
+}
+
+// waitAcks waits for n events to be acknowledged.
+func waitAcks(t *testing.T, acks ackRecorder, n int) {
+	t.Helper()
+	for i := range n {
+		select {
+		case <-acks:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d acknowledged events, want %d", i, n)
+		}
+	}
+}
+
+// serveFake accepts the connections of the returned listener with serve until
+// the test ends.
+func serveFake(t *testing.T, serve func(conn net.Conn)) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return listener
+}
+
+// waitReceived waits for received to return at least n items, checking again
+// whenever changed is notified, and returns them.
+func waitReceived[T any](t *testing.T, changed <-chan struct{}, received func() []T, n int) []T {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		items := received()
+		if len(items) >= n {
+			return items
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("got %d items, want %d", len(items), n)
+		}
+	}
+}
+
+func getAPIEvent(namespace, name, path string) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Metadata:    &protobuf.Metadata{Timestamp: uint64(time.Now().Unix())},
+		Destination: &protobuf.Workload{Namespace: namespace, Name: name},
+		Request:     &protobuf.Request{Headers: map[string]string{":method": "POST", ":path": path}},
+		Response:    &protobuf.Response{Headers: map[string]string{":status": "200"}},
+	}
+}
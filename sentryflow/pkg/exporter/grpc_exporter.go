@@ -6,9 +6,15 @@ package exporter
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/classifier"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/queue"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -17,18 +23,124 @@ import (
 	grpcstatus "google.golang.org/grpc/status"
 )
 
-// clientList represents a list of gRPC clients and their associated channels for
+// clientList represents a list of gRPC clients and their associated queues for
 // sending API events. It uses a mutex to synchronize access to the client map.
 type clientList struct {
 	*sync.Mutex
-	client map[string]chan *protobuf.APIEvent
+	client map[string]*queue.Queue
+}
+
+// Kinds of streams clients can subscribe to, as labelled in the GrpcClients
+// metric.
+const (
+	apiEventStream     = "APIEvent"
+	apiMetricsStream   = "APIMetrics"
+	envoyMetricsStream = "EnvoyMetrics"
+)
+
+// subscriberList represents the gRPC clients subscribed to updates of a kind,
+// e.g. APIMetrics, and their associated channels. Each channel holds up to size
+// updates, after which the oldest ones are dropped.
+type subscriberList[T any] struct {
+	sync.Mutex
+	kind   string
+	size   int
+	client map[string]chan T
+}
+
+func (s *subscriberList[T]) add(uid string) chan T {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.client == nil {
+		s.client = make(map[string]chan T)
+	}
+	connChan := make(chan T, max(s.size, 1))
+	s.client[uid] = connChan
+	metrics.GrpcClients.WithLabelValues(s.kind).Inc()
+	return connChan
+}
+
+func (s *subscriberList[T]) delete(uid string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.client, uid)
+	metrics.GrpcClients.WithLabelValues(s.kind).Dec()
+}
+
+// publish sends update to every client, dropping the oldest update of clients
+// that are falling behind.
+func (s *subscriberList[T]) publish(update T) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, clientChan := range s.client {
+		select {
+		case clientChan <- update:
+		default:
+			<-clientChan // Drop oldest update
+			clientChan <- update
+		}
+	}
+}
+
+// stream sends the updates of a new client to send until ctx is done or send
+// fails.
+func (s *subscriberList[T]) stream(ctx context.Context, logger *zap.SugaredLogger, clientInfo *protobuf.ClientInfo, send func(T) error) error {
+	uid := uuid.Must(uuid.NewRandom()).String()
+
+	connChan := s.add(uid)
+	defer s.delete(uid)
+
+	logger.Infof("%s client: %s %s (%s) connected", s.kind, uid, clientInfo.HostName, clientInfo.IPAddress)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("%s client: %s %s (%s) disconnected", s.kind, uid, clientInfo.HostName, clientInfo.IPAddress)
+			return ctx.Err()
+		case update := <-connChan:
+			if err := send(update); err != nil {
+				if status, ok := grpcstatus.FromError(err); ok && status.Code() == codes.Canceled {
+					logger.Infof("%s client: %s %s (%s) cancelled the operation", s.kind, uid, clientInfo.HostName, clientInfo.IPAddress)
+					return nil
+				}
+				logger.Errorf("Failed to send %s: %v", s.kind, err)
+				return err
+			}
+		}
+	}
 }
 
 type grpcExporter struct {
 	protobuf.UnimplementedSentryFlowServer
-	apiEvents chan *protobuf.APIEvent
-	logger    *zap.SugaredLogger
-	clients   *clientList
+	apiEvents      chan *protobuf.APIEvent
+	logger         *zap.SugaredLogger
+	clients        *clientList
+	metricsClients *subscriberList[*protobuf.APIMetrics]
+	envoyClients   *subscriberList[*protobuf.EnvoyMetrics]
+	envoyMetrics   chan *protobuf.EnvoyMetrics
+
+	// apiCounts holds the per-API counts streamed by GetAPIMetrics. It's
+	// replaced when the window or interval changes.
+	apiCounts atomic.Pointer[apiCounts]
+	intervals chan time.Duration
+	settings  apiMetricsSettings
+
+	// redactor redacts the API events streamed to clients, it's nil if they
+	// aren't redacted.
+	redactor atomic.Pointer[redact.Redactor]
+
+	// queues configures the queue of every client, see
+	// config.QueueGrpcClients.
+	queues *config.QueuesConfig
+}
+
+type apiMetricsSettings struct {
+	window   time.Duration
+	interval time.Duration
+	maxAPIs  int
 }
 
 // GetAPIEvent streams generated API events to connected clients. Each client is
@@ -66,11 +178,15 @@ func (e *grpcExporter) GetAPIEvent(clientInfo *protobuf.ClientInfo, stream grpc.
 }
 
 func (e *grpcExporter) addClientToList(uid string) chan *protobuf.APIEvent {
+	cfg := e.queues.Queue(config.QueueGrpcClients)
+	// The clients' queues don't spill, creating them can't fail.
+	clientQueue, _ := queue.New(config.QueueGrpcClients, make(chan *protobuf.APIEvent, cfg.BufferSize), cfg)
+
 	e.clients.Lock()
-	connChan := make(chan *protobuf.APIEvent, 1000)
-	e.clients.client[uid] = connChan
+	e.clients.client[uid] = clientQueue
 	e.clients.Unlock()
-	return connChan
+	metrics.GrpcClients.WithLabelValues(apiEventStream).Inc()
+	return clientQueue.Events()
 }
 
 func (e *grpcExporter) deleteClientFromList(uid string, connChan chan *protobuf.APIEvent) {
@@ -78,6 +194,114 @@ func (e *grpcExporter) deleteClientFromList(uid string, connChan chan *protobuf.
 	close(connChan)
 	delete(e.clients.client, uid)
 	e.clients.Unlock()
+	metrics.GrpcClients.WithLabelValues(apiEventStream).Dec()
+}
+
+// GetAPIMetrics periodically streams the number of calls per API over the
+// configured sliding window to connected clients. APIs are identified by their
+// method, destination and path, see apiKey.
+func (e *grpcExporter) GetAPIMetrics(clientInfo *protobuf.ClientInfo, stream grpc.ServerStreamingServer[protobuf.APIMetrics]) error {
+	return e.metricsClients.stream(stream.Context(), e.logger, clientInfo, stream.Send)
+}
+
+// GetEnvoyMetrics streams the stats scraped from Envoy proxies to connected
+// clients, one message per proxy and scrape.
+func (e *grpcExporter) GetEnvoyMetrics(clientInfo *protobuf.ClientInfo, stream grpc.ServerStreamingServer[protobuf.EnvoyMetrics]) error {
+	return e.envoyClients.stream(stream.Context(), e.logger, clientInfo, stream.Send)
+}
+
+// putEnvoyMetricsOnClientsChannel forwards the scraped Envoy stats to all
+// connected clients until ctx is cancelled.
+func (e *grpcExporter) putEnvoyMetricsOnClientsChannel(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case envoyMetrics, ok := <-e.envoyMetrics:
+			if !ok {
+				e.logger.Warn("Envoy metrics channel closed")
+				return
+			}
+			e.envoyClients.publish(envoyMetrics)
+		}
+	}
+}
+
+// pushAPIMetrics sends the per-API counts to every subscribed client once per
+// interval. Clients that haven't consumed the previous counts yet only get the
+// latest ones.
+func (e *grpcExporter) pushAPIMetrics(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case interval := <-e.intervals:
+			ticker.Reset(interval)
+		case <-ticker.C:
+			counts := e.apiCounts.Load()
+			apiMetrics := &protobuf.APIMetrics{PerAPICounts: counts.snapshot()}
+			counts.rotate()
+
+			e.metricsClients.publish(apiMetrics)
+		}
+	}
+}
+
+// Reconfigure applies a changed redact configuration and API metrics window,
+// interval or maximum number of APIs. The per-API counts start over when any of
+// the latter changes.
+func (e *grpcExporter) Reconfigure(ctx context.Context, cfg *config.Config) error {
+	r, err := newRedactor(grpcRedactConfigOf(cfg))
+	if err != nil {
+		return err
+	}
+	e.redactor.Store(r)
+
+	settings := apiMetricsSettingsOf(cfg)
+	if settings == e.settings {
+		return nil
+	}
+
+	select {
+	case e.intervals <- settings.interval:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.apiCounts.Store(newAPICounts(settings.window, settings.interval, settings.maxAPIs))
+	e.settings = settings
+
+	e.logger.Infow("API metrics reconfigured", "window", settings.window, "interval", settings.interval, "maxAPIs", settings.maxAPIs)
+	return nil
+}
+
+func apiMetricsSettingsOf(cfg *config.Config) apiMetricsSettings {
+	settings := apiMetricsSettings{
+		window:   config.DefaultGrpcAPIMetricsWindow,
+		interval: config.DefaultGrpcAPIMetricsInterval,
+		maxAPIs:  config.DefaultGrpcAPIMetricsMaxAPIs,
+	}
+	if cfg.Exporter != nil && cfg.Exporter.Grpc != nil {
+		if cfg.Exporter.Grpc.APIMetricsWindow > 0 {
+			settings.window = cfg.Exporter.Grpc.APIMetricsWindow
+		}
+		if cfg.Exporter.Grpc.APIMetricsInterval > 0 {
+			settings.interval = cfg.Exporter.Grpc.APIMetricsInterval
+		}
+		if cfg.Exporter.Grpc.APIMetricsMaxAPIs > 0 {
+			settings.maxAPIs = cfg.Exporter.Grpc.APIMetricsMaxAPIs
+		}
+	}
+	return settings
+}
+
+func grpcRedactConfigOf(cfg *config.Config) *config.RedactConfig {
+	if cfg.Exporter == nil || cfg.Exporter.Grpc == nil {
+		return nil
+	}
+	return cfg.Exporter.Grpc.Redact
 }
 
 // SendAPIEvent ingests an API event received from the source and publishes it to
@@ -94,7 +318,8 @@ func (e *grpcExporter) SendAPIEvent(ctx context.Context, apiEvent *protobuf.APIE
 }
 
 // putApiEventOnClientsChannel continuously listens to the `apiEvents` channel
-// and forwards incoming API events to all connected clients. If the context is
+// and forwards incoming API events to all connected clients, according to the
+// backpressure policy of their queue, which never blocks. If the context is
 // canceled, the function returns.
 func (e *grpcExporter) putApiEventOnClientsChannel(ctx context.Context) {
 	for {
@@ -106,15 +331,12 @@ func (e *grpcExporter) putApiEventOnClientsChannel(ctx context.Context) {
 				e.logger.Warn("Channel closed")
 				continue
 			}
-			eventToSend := apiEvent
+			e.apiCounts.Load().add(apiEvent)
+			eventToSend := redacted(e.redactor.Load(), apiEvent)
 			e.clients.Lock()
-			for uid, clientChan := range e.clients.client {
-				select {
-				case clientChan <- eventToSend:
-				default:
-					<-clientChan // Drop oldest event
-					e.logger.Warnf("Client %s channel full, dropping oldest event", uid)
-					clientChan <- eventToSend // ADD NEWEST
+			for uid, clientQueue := range e.clients.client {
+				if err := clientQueue.Push(ctx, eventToSend); err != nil {
+					e.logger.Warnf("Client %s channel full, dropping event: %v", uid, err)
 				}
 			}
 			e.clients.Unlock()
@@ -124,8 +346,8 @@ func (e *grpcExporter) putApiEventOnClientsChannel(ctx context.Context) {
 
 // InitGRPCExporter initializes and registers the gRPC-based exporter with the provided
 // server. This allows clients to connect and consume the generated API events
-// streamed through the server.
-func InitGRPCExporter(ctx context.Context, server *grpc.Server, cfg *config.Config, events chan *protobuf.APIEvent, wg *sync.WaitGroup) error {
+// and API metrics streamed through the server.
+func InitGRPCExporter(ctx context.Context, server *grpc.Server, cfg *config.Config, events chan *protobuf.APIEvent, envoyMetrics chan *protobuf.EnvoyMetrics, wg *sync.WaitGroup) (Reconfigurer, error) {
 	logger := util.LoggerFromCtx(ctx).Named("grpc-exporter")
 	logger.Info("Starting grpc exporter")
 
@@ -134,11 +356,24 @@ func InitGRPCExporter(ctx context.Context, server *grpc.Server, cfg *config.Conf
 		logger:    logger,
 		clients: &clientList{
 			Mutex:  &sync.Mutex{},
-			client: make(map[string]chan *protobuf.APIEvent),
+			client: make(map[string]*queue.Queue),
 		},
+		metricsClients: &subscriberList[*protobuf.APIMetrics]{kind: apiMetricsStream, size: 1},
+		envoyClients:   &subscriberList[*protobuf.EnvoyMetrics]{kind: envoyMetricsStream, size: 1000},
+		envoyMetrics:   envoyMetrics,
+		intervals:      make(chan time.Duration),
+		settings:       apiMetricsSettingsOf(cfg),
+		queues:         cfg.Queues,
 	}
+	r, err := newRedactor(grpcRedactConfigOf(cfg))
+	if err != nil {
+		return nil, err
+	}
+	e.redactor.Store(r)
+	e.apiCounts.Store(newAPICounts(e.settings.window, e.settings.interval, e.settings.maxAPIs))
 
 	protobuf.RegisterSentryFlowServer(server, e)
+	protobuf.RegisterAPIClassifierServer(server, &classifier.Server{})
 
 	wg.Add(1)
 	go func(ctx context.Context) {
@@ -146,5 +381,17 @@ func InitGRPCExporter(ctx context.Context, server *grpc.Server, cfg *config.Conf
 		e.putApiEventOnClientsChannel(ctx)
 	}(ctx)
 
-	return nil
+	wg.Add(1)
+	go func(ctx context.Context) {
+		defer wg.Done()
+		e.pushAPIMetrics(ctx, e.settings.interval)
+	}(ctx)
+
+	wg.Add(1)
+	go func(ctx context.Context) {
+		defer wg.Done()
+		e.putEnvoyMetricsOnClientsChannel(ctx)
+	}(ctx)
+
+	return e, nil
 }
@@ -9,11 +9,13 @@ import (
 	"encoding/json"
 	"net"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -22,6 +24,10 @@ import (
 	"k8s.io/apimachinery/pkg/util/rand"
 
 	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/queue"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
 )
 
 func Test_exporter_GetAPIEvent(t *testing.T) {
@@ -89,6 +95,252 @@ func Test_exporter_GetAPIEvent(t *testing.T) {
 	wg.Wait()
 }
 
+func Test_exporter_putApiEventOnClientsChannel_redact(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := getExporter()
+	r, err := newRedactor(&config.RedactConfig{Headers: []string{"x-session"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.redactor.Store(r)
+	clientChan := e.addClientToList("client")
+	go e.putApiEventOnClientsChannel(ctx)
+
+	// Given
+	event := getDummyApiEvent(1)
+	event.Request.Headers["x-session"] = "abc"
+
+	// When
+	e.apiEvents <- event
+	var got *protobuf.APIEvent
+	select {
+	case got = <-clientChan:
+	case <-ctx.Done():
+		t.Fatal("no API event received")
+	}
+
+	// Then
+	if v := got.Request.Headers["x-session"]; v != redact.Mask {
+		t.Errorf("putApiEventOnClientsChannel() x-session = %q, want %q", v, redact.Mask)
+	}
+	if v := event.Request.Headers["x-session"]; v != "abc" {
+		t.Errorf("putApiEventOnClientsChannel() changed original x-session to %q", v)
+	}
+}
+
+func Test_exporter_putApiEventOnClientsChannel_backpressure(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   []uint32
+	}{
+		{name: "with dropOldest policy should keep the latest events", policy: config.QueuePolicyDropOldest, want: []uint32{2, 3}},
+		{name: "with dropNewest policy should keep the first events", policy: config.QueuePolicyDropNewest, want: []uint32{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			e := getExporter()
+			e.queues = &config.QueuesConfig{GrpcClients: &config.QueueConfig{Policy: tt.policy, BufferSize: 2}}
+			clientChan := e.addClientToList("client")
+			clientQueue := e.clients.client["client"]
+			go e.putApiEventOnClientsChannel(ctx)
+
+			// Given a client that doesn't consume its events
+			for i := 1; i <= 3; i++ {
+				e.apiEvents <- getDummyApiEvent(i)
+			}
+
+			// When its queue overflows
+			for clientQueue.Dropped() == 0 {
+				select {
+				case <-ctx.Done():
+					t.Fatal("no API event dropped")
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+
+			// Then
+			var got []uint32
+			for len(clientChan) > 0 {
+				got = append(got, (<-clientChan).Metadata.ContextId)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("putApiEventOnClientsChannel() client got events %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_exporter_GetAPIMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := getExporter()
+	e.intervals = make(chan time.Duration)
+	e.settings = apiMetricsSettings{window: time.Minute, interval: 50 * time.Millisecond, maxAPIs: 10}
+	e.apiCounts.Store(newAPICounts(e.settings.window, e.settings.interval, e.settings.maxAPIs))
+
+	sfClient, closer := getSentryFlowClientAndCloser(t, e)
+	defer closer()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		e.putApiEventOnClientsChannel(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		e.pushAPIMetrics(ctx, e.settings.interval)
+	}()
+
+	// Given
+	stream, err := sfClient.GetAPIMetrics(ctx, getClientInfo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	for i := 0; i < 3; i++ {
+		e.apiEvents <- getDummyApiEvent(i)
+	}
+
+	// Then
+	want := map[string]uint64{"GET destination-workload.destination-namespace /": 3}
+	for {
+		apiMetrics, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("GetAPIMetrics() error = %v, want counts %v", err, want)
+		}
+		if reflect.DeepEqual(apiMetrics.PerAPICounts, want) {
+			break
+		}
+	}
+
+	t.Run("with changed window should start over", func(t *testing.T) {
+		cfg := &config.Config{
+			Exporter: &config.ExporterConfig{
+				Grpc: &config.GrpcConfig{APIMetricsWindow: 2 * time.Minute, APIMetricsInterval: 50 * time.Millisecond},
+			},
+		}
+		if err := e.Reconfigure(ctx, cfg); err != nil {
+			t.Fatalf("Reconfigure() error = %v, wantErr = nil", err)
+		}
+
+		for {
+			apiMetrics, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("GetAPIMetrics() error = %v, want empty counts", err)
+			}
+			if len(apiMetrics.PerAPICounts) == 0 {
+				break
+			}
+		}
+	})
+
+	cancel()
+	wg.Wait()
+}
+
+func Test_exporter_GetEnvoyMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := getExporter()
+
+	sfClient, closer := getSentryFlowClientAndCloser(t, e)
+	defer closer()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.putEnvoyMetricsOnClientsChannel(ctx)
+	}()
+
+	// Given
+	stream, err := sfClient.GetEnvoyMetrics(ctx, getClientInfo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the client to subscribe before publishing.
+	for subscribed := false; !subscribed; time.Sleep(10 * time.Millisecond) {
+		e.envoyClients.Lock()
+		subscribed = len(e.envoyClients.client) == 1
+		e.envoyClients.Unlock()
+	}
+
+	// When
+	want := []string{"backend-1", "backend-2"}
+	for _, name := range want {
+		e.envoyMetrics <- &protobuf.EnvoyMetrics{
+			Namespace: "default",
+			Name:      name,
+			Metrics: map[string]*protobuf.MetricValue{
+				"envoy_server_live": {Value: map[string]string{"": "1"}},
+			},
+		}
+	}
+
+	// Then
+	for _, name := range want {
+		envoyMetrics, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("GetEnvoyMetrics() error = %v", err)
+		}
+		if envoyMetrics.Name != name || envoyMetrics.Metrics["envoy_server_live"].GetValue()[""] != "1" {
+			t.Errorf("GetEnvoyMetrics() got = %v, want metrics of %s", envoyMetrics, name)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+}
+
+func Test_subscriberList_publish(t *testing.T) {
+	// Given
+	s := &subscriberList[int]{size: 2}
+	connChan := s.add("client")
+	defer s.delete("client")
+
+	// When
+	for i := 1; i <= 3; i++ {
+		s.publish(i)
+	}
+
+	// Then
+	if got := []int{<-connChan, <-connChan}; got[0] != 2 || got[1] != 3 {
+		t.Errorf("publish() client got = %v, want [2 3]", got)
+	}
+}
+
+func Test_subscriberList_clients(t *testing.T) {
+	// Given
+	s := &subscriberList[int]{kind: envoyMetricsStream}
+	envoyClients := testutil.ToFloat64(metrics.GrpcClients.WithLabelValues(envoyMetricsStream))
+	apiEventClients := testutil.ToFloat64(metrics.GrpcClients.WithLabelValues(apiEventStream))
+
+	// When
+	s.add("client")
+
+	// Then
+	if got := testutil.ToFloat64(metrics.GrpcClients.WithLabelValues(envoyMetricsStream)) - envoyClients; got != 1 {
+		t.Errorf("add() counted %v %s clients, want 1", got, envoyMetricsStream)
+	}
+	if got := testutil.ToFloat64(metrics.GrpcClients.WithLabelValues(apiEventStream)) - apiEventClients; got != 0 {
+		t.Errorf("add() counted %v %s clients, want 0", got, apiEventStream)
+	}
+	s.delete("client")
+	if got := testutil.ToFloat64(metrics.GrpcClients.WithLabelValues(envoyMetricsStream)) - envoyClients; got != 0 {
+		t.Errorf("delete() left %v %s clients, want 0", got, envoyMetricsStream)
+	}
+}
+
 func Test_exporter_SendAPIEvent(t *testing.T) {
 	e := getExporter()
 
@@ -120,7 +372,7 @@ func Test_exporter_addClientToList(t *testing.T) {
 	e := grpcExporter{
 		clients: &clientList{
 			Mutex:  &sync.Mutex{},
-			client: make(map[string]chan *protobuf.APIEvent),
+			client: make(map[string]*queue.Queue),
 		},
 	}
 	uid := uuid.Must(uuid.NewRandom()).String()
@@ -132,7 +384,7 @@ func Test_exporter_addClientToList(t *testing.T) {
 	e.clients.Lock()
 	got, exists := e.clients.client[uid]
 	e.clients.Unlock()
-	if !exists || got != want || got == nil {
+	if !exists || got == nil || got.Events() != want {
 		t.Errorf("addClientToList() client not added to the client list correctly")
 	}
 }
@@ -142,7 +394,7 @@ func Test_exporter_deleteClientFromList(t *testing.T) {
 	e := grpcExporter{
 		clients: &clientList{
 			Mutex:  &sync.Mutex{},
-			client: make(map[string]chan *protobuf.APIEvent),
+			client: make(map[string]*queue.Queue),
 		},
 	}
 	uid := uuid.Must(uuid.NewRandom()).String()
@@ -164,7 +416,7 @@ func Test_exporter_add_and_delete_client_fromList_concurrently(t *testing.T) {
 	e := grpcExporter{
 		clients: &clientList{
 			Mutex:  &sync.Mutex{},
-			client: make(map[string]chan *protobuf.APIEvent),
+			client: make(map[string]*queue.Queue),
 		},
 	}
 
@@ -240,8 +492,11 @@ func getExporter() *grpcExporter {
 		logger:    zap.S(),
 		clients: &clientList{
 			Mutex:  &sync.Mutex{},
-			client: make(map[string]chan *protobuf.APIEvent),
+			client: make(map[string]*queue.Queue),
 		},
+		metricsClients: &subscriberList[*protobuf.APIMetrics]{kind: apiMetricsStream, size: 1},
+		envoyClients:   &subscriberList[*protobuf.EnvoyMetrics]{kind: envoyMetricsStream, size: 10},
+		envoyMetrics:   make(chan *protobuf.EnvoyMetrics, 10),
 	}
 }
 
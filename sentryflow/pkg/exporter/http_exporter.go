@@ -4,176 +4,243 @@
 package exporter
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"net/http"
-	"os"
-	"strings"
+	"fmt"
 	"sync"
 	"time"
 
-	"google.golang.org/protobuf/encoding/protojson"
-
 	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 	"go.uber.org/zap"
 )
 
+// httpFlushTimeout is how long the events queued for the webhooks are waited
+// for to be sent when the exporter stops or switches to a new configuration.
+const httpFlushTimeout = 10 * time.Second
+
+// Exporter posts API events to the configured webhooks. Its configuration can
+// be replaced at runtime with Reconfigure.
 type Exporter struct {
-	logger   *zap.SugaredLogger
-	client   *http.Client
-	webhooks []config.WebhookConfig
-	events   chan *protobuf.APIEvent
-}
+	reloadable[*delivery]
 
-func InitHTTPExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, wg *sync.WaitGroup) error {
-	if !cfg.Exporter.HTTP.Enabled {
-		return nil
-	}
+	// failed holds the names of the webhooks the events being replayed
+	// couldn't be delivered to, so that they're only sent to those again.
+	failedLock sync.Mutex
+	failed     map[*protobuf.APIEvent]map[string]bool
+}
 
-	logger := util.LoggerFromCtx(ctx).Named("http-exporter")
+// delivery is the webhooks events are sent to, the redactor they're redacted
+// with and the file they're dead-lettered to, along with the sends still in
+// flight. Events that were neither delivered nor dead-lettered are replayed
+// after replayDelay. It has no webhooks while the exporter is disabled.
+type delivery struct {
+	webhooks    []*webhook
+	redactor    *redact.Redactor
+	deadLetters *deadLetters
+	replayDelay time.Duration
+	inflight    sync.WaitGroup
+	// cancel stops the workers of the webhooks.
+	cancel context.CancelFunc
+}
 
-	client, err := buildHTTPClient(cfg)
-	if err != nil {
-		return err
-	}
+func (d *delivery) enabled() bool {
+	return len(d.webhooks) > 0
+}
 
-	exp := &Exporter{
-		logger:   logger,
-		client:   client,
-		webhooks: cfg.Exporter.HTTP.Webhooks,
-		events:   events,
+func (d *delivery) matches(event *protobuf.APIEvent) bool {
+	for _, wh := range d.webhooks {
+		if wh.match.Matches(event) {
+			return true
+		}
 	}
+	return false
+}
 
-	wg.Add(1)
+// close waits for the events queued with d to be sent until ctx is done. It
+// then stops the workers of the webhooks of d and releases its resources.
+func (d *delivery) close(ctx context.Context) error {
+	sent := make(chan struct{})
 	go func() {
-		defer wg.Done()
-		exp.run(ctx)
+		d.inflight.Wait()
+		close(sent)
 	}()
+	select {
+	case <-sent:
+	case <-ctx.Done():
+	}
 
-	logger.Info("HTTP exporter started")
-	return nil
+	if d.cancel != nil {
+		d.cancel()
+	}
+	for _, wh := range d.webhooks {
+		wh.stop()
+	}
+	return d.deadLetters.close()
 }
 
-func (e *Exporter) run(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			e.logger.Info("HTTP exporter context cancelled")
-			return
-
-		case ev, ok := <-e.events:
-			if !ok {
-				e.logger.Warn("HTTP exporter channel closed")
-				return
-			}
-			e.dispatch(ev)
-		}
-	}
+// HTTPDependencies holds the resources the HTTP exporter uses.
+type HTTPDependencies struct {
+	// Acks, if set, is told about every event once it was delivered or
+	// dead-lettered for all webhooks. If it's a Replayer, it's asked to send
+	// the other events again.
+	Acks Acknowledger
+	// Secrets reads the Kubernetes Secrets webhooks refer to, if set.
+	Secrets SecretReader
 }
 
-func (e *Exporter) dispatch(event *protobuf.APIEvent) {
-	for _, wh := range e.webhooks {
-		go e.send(wh, event)
+// InitHTTPExporter starts the HTTP exporter.
+func InitHTTPExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, deps HTTPDependencies, wg *sync.WaitGroup) (*Exporter, error) {
+	exp := &Exporter{failed: make(map[*protobuf.APIEvent]map[string]bool)}
+	exp.reloadable = reloadable[*delivery]{
+		name:         "HTTP",
+		logger:       util.LoggerFromCtx(ctx).Named("http-exporter"),
+		events:       events,
+		acks:         deps.Acks,
+		flushTimeout: httpFlushTimeout,
+		configOf: func(cfg *config.ExporterConfig) any {
+			return cfg.HTTP
+		},
+		newDestination: func(ctx context.Context, cfg *config.Config) (*delivery, error) {
+			return newDelivery(ctx, cfg, deps.Secrets, exp.logger)
+		},
+		send: exp.dispatch,
 	}
+	if err := exp.start(ctx, cfg, wg); err != nil {
+		return nil, err
+	}
+	return exp, nil
 }
 
-func (e *Exporter) send(wh config.WebhookConfig, event *protobuf.APIEvent) {
-	body, err := protojson.Marshal(event)
-	if err != nil {
-		e.logger.Errorf("marshal failed: %v", err)
-		return
+// dispatch queues event for the workers of every webhook of d it matches,
+// waiting for room in their queues. A replayed event is only queued for the
+// webhooks it couldn't be delivered to before.
+func (e *Exporter) dispatch(ctx context.Context, d *delivery, event *protobuf.APIEvent) {
+	e.failedLock.Lock()
+	retry, replayed := e.failed[event]
+	delete(e.failed, event)
+	e.failedLock.Unlock()
+
+	var webhooks []*webhook
+	for _, wh := range d.webhooks {
+		if wh.match.Matches(event) && (!replayed || retry[wh.cfg.Name]) {
+			webhooks = append(webhooks, wh)
+		}
 	}
-
-	req, err := http.NewRequest(wh.Method, wh.URL, bytes.NewBuffer(body))
-	if err != nil {
-		e.logger.Errorf("request creation failed: %v", err)
+	if len(webhooks) == 0 {
+		e.ack(event)
 		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range wh.Headers {
-		req.Header.Set(k, v)
-	}
+	original := event
+	event = redacted(d.redactor, event)
+	var lock sync.Mutex
+	remaining := len(webhooks)
+	failed := make(map[string]bool)
+	for _, wh := range webhooks {
+		name := wh.cfg.Name
+		done := func(delivered bool) {
+			lock.Lock()
+			if !delivered {
+				failed[name] = true
+			}
+			remaining--
+			last := remaining == 0
+			lock.Unlock()
+			if !last {
+				return
+			}
+			if len(failed) > 0 {
+				e.replay(original, failed, d.replayDelay)
+				return
+			}
+			e.ack(original)
+		}
 
-	e.logger.Infow(
-		"sending webhook",
-		"name", wh.Name,
-		"method", wh.Method,
-		"url", wh.URL,
-	)
+		d.inflight.Add(1)
+		select {
+		case wh.jobs <- job{event: event, done: done}:
+		case <-ctx.Done():
+			d.inflight.Done()
+			return
+		}
+	}
+}
 
-	resp, err := e.client.Do(req)
-	if err != nil {
-		e.logger.Errorf("webhook %s failed: %v", wh.Name, err)
+// replay asks the source of event to send it again after delay to the webhooks
+// in failed. Without a durable source, the event is lost.
+func (e *Exporter) replay(event *protobuf.APIEvent, failed map[string]bool, delay time.Duration) {
+	r, ok := e.acks.(Replayer)
+	if !ok {
+		metrics.EventsDropped.WithLabelValues(config.QueueHTTP).Inc()
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode >= 300 {
-		e.logger.Warnf("webhook %s returned status %d", wh.Name, resp.StatusCode)
+	e.failedLock.Lock()
+	e.failed[event] = failed
+	e.failedLock.Unlock()
+	if !r.Replay(event, delay) {
+		e.failedLock.Lock()
+		delete(e.failed, event)
+		e.failedLock.Unlock()
+		e.logger.Warnf("HTTP exporter gave up on event after replaying it")
 	}
 }
 
-func buildHTTPClient(cfg *config.Config) (*http.Client, error) {
-	timeout := time.Duration(cfg.Exporter.HTTP.TimeoutSeconds) * time.Second
-
-	transport := &http.Transport{
-		TLSHandshakeTimeout: 10 * time.Second,
+// newDelivery returns the delivery of cfg, whose webhooks' workers run until
+// it's closed.
+func newDelivery(ctx context.Context, cfg *config.Config, secrets SecretReader, logger *zap.SugaredLogger) (*delivery, error) {
+	if cfg.Exporter == nil || cfg.Exporter.HTTP == nil || !cfg.Exporter.HTTP.Enabled {
+		return &delivery{}, nil
 	}
 
-	var tlsConfig *tls.Config
-
-	for _, wh := range cfg.Exporter.HTTP.Webhooks {
-		if !strings.HasPrefix(strings.ToLower(wh.URL), "https://") {
-			continue
-		}
-
-		if wh.TLS == nil {
-			continue // public CA → default Go TLS
-		}
-
-		if tlsConfig == nil {
-			tlsConfig = &tls.Config{
-				MinVersion: tls.VersionTLS12,
-			}
-		}
+	r, err := newRedactor(cfg.Exporter.HTTP.Redact)
+	if err != nil {
+		return nil, err
+	}
+	deadLetters, err := openDeadLetters(cfg.Exporter.HTTP.DeadLetter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open HTTP exporter dead-letter file: %w", err)
+	}
 
-		if wh.TLS.CACertPath != "" {
-			caCert, err := os.ReadFile(wh.TLS.CACertPath)
-			if err != nil {
-				return nil, err
-			}
-			caPool := x509.NewCertPool()
-			caPool.AppendCertsFromPEM(caCert)
-			tlsConfig.RootCAs = caPool
+	d := &delivery{
+		redactor:    r,
+		deadLetters: deadLetters,
+		replayDelay: time.Second,
+	}
+	if retry := cfg.Exporter.HTTP.Retry; retry != nil {
+		d.replayDelay = max(d.replayDelay, retry.MaxBackoff)
+	}
+	if breaker := cfg.Exporter.HTTP.CircuitBreaker; breaker != nil {
+		d.replayDelay = max(d.replayDelay, breaker.OpenDuration)
+	}
+	timeout := time.Duration(cfg.Exporter.HTTP.TimeoutSeconds) * time.Second
+	for _, cfgWebhook := range cfg.Exporter.HTTP.Webhooks {
+		client, err := newWebhookClient(cfgWebhook, timeout)
+		if err != nil {
+			_ = d.close(ctx)
+			return nil, err
 		}
-
-		if wh.TLS.ClientCertPath != "" && wh.TLS.ClientKeyPath != "" {
-			cert, err := tls.LoadX509KeyPair(
-				wh.TLS.ClientCertPath,
-				wh.TLS.ClientKeyPath,
-			)
-			if err != nil {
-				return nil, err
-			}
-			tlsConfig.Certificates = []tls.Certificate{cert}
+		auth, err := newAuth(ctx, cfgWebhook, client, secrets)
+		if err != nil {
+			_ = d.close(ctx)
+			return nil, err
 		}
-
-		if wh.TLS.InsecureSkipVerify {
-			tlsConfig.InsecureSkipVerify = true
+		wh, err := newWebhook(cfgWebhook, client, cfg.Exporter.HTTP, auth, deadLetters, logger)
+		if err != nil {
+			_ = d.close(ctx)
+			return nil, err
 		}
+		d.webhooks = append(d.webhooks, wh)
 	}
 
-	if tlsConfig != nil {
-		transport.TLSClientConfig = tlsConfig
+	// The workers outlive ctx, which may only be the context of a reload.
+	workersCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d.cancel = cancel
+	for _, wh := range d.webhooks {
+		wh.start(workersCtx, &d.inflight)
 	}
-
-	return &http.Client{
-		Timeout:   timeout,
-		Transport: transport,
-	}, nil
+	return d, nil
 }
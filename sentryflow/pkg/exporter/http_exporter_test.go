@@ -5,15 +5,23 @@ package exporter
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/wal"
 	"go.uber.org/zap"
 )
 
@@ -55,7 +63,7 @@ func TestHTTPExporter_HTTPWebhook(t *testing.T) {
 	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar())
 
 	var wg sync.WaitGroup
-	if err := InitHTTPExporter(ctx, cfg, events, &wg); err != nil {
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{}, &wg); err != nil {
 		t.Fatalf("init failed: %v", err)
 	}
 
@@ -69,6 +77,46 @@ func TestHTTPExporter_HTTPWebhook(t *testing.T) {
 	}
 }
 
+func TestHTTPExporter_Redact(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := getWebhookConfig(server.URL, nil)
+	cfg.Exporter.HTTP.Redact = &config.RedactConfig{Detectors: []string{config.RedactDetectorEmail}}
+
+	events := make(chan *protobuf.APIEvent, 1)
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar())
+
+	var wg sync.WaitGroup
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{}, &wg); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	// Given
+	event := &protobuf.APIEvent{Request: &protobuf.Request{Body: "jane@example.com"}}
+
+	// When
+	events <- event
+
+	// Then
+	select {
+	case body := <-received:
+		if strings.Contains(body, "jane@example.com") || !strings.Contains(body, redact.Mask) {
+			t.Errorf("webhook body = %v, want email redacted", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not received")
+	}
+	if event.Request.Body != "jane@example.com" {
+		t.Errorf("exporter changed original body to %v", event.Request.Body)
+	}
+}
+
 func TestHTTPExporter_HTTPS_Insecure(t *testing.T) {
 	received := make(chan struct{}, 1)
 
@@ -88,7 +136,7 @@ func TestHTTPExporter_HTTPS_Insecure(t *testing.T) {
 						Name:   "https-test",
 						URL:    server.URL,
 						Method: http.MethodPost,
-						TLS: &config.WebhookTLSConfig{
+						TLS: &config.TLSConfig{
 							InsecureSkipVerify: true,
 						},
 					},
@@ -101,7 +149,7 @@ func TestHTTPExporter_HTTPS_Insecure(t *testing.T) {
 	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar())
 
 	var wg sync.WaitGroup
-	if err := InitHTTPExporter(ctx, cfg, events, &wg); err != nil {
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{}, &wg); err != nil {
 		t.Fatalf("init failed: %v", err)
 	}
 
@@ -115,56 +163,450 @@ func TestHTTPExporter_HTTPS_Insecure(t *testing.T) {
 	}
 }
 
-func TestBuildHTTPClient_TLSConfig(t *testing.T) {
+func TestHTTPExporter_ContextCancel(t *testing.T) {
 	cfg := &config.Config{
 		Exporter: &config.ExporterConfig{
 			HTTP: &config.HttpConfig{
-				TimeoutSeconds: 5,
-				Webhooks: []config.WebhookConfig{
-					{
-						URL: "https://example.com",
-						TLS: &config.WebhookTLSConfig{
-							InsecureSkipVerify: true,
-						},
-					},
-				},
+				Enabled: true,
 			},
 		},
 	}
 
-	client, err := buildHTTPClient(cfg)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	events := make(chan *protobuf.APIEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, util.LoggerContextKey{}, zap.NewNop().Sugar())
+
+	var wg sync.WaitGroup
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{}, &wg); err != nil {
+		t.Fatalf("init failed: %v", err)
 	}
 
-	tr, ok := client.Transport.(*http.Transport)
-	if !ok || tr.TLSClientConfig == nil {
-		t.Fatal("TLS config not applied")
+	cancel()
+	wg.Wait()
+}
+
+func TestHTTPExporter_Reconfigure(t *testing.T) {
+	oldStarted := make(chan struct{}, 1)
+	var oldDone atomic.Int32
+	oldServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oldStarted <- struct{}{}
+		time.Sleep(200 * time.Millisecond)
+		oldDone.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer oldServer.Close()
+
+	newReceived := make(chan struct{}, 1)
+	newServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newReceived <- struct{}{}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer newServer.Close()
+
+	events := make(chan *protobuf.APIEvent, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, util.LoggerContextKey{}, zap.NewNop().Sugar())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	exp, err := InitHTTPExporter(ctx, getWebhookConfig(oldServer.URL, nil), events, HTTPDependencies{}, &wg)
+	if err != nil {
+		t.Fatalf("init failed: %v", err)
 	}
 
-	if !tr.TLSClientConfig.InsecureSkipVerify {
-		t.Fatal("InsecureSkipVerify not set")
+	t.Run("with in-flight events should drain them to the old webhook", func(t *testing.T) {
+		// Given
+		events <- &protobuf.APIEvent{}
+		<-oldStarted
+
+		// When
+		if err := exp.Reconfigure(ctx, getWebhookConfig(newServer.URL, nil)); err != nil {
+			t.Fatalf("Reconfigure() error = %v, wantErr = nil", err)
+		}
+
+		// Then
+		if got := oldDone.Load(); got != 1 {
+			t.Errorf("Reconfigure() returned with %d completed old webhook calls, want 1", got)
+		}
+	})
+
+	t.Run("after reconfigure should send events to the new webhook", func(t *testing.T) {
+		events <- &protobuf.APIEvent{}
+
+		select {
+		case <-newReceived:
+		case <-oldStarted:
+			t.Fatal("event sent to old webhook")
+		case <-time.After(2 * time.Second):
+			t.Fatal("new webhook not called")
+		}
+	})
+
+	t.Run("with invalid TLS config should keep the previous webhooks", func(t *testing.T) {
+		tlsConfig := &config.TLSConfig{CACertPath: filepath.Join(t.TempDir(), "missing-ca.crt")}
+		if err := exp.Reconfigure(ctx, getWebhookConfig("https://example.com", tlsConfig)); err == nil {
+			t.Fatal("Reconfigure() error = nil, want error")
+		}
+
+		events <- &protobuf.APIEvent{}
+
+		select {
+		case <-newReceived:
+		case <-time.After(2 * time.Second):
+			t.Fatal("previous webhook not called")
+		}
+	})
+
+	cancel()
+	wg.Wait()
+
+	if err := exp.Reconfigure(context.Background(), getWebhookConfig(oldServer.URL, nil)); err == nil {
+		t.Error("Reconfigure() of stopped exporter error = nil, want error")
 	}
 }
 
-func TestHTTPExporter_ContextCancel(t *testing.T) {
-	cfg := &config.Config{
-		Exporter: &config.ExporterConfig{
-			HTTP: &config.HttpConfig{
-				Enabled: true,
-			},
-		},
+func TestHTTPExporter_Reconfigure_slowWebhook(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	closed := make(chan struct{}, 1)
+	oldServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	oldServer.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+		}
 	}
+	oldServer.Start()
+	defer oldServer.Close()
 
-	events := make(chan *protobuf.APIEvent)
+	events := make(chan *protobuf.APIEvent, 1)
 	ctx, cancel := context.WithCancel(context.Background())
 	ctx = context.WithValue(ctx, util.LoggerContextKey{}, zap.NewNop().Sugar())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	exp, err := InitHTTPExporter(ctx, getWebhookConfig(oldServer.URL, nil), events, HTTPDependencies{}, &wg)
+	if err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	events <- &protobuf.APIEvent{}
+	<-started
+
+	t.Run("with unchanged config should not wait for in-flight events", func(t *testing.T) {
+		// Given
+		reloadCtx, cancelReload := context.WithTimeout(ctx, time.Second)
+		defer cancelReload()
+
+		// When
+		err := exp.Reconfigure(reloadCtx, getWebhookConfig(oldServer.URL, nil))
+
+		// Then
+		if err != nil {
+			t.Errorf("Reconfigure() error = %v, wantErr = nil", err)
+		}
+	})
+
+	t.Run("when timing out should still stop the old webhooks", func(t *testing.T) {
+		// Given
+		reloadCtx, cancelReload := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancelReload()
+
+		// When
+		err := exp.Reconfigure(reloadCtx, getWebhookConfig("http://127.0.0.1:1", nil))
+
+		// Then
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Reconfigure() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		close(release)
+		select {
+		case <-closed:
+		case <-time.After(5 * time.Second):
+			t.Error("old webhook's connections weren't closed once its events were sent")
+		}
+	})
+}
+
+type ackRecorder chan *protobuf.APIEvent
+
+func (a ackRecorder) Ack(event *protobuf.APIEvent) {
+	a <- event
+}
+
+func TestHTTPExporter_Ack(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := getWebhookConfig(server.URL, nil)
+	cfg.Exporter.HTTP.Webhooks = append(cfg.Exporter.HTTP.Webhooks, config.WebhookConfig{
+		Name:   "second",
+		URL:    server.URL,
+		Method: http.MethodPost,
+	})
+	cfg.Exporter.HTTP.Redact = &config.RedactConfig{Detectors: []string{config.RedactDetectorEmail}}
+
+	events := make(chan *protobuf.APIEvent, 1)
+	acks := make(ackRecorder, 1)
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar())
 
 	var wg sync.WaitGroup
-	if err := InitHTTPExporter(ctx, cfg, events, &wg); err != nil {
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{Acks: acks}, &wg); err != nil {
 		t.Fatalf("init failed: %v", err)
 	}
 
-	cancel()
-	wg.Wait()
+	// Given
+	event := &protobuf.APIEvent{Request: &protobuf.Request{Body: "jane@example.com"}}
+
+	// When
+	events <- event
+
+	// Then
+	select {
+	case acked := <-acks:
+		if acked != event {
+			t.Errorf("Ack() called with %v, want the original event", acked)
+		}
+		if got := received.Load(); got != 2 {
+			t.Errorf("Ack() called after %d webhook calls, want 2", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not acknowledged")
+	}
+}
+
+func TestHTTPExporter_WALReplay(t *testing.T) {
+	t.Run("with unavailable webhook should acknowledge the event once replayed", func(t *testing.T) {
+		// Given a webhook that's unavailable once, without dead-letter file
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		log, acks := startWALExporter(t, getWebhookConfig(server.URL, nil))
+
+		// When
+		if err := log.Append(&protobuf.APIEvent{Metadata: &protobuf.Metadata{ContextId: 7}}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+
+		// Then the event is only acknowledged once the log replayed it
+		waitForAck(t, acks, 7)
+		if got := calls.Load(); got != 2 {
+			t.Errorf("Ack() called after %d webhook calls, want 2", got)
+		}
+	})
+
+	t.Run("with rejected event should acknowledge it without replaying", func(t *testing.T) {
+		// Given a webhook that rejects every event, without dead-letter file
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+		log, acks := startWALExporter(t, getWebhookConfig(server.URL, nil))
+
+		// When
+		if err := log.Append(&protobuf.APIEvent{Metadata: &protobuf.Metadata{ContextId: 7}}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+
+		// Then
+		waitForAck(t, acks, 7)
+		if got := calls.Load(); got != 1 {
+			t.Errorf("Ack() called after %d webhook calls, want 1", got)
+		}
+	})
+
+	t.Run("with one unavailable webhook should only replay the event to it", func(t *testing.T) {
+		// Given a webhook that's unavailable once and another one that's up
+		var flakyCalls, healthyCalls atomic.Int32
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if flakyCalls.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer flaky.Close()
+		healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			healthyCalls.Add(1)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer healthy.Close()
+		cfg := getWebhookConfig(flaky.URL, nil)
+		cfg.Exporter.HTTP.Webhooks = append(cfg.Exporter.HTTP.Webhooks, config.WebhookConfig{
+			Name:   "healthy",
+			URL:    healthy.URL,
+			Method: http.MethodPost,
+		})
+		log, acks := startWALExporter(t, cfg)
+
+		// When
+		if err := log.Append(&protobuf.APIEvent{Metadata: &protobuf.Metadata{ContextId: 7}}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+
+		// Then
+		waitForAck(t, acks, 7)
+		if got := flakyCalls.Load(); got != 2 {
+			t.Errorf("unavailable webhook received %d calls, want 2", got)
+		}
+		if got := healthyCalls.Load(); got != 1 {
+			t.Errorf("available webhook received %d calls, want 1", got)
+		}
+	})
+}
+
+// startWALExporter starts an HTTP exporter with cfg that consumes the events
+// of a write-ahead log until the test ends.
+func startWALExporter(t *testing.T, cfg *config.Config) (*wal.Log, walAcks) {
+	t.Helper()
+	log, err := wal.Open(&config.WALConfig{
+		Enabled:      true,
+		Dir:          t.TempDir(),
+		Exporters:    []string{config.QueueHTTP},
+		SegmentBytes: config.DefaultWALSegmentBytes,
+		MaxBytes:     config.DefaultWALMaxBytes,
+		Sync:         config.WALSyncInterval,
+		SyncInterval: config.DefaultWALSyncInterval,
+		MaxReplays:   config.DefaultWALMaxReplays,
+	})
+	if err != nil {
+		t.Fatalf("wal.Open() error = %v", err)
+	}
+
+	events := make(chan *protobuf.APIEvent, 1)
+	acks := walAcks{Reader: log.Reader(config.QueueHTTP), acked: make(chan *protobuf.APIEvent, 1)}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar()))
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+		_ = log.Close()
+	})
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{Acks: acks}, &wg); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		acks.Run(ctx, events, zap.NewNop().Sugar())
+	}()
+	return log, acks
+}
+
+// waitForAck waits for the event with contextID to be acknowledged to acks.
+func waitForAck(t *testing.T, acks walAcks, contextID uint32) {
+	t.Helper()
+	select {
+	case acked := <-acks.acked:
+		if acked.GetMetadata().GetContextId() != contextID {
+			t.Errorf("Ack() called with %v, want the appended event", acked)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not acknowledged")
+	}
+}
+
+// walAcks records the events acknowledged to a write-ahead log reader.
+type walAcks struct {
+	*wal.Reader
+	acked chan *protobuf.APIEvent
+}
+
+func (a walAcks) Ack(event *protobuf.APIEvent) {
+	a.Reader.Ack(event)
+	a.acked <- event
+}
+
+func TestHTTPExporter_Match(t *testing.T) {
+	received := map[string]*atomic.Int32{"pagerduty": {}, "compliance": {}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received[strings.TrimPrefix(r.URL.Path, "/")].Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := getWebhookConfig(server.URL, nil)
+	cfg.Exporter.HTTP.Webhooks = []config.WebhookConfig{
+		{
+			Name:   "pagerduty",
+			URL:    server.URL + "/pagerduty",
+			Method: http.MethodPost,
+			Match:  &config.MatchConfig{Statuses: []string{"5xx"}},
+		},
+		{
+			Name:   "compliance",
+			URL:    server.URL + "/compliance",
+			Method: http.MethodPost,
+			Match:  &config.MatchConfig{Namespaces: []string{"payments"}},
+		},
+	}
+
+	events := make(chan *protobuf.APIEvent, 3)
+	acks := make(ackRecorder, 3)
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar())
+
+	var wg sync.WaitGroup
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{Acks: acks}, &wg); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	// When
+	for _, event := range []*protobuf.APIEvent{
+		{Source: &protobuf.Workload{Namespace: "shop"}, Response: &protobuf.Response{Headers: map[string]string{":status": "503"}}},
+		{Source: &protobuf.Workload{Namespace: "payments"}, Response: &protobuf.Response{Headers: map[string]string{":status": "200"}}},
+		{Source: &protobuf.Workload{Namespace: "shop"}, Response: &protobuf.Response{Headers: map[string]string{":status": "200"}}},
+	} {
+		events <- event
+	}
+
+	// Then every event is acknowledged, including the one no webhook matches
+	for i := 0; i < 3; i++ {
+		select {
+		case <-acks:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%d events acknowledged, want 3", i)
+		}
+	}
+	for name, count := range received {
+		if got := count.Load(); got != 1 {
+			t.Errorf("webhook %s received %d events, want 1", name, got)
+		}
+	}
+}
+
+func getWebhookConfig(url string, tlsConfig *config.TLSConfig) *config.Config {
+	return &config.Config{
+		Exporter: &config.ExporterConfig{
+			HTTP: &config.HttpConfig{
+				Enabled:        true,
+				TimeoutSeconds: 2,
+				Webhooks: []config.WebhookConfig{
+					{
+						Name:   "reconfigure-test",
+						URL:    url,
+						Method: http.MethodPost,
+						TLS:    tlsConfig,
+					},
+				},
+			},
+		},
+	}
 }
@@ -0,0 +1,343 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/payload"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// kafkaFlushTimeout is how long the records left are waited for to be written
+// when the exporter stops or switches to a new configuration.
+const kafkaFlushTimeout = 10 * time.Second
+
+// unknownTopic is the topic label of the records whose topic couldn't be
+// rendered.
+const unknownTopic = "unknown"
+
+// KafkaExporter produces API events to Kafka topics. Its configuration can be
+// replaced at runtime with Reconfigure.
+type KafkaExporter struct {
+	reloadable[*kafkaProducer]
+}
+
+// kafkaProducer is the client events are produced with, and how they're turned
+// into records. Its client is nil while the exporter is disabled.
+type kafkaProducer struct {
+	client   *kgo.Client
+	topic    string
+	template *payload.Transform
+	key      string
+	format   string
+	match    *match.Matcher
+	redactor *redact.Redactor
+}
+
+// KafkaDependencies holds the resources the Kafka exporter uses.
+type KafkaDependencies struct {
+	// Acks, if set, is told about every event once it was written to Kafka
+	// or given up on.
+	Acks Acknowledger
+	// Secrets reads the Kubernetes Secrets the SASL password refers to, if
+	// set.
+	Secrets SecretReader
+}
+
+// InitKafkaExporter starts the Kafka exporter.
+func InitKafkaExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, deps KafkaDependencies, wg *sync.WaitGroup) (*KafkaExporter, error) {
+	exp := &KafkaExporter{}
+	exp.reloadable = reloadable[*kafkaProducer]{
+		name:         "Kafka",
+		logger:       util.LoggerFromCtx(ctx).Named("kafka-exporter"),
+		events:       events,
+		acks:         deps.Acks,
+		flushTimeout: kafkaFlushTimeout,
+		configOf: func(cfg *config.ExporterConfig) any {
+			return cfg.Kafka
+		},
+		newDestination: func(ctx context.Context, cfg *config.Config) (*kafkaProducer, error) {
+			return newKafkaProducer(ctx, cfg, deps.Secrets, exp.logger)
+		},
+		send: exp.produce,
+	}
+	if err := exp.start(ctx, cfg, wg); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+// produce produces event with p, waiting for room in its buffer.
+func (e *KafkaExporter) produce(ctx context.Context, p *kafkaProducer, event *protobuf.APIEvent) {
+	record, err := p.record(redacted(p.redactor, event))
+	if err != nil {
+		e.logger.Warnf("Failed to encode API event for Kafka: %v", err)
+		metrics.KafkaRecords.WithLabelValues(unknownTopic, "failed").Inc()
+		e.ack(event)
+		return
+	}
+
+	start := time.Now()
+	p.client.Produce(ctx, record, func(r *kgo.Record, err error) {
+		metrics.KafkaBufferedRecords.Set(float64(p.client.BufferedProduceRecords()))
+		// Records that weren't written because SentryFlow is stopping aren't
+		// acknowledged, so that they're produced again after a restart.
+		if errors.Is(err, kgo.ErrClientClosed) || errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			e.logger.Warnw("Failed to write record to Kafka", "topic", r.Topic, "error", err)
+			metrics.KafkaRecords.WithLabelValues(r.Topic, "failed").Inc()
+		} else {
+			metrics.KafkaRecords.WithLabelValues(r.Topic, "delivered").Inc()
+			metrics.KafkaProduceDuration.Observe(time.Since(start).Seconds())
+		}
+		e.ack(event)
+	})
+	metrics.KafkaBufferedRecords.Set(float64(p.client.BufferedProduceRecords()))
+}
+
+func (p *kafkaProducer) enabled() bool {
+	return p.client != nil
+}
+
+func (p *kafkaProducer) matches(event *protobuf.APIEvent) bool {
+	return p.match.Matches(event)
+}
+
+// record returns the record of event.
+func (p *kafkaProducer) record(event *protobuf.APIEvent) (*kgo.Record, error) {
+	topic := p.topic
+	if p.template != nil {
+		rendered, err := p.template.Render(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render topic, %v", err)
+		}
+		topic = kafkaTopicName(string(rendered))
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("empty topic")
+	}
+
+	var value []byte
+	var contentType string
+	var err error
+	if p.format == config.KafkaFormatProtobuf {
+		value, err = proto.Marshal(event)
+		contentType = "application/x-protobuf"
+	} else {
+		value, err = protojson.Marshal(event)
+		contentType = "application/json"
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &kgo.Record{
+		Topic:   topic,
+		Key:     kafkaKey(event, p.key),
+		Value:   value,
+		Headers: []kgo.RecordHeader{{Key: "content-type", Value: []byte(contentType)}},
+	}
+	if timestamp := event.GetMetadata().GetTimestamp(); timestamp > 0 {
+		r.Timestamp = time.Unix(int64(timestamp), 0)
+	}
+	return r, nil
+}
+
+// close writes the records left, until ctx is done, and closes the client of
+// p. The records that couldn't be written are failed.
+func (p *kafkaProducer) close(ctx context.Context) error {
+	if p.client == nil {
+		return nil
+	}
+	err := p.client.Flush(ctx)
+	p.client.Close()
+	return err
+}
+
+// kafkaKey returns the key of event's record, nil if records aren't keyed.
+func kafkaKey(event *protobuf.APIEvent, key string) []byte {
+	var value string
+	switch key {
+	case config.KafkaKeyDestinationWorkload:
+		value = workloadKey(event.GetDestination())
+	case config.KafkaKeyDestinationNamespace:
+		value = event.GetDestination().GetNamespace()
+	case config.KafkaKeySourceWorkload:
+		value = workloadKey(event.GetSource())
+	case config.KafkaKeySourceIP:
+		value = event.GetSource().GetIp()
+	}
+	if value == "" {
+		return nil
+	}
+	return []byte(value)
+}
+
+// workloadKey returns the `namespace/name` of w, or its IP address if its name
+// isn't known.
+func workloadKey(w *protobuf.Workload) string {
+	if w.GetName() == "" {
+		return w.GetIp()
+	}
+	return w.GetNamespace() + "/" + w.GetName()
+}
+
+// kafkaTopicName replaces the characters that aren't allowed in topic names
+// with `_`, and cuts the name to the maximum length of topic names.
+func kafkaTopicName(topic string) string {
+	name := []byte(strings.TrimSpace(topic))
+	for i, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	return string(name[:min(len(name), 249)])
+}
+
+func newKafkaProducer(ctx context.Context, cfg *config.Config, secrets SecretReader, logger *zap.SugaredLogger) (*kafkaProducer, error) {
+	if cfg.Exporter == nil || cfg.Exporter.Kafka == nil || !cfg.Exporter.Kafka.Enabled {
+		return &kafkaProducer{}, nil
+	}
+	k := cfg.Exporter.Kafka
+
+	r, err := newRedactor(k.Redact)
+	if err != nil {
+		return nil, err
+	}
+	m, err := match.New(k.Match)
+	if err != nil {
+		return nil, err
+	}
+	p := &kafkaProducer{
+		key:      k.Key,
+		format:   k.Format,
+		match:    m,
+		redactor: r,
+	}
+	if strings.Contains(k.Topic, "{{") {
+		p.template, err = payload.New("", k.Topic, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's Kafka topic: %w", err)
+		}
+	} else {
+		p.topic = kafkaTopicName(k.Topic)
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(k.Brokers...),
+		kgo.ClientID("sentryflow"),
+		kgo.WithLogger(kafkaLogger{logger: logger}),
+		kgo.ProducerBatchCompression(kafkaCompression(k.Compression)),
+		kgo.ProducerLinger(k.Linger),
+		kgo.RecordDeliveryTimeout(k.DeliveryTimeout),
+		kgo.MaxBufferedRecords(k.MaxBufferedRecords),
+	}
+	switch k.Acks {
+	case config.KafkaAcksLeader:
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	case config.KafkaAcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()))
+	default:
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	}
+	if k.DisableIdempotence {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	}
+	if k.AutoCreateTopics {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+	if k.TLS != nil {
+		// The client sets the ServerName of every connection to the host of
+		// its broker.
+		tlsConfig, err := newTLSConfig(k.TLS, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's Kafka TLS configuration: %w", err)
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	if k.SASL != nil {
+		mechanism, err := newKafkaSASL(ctx, k.SASL, secrets)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+
+	p.client, err = kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+	return p, nil
+}
+
+func newKafkaSASL(ctx context.Context, cfg *config.KafkaSASLConfig, secrets SecretReader) (sasl.Mechanism, error) {
+	password, err := readSecret(ctx, cfg.Password, secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exporter's Kafka SASL password: %w", err)
+	}
+	switch cfg.Mechanism {
+	case config.KafkaSASLPlain:
+		return plain.Auth{User: cfg.Username, Pass: string(password)}.AsMechanism(), nil
+	case config.KafkaSASLScramSHA256:
+		return scram.Auth{User: cfg.Username, Pass: string(password)}.AsSha256Mechanism(), nil
+	case config.KafkaSASLScramSHA512:
+		return scram.Auth{User: cfg.Username, Pass: string(password)}.AsSha512Mechanism(), nil
+	}
+	return nil, fmt.Errorf("unsupported exporter's Kafka SASL mechanism, %v", cfg.Mechanism)
+}
+
+func kafkaCompression(compression string) kgo.CompressionCodec {
+	switch compression {
+	case config.KafkaCompressionGzip:
+		return kgo.GzipCompression()
+	case config.KafkaCompressionSnappy:
+		return kgo.SnappyCompression()
+	case config.KafkaCompressionLZ4:
+		return kgo.Lz4Compression()
+	case config.KafkaCompressionZstd:
+		return kgo.ZstdCompression()
+	}
+	return kgo.NoCompression()
+}
+
+// kafkaLogger logs the warnings and errors of the Kafka client.
+type kafkaLogger struct {
+	logger *zap.SugaredLogger
+}
+
+func (l kafkaLogger) Level() kgo.LogLevel {
+	return kgo.LogLevelWarn
+}
+
+func (l kafkaLogger) Log(level kgo.LogLevel, msg string, keyvals ...any) {
+	switch level {
+	case kgo.LogLevelError:
+		l.logger.Errorw(msg, keyvals...)
+	case kgo.LogLevelWarn:
+		l.logger.Warnw(msg, keyvals...)
+	default:
+		l.logger.Debugw(msg, keyvals...)
+	}
+}
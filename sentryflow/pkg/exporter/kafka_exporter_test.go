@@ -0,0 +1,379 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestKafkaExporter_Produce(t *testing.T) {
+	// Given
+	broker := newFakeKafka(t)
+	cfg := getKafkaConfig(broker.addr())
+	cfg.Exporter.Kafka.Topic = "sentryflow.{{ .DestinationNamespace }}"
+	cfg.Exporter.Kafka.Key = config.KafkaKeyDestinationWorkload
+
+	events := make(chan *protobuf.APIEvent, 2)
+	acks := make(ackRecorder, 2)
+	ctx, wg := exporterContext(t)
+	if _, err := InitKafkaExporter(ctx, cfg, events, KafkaDependencies{Acks: acks}, wg); err != nil {
+		t.Fatalf("InitKafkaExporter() error = %v", err)
+	}
+
+	// When
+	events <- getAPIEvent("payments", "billing", "/charges")
+	events <- getAPIEvent("orders", "cart", "/items")
+
+	// Then
+	records := broker.waitRecords(t, 2)
+	want := map[string]struct{ key, path string }{
+		"sentryflow.payments": {key: "payments/billing", path: "/charges"},
+		"sentryflow.orders":   {key: "orders/cart", path: "/items"},
+	}
+	for _, r := range records {
+		w, exists := want[r.topic]
+		if !exists {
+			t.Errorf("record produced to unexpected topic %q", r.topic)
+			continue
+		}
+		if string(r.Key) != w.key {
+			t.Errorf("record of topic %s has key %q, want %q", r.topic, r.Key, w.key)
+		}
+		event := &protobuf.APIEvent{}
+		if err := protojson.Unmarshal(r.Value, event); err != nil {
+			t.Fatalf("record of topic %s isn't a protojson event: %v", r.topic, err)
+		}
+		if got := event.GetRequest().GetHeaders()[":path"]; got != w.path {
+			t.Errorf("record of topic %s has path %q, want %q", r.topic, got, w.path)
+		}
+		if len(r.Headers) != 1 || r.Headers[0].Key != "content-type" || string(r.Headers[0].Value) != "application/json" {
+			t.Errorf("record of topic %s has headers %v, want the JSON content-type", r.topic, r.Headers)
+		}
+	}
+	waitAcks(t, acks, 2)
+	if broker.producerIDs() == 0 {
+		t.Error("producer didn't initialize a producer ID, want an idempotent producer")
+	}
+}
+
+func TestKafkaExporter_Reconfigure(t *testing.T) {
+	// Given
+	broker := newFakeKafka(t)
+	events := make(chan *protobuf.APIEvent, 2)
+	acks := make(ackRecorder, 3)
+	ctx, wg := exporterContext(t)
+	exp, err := InitKafkaExporter(ctx, &config.Config{Exporter: &config.ExporterConfig{}}, events, KafkaDependencies{Acks: acks}, wg)
+	if err != nil {
+		t.Fatalf("InitKafkaExporter() error = %v", err)
+	}
+
+	events <- getAPIEvent("payments", "billing", "/charges")
+	waitAcks(t, acks, 1)
+
+	// When
+	cfg := getKafkaConfig(broker.addr())
+	cfg.Exporter.Kafka.Format = config.KafkaFormatProtobuf
+	cfg.Exporter.Kafka.Match = &config.MatchConfig{Namespaces: []string{"payments"}}
+	if err := exp.Reconfigure(ctx, cfg); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	events <- getAPIEvent("orders", "cart", "/items")
+	events <- getAPIEvent("payments", "billing", "/refunds")
+
+	// Then
+	records := broker.waitRecords(t, 1)
+	event := &protobuf.APIEvent{}
+	if err := proto.Unmarshal(records[0].Value, event); err != nil {
+		t.Fatalf("record isn't a protobuf event: %v", err)
+	}
+	if records[0].topic != "sentryflow" || event.GetRequest().GetHeaders()[":path"] != "/refunds" {
+		t.Errorf("produced %s to %s, want /refunds to sentryflow", event.GetRequest().GetHeaders()[":path"], records[0].topic)
+	}
+	waitAcks(t, acks, 2)
+	if got := broker.recordCount(); got != 1 {
+		t.Errorf("produced %d records, want only the matching one", got)
+	}
+}
+
+func TestKafkaKey(t *testing.T) {
+	event := getAPIEvent("payments", "billing", "/charges")
+	event.Source = &protobuf.Workload{Ip: "10.0.0.7"}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "", want: ""},
+		{key: config.KafkaKeyDestinationWorkload, want: "payments/billing"},
+		{key: config.KafkaKeyDestinationNamespace, want: "payments"},
+		{key: config.KafkaKeySourceWorkload, want: "10.0.0.7"},
+		{key: config.KafkaKeySourceIP, want: "10.0.0.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := string(kafkaKey(event, tt.key)); got != tt.want {
+				t.Errorf("kafkaKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKafkaTopicName(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{topic: "sentryflow.payments", want: "sentryflow.payments"},
+		{topic: " sentryflow/api events\n", want: "sentryflow_api_events"},
+		{topic: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := kafkaTopicName(tt.topic); got != tt.want {
+				t.Errorf("kafkaTopicName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func getKafkaConfig(broker string) *config.Config {
+	return &config.Config{
+		Exporter: &config.ExporterConfig{
+			Kafka: &config.KafkaConfig{
+				Enabled:            true,
+				Brokers:            []string{broker},
+				Topic:              "sentryflow",
+				Format:             config.KafkaFormatJSON,
+				Acks:               config.KafkaAcksAll,
+				DeliveryTimeout:    10 * time.Second,
+				MaxBufferedRecords: 100,
+			},
+		},
+	}
+}
+
+// fakeKafka is a single Kafka broker speaking just enough of the protocol for
+// an idempotent producer: ApiVersions, Metadata, InitProducerID and Produce.
+// Every topic exists and has a single partition.
+type fakeKafka struct {
+	listener net.Listener
+
+	lock     sync.Mutex
+	records  []fakeRecord
+	offsets  map[string]int64
+	produced chan struct{}
+	ids      int64
+}
+
+type fakeRecord struct {
+	topic string
+	kmsg.Record
+}
+
+func newFakeKafka(t *testing.T) *fakeKafka {
+	t.Helper()
+	k := &fakeKafka{
+		offsets:  make(map[string]int64),
+		produced: make(chan struct{}, 1),
+	}
+	k.listener = serveFake(t, k.serve)
+	return k
+}
+
+func (k *fakeKafka) addr() string {
+	return k.listener.Addr().String()
+}
+
+func (k *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+
+		req, correlationID, err := parseKafkaRequest(msg)
+		if err != nil {
+			return
+		}
+		resp := k.handle(req)
+		if resp == nil {
+			continue
+		}
+
+		out := binary.BigEndian.AppendUint32(make([]byte, 4), uint32(correlationID))
+		// ApiVersions responses always have a non-flexible header.
+		if resp.IsFlexible() && resp.Key() != kmsg.ApiVersions.Int16() {
+			out = append(out, 0)
+		}
+		out = resp.AppendTo(out)
+		binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+// parseKafkaRequest parses the request header and body of msg.
+func parseKafkaRequest(msg []byte) (kmsg.Request, int32, error) {
+	if len(msg) < 10 {
+		return nil, 0, fmt.Errorf("short request")
+	}
+	key := int16(binary.BigEndian.Uint16(msg))
+	version := int16(binary.BigEndian.Uint16(msg[2:]))
+	correlationID := int32(binary.BigEndian.Uint32(msg[4:]))
+	rest := msg[8:]
+	if clientIDLen := int16(binary.BigEndian.Uint16(rest)); clientIDLen > 0 {
+		rest = rest[clientIDLen:]
+	}
+	rest = rest[2:]
+
+	req := kmsg.RequestForKey(key)
+	if req == nil {
+		return nil, 0, fmt.Errorf("unsupported request key %d", key)
+	}
+	req.SetVersion(version)
+	if req.IsFlexible() {
+		tags, n := binary.Uvarint(rest)
+		rest = rest[n:]
+		for range tags {
+			_, n := binary.Uvarint(rest)
+			rest = rest[n:]
+			size, n := binary.Uvarint(rest)
+			rest = rest[n+int(size):]
+		}
+	}
+	return req, correlationID, req.ReadFrom(rest)
+}
+
+func (k *fakeKafka) handle(req kmsg.Request) kmsg.Response {
+	switch req := req.(type) {
+	case *kmsg.ApiVersionsRequest:
+		resp := req.ResponseKind().(*kmsg.ApiVersionsResponse)
+		for _, supported := range []kmsg.Request{&kmsg.ProduceRequest{}, &kmsg.MetadataRequest{}, &kmsg.ApiVersionsRequest{}, &kmsg.InitProducerIDRequest{}} {
+			resp.ApiKeys = append(resp.ApiKeys, kmsg.ApiVersionsResponseApiKey{
+				ApiKey:     supported.Key(),
+				MaxVersion: supported.MaxVersion(),
+			})
+		}
+		return resp
+
+	case *kmsg.MetadataRequest:
+		resp := req.ResponseKind().(*kmsg.MetadataResponse)
+		host, port, _ := net.SplitHostPort(k.addr())
+		portNum, _ := strconv.Atoi(port)
+		resp.Brokers = []kmsg.MetadataResponseBroker{{NodeID: 1, Host: host, Port: int32(portNum)}}
+		resp.ControllerID = 1
+		for _, topic := range req.Topics {
+			resp.Topics = append(resp.Topics, kmsg.MetadataResponseTopic{
+				Topic:      topic.Topic,
+				Partitions: []kmsg.MetadataResponseTopicPartition{{Leader: 1, Replicas: []int32{1}, ISR: []int32{1}}},
+			})
+		}
+		return resp
+
+	case *kmsg.InitProducerIDRequest:
+		resp := req.ResponseKind().(*kmsg.InitProducerIDResponse)
+		k.lock.Lock()
+		k.ids++
+		resp.ProducerID = k.ids
+		k.lock.Unlock()
+		return resp
+
+	case *kmsg.ProduceRequest:
+		resp := req.ResponseKind().(*kmsg.ProduceResponse)
+		for _, topic := range req.Topics {
+			respTopic := kmsg.ProduceResponseTopic{Topic: topic.Topic}
+			for _, partition := range topic.Partitions {
+				offset, err := k.append(topic.Topic, partition.Records)
+				respPartition := kmsg.ProduceResponseTopicPartition{Partition: partition.Partition, BaseOffset: offset, LogAppendTime: -1}
+				if err != nil {
+					respPartition.ErrorCode = 2 // CORRUPT_MESSAGE
+				}
+				respTopic.Partitions = append(respTopic.Partitions, respPartition)
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		if req.Acks == 0 {
+			return nil
+		}
+		return resp
+	}
+	return nil
+}
+
+// append stores the records of the uncompressed record batch of topic, and
+// returns the offset of its first record.
+func (k *fakeKafka) append(topic string, records []byte) (int64, error) {
+	batch := kmsg.RecordBatch{}
+	if err := batch.ReadFrom(records); err != nil {
+		return 0, err
+	}
+	var parsed []fakeRecord
+	raw := batch.Records
+	for range batch.NumRecords {
+		length, n := binary.Varint(raw)
+		if n <= 0 || int(length)+n > len(raw) {
+			return 0, errors.New("truncated record")
+		}
+		r := fakeRecord{topic: topic}
+		if err := r.ReadFrom(raw[:n+int(length)]); err != nil {
+			return 0, err
+		}
+		parsed = append(parsed, r)
+		raw = raw[n+int(length):]
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	offset := k.offsets[topic]
+	k.offsets[topic] += int64(len(parsed))
+	k.records = append(k.records, parsed...)
+	select {
+	case k.produced <- struct{}{}:
+	default:
+	}
+	return offset, nil
+}
+
+// waitRecords waits for n records to be produced and returns them.
+func (k *fakeKafka) waitRecords(t *testing.T, n int) []fakeRecord {
+	t.Helper()
+	return waitReceived(t, k.produced, func() []fakeRecord {
+		k.lock.Lock()
+		defer k.lock.Unlock()
+		return append([]fakeRecord(nil), k.records...)
+	}, n)
+}
+
+func (k *fakeKafka) recordCount() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return len(k.records)
+}
+
+func (k *fakeKafka) producerIDs() int64 {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.ids
+}
@@ -0,0 +1,446 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/payload"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+const (
+	// natsFlushTimeout is how long the messages left are waited for to be
+	// written, and acknowledged by JetStream, when the exporter stops or
+	// switches to a new configuration.
+	natsFlushTimeout = 10 * time.Second
+
+	// natsStreamTimeout is how long the exporter waits for the JetStream API
+	// when it makes sure its stream exists.
+	natsStreamTimeout = 10 * time.Second
+)
+
+// NATSExporter publishes API events to NATS subjects, optionally into a
+// JetStream stream. Its configuration can be replaced at runtime with
+// Reconfigure.
+type NATSExporter struct {
+	reloadable[*natsPublisher]
+}
+
+// natsPublisher is the connection events are published with, and how they're
+// turned into messages. Its conn is nil while the exporter is disabled.
+type natsPublisher struct {
+	logger   *zap.SugaredLogger
+	ack      func(*protobuf.APIEvent)
+	conn     *nats.Conn
+	subject  string
+	template *payload.Transform
+	format   string
+	match    *match.Matcher
+	redactor *redact.Redactor
+
+	// js, stream and pending are only set when events are published into a
+	// JetStream stream. The futures of the messages are sent to pending, and
+	// waited for by the acker goroutine until pending is closed or stop is.
+	// acked is closed once it returns.
+	js      jetstream.JetStream
+	stream  string
+	pending chan natsPending
+	stop    chan struct{}
+	acked   chan struct{}
+
+	// create is the configuration of the stream, if it's created when it
+	// doesn't exist. connected tells the goroutine creating it about new
+	// connections.
+	create      *jetstream.StreamConfig
+	streamReady atomic.Bool
+	connected   chan struct{}
+}
+
+// natsPending is a message waiting to be acknowledged by JetStream.
+type natsPending struct {
+	future jetstream.PubAckFuture
+	event  *protobuf.APIEvent
+}
+
+// NATSDependencies holds the resources the NATS exporter uses.
+type NATSDependencies struct {
+	// Acks, if set, is told about every event once it was published to NATS,
+	// or stored by JetStream, or given up on.
+	Acks Acknowledger
+}
+
+// InitNATSExporter starts the NATS exporter.
+func InitNATSExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, deps NATSDependencies, wg *sync.WaitGroup) (*NATSExporter, error) {
+	exp := &NATSExporter{}
+	exp.reloadable = reloadable[*natsPublisher]{
+		name:         "NATS",
+		logger:       util.LoggerFromCtx(ctx).Named("nats-exporter"),
+		events:       events,
+		acks:         deps.Acks,
+		flushTimeout: natsFlushTimeout,
+		configOf: func(cfg *config.ExporterConfig) any {
+			return cfg.NATS
+		},
+		newDestination: exp.newPublisher,
+		send: func(ctx context.Context, p *natsPublisher, event *protobuf.APIEvent) {
+			p.publish(ctx, event)
+		},
+	}
+	if err := exp.start(ctx, cfg, wg); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+// publish publishes event with p. Into a JetStream stream, it waits while too
+// many messages are waiting for their acknowledgement.
+func (p *natsPublisher) publish(ctx context.Context, event *protobuf.APIEvent) {
+	msg, err := p.message(redacted(p.redactor, event))
+	if err != nil {
+		p.logger.Warnf("Failed to encode API event for NATS: %v", err)
+		metrics.NATSMessages.WithLabelValues("failed").Inc()
+		p.ack(event)
+		return
+	}
+
+	if p.js == nil {
+		err = p.conn.PublishMsg(msg)
+		if err == nil {
+			metrics.NATSMessages.WithLabelValues("published").Inc()
+			p.ack(event)
+			return
+		}
+		p.failed(event, err)
+		return
+	}
+
+	for {
+		future, err := p.js.PublishMsgAsync(msg, jetstream.WithExpectStream(p.stream))
+		if err == nil {
+			select {
+			case p.pending <- natsPending{future: future, event: event}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		if !errors.Is(err, jetstream.ErrTooManyStalledMsgs) {
+			p.failed(event, err)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// failed accounts for the event whose message couldn't be published because
+// of err. Messages that weren't published because SentryFlow is stopping
+// aren't acknowledged, so that they're published again after a restart.
+func (p *natsPublisher) failed(event *protobuf.APIEvent, err error) {
+	switch {
+	case errors.Is(err, nats.ErrConnectionClosed):
+		return
+	case errors.Is(err, nats.ErrReconnectBufExceeded):
+		metrics.NATSMessages.WithLabelValues("dropped").Inc()
+		metrics.EventsDropped.WithLabelValues(config.QueueNATS).Inc()
+	default:
+		p.logger.Warnw("Failed to publish message to NATS", "error", err)
+		metrics.NATSMessages.WithLabelValues("failed").Inc()
+	}
+	p.ack(event)
+}
+
+// ackStored waits for the acknowledgements of the messages sent to pending.
+func (p *natsPublisher) ackStored() {
+	defer close(p.acked)
+	for m := range p.pending {
+		if !p.waitStored(m) {
+			return
+		}
+	}
+}
+
+// waitStored waits for the acknowledgement of m, or for stop to be closed. A
+// message whose acknowledgement was lost with the connection is published
+// again.
+func (p *natsPublisher) waitStored(m natsPending) bool {
+	for {
+		select {
+		case <-p.stop:
+			return false
+		case <-m.future.Ok():
+			metrics.NATSMessages.WithLabelValues("published").Inc()
+			p.ack(m.event)
+			return true
+		case err := <-m.future.Err():
+			if errors.Is(err, nats.ErrDisconnected) {
+				m.future, err = p.js.PublishMsgAsync(m.future.Msg())
+			}
+			if err != nil {
+				p.failed(m.event, err)
+				return true
+			}
+		}
+	}
+}
+
+// message returns the message of event.
+func (p *natsPublisher) message(event *protobuf.APIEvent) (*nats.Msg, error) {
+	subject := p.subject
+	if p.template != nil {
+		rendered, err := p.template.Render(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render subject, %v", err)
+		}
+		subject = natsSubject(string(rendered))
+	}
+
+	msg := nats.NewMsg(subject)
+	var err error
+	if p.format == config.NATSFormatProtobuf {
+		msg.Data, err = proto.Marshal(event)
+		msg.Header.Set("Content-Type", "application/x-protobuf")
+	} else {
+		msg.Data, err = protojson.Marshal(event)
+		msg.Header.Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// maintainStream makes sure the stream of p exists whenever it connects, until
+// stop is closed.
+func (p *natsPublisher) maintainStream() {
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-p.connected:
+			p.ensureStream()
+		}
+	}
+}
+
+// ensureStream creates the stream of p if it doesn't exist yet.
+func (p *natsPublisher) ensureStream() {
+	if p.streamReady.Load() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), natsStreamTimeout)
+	defer cancel()
+
+	_, err := p.js.Stream(ctx, p.stream)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		_, err = p.js.CreateStream(ctx, *p.create)
+		if err == nil {
+			p.logger.Infow("Created JetStream stream", "stream", p.stream)
+		}
+	}
+	if err != nil {
+		p.logger.Warnw("Failed to make sure the JetStream stream exists", "stream", p.stream, "error", err)
+		return
+	}
+	p.streamReady.Store(true)
+}
+
+func (p *natsPublisher) enabled() bool {
+	return p.conn != nil
+}
+
+func (p *natsPublisher) matches(event *protobuf.APIEvent) bool {
+	return p.match.Matches(event)
+}
+
+// close writes the messages left, and waits for their acknowledgements, until
+// ctx is done, and closes the connection of p. The messages that weren't
+// acknowledged are left as they are.
+func (p *natsPublisher) close(ctx context.Context) error {
+	if p.conn == nil {
+		return nil
+	}
+	defer p.conn.Close()
+
+	var err error
+	if p.js != nil {
+		select {
+		case <-p.js.PublishAsyncComplete():
+		case <-ctx.Done():
+		}
+		close(p.pending)
+		select {
+		case <-p.acked:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		close(p.stop)
+		<-p.acked
+	}
+	if err == nil && p.conn.IsConnected() {
+		flushCtx, cancel := context.WithTimeout(ctx, natsFlushTimeout)
+		defer cancel()
+		err = p.conn.FlushWithContext(flushCtx)
+	}
+	return err
+}
+
+// natsSubject replaces the characters that aren't allowed in the tokens of
+// subjects, i.e. whitespace and wildcards, with `_`, as well as empty tokens.
+func natsSubject(subject string) string {
+	tokens := strings.Split(strings.TrimSpace(subject), ".")
+	for i, token := range tokens {
+		if token == "" {
+			tokens[i] = "_"
+			continue
+		}
+		tokens[i] = strings.Map(func(r rune) rune {
+			if r == '*' || r == '>' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+				return '_'
+			}
+			return r
+		}, token)
+	}
+	return strings.Join(tokens, ".")
+}
+
+func (e *NATSExporter) newPublisher(ctx context.Context, cfg *config.Config) (*natsPublisher, error) {
+	p := &natsPublisher{logger: e.logger, ack: e.ack}
+	if cfg.Exporter == nil || cfg.Exporter.NATS == nil || !cfg.Exporter.NATS.Enabled {
+		return p, nil
+	}
+	n := cfg.Exporter.NATS
+
+	var err error
+	p.redactor, err = newRedactor(n.Redact)
+	if err != nil {
+		return nil, err
+	}
+	p.match, err = match.New(n.Match)
+	if err != nil {
+		return nil, err
+	}
+	p.format = n.Format
+	if strings.Contains(n.Subject, "{{") {
+		p.template, err = payload.New("", n.Subject, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's NATS subject: %w", err)
+		}
+	} else {
+		p.subject = natsSubject(n.Subject)
+	}
+
+	opts := []nats.Option{
+		nats.Name("sentryflow"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(n.ReconnectWait),
+		nats.ReconnectBufSize(n.ReconnectBufferSize),
+		// Events are buffered until the servers can be reached, rather than
+		// failing SentryFlow's start.
+		nats.RetryOnFailedConnect(true),
+		nats.ConnectHandler(func(*nats.Conn) {
+			e.logger.Info("Connected to NATS")
+			metrics.NATSConnected.Set(1)
+			p.notifyConnected()
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				e.logger.Warnf("Disconnected from NATS: %v", err)
+			}
+			metrics.NATSConnected.Set(0)
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			e.logger.Info("Reconnected to NATS")
+			metrics.NATSConnected.Set(1)
+			metrics.NATSReconnects.Inc()
+			// The stream may have been lost with the server, e.g. when it's
+			// kept in memory.
+			p.streamReady.Store(false)
+			p.notifyConnected()
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			e.logger.Warnf("NATS error: %v", err)
+		}),
+	}
+	if n.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(n.CredentialsFile))
+	}
+	if n.TLS != nil {
+		// The connection sets the ServerName to the host of its server.
+		tlsConfig, err := newTLSConfig(n.TLS, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's NATS TLS configuration: %w", err)
+		}
+		opts = append(opts, nats.Secure(tlsConfig))
+	}
+
+	if js := n.JetStream; js != nil {
+		p.stream = js.Stream
+		p.pending = make(chan natsPending, js.MaxPending)
+		p.stop = make(chan struct{})
+		p.acked = make(chan struct{})
+		if len(js.Subjects) > 0 {
+			p.connected = make(chan struct{}, 1)
+			p.create = &jetstream.StreamConfig{
+				Name:     js.Stream,
+				Subjects: js.Subjects,
+				Storage:  jetstream.FileStorage,
+				MaxAge:   js.MaxAge,
+				MaxBytes: js.MaxBytes,
+				Replicas: js.Replicas,
+			}
+			if js.MaxBytes == 0 {
+				p.create.MaxBytes = -1
+			}
+			if js.Storage == config.NATSStorageMemory {
+				p.create.Storage = jetstream.MemoryStorage
+			}
+		}
+	}
+
+	p.conn, err = nats.Connect(strings.Join(n.URLs, ","), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	if p.pending != nil {
+		p.js, err = jetstream.New(p.conn, jetstream.WithPublishAsyncMaxPending(n.JetStream.MaxPending))
+		if err != nil {
+			p.conn.Close()
+			return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+		}
+		go p.ackStored()
+		if p.create != nil {
+			go p.maintainStream()
+		}
+	}
+	return p, nil
+}
+
+// notifyConnected tells the goroutine creating the stream of p, if any, that
+// p connected.
+func (p *natsPublisher) notifyConnected() {
+	select {
+	case p.connected <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,381 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestNATSExporter_Publish(t *testing.T) {
+	// Given
+	server := newFakeNATS(t)
+	cfg := getNATSConfig(server.url())
+	cfg.Exporter.NATS.Subject = "sentryflow.{{ .DestinationNamespace }}.{{ .DestinationName }}"
+
+	events := make(chan *protobuf.APIEvent, 2)
+	acks := make(ackRecorder, 2)
+	ctx, wg := exporterContext(t)
+	if _, err := InitNATSExporter(ctx, cfg, events, NATSDependencies{Acks: acks}, wg); err != nil {
+		t.Fatalf("InitNATSExporter() error = %v", err)
+	}
+
+	// When
+	events <- getAPIEvent("payments", "billing", "/charges")
+	events <- getAPIEvent("orders", "", "/items")
+
+	// Then
+	messages := server.waitMessages(t, 2)
+	want := map[string]string{
+		"sentryflow.payments.billing": "/charges",
+		"sentryflow.orders._":         "/items",
+	}
+	for _, m := range messages {
+		path, exists := want[m.subject]
+		if !exists {
+			t.Errorf("message published to unexpected subject %q", m.subject)
+			continue
+		}
+		event := &protobuf.APIEvent{}
+		if err := protojson.Unmarshal(m.data, event); err != nil {
+			t.Fatalf("message of subject %s isn't a protojson event: %v", m.subject, err)
+		}
+		if got := event.GetRequest().GetHeaders()[":path"]; got != path {
+			t.Errorf("message of subject %s has path %q, want %q", m.subject, got, path)
+		}
+		if got := m.header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("message of subject %s has Content-Type %q, want application/json", m.subject, got)
+		}
+	}
+	waitAcks(t, acks, 2)
+}
+
+func TestNATSExporter_JetStream(t *testing.T) {
+	// Given
+	server := newFakeNATS(t)
+	events := make(chan *protobuf.APIEvent, 2)
+	acks := make(ackRecorder, 3)
+	ctx, wg := exporterContext(t)
+	exp, err := InitNATSExporter(ctx, &config.Config{Exporter: &config.ExporterConfig{}}, events, NATSDependencies{Acks: acks}, wg)
+	if err != nil {
+		t.Fatalf("InitNATSExporter() error = %v", err)
+	}
+
+	events <- getAPIEvent("payments", "billing", "/charges")
+	waitAcks(t, acks, 1)
+
+	// When
+	cfg := getNATSConfig(server.url())
+	cfg.Exporter.NATS.Subject = "sentryflow.{{ .DestinationNamespace }}"
+	cfg.Exporter.NATS.Format = config.NATSFormatProtobuf
+	cfg.Exporter.NATS.Match = &config.MatchConfig{Namespaces: []string{"payments"}}
+	cfg.Exporter.NATS.JetStream = &config.NATSJetStreamConfig{
+		Stream:     "SENTRYFLOW",
+		Subjects:   []string{"sentryflow.>"},
+		Storage:    config.NATSStorageMemory,
+		Replicas:   1,
+		MaxPending: 10,
+	}
+	if err := exp.Reconfigure(ctx, cfg); err != nil {
+		t.Fatalf("Reconfigure() error = %v", err)
+	}
+	stream := server.waitStream(t, "SENTRYFLOW")
+	events <- getAPIEvent("orders", "cart", "/items")
+	events <- getAPIEvent("payments", "billing", "/refunds")
+
+	// Then
+	if stream.Storage != "memory" || len(stream.Subjects) != 1 || stream.Subjects[0] != "sentryflow.>" {
+		t.Errorf("created stream %+v, want a memory stream of sentryflow.>", stream)
+	}
+	messages := server.waitMessages(t, 1)
+	event := &protobuf.APIEvent{}
+	if err := proto.Unmarshal(messages[0].data, event); err != nil {
+		t.Fatalf("message isn't a protobuf event: %v", err)
+	}
+	if messages[0].subject != "sentryflow.payments" || event.GetRequest().GetHeaders()[":path"] != "/refunds" {
+		t.Errorf("published %s to %s, want /refunds to sentryflow.payments", event.GetRequest().GetHeaders()[":path"], messages[0].subject)
+	}
+	if got := messages[0].header.Get("Nats-Expected-Stream"); got != "SENTRYFLOW" {
+		t.Errorf("message expects stream %q, want SENTRYFLOW", got)
+	}
+	waitAcks(t, acks, 2)
+	if got := server.storedCount(); got != 1 {
+		t.Errorf("stored %d messages, want only the matching one", got)
+	}
+}
+
+func TestNATSSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{subject: "sentryflow.payments.billing", want: "sentryflow.payments.billing"},
+		{subject: " sentryflow.api events.*.>\n", want: "sentryflow.api_events._._"},
+		{subject: "sentryflow..billing.", want: "sentryflow._.billing._"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			if got := natsSubject(tt.subject); got != tt.want {
+				t.Errorf("natsSubject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func getNATSConfig(url string) *config.Config {
+	return &config.Config{
+		Exporter: &config.ExporterConfig{
+			NATS: &config.NATSConfig{
+				Enabled:             true,
+				URLs:                []string{url},
+				Subject:             "sentryflow",
+				Format:              config.NATSFormatJSON,
+				ReconnectWait:       100 * time.Millisecond,
+				ReconnectBufferSize: 1 << 20,
+			},
+		},
+	}
+}
+
+// fakeNATS is a single NATS server speaking just enough of the protocol for a
+// publisher: PUB and HPUB, SUB and UNSUB for the replies, and the JetStream API
+// to look up and create streams. Messages published with a reply subject are
+// acknowledged as stored in the stream whose subjects match theirs.
+type fakeNATS struct {
+	listener net.Listener
+
+	lock      sync.Mutex
+	messages  []fakeNATSMessage
+	streams   map[string]*fakeNATSStream
+	published chan struct{}
+}
+
+type fakeNATSMessage struct {
+	subject string
+	header  nats.Header
+	data    []byte
+}
+
+type fakeNATSStream struct {
+	Name     string   `json:"name"`
+	Subjects []string `json:"subjects"`
+	Storage  string   `json:"storage"`
+	stored   uint64
+}
+
+// fakeNATSConn is a client connection and its subscriptions, by sid.
+type fakeNATSConn struct {
+	lock sync.Mutex
+	conn net.Conn
+	subs map[string]string
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	s := &fakeNATS{
+		streams:   make(map[string]*fakeNATSStream),
+		published: make(chan struct{}, 1),
+	}
+	s.listener = serveFake(t, s.serve)
+	return s
+}
+
+func (s *fakeNATS) url() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	c := &fakeNATSConn{conn: conn, subs: make(map[string]string)}
+	c.write(`INFO {"server_id":"fake","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}` + "\r\n")
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			c.write("PONG\r\n")
+
+		case "SUB":
+			c.lock.Lock()
+			c.subs[args[len(args)-1]] = args[1]
+			c.lock.Unlock()
+
+		case "UNSUB":
+			c.lock.Lock()
+			delete(c.subs, args[1])
+			c.lock.Unlock()
+
+		case "PUB", "HPUB":
+			// PUB <subject> [reply] <size>, HPUB <subject> [reply] <header size> <size>
+			headerSize := 0
+			if args[0] == "HPUB" {
+				headerSize, _ = strconv.Atoi(args[len(args)-2])
+				args = append(args[:len(args)-2], args[len(args)-1])
+			}
+			size, _ := strconv.Atoi(args[len(args)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			m := fakeNATSMessage{subject: args[1], data: payload[headerSize:size]}
+			if headerSize > 0 {
+				m.header, _ = nats.DecodeHeadersMsg(payload[:headerSize])
+			}
+			reply := ""
+			if len(args) == 4 {
+				reply = args[2]
+			}
+			if resp := s.handle(m); reply != "" && resp != nil {
+				c.deliver(reply, resp)
+			}
+		}
+	}
+}
+
+// handle answers the JetStream API requests, and stores the other messages.
+// It returns the reply to m, if any.
+func (s *fakeNATS) handle(m fakeNATSMessage) []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if name, ok := strings.CutPrefix(m.subject, "$JS.API.STREAM.INFO."); ok {
+		stream, exists := s.streams[name]
+		if !exists {
+			return []byte(`{"type":"io.nats.jetstream.api.v1.stream_info_response","error":{"code":404,"err_code":10059,"description":"stream not found"}}`)
+		}
+		return s.streamInfo(stream)
+	}
+	if _, ok := strings.CutPrefix(m.subject, "$JS.API.STREAM.CREATE."); ok {
+		stream := &fakeNATSStream{}
+		if err := json.Unmarshal(m.data, stream); err != nil {
+			return []byte(`{"error":{"code":400,"err_code":10025,"description":"invalid JSON"}}`)
+		}
+		s.streams[stream.Name] = stream
+		return s.streamInfo(stream)
+	}
+
+	s.messages = append(s.messages, m)
+	select {
+	case s.published <- struct{}{}:
+	default:
+	}
+	for _, stream := range s.streams {
+		for _, subject := range stream.Subjects {
+			if fakeNATSMatch(subject, m.subject) {
+				stream.stored++
+				return fmt.Appendf(nil, `{"stream":%q,"seq":%d}`, stream.Name, stream.stored)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fakeNATS) streamInfo(stream *fakeNATSStream) []byte {
+	config, _ := json.Marshal(stream)
+	return fmt.Appendf(nil, `{"type":"io.nats.jetstream.api.v1.stream_info_response","config":%s,"created":"2024-01-01T00:00:00Z","state":{"messages":%d}}`, config, stream.stored)
+}
+
+// deliver sends a message of data to the subscriptions of c matching subject.
+func (c *fakeNATSConn) deliver(subject string, data []byte) {
+	c.lock.Lock()
+	var sids []string
+	for sid, pattern := range c.subs {
+		if fakeNATSMatch(pattern, subject) {
+			sids = append(sids, sid)
+		}
+	}
+	c.lock.Unlock()
+	for _, sid := range sids {
+		c.write(fmt.Sprintf("MSG %s %s %d\r\n%s\r\n", subject, sid, len(data), data))
+	}
+}
+
+func (c *fakeNATSConn) write(s string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, _ = io.WriteString(c.conn, s)
+}
+
+// fakeNATSMatch returns whether subject matches pattern, with the `*` and `>`
+// wildcards.
+func fakeNATSMatch(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// waitMessages waits for n messages to be published, besides the JetStream API
+// requests, and returns them.
+func (s *fakeNATS) waitMessages(t *testing.T, n int) []fakeNATSMessage {
+	t.Helper()
+	return waitReceived(t, s.published, func() []fakeNATSMessage {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return append([]fakeNATSMessage(nil), s.messages...)
+	}, n)
+}
+
+// waitStream waits for the stream name to be created and returns it.
+func (s *fakeNATS) waitStream(t *testing.T, name string) fakeNATSStream {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		s.lock.Lock()
+		stream, exists := s.streams[name]
+		var created fakeNATSStream
+		if exists {
+			created = *stream
+		}
+		s.lock.Unlock()
+		if exists {
+			return created
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			t.Fatalf("stream %s wasn't created", name)
+		}
+	}
+}
+
+func (s *fakeNATS) storedCount() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var stored uint64
+	for _, stream := range s.streams {
+		stored += stream.stored
+	}
+	return stored
+}
@@ -0,0 +1,328 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+// otlpScope is the instrumentation scope of the spans and log records of API
+// events.
+var otlpScope = &commonpb.InstrumentationScope{Name: "github.com/accuknox/SentryFlow/sentryflow"}
+
+// otlpEventName is the event name of the log records of API events.
+const otlpEventName = "sentryflow.api_event"
+
+// Flags of spans whose parent is the remote span of a W3C traceparent.
+const otlpRemoteParentFlags = uint32(tracepb.SpanFlags_SPAN_FLAGS_CONTEXT_HAS_IS_REMOTE_MASK | tracepb.SpanFlags_SPAN_FLAGS_CONTEXT_IS_REMOTE_MASK)
+
+// otlpRecord is what an API event is exported as, shared by its span and its
+// log record so that they're correlated.
+type otlpRecord struct {
+	resource    []*commonpb.KeyValue
+	resourceKey string
+
+	traceID      []byte
+	spanID       []byte
+	parentSpanID []byte
+	traceState   string
+	flags        uint32
+
+	name       string
+	start      uint64
+	end        uint64
+	status     int
+	attributes []*commonpb.KeyValue
+	body       string
+}
+
+// newOTLPRecord returns the record of event. Its span joins the trace of the
+// W3C traceparent of the captured request if any, and starts a new trace
+// otherwise. resourceAttributes replace the attributes derived from the
+// event's destination.
+func newOTLPRecord(event *protobuf.APIEvent, resourceAttributes map[string]string) *otlpRecord {
+	request := event.GetRequest().GetHeaders()
+	response := event.GetResponse().GetHeaders()
+
+	r := &otlpRecord{spanID: randomID(8)}
+	r.resource, r.resourceKey = otlpResource(event.GetDestination(), resourceAttributes)
+
+	if traceID, parentID, flags, ok := parseTraceparent(header(request, "traceparent")); ok {
+		r.traceID, r.parentSpanID = traceID, parentID
+		r.traceState = header(request, "tracestate")
+		r.flags = uint32(flags) | otlpRemoteParentFlags
+	} else {
+		r.traceID = randomID(16)
+		r.flags = 1 // sampled
+	}
+
+	end := time.Now()
+	if timestamp := event.GetMetadata().GetTimestamp(); timestamp > 0 {
+		end = time.Unix(int64(timestamp), 0)
+	}
+	r.end = uint64(end.UnixNano())
+	r.start = r.end - min(event.GetResponse().GetBackendLatencyInNanos(), r.end)
+
+	method := header(request, ":method")
+	route := event.GetRequest().GetRoute()
+	r.name = strings.TrimSpace(method + " " + route)
+	if r.name == "" {
+		r.name = "HTTP"
+	}
+	r.status, _ = strconv.Atoi(header(response, ":status"))
+
+	var attrs otlpAttributes
+	attrs.string("http.request.method", method)
+	attrs.string("http.route", route)
+	path, query, _ := strings.Cut(header(request, ":path"), "?")
+	attrs.string("url.path", path)
+	attrs.string("url.query", query)
+	scheme := header(request, ":scheme")
+	if scheme == "" {
+		scheme = header(request, "x-forwarded-proto")
+	}
+	attrs.string("url.scheme", scheme)
+	if r.status > 0 {
+		attrs.int("http.response.status_code", int64(r.status))
+	}
+
+	if host, port := splitHostPort(header(request, ":authority")); host != "" {
+		attrs.string("server.address", host)
+		attrs.int("server.port", port)
+	} else {
+		attrs.string("server.address", event.GetDestination().GetIp())
+		attrs.int("server.port", int64(event.GetDestination().GetPort()))
+	}
+	// The client is the first address of X-Forwarded-For when the request
+	// went through proxies, and the peer otherwise.
+	client := event.GetSource().GetIp()
+	if forwarded, _, _ := strings.Cut(header(request, "x-forwarded-for"), ","); strings.TrimSpace(forwarded) != "" {
+		client = strings.TrimSpace(forwarded)
+	}
+	attrs.string("client.address", client)
+	attrs.string("network.peer.address", event.GetSource().GetIp())
+	attrs.int("network.peer.port", int64(event.GetSource().GetPort()))
+	if name, version, ok := strings.Cut(event.GetProtocol(), "/"); ok {
+		attrs.string("network.protocol.name", strings.ToLower(name))
+		attrs.string("network.protocol.version", version)
+	}
+	attrs.string("user_agent.original", header(request, "user-agent"))
+
+	attrs.string("sentryflow.source.name", event.GetSource().GetName())
+	attrs.string("sentryflow.source.namespace", event.GetSource().GetNamespace())
+	attrs.string("sentryflow.source.kind", event.GetSource().GetKind())
+	attrs.string("sentryflow.receiver", event.GetMetadata().GetReceiverName())
+	r.attributes = attrs
+
+	body, err := protojson.Marshal(event)
+	if err == nil {
+		r.body = string(body)
+	}
+	return r
+}
+
+// otlpResource returns the resource attributes of the workload an event was
+// sent to, and a key identifying them.
+func otlpResource(w *protobuf.Workload, resourceAttributes map[string]string) ([]*commonpb.KeyValue, string) {
+	values := map[string]string{
+		"service.name":       w.GetName(),
+		"k8s.namespace.name": w.GetNamespace(),
+		"k8s.node.name":      w.GetNodeName(),
+	}
+	if values["service.name"] == "" {
+		values["service.name"] = "unknown_service"
+	}
+	switch w.GetKind() {
+	case "Deployment", "StatefulSet", "DaemonSet", "Pod":
+		values["k8s."+strings.ToLower(w.GetKind())+".name"] = w.GetName()
+	}
+	maps.Copy(values, resourceAttributes)
+
+	var attrs otlpAttributes
+	var key strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		attrs.string(k, values[k])
+		key.WriteString(k + "=" + values[k] + "\n")
+	}
+	return attrs, key.String()
+}
+
+// otlpTraces returns the export request of the spans of records.
+func otlpTraces(records []*otlpRecord) *coltracepb.ExportTraceServiceRequest {
+	req := &coltracepb.ExportTraceServiceRequest{}
+	scopes := make(map[string]*tracepb.ScopeSpans)
+	for _, r := range records {
+		scope, exists := scopes[r.resourceKey]
+		if !exists {
+			scope = &tracepb.ScopeSpans{Scope: otlpScope}
+			scopes[r.resourceKey] = scope
+			req.ResourceSpans = append(req.ResourceSpans, &tracepb.ResourceSpans{
+				Resource:   &resourcepb.Resource{Attributes: r.resource},
+				ScopeSpans: []*tracepb.ScopeSpans{scope},
+			})
+		}
+
+		span := &tracepb.Span{
+			TraceId:           r.traceID,
+			SpanId:            r.spanID,
+			ParentSpanId:      r.parentSpanID,
+			TraceState:        r.traceState,
+			Flags:             r.flags,
+			Name:              r.name,
+			Kind:              tracepb.Span_SPAN_KIND_SERVER,
+			StartTimeUnixNano: r.start,
+			EndTimeUnixNano:   r.end,
+			Attributes:        r.attributes,
+		}
+		// Only server errors are errors of server spans.
+		if r.status >= 500 {
+			span.Status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+	return req
+}
+
+// otlpLogs returns the export request of the log records of records. Their
+// body is the protojson encoded event.
+func otlpLogs(records []*otlpRecord) *collogspb.ExportLogsServiceRequest {
+	req := &collogspb.ExportLogsServiceRequest{}
+	scopes := make(map[string]*logspb.ScopeLogs)
+	observed := uint64(time.Now().UnixNano())
+	for _, r := range records {
+		scope, exists := scopes[r.resourceKey]
+		if !exists {
+			scope = &logspb.ScopeLogs{Scope: otlpScope}
+			scopes[r.resourceKey] = scope
+			req.ResourceLogs = append(req.ResourceLogs, &logspb.ResourceLogs{
+				Resource:  &resourcepb.Resource{Attributes: r.resource},
+				ScopeLogs: []*logspb.ScopeLogs{scope},
+			})
+		}
+
+		severity, severityText := logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "INFO"
+		switch {
+		case r.status >= 500:
+			severity, severityText = logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "ERROR"
+		case r.status >= 400:
+			severity, severityText = logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARN"
+		}
+		scope.LogRecords = append(scope.LogRecords, &logspb.LogRecord{
+			TimeUnixNano:         r.end,
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       severity,
+			SeverityText:         severityText,
+			EventName:            otlpEventName,
+			Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: r.body}},
+			Attributes:           r.attributes,
+			TraceId:              r.traceID,
+			SpanId:               r.spanID,
+			Flags:                r.flags & 0xff,
+		})
+	}
+	return req
+}
+
+// otlpAttributes builds attributes, leaving out the empty ones.
+type otlpAttributes []*commonpb.KeyValue
+
+func (a *otlpAttributes) string(key, value string) {
+	if value == "" {
+		return
+	}
+	*a = append(*a, &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}})
+}
+
+func (a *otlpAttributes) int(key string, value int64) {
+	if value == 0 {
+		return
+	}
+	*a = append(*a, &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}})
+}
+
+// parseTraceparent returns the trace ID, parent span ID and trace flags of a
+// W3C traceparent header.
+func parseTraceparent(value string) ([]byte, []byte, byte, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return nil, nil, 0, false
+	}
+	version, err := hex.DecodeString(value[:2])
+	if err != nil || version[0] == 0xff {
+		return nil, nil, 0, false
+	}
+	// Later versions may add fields after the flags.
+	if len(value) > 55 && (version[0] == 0 || value[55] != '-') {
+		return nil, nil, 0, false
+	}
+	traceID, err := hex.DecodeString(value[3:35])
+	if err != nil || isZero(traceID) {
+		return nil, nil, 0, false
+	}
+	parentID, err := hex.DecodeString(value[36:52])
+	if err != nil || isZero(parentID) {
+		return nil, nil, 0, false
+	}
+	flags, err := hex.DecodeString(value[53:55])
+	if err != nil {
+		return nil, nil, 0, false
+	}
+	return traceID, parentID, flags[0], true
+}
+
+// header returns the value of the header name, whose case may differ.
+func header(headers map[string]string, name string) string {
+	if value, exists := headers[name]; exists {
+		return value
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitHostPort splits an authority into its host and port, 0 if it has
+// none.
+func splitHostPort(authority string) (string, int64) {
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return authority, 0
+	}
+	p, _ := strconv.ParseInt(port, 10, 32)
+	return host, p
+}
+
+func randomID(size int) []byte {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return id
+}
+
+func isZero(id []byte) bool {
+	for _, b := range id {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,428 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// otlpFlushTimeout is how long the events left are waited for to be exported
+// when the exporter stops.
+const otlpFlushTimeout = 10 * time.Second
+
+// OTLPExporter exports API events as spans and log records with the
+// OpenTelemetry protocol, in batches. Its configuration can be replaced at
+// runtime with Reconfigure.
+type OTLPExporter struct {
+	reloadable[*otlpSender]
+
+	// The batch is only accessed by the run goroutine. pending are its
+	// events, and records what they're exported as.
+	pending []*protobuf.APIEvent
+	records []*otlpRecord
+}
+
+// otlpSender is the client events are exported with, and how they're turned
+// into spans and log records. Its client is nil while the exporter is
+// disabled.
+type otlpSender struct {
+	client   otlpClient
+	cfg      *config.OTLPConfig
+	traces   bool
+	logs     bool
+	match    *match.Matcher
+	redactor *redact.Redactor
+}
+
+// otlpClient exports spans and log records to an OTLP server. The exports
+// return the number of items the server rejected, and a *callError if they
+// failed.
+type otlpClient interface {
+	exportTraces(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (int64, error)
+	exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (int64, error)
+	close() error
+}
+
+// OTLPDependencies holds the resources the OTLP exporter uses.
+type OTLPDependencies struct {
+	// Acks, if set, is told about every event once it was exported or given
+	// up on.
+	Acks Acknowledger
+}
+
+// InitOTLPExporter starts the OTLP exporter.
+func InitOTLPExporter(ctx context.Context, cfg *config.Config, events chan *protobuf.APIEvent, deps OTLPDependencies, wg *sync.WaitGroup) (*OTLPExporter, error) {
+	exp := &OTLPExporter{}
+	exp.reloadable = reloadable[*otlpSender]{
+		name:         "OTLP",
+		logger:       util.LoggerFromCtx(ctx).Named("otlp-exporter"),
+		events:       events,
+		acks:         deps.Acks,
+		flushTimeout: otlpFlushTimeout,
+		configOf: func(cfg *config.ExporterConfig) any {
+			return cfg.OTLP
+		},
+		newDestination: func(_ context.Context, cfg *config.Config) (*otlpSender, error) {
+			return newOTLPSender(cfg)
+		},
+		send:  exp.batchEvent,
+		flush: exp.exportBatch,
+	}
+	if err := exp.start(ctx, cfg, wg); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+// batchEvent adds event to the batch, and exports the batch once it's full.
+func (e *OTLPExporter) batchEvent(ctx context.Context, s *otlpSender, event *protobuf.APIEvent) {
+	if len(e.pending) == 0 && s.cfg.MaxBatchSize > 1 {
+		e.startBatch(s.cfg.BatchTimeout)
+	}
+	e.pending = append(e.pending, event)
+	e.records = append(e.records, newOTLPRecord(redacted(s.redactor, event), s.cfg.ResourceAttributes))
+	if len(e.pending) >= s.cfg.MaxBatchSize {
+		e.flushBatch(ctx)
+	}
+}
+
+// exportBatch exports the batch of events with s, and acknowledges them once
+// they were exported or given up on. The events are left unacknowledged if
+// ctx is done before, so that they're exported again after a restart.
+func (e *OTLPExporter) exportBatch(ctx context.Context, s *otlpSender) {
+	if len(e.pending) == 0 {
+		return
+	}
+	done := true
+	if s.traces {
+		done = s.export(ctx, e.logger, config.OTLPSignalTraces, len(e.records), func(ctx context.Context) (int64, error) {
+			return s.client.exportTraces(ctx, otlpTraces(e.records))
+		})
+	}
+	if done && s.logs {
+		done = s.export(ctx, e.logger, config.OTLPSignalLogs, len(e.records), func(ctx context.Context) (int64, error) {
+			return s.client.exportLogs(ctx, otlpLogs(e.records))
+		})
+	}
+	if done {
+		for _, ev := range e.pending {
+			e.ack(ev)
+		}
+	}
+	e.pending, e.records = nil, nil
+}
+
+// export exports the items of signal with call, retrying the exports that
+// failed with a retryable error. It returns false if ctx is done before they
+// were exported or given up on.
+func (s *otlpSender) export(ctx context.Context, logger *zap.SugaredLogger, signal string, items int, call func(context.Context) (int64, error)) bool {
+	start := time.Now()
+	defer func() {
+		metrics.OTLPExportDuration.WithLabelValues(signal).Observe(time.Since(start).Seconds())
+	}()
+
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		rejected, err := call(callCtx)
+		cancel()
+		if err == nil {
+			if rejected > 0 {
+				logger.Warnw("OTLP server rejected items", "signal", signal, "rejected", rejected)
+				metrics.OTLPItems.WithLabelValues(signal, "rejected").Add(float64(rejected))
+			}
+			metrics.OTLPItems.WithLabelValues(signal, "exported").Add(float64(int64(items) - rejected))
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		var callErr *callError
+		if !errors.As(err, &callErr) || !callErr.retryable || attempt >= s.cfg.Retry.MaxAttempts {
+			logger.Warnw("Failed to export with OTLP", "signal", signal, "attempts", attempt, "error", err)
+			metrics.OTLPItems.WithLabelValues(signal, "failed").Add(float64(items))
+			return true
+		}
+
+		metrics.OTLPRetries.WithLabelValues(signal).Inc()
+		timer := time.NewTimer(retryBackoff(*s.cfg.Retry, attempt, callErr.retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *otlpSender) enabled() bool {
+	return s.client != nil
+}
+
+func (s *otlpSender) matches(event *protobuf.APIEvent) bool {
+	return s.match.Matches(event)
+}
+
+// close closes the client of s. Its batch was exported before.
+func (s *otlpSender) close(context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.close()
+}
+
+func newOTLPSender(cfg *config.Config) (*otlpSender, error) {
+	if cfg.Exporter == nil || cfg.Exporter.OTLP == nil || !cfg.Exporter.OTLP.Enabled {
+		return &otlpSender{}, nil
+	}
+	o := cfg.Exporter.OTLP
+
+	r, err := newRedactor(o.Redact)
+	if err != nil {
+		return nil, err
+	}
+	m, err := match.New(o.Match)
+	if err != nil {
+		return nil, err
+	}
+	s := &otlpSender{cfg: o, match: m, redactor: r}
+	for _, signal := range o.Signals {
+		switch signal {
+		case config.OTLPSignalTraces:
+			s.traces = true
+		case config.OTLPSignalLogs:
+			s.logs = true
+		}
+	}
+
+	if o.Protocol == config.OTLPProtocolHTTP {
+		s.client, err = newOTLPHTTPClient(o)
+	} else {
+		s.client, err = newOTLPGRPCClient(o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// otlpGRPCClient exports with OTLP/gRPC.
+type otlpGRPCClient struct {
+	conn     *grpc.ClientConn
+	traces   coltracepb.TraceServiceClient
+	logs     collogspb.LogsServiceClient
+	headers  metadata.MD
+	callOpts []grpc.CallOption
+}
+
+func newOTLPGRPCClient(cfg *config.OTLPConfig) (*otlpGRPCClient, error) {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		tlsCfg := cfg.TLS
+		if tlsCfg == nil {
+			tlsCfg = &config.TLSConfig{}
+		}
+		host, _, err := net.SplitHostPort(cfg.Endpoint)
+		if err != nil {
+			host = cfg.Endpoint
+		}
+		tlsConfig, err := newTLSConfig(tlsCfg, host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's OTLP TLS configuration: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP client: %w", err)
+	}
+	c := &otlpGRPCClient{
+		conn:    conn,
+		traces:  coltracepb.NewTraceServiceClient(conn),
+		logs:    collogspb.NewLogsServiceClient(conn),
+		headers: metadata.New(cfg.Headers),
+	}
+	if cfg.Compression == config.OTLPCompressionGzip {
+		c.callOpts = append(c.callOpts, grpc.UseCompressor(grpcgzip.Name))
+	}
+	return c, nil
+}
+
+func (c *otlpGRPCClient) exportTraces(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (int64, error) {
+	resp, err := c.traces.Export(metadata.NewOutgoingContext(ctx, c.headers), req, c.callOpts...)
+	if err != nil {
+		return 0, otlpGRPCError(err)
+	}
+	return resp.GetPartialSuccess().GetRejectedSpans(), nil
+}
+
+func (c *otlpGRPCClient) exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (int64, error) {
+	resp, err := c.logs.Export(metadata.NewOutgoingContext(ctx, c.headers), req, c.callOpts...)
+	if err != nil {
+		return 0, otlpGRPCError(err)
+	}
+	return resp.GetPartialSuccess().GetRejectedLogRecords(), nil
+}
+
+func (c *otlpGRPCClient) close() error {
+	return c.conn.Close()
+}
+
+// otlpGRPCError returns the *callError of a failed OTLP/gRPC export. The
+// servers ask for exports to be retried with the codes retryable by the OTLP
+// specification, and, when they're overloaded, with a RetryInfo delay.
+func otlpGRPCError(err error) error {
+	st := status.Convert(err)
+	var delay time.Duration
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			delay = info.GetRetryDelay().AsDuration()
+		}
+	}
+	retryable := false
+	switch st.Code() {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		retryable = true
+	case codes.ResourceExhausted:
+		retryable = delay > 0
+	}
+	return &callError{err: err, retryable: retryable, retryAfter: delay}
+}
+
+// otlpHTTPClient exports with OTLP/HTTP, in binary protobuf.
+type otlpHTTPClient struct {
+	client   *http.Client
+	endpoint string
+	headers  map[string]string
+	gzip     bool
+}
+
+func newOTLPHTTPClient(cfg *config.OTLPConfig) (*otlpHTTPClient, error) {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if cfg.TLS != nil {
+		host := ""
+		if u, err := url.Parse(cfg.Endpoint); err == nil {
+			host = u.Hostname()
+		}
+		tlsConfig, err := newTLSConfig(cfg.TLS, host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exporter's OTLP TLS configuration: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &otlpHTTPClient{
+		client:   &http.Client{Transport: transport},
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		headers:  cfg.Headers,
+		gzip:     cfg.Compression == config.OTLPCompressionGzip,
+	}, nil
+}
+
+func (c *otlpHTTPClient) exportTraces(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (int64, error) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if err := c.post(ctx, "/v1/traces", req, resp); err != nil {
+		return 0, err
+	}
+	return resp.GetPartialSuccess().GetRejectedSpans(), nil
+}
+
+func (c *otlpHTTPClient) exportLogs(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (int64, error) {
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if err := c.post(ctx, "/v1/logs", req, resp); err != nil {
+		return 0, err
+	}
+	return resp.GetPartialSuccess().GetRejectedLogRecords(), nil
+}
+
+// post sends req to the path of the server and reads its response into resp.
+func (c *otlpHTTPClient) post(ctx context.Context, path string, req, resp proto.Message) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encoding failed: %w", err)
+	}
+	if c.gzip {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return fmt.Errorf("compression failed: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("compression failed: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("request creation failed: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	if c.gzip {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range c.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return &callError{err: err, retryable: true}
+	}
+	defer httpResp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4<<20))
+
+	if httpResp.StatusCode < 300 {
+		// The partial success is only reported by servers that reject
+		// items.
+		_ = proto.Unmarshal(data, resp)
+		return nil
+	}
+	retryable := httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode == http.StatusBadGateway ||
+		httpResp.StatusCode == http.StatusServiceUnavailable || httpResp.StatusCode == http.StatusGatewayTimeout
+	return &callError{
+		err:        fmt.Errorf("OTLP server returned status %d", httpResp.StatusCode),
+		retryable:  retryable,
+		retryAfter: parseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (c *otlpHTTPClient) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
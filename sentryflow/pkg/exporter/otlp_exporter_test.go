@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestOTLPExporter_GRPC(t *testing.T) {
+	// Given
+	collector := newFakeOTLPCollector(t)
+	cfg := getOTLPConfig(collector.addr())
+	cfg.Exporter.OTLP.Signals = []string{config.OTLPSignalTraces, config.OTLPSignalLogs}
+	cfg.Exporter.OTLP.Headers = map[string]string{"authorization": "Bearer token"}
+
+	events := make(chan *protobuf.APIEvent, 2)
+	acks := make(ackRecorder, 2)
+	ctx, wg := exporterContext(t)
+	if _, err := InitOTLPExporter(ctx, cfg, events, OTLPDependencies{Acks: acks}, wg); err != nil {
+		t.Fatalf("InitOTLPExporter() error = %v", err)
+	}
+
+	// When
+	traced := getAPIEvent("payments", "billing", "/charges")
+	traced.Request.Headers["traceparent"] = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	events <- traced
+	events <- getAPIEvent("orders", "cart", "/items")
+
+	// Then
+	waitAcks(t, acks, 2)
+	traces, logs := collector.received()
+	if len(traces) != 1 || len(logs) != 1 {
+		t.Fatalf("got %d trace and %d log exports, want a single batch of each", len(traces), len(logs))
+	}
+	if got := len(traces[0].GetResourceSpans()); got != 2 {
+		t.Errorf("got spans of %d resources, want one per destination", got)
+	}
+	span := traces[0].GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0]
+	if got := hex.EncodeToString(span.GetTraceId()); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("span has trace ID %s, want the one of the traceparent", got)
+	}
+	if got := hex.EncodeToString(span.GetParentSpanId()); got != "00f067aa0ba902b7" {
+		t.Errorf("span has parent span ID %s, want the one of the traceparent", got)
+	}
+	record := logs[0].GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()[0]
+	if string(record.GetSpanId()) != string(span.GetSpanId()) {
+		t.Errorf("log record has span ID %x, want the one of its span %x", record.GetSpanId(), span.GetSpanId())
+	}
+	if got := collector.authorization(); got != "Bearer token" {
+		t.Errorf("exports have authorization %q, want the configured header", got)
+	}
+}
+
+func TestOTLPExporter_HTTP(t *testing.T) {
+	// Given
+	var calls atomic.Int32
+	received := make(chan *coltracepb.ExportTraceServiceRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("got %s request with Content-Type %q, want protobuf to /v1/traces", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("request body isn't gzip compressed: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(body)
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(data, req); err != nil {
+			t.Errorf("request body isn't an ExportTraceServiceRequest: %v", err)
+		}
+		received <- req
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, _ = w.Write([]byte{})
+	}))
+	defer server.Close()
+
+	cfg := getOTLPConfig(server.URL)
+	cfg.Exporter.OTLP.Protocol = config.OTLPProtocolHTTP
+	cfg.Exporter.OTLP.Compression = config.OTLPCompressionGzip
+	cfg.Exporter.OTLP.MaxBatchSize = 1
+
+	events := make(chan *protobuf.APIEvent, 1)
+	acks := make(ackRecorder, 1)
+	ctx, wg := exporterContext(t)
+	if _, err := InitOTLPExporter(ctx, cfg, events, OTLPDependencies{Acks: acks}, wg); err != nil {
+		t.Fatalf("InitOTLPExporter() error = %v", err)
+	}
+
+	// When
+	events <- getAPIEvent("payments", "billing", "/charges")
+
+	// Then
+	select {
+	case req := <-received:
+		if got := req.GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0].GetName(); got != "POST" {
+			t.Errorf("exported span %q, want POST", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("span wasn't exported")
+	}
+	waitAcks(t, acks, 1)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("got %d calls, want the unavailable one retried once", got)
+	}
+}
+
+func getOTLPConfig(endpoint string) *config.Config {
+	return &config.Config{
+		Exporter: &config.ExporterConfig{
+			OTLP: &config.OTLPConfig{
+				Enabled:      true,
+				Endpoint:     endpoint,
+				Protocol:     config.OTLPProtocolGRPC,
+				Signals:      []string{config.OTLPSignalTraces},
+				Insecure:     true,
+				Timeout:      5 * time.Second,
+				MaxBatchSize: 2,
+				BatchTimeout: time.Minute,
+				Retry:        &config.WebhookRetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+			},
+		},
+	}
+}
+
+// fakeOTLPCollector is an OTLP/gRPC server recording the exports it receives.
+type fakeOTLPCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	listener net.Listener
+
+	lock   sync.Mutex
+	traces []*coltracepb.ExportTraceServiceRequest
+	logs   []*collogspb.ExportLogsServiceRequest
+	auth   string
+}
+
+func newFakeOTLPCollector(t *testing.T) *fakeOTLPCollector {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	c := &fakeOTLPCollector{listener: listener}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, c)
+	collogspb.RegisterLogsServiceServer(server, fakeOTLPLogs{c: c})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return c
+}
+
+func (c *fakeOTLPCollector) addr() string {
+	return c.listener.Addr().String()
+}
+
+func (c *fakeOTLPCollector) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.traces = append(c.traces, req)
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		c.auth = md.Get("authorization")[0]
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// fakeOTLPLogs adapts the collector to the logs service, whose Export method
+// has the same name as the trace service's.
+type fakeOTLPLogs struct {
+	collogspb.UnimplementedLogsServiceServer
+	c *fakeOTLPCollector
+}
+
+func (s fakeOTLPLogs) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.c.lock.Lock()
+	defer s.c.lock.Unlock()
+	s.c.logs = append(s.c.logs, req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (c *fakeOTLPCollector) received() ([]*coltracepb.ExportTraceServiceRequest, []*collogspb.ExportLogsServiceRequest) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.traces, c.logs
+}
+
+func (c *fakeOTLPCollector) authorization() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.auth
+}
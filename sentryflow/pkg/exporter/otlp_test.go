@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"encoding/hex"
+	"maps"
+	"strconv"
+	"testing"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantTraceID string
+		wantParent  string
+		wantFlags   byte
+		wantOK      bool
+	}{
+		{
+			name:        "with valid traceparent should return its fields",
+			value:       "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParent:  "00f067aa0ba902b7",
+			wantFlags:   1,
+			wantOK:      true,
+		},
+		{
+			name:        "with later version and more fields should return the known fields",
+			value:       "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParent:  "00f067aa0ba902b7",
+			wantOK:      true,
+		},
+		{
+			name:  "with version 00 and more fields should return false",
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		},
+		{
+			name:  "with version ff should return false",
+			value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:  "with zero trace ID should return false",
+			value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			name:  "with zero parent ID should return false",
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		},
+		{
+			name:  "with malformed traceparent should return false",
+			value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		},
+		{
+			name:  "with non hex trace ID should return false",
+			value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			traceID, parentID, flags, ok := parseTraceparent(tt.value)
+
+			// Then
+			if ok != tt.wantOK {
+				t.Fatalf("parseTraceparent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got := hex.EncodeToString(traceID); got != tt.wantTraceID {
+				t.Errorf("parseTraceparent() traceID = %v, want %v", got, tt.wantTraceID)
+			}
+			if got := hex.EncodeToString(parentID); got != tt.wantParent {
+				t.Errorf("parseTraceparent() parentID = %v, want %v", got, tt.wantParent)
+			}
+			if flags != tt.wantFlags {
+				t.Errorf("parseTraceparent() flags = %v, want %v", flags, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestNewOTLPRecord(t *testing.T) {
+	// Given
+	event := &protobuf.APIEvent{
+		Metadata: &protobuf.Metadata{Timestamp: 1700000000, ReceiverName: "istio-sidecar"},
+		Source:   &protobuf.Workload{Name: "frontend", Namespace: "shop", Ip: "10.0.0.1", Port: 43210},
+		Destination: &protobuf.Workload{
+			Name:      "billing",
+			Namespace: "payments",
+			Kind:      "Deployment",
+			Ip:        "10.0.0.2",
+			Port:      8080,
+		},
+		Request: &protobuf.Request{
+			Headers: map[string]string{
+				":method":         "GET",
+				":path":           "/charges/42?expand=true",
+				":authority":      "billing.payments:8080",
+				"X-Forwarded-For": "203.0.113.7, 10.0.0.9",
+			},
+			Route: "/charges/{id}",
+		},
+		Response: &protobuf.Response{
+			Headers:               map[string]string{":status": "503"},
+			BackendLatencyInNanos: 2_000_000,
+		},
+		Protocol: "HTTP/1.1",
+	}
+
+	// When
+	r := newOTLPRecord(event, map[string]string{"deployment.environment": "prod"})
+
+	// Then
+	if r.name != "GET /charges/{id}" {
+		t.Errorf("newOTLPRecord() name = %q, want method and route", r.name)
+	}
+	if r.end != 1700000000*1e9 || r.end-r.start != 2_000_000 {
+		t.Errorf("newOTLPRecord() span [%d, %d], want it to end at the event and last its latency", r.start, r.end)
+	}
+	if len(r.traceID) != 16 || len(r.parentSpanID) != 0 || r.flags != 1 {
+		t.Errorf("newOTLPRecord() traceID = %x, parent = %x, flags = %d, want a new sampled trace", r.traceID, r.parentSpanID, r.flags)
+	}
+
+	wantAttributes := map[string]string{
+		"http.request.method":         "GET",
+		"http.route":                  "/charges/{id}",
+		"url.path":                    "/charges/42",
+		"url.query":                   "expand=true",
+		"http.response.status_code":   "503",
+		"server.address":              "billing.payments",
+		"server.port":                 "8080",
+		"client.address":              "203.0.113.7",
+		"network.peer.address":        "10.0.0.1",
+		"network.peer.port":           "43210",
+		"network.protocol.name":       "http",
+		"network.protocol.version":    "1.1",
+		"sentryflow.source.name":      "frontend",
+		"sentryflow.source.namespace": "shop",
+		"sentryflow.receiver":         "istio-sidecar",
+	}
+	if got := attributeMap(r.attributes); !maps.Equal(got, wantAttributes) {
+		t.Errorf("newOTLPRecord() attributes = %v, want %v", got, wantAttributes)
+	}
+	wantResource := map[string]string{
+		"service.name":           "billing",
+		"k8s.namespace.name":     "payments",
+		"k8s.deployment.name":    "billing",
+		"deployment.environment": "prod",
+	}
+	if got := attributeMap(r.resource); !maps.Equal(got, wantResource) {
+		t.Errorf("newOTLPRecord() resource = %v, want %v", got, wantResource)
+	}
+}
+
+func attributeMap(attrs []*commonpb.KeyValue) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		switch v := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			m[kv.GetKey()] = v.StringValue
+		case *commonpb.AnyValue_IntValue:
+			m[kv.GetKey()] = strconv.FormatInt(v.IntValue, 10)
+		}
+	}
+	return m
+}
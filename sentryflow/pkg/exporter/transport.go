@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// newWebhookClient returns the client of a webhook, with its own transport
+// using its TLS and proxy configuration.
+func newWebhookClient(cfg config.WebhookConfig, timeout time.Duration) (*http.Client, error) {
+	transport := &http.Transport{
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	if cfg.Proxy != nil {
+		if cfg.Proxy.FromEnvironment {
+			transport.Proxy = http.ProxyFromEnvironment
+		} else {
+			proxyURL, err := url.Parse(cfg.Proxy.URL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webhook %s proxy url, %v", cfg.Name, err)
+			}
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+
+	if cfg.TLS != nil {
+		host := ""
+		if u, err := url.Parse(cfg.URL); err == nil {
+			host = u.Hostname()
+		}
+		tlsConfig, err := newTLSConfig(cfg.TLS, host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook %s TLS configuration: %w", cfg.Name, err)
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// newTLSConfig returns the TLS configuration of cfg for connections to host,
+// unless the connection's ServerName is set. Its CA and client certificates
+// are loaded again when their files change, e.g. when they're rotated by
+// cert-manager.
+func newTLSConfig(cfg *config.TLSConfig, host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	certs := &certReloader{
+		caPath:   cfg.CACertPath,
+		certPath: cfg.ClientCertPath,
+		keyPath:  cfg.ClientKeyPath,
+	}
+	if certs.certPath == "" || certs.keyPath == "" {
+		certs.certPath, certs.keyPath = "", ""
+	}
+	if certs.caPath == "" && certs.certPath == "" {
+		return tlsConfig, nil
+	}
+	if err := certs.load(); err != nil {
+		return nil, err
+	}
+
+	if certs.certPath != "" {
+		tlsConfig.GetClientCertificate = certs.clientCertificate
+	}
+	if certs.caPath != "" && !cfg.InsecureSkipVerify {
+		// The server certificate is verified with the current CA by
+		// VerifyConnection instead.
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return certs.verify(state, host)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// certReloader holds the CA and client certificates of a webhook, loaded from
+// their files again whenever one of them changed.
+type certReloader struct {
+	caPath   string
+	certPath string
+	keyPath  string
+
+	lock     sync.Mutex
+	modTimes map[string]time.Time
+	roots    *x509.CertPool
+	cert     *tls.Certificate
+}
+
+// load loads the certificates if their files changed since they were last
+// loaded. If they can't be loaded, e.g. because a certificate and its key are
+// being replaced, the previous certificates are kept and loaded again next
+// time.
+func (r *certReloader) load() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	modTimes := make(map[string]time.Time)
+	changed := false
+	for _, path := range []string{r.caPath, r.certPath, r.keyPath} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		modTimes[path] = info.ModTime()
+		changed = changed || !info.ModTime().Equal(r.modTimes[path])
+	}
+	if !changed {
+		return nil
+	}
+
+	var roots *x509.CertPool
+	if r.caPath != "" {
+		pem, err := os.ReadFile(r.caPath)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no CA certificate found in %s", r.caPath)
+		}
+	}
+	var cert *tls.Certificate
+	if r.certPath != "" {
+		loaded, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+		if err != nil {
+			return err
+		}
+		cert = &loaded
+	}
+
+	r.roots, r.cert, r.modTimes = roots, cert, modTimes
+	return nil
+}
+
+func (r *certReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	// The previous certificate is still valid if the new one can't be loaded
+	// yet.
+	_ = r.load()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cert, nil
+}
+
+// verify verifies the certificate the server presented for host with the
+// current CA.
+func (r *certReloader) verify(state tls.ConnectionState, host string) error {
+	_ = r.load()
+	r.lock.Lock()
+	roots := r.roots
+	r.lock.Unlock()
+
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("no server certificate")
+	}
+	if state.ServerName != "" {
+		host = state.ServerName
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestNewWebhookClient_TLSConfig(t *testing.T) {
+	client, err := newWebhookClient(config.WebhookConfig{
+		URL: "https://example.com",
+		TLS: &config.TLSConfig{InsecureSkipVerify: true},
+	}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tr, ok := client.Transport.(*http.Transport)
+	if !ok || tr.TLSClientConfig == nil {
+		t.Fatal("TLS config not applied")
+	}
+	if !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Fatal("InsecureSkipVerify not set")
+	}
+}
+
+func TestNewWebhookClient_perWebhookCA(t *testing.T) {
+	dir := t.TempDir()
+
+	// Given two webhooks whose certificates are issued by different CAs
+	var clients []*http.Client
+	var urls []string
+	for _, name := range []string{"first", "second"} {
+		ca := newTestCA(t, name)
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{ca.issue(t, name, true)}}
+		server.StartTLS()
+		defer server.Close()
+
+		caPath := filepath.Join(dir, name+"-ca.crt")
+		ca.writeCert(t, caPath)
+		client, err := newWebhookClient(config.WebhookConfig{
+			Name: name,
+			URL:  server.URL,
+			TLS:  &config.TLSConfig{CACertPath: caPath},
+		}, 2*time.Second)
+		if err != nil {
+			t.Fatalf("newWebhookClient() error = %v", err)
+		}
+		clients = append(clients, client)
+		urls = append(urls, server.URL)
+	}
+
+	// Then each webhook trusts its own CA only
+	for i, client := range clients {
+		for j, url := range urls {
+			resp, err := client.Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err == nil) != (i == j) {
+				t.Errorf("client %d calling server %d, error = %v", i, j, err)
+			}
+		}
+	}
+}
+
+func TestNewWebhookClient_reloadsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	serverCA := newTestCA(t, "server")
+	clientCA := newTestCA(t, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+	seen := make(chan string, 2)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.TLS.PeerCertificates[0].Subject.CommonName
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCA.issue(t, "server", true)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	// Given a client certificate
+	caPath := filepath.Join(dir, "ca.crt")
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	serverCA.writeCert(t, caPath)
+	writeKeyPair(t, clientCA.issue(t, "first", false), certPath, keyPath, time.Now())
+
+	client, err := newWebhookClient(config.WebhookConfig{
+		Name: "reload-test",
+		URL:  server.URL,
+		TLS:  &config.TLSConfig{CACertPath: caPath, ClientCertPath: certPath, ClientKeyPath: keyPath},
+	}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("newWebhookClient() error = %v", err)
+	}
+	call := func() string {
+		t.Helper()
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		resp.Body.Close()
+		client.CloseIdleConnections()
+		return <-seen
+	}
+	if got := call(); got != "first" {
+		t.Fatalf("server saw client certificate %s, want first", got)
+	}
+
+	// When the certificate is rotated
+	writeKeyPair(t, clientCA.issue(t, "second", false), certPath, keyPath, time.Now().Add(time.Minute))
+
+	// Then the next connection uses the new one
+	if got := call(); got != "second" {
+		t.Errorf("server saw client certificate %s after rotation, want second", got)
+	}
+}
+
+func TestNewWebhookClient_proxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	client, err := newWebhookClient(config.WebhookConfig{
+		Name:  "proxy-test",
+		URL:   "http://webhook.invalid/events",
+		Proxy: &config.WebhookProxyConfig{URL: proxy.URL},
+	}, 2*time.Second)
+	if err != nil {
+		t.Fatalf("newWebhookClient() error = %v", err)
+	}
+
+	resp, err := client.Post("http://webhook.invalid/events", "application/json", nil)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := <-proxied; got != "webhook.invalid" {
+		t.Errorf("proxy got request for %s, want webhook.invalid", got)
+	}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + "-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a certificate for 127.0.0.1 if server, or a client
+// certificate otherwise.
+func (ca *testCA) issue(t *testing.T, name string, server bool) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) writeCert(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeKeyPair writes cert and its key, and sets their modification time.
+func writeKeyPair(t *testing.T, cert tls.Certificate, certPath, keyPath string, modTime time.Time) {
+	t.Helper()
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		certPath: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}),
+		keyPath:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}),
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+// errCircuitOpen is the error of the calls not made because the circuit of
+// their webhook is open.
+var errCircuitOpen = errors.New("circuit open")
+
+// webhook sends events to a single webhook with a pool of workers, in batches
+// if configured, retrying failed calls and dead-lettering the events it gives
+// up on.
+type webhook struct {
+	cfg         config.WebhookConfig
+	client      *http.Client
+	logger      *zap.SugaredLogger
+	encoder     *encoder
+	match       *match.Matcher
+	auth        *auth
+	retry       config.WebhookRetryConfig
+	breaker     *breaker
+	deadLetters *deadLetters
+
+	// maxEvents, maxBytes and maxLinger bound the batches, see
+	// config.WebhookBatchConfig.
+	maxEvents int
+	maxBytes  int
+	maxLinger time.Duration
+
+	workers int
+	jobs    chan job
+	batches chan *batch
+	stopped sync.WaitGroup
+	// inflight is marked done for every job w completes or abandons.
+	inflight *sync.WaitGroup
+}
+
+// job is an event to send, done is called once it was delivered,
+// dead-lettered or dropped, or couldn't be, in which case it's sent again
+// later.
+type job struct {
+	event *protobuf.APIEvent
+	done  func(delivered bool)
+}
+
+// batch is the events sent in a single request, and their encodings.
+type batch struct {
+	jobs    []job
+	encoded [][]byte
+	size    int
+}
+
+func newWebhook(cfg config.WebhookConfig, client *http.Client, httpCfg *config.HttpConfig, auth *auth, deadLetters *deadLetters, logger *zap.SugaredLogger) (*webhook, error) {
+	enc, err := newEncoder(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook %s encoder: %w", cfg.Name, err)
+	}
+
+	m, err := match.New(cfg.Match)
+	if err != nil {
+		enc.close()
+		return nil, fmt.Errorf("invalid webhook %s: %w", cfg.Name, err)
+	}
+
+	retry := config.WebhookRetryConfig{MaxAttempts: 1}
+	if httpCfg.Retry != nil {
+		retry = *httpCfg.Retry
+	}
+	retry.MaxAttempts = max(retry.MaxAttempts, 1)
+
+	w := &webhook{
+		cfg:         cfg,
+		client:      client,
+		logger:      logger,
+		encoder:     enc,
+		match:       m,
+		auth:        auth,
+		retry:       retry,
+		breaker:     newBreaker(cfg.Name, httpCfg.CircuitBreaker),
+		deadLetters: deadLetters,
+		maxEvents:   1,
+		workers:     max(httpCfg.Workers, 1),
+		jobs:        make(chan job, max(httpCfg.QueueSize, 0)),
+		batches:     make(chan *batch),
+	}
+	if cfg.Batch != nil {
+		w.maxEvents = max(cfg.Batch.MaxEvents, 1)
+		w.maxBytes = cfg.Batch.MaxBytes
+		w.maxLinger = cfg.Batch.MaxLinger
+	}
+	return w, nil
+}
+
+// start starts batching the events of w and the workers sending the batches.
+// Every job they complete is marked done in inflight. They stop when ctx is
+// done or when stop is called.
+func (w *webhook) start(ctx context.Context, inflight *sync.WaitGroup) {
+	w.inflight = inflight
+	w.stopped.Add(1)
+	go func() {
+		defer w.stopped.Done()
+		defer close(w.batches)
+		w.batch(ctx, inflight)
+	}()
+
+	for i := 0; i < w.workers; i++ {
+		w.stopped.Add(1)
+		go func() {
+			defer w.stopped.Done()
+			for b := range w.batches {
+				// Events that weren't delivered because ctx is done aren't
+				// done, so that they're replayed after a restart.
+				delivered := w.deliver(ctx, b)
+				for _, j := range b.jobs {
+					if ctx.Err() == nil {
+						j.done(delivered)
+					}
+					inflight.Done()
+				}
+			}
+		}()
+	}
+}
+
+// batch groups the queued events into batches for the workers until ctx is
+// done or the queue is closed.
+func (w *webhook) batch(ctx context.Context, inflight *sync.WaitGroup) {
+	var current *batch
+	var linger *time.Timer
+	var lingerC <-chan time.Time
+
+	// Jobs left when ctx is done are abandoned, they're neither delivered
+	// nor given up on.
+	abandon := func() {
+		if current != nil {
+			for range current.jobs {
+				inflight.Done()
+			}
+		}
+	}
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if linger != nil {
+			linger.Stop()
+			lingerC = nil
+		}
+		select {
+		case w.batches <- current:
+		case <-ctx.Done():
+			abandon()
+		}
+		current = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			abandon()
+			return
+
+		case <-lingerC:
+			lingerC = nil
+			flush()
+
+		case j, ok := <-w.jobs:
+			if !ok {
+				flush()
+				return
+			}
+
+			encoded, err := w.encoder.marshal(j.event)
+			if err != nil {
+				j.done(w.deadLetter(j.event, 0, true, fmt.Errorf("marshal failed: %w", err)))
+				inflight.Done()
+				continue
+			}
+
+			if current != nil && w.maxBytes > 0 && current.size+len(encoded) > w.maxBytes {
+				flush()
+			}
+			if current == nil {
+				current = &batch{}
+				if w.maxLinger > 0 && w.maxEvents > 1 {
+					linger = time.NewTimer(w.maxLinger)
+					lingerC = linger.C
+				}
+			}
+			current.jobs = append(current.jobs, j)
+			current.encoded = append(current.encoded, encoded)
+			current.size += len(encoded)
+			if len(current.jobs) >= w.maxEvents {
+				flush()
+			}
+		}
+	}
+}
+
+// stop stops the workers of w once they completed the queued jobs, or once
+// they abandoned them if the context of start is done. No jobs may be queued
+// after stop is called.
+func (w *webhook) stop() {
+	close(w.jobs)
+	w.stopped.Wait()
+	// The jobs that were still queued when ctx was done are abandoned.
+	for range w.jobs {
+		w.inflight.Done()
+	}
+	w.encoder.close()
+	w.client.CloseIdleConnections()
+}
+
+// deliver sends the events of b to the webhook, retrying the calls that can be
+// retried. Events that can't be delivered are dead-lettered. It returns false
+// if the events are to be sent again later, i.e. if ctx is done before, if the
+// circuit of the webhook is open or if they couldn't be dead-lettered after a
+// failure that may be temporary, see deadLetter.
+func (w *webhook) deliver(ctx context.Context, b *batch) bool {
+	body, contentType, err := w.encoder.body(b.encoded)
+	if err != nil {
+		return w.deadLetterBatch(b, 0, true, fmt.Errorf("encoding failed: %w", err))
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := w.post(ctx, body, contentType)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		var callErr *callError
+		permanent := !errors.As(err, &callErr) || !callErr.retryable
+		if permanent || attempt >= w.retry.MaxAttempts {
+			if errors.Is(err, errCircuitOpen) {
+				// The webhook wasn't called, the events are sent again later.
+				w.logger.Warnf("webhook %s circuit is open, kept %d events to send them again", w.cfg.Name, len(b.jobs))
+				return false
+			}
+			return w.deadLetterBatch(b, attempt, permanent, err)
+		}
+
+		metrics.WebhookRetries.WithLabelValues(w.cfg.Name).Inc()
+		timer := time.NewTimer(w.backoff(attempt, callErr.retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// callError is the error of a failed webhook call.
+type callError struct {
+	err       error
+	retryable bool
+	// retryAfter is the delay requested by the webhook's Retry-After header.
+	retryAfter time.Duration
+}
+
+func (e *callError) Error() string {
+	return e.err.Error()
+}
+
+func (e *callError) Unwrap() error {
+	return e.err
+}
+
+// post calls the webhook once with body.
+func (w *webhook) post(ctx context.Context, body []byte, contentType string) error {
+	if !w.breaker.allow() {
+		return &callError{err: errCircuitOpen, retryable: true}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, w.cfg.Method, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("request creation failed: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if w.cfg.Compression != "" {
+		req.Header.Set("Content-Encoding", w.cfg.Compression)
+	}
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if err := w.auth.apply(ctx, req, body); err != nil {
+		metrics.WebhookRequests.WithLabelValues(w.cfg.Name, "error").Inc()
+		w.logger.Errorf("webhook %s authentication failed: %v", w.cfg.Name, err)
+		return &callError{err: err, retryable: true}
+	}
+
+	w.logger.Infow(
+		"sending webhook",
+		"name", w.cfg.Name,
+		"method", w.cfg.Method,
+		"url", w.cfg.URL,
+	)
+
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	metrics.WebhookDuration.WithLabelValues(w.cfg.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.WebhookRequests.WithLabelValues(w.cfg.Name, "error").Inc()
+		w.breaker.record(false)
+		w.logger.Errorf("webhook %s failed: %v", w.cfg.Name, err)
+		return &callError{err: err, retryable: true}
+	}
+	defer resp.Body.Close()
+	// The connection can only be reused once the body was read.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	metrics.WebhookRequests.WithLabelValues(w.cfg.Name, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if resp.StatusCode < 300 {
+		w.breaker.record(true)
+		return nil
+	}
+
+	w.logger.Warnf("webhook %s returned status %d", w.cfg.Name, resp.StatusCode)
+	retryable := resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The access token may have been revoked before it expired.
+		retryable = w.auth.rejected()
+	}
+	// Other client errors are caused by the event, not by the webhook.
+	w.breaker.record(!retryable)
+	return &callError{
+		err:        fmt.Errorf("webhook returned status %d", resp.StatusCode),
+		retryable:  retryable,
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// backoff returns the delay before the call following attempt, see
+// retryBackoff.
+func (w *webhook) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	return retryBackoff(w.retry, attempt, retryAfter)
+}
+
+// retryBackoff returns the delay before the call following attempt. It's the
+// delay requested by the server if any, and otherwise grows exponentially with
+// half of it random, both up to the maximum backoff.
+func retryBackoff(retry config.WebhookRetryConfig, attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, retry.MaxBackoff)
+	}
+	delay := retry.InitialBackoff
+	for i := 1; i < attempt && delay < retry.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, retry.MaxBackoff)
+	return delay/2 + time.Duration(rand.Float64()*float64(delay/2))
+}
+
+// deadLetterBatch dead-letters the events of b, see deadLetter. It returns
+// whether all of them are done with.
+func (w *webhook) deadLetterBatch(b *batch, attempts int, permanent bool, err error) bool {
+	done := true
+	for _, j := range b.jobs {
+		done = w.deadLetter(j.event, attempts, permanent, err) && done
+	}
+	return done
+}
+
+// deadLetter writes event to the dead-letter file. Without one, an event that
+// failed permanently, e.g. because the webhook rejected it, is dropped, while
+// the others are sent again later. It returns false if event is to be sent
+// again, i.e. if it couldn't be dead-lettered and the failure may be
+// temporary, or if it couldn't be written to the dead-letter file.
+func (w *webhook) deadLetter(event *protobuf.APIEvent, attempts int, permanent bool, err error) bool {
+	metrics.WebhookDeadLetters.WithLabelValues(w.cfg.Name).Inc()
+	w.logger.Warnf("webhook %s gave up on event after %d attempts: %v", w.cfg.Name, attempts, err)
+	if w.deadLetters == nil {
+		if permanent {
+			metrics.EventsDropped.WithLabelValues(config.QueueHTTP).Inc()
+		}
+		return permanent
+	}
+	if err := w.deadLetters.write(w.cfg.Name, event, attempts, err); err != nil {
+		w.logger.Errorf("Failed to write dead letter of webhook %s: %v", w.cfg.Name, err)
+		return false
+	}
+	return true
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, in seconds or as
+// an HTTP date, or 0 if there's none.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// Circuit breaker states.
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// breaker is the circuit breaker of a webhook. A nil breaker is always
+// closed.
+type breaker struct {
+	name     string
+	failures int
+	openFor  time.Duration
+	now      func() time.Time
+
+	lock        sync.Mutex
+	state       int
+	consecutive int
+	openedAt    time.Time
+	// probing is whether the call let through while half-open is in flight.
+	probing bool
+}
+
+func newBreaker(name string, cfg *config.CircuitBreakerConfig) *breaker {
+	if cfg == nil || cfg.Failures <= 0 {
+		return nil
+	}
+	metrics.WebhookCircuitOpen.WithLabelValues(name).Set(0)
+	return &breaker{
+		name:     name,
+		failures: cfg.Failures,
+		openFor:  cfg.OpenDuration,
+		now:      time.Now,
+	}
+}
+
+// allow reports whether a call can be made. Once the circuit was open long
+// enough, a single call is allowed until its result is recorded.
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.openFor {
+			return false
+		}
+		b.state, b.probing = circuitHalfOpen, true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// record records the result of an allowed call.
+func (b *breaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.probing = false
+	if success {
+		b.state, b.consecutive = circuitClosed, 0
+		metrics.WebhookCircuitOpen.WithLabelValues(b.name).Set(0)
+		return
+	}
+
+	b.consecutive++
+	if b.state == circuitHalfOpen || b.consecutive >= b.failures {
+		b.state, b.openedAt = circuitOpen, b.now()
+		metrics.WebhookCircuitOpen.WithLabelValues(b.name).Set(1)
+	}
+}
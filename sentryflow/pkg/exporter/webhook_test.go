@@ -0,0 +1,352 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func TestWebhook_deliver(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		wantCalls        int32
+		wantDeadLetter   bool
+		wantDeadAttempts int
+	}{
+		{
+			name:      "with transient failures should retry until delivered",
+			statuses:  []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			wantCalls: 3,
+		},
+		{
+			name:             "with persistent failures should dead-letter after max attempts",
+			statuses:         []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			wantCalls:        3,
+			wantDeadLetter:   true,
+			wantDeadAttempts: 3,
+		},
+		{
+			name:             "with client error should dead-letter without retrying",
+			statuses:         []int{http.StatusBadRequest},
+			wantCalls:        1,
+			wantDeadLetter:   true,
+			wantDeadAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := calls.Add(1)
+				w.WriteHeader(tt.statuses[min(int(call), len(tt.statuses))-1])
+			}))
+			defer server.Close()
+
+			path := filepath.Join(t.TempDir(), "dead-letters.json")
+			w := testWebhook(t, server.URL, path)
+
+			// When
+			if !w.deliver(context.Background(), testBatch(t, w, &protobuf.APIEvent{Metadata: &protobuf.Metadata{ContextId: 7}})) {
+				t.Fatal("deliver() = false, want true")
+			}
+			_ = w.deadLetters.close()
+
+			// Then
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("deliver() made %d calls, want %d", got, tt.wantCalls)
+			}
+			letters := readDeadLetters(t, path)
+			if !tt.wantDeadLetter {
+				if len(letters) != 0 {
+					t.Errorf("deliver() wrote %d dead letters, want 0", len(letters))
+				}
+				return
+			}
+			if len(letters) != 1 {
+				t.Fatalf("deliver() wrote %d dead letters, want 1", len(letters))
+			}
+			if letters[0].Webhook != "retry-test" || letters[0].Attempts != tt.wantDeadAttempts {
+				t.Errorf("dead letter = %+v, want webhook retry-test after %d attempts", letters[0], tt.wantDeadAttempts)
+			}
+			event := &protobuf.APIEvent{}
+			if err := protojson.Unmarshal(letters[0].Event, event); err != nil || event.Metadata.GetContextId() != 7 {
+				t.Errorf("dead letter event = %s, want the undelivered event", letters[0].Event)
+			}
+		})
+	}
+}
+
+func TestWebhook_deliver_cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "dead-letters.json")
+	w := testWebhook(t, server.URL, path)
+	w.retry.InitialBackoff, w.retry.MaxBackoff = time.Hour, time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if w.deliver(ctx, testBatch(t, w, &protobuf.APIEvent{})) {
+		t.Error("deliver() = true after ctx was cancelled, want false")
+	}
+	_ = w.deadLetters.close()
+	if letters := readDeadLetters(t, path); len(letters) != 0 {
+		t.Errorf("deliver() wrote %d dead letters after ctx was cancelled, want 0", len(letters))
+	}
+}
+
+func TestHTTPExporter_Batch(t *testing.T) {
+	bodies := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Content-Type = %v, want application/x-ndjson", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := getWebhookConfig(server.URL, nil)
+	cfg.Exporter.HTTP.Webhooks[0].Format = config.WebhookFormatNDJSON
+	cfg.Exporter.HTTP.Webhooks[0].Batch = &config.WebhookBatchConfig{MaxEvents: 2, MaxLinger: 50 * time.Millisecond}
+
+	events := make(chan *protobuf.APIEvent, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, util.LoggerContextKey{}, zap.NewNop().Sugar())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if _, err := InitHTTPExporter(ctx, cfg, events, HTTPDependencies{}, &wg); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	// When
+	for i := 0; i < 3; i++ {
+		events <- &protobuf.APIEvent{}
+	}
+
+	// Then a full batch is sent, and the rest after lingering
+	for _, want := range []int{2, 1} {
+		select {
+		case body := <-bodies:
+			if got := strings.Count(body, "\n"); got != want {
+				t.Errorf("webhook received %d events, want %d", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("batch of %d events not received", want)
+		}
+	}
+}
+
+func TestWebhook_backoff(t *testing.T) {
+	w := &webhook{retry: config.WebhookRetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}}
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{name: "first retry", attempt: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "third retry", attempt: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped retry", attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+		{name: "retry after", attempt: 1, retryAfter: 700 * time.Millisecond, min: 700 * time.Millisecond, max: 700 * time.Millisecond},
+		{name: "capped retry after", attempt: 1, retryAfter: time.Minute, min: time.Second, max: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.backoff(tt.attempt, tt.retryAfter); got < tt.min || got > tt.max {
+				t.Errorf("backoff() = %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: "Wed, 01 May 2024 12:00:30 GMT", want: 30 * time.Second},
+		{value: "Wed, 01 May 2024 11:00:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker("breaker-test", &config.CircuitBreakerConfig{Failures: 2, OpenDuration: time.Minute})
+	b.now = func() time.Time { return now }
+
+	// Given consecutive failures
+	b.record(false)
+	if !b.allow() {
+		t.Fatal("allow() = false before the failure threshold, want true")
+	}
+	b.record(false)
+
+	// Then the circuit opens
+	if b.allow() {
+		t.Fatal("allow() = true with open circuit, want false")
+	}
+
+	// When the circuit was open long enough a single probe is allowed
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("allow() = false after open duration, want true")
+	}
+	if b.allow() {
+		t.Fatal("allow() = true while probing, want false")
+	}
+
+	// And a failed probe opens the circuit again
+	b.record(false)
+	if b.allow() {
+		t.Fatal("allow() = true after failed probe, want false")
+	}
+
+	// And a successful one closes it
+	now = now.Add(time.Minute)
+	_ = b.allow()
+	b.record(true)
+	if !b.allow() || !b.allow() {
+		t.Fatal("allow() = false after successful probe, want true")
+	}
+}
+
+func TestWebhook_deliver_undelivered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	t.Run("without dead-letter file should return false", func(t *testing.T) {
+		w := testWebhook(t, server.URL, filepath.Join(t.TempDir(), "dead-letters.json"))
+		_ = w.deadLetters.close()
+		w.deadLetters = nil
+
+		if w.deliver(context.Background(), testBatch(t, w, &protobuf.APIEvent{})) {
+			t.Error("deliver() = true without dead-letter file, want false")
+		}
+	})
+
+	t.Run("with rejected events and without dead-letter file should return true", func(t *testing.T) {
+		rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer rejecting.Close()
+		w := testWebhook(t, rejecting.URL, filepath.Join(t.TempDir(), "dead-letters.json"))
+		_ = w.deadLetters.close()
+		w.deadLetters = nil
+
+		if !w.deliver(context.Background(), testBatch(t, w, &protobuf.APIEvent{})) {
+			t.Error("deliver() = false for rejected events, want true")
+		}
+	})
+
+	t.Run("with open circuit should return false without dead-lettering", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dead-letters.json")
+		w := testWebhook(t, server.URL, path)
+		w.breaker = newBreaker("retry-test", &config.CircuitBreakerConfig{Failures: 1, OpenDuration: time.Hour})
+
+		if w.deliver(context.Background(), testBatch(t, w, &protobuf.APIEvent{})) {
+			t.Error("deliver() = true with open circuit, want false")
+		}
+		_ = w.deadLetters.close()
+		if letters := readDeadLetters(t, path); len(letters) != 0 {
+			t.Errorf("deliver() wrote %d dead letters with open circuit, want 0", len(letters))
+		}
+	})
+}
+
+func testWebhook(t *testing.T, url, deadLetterPath string) *webhook {
+	t.Helper()
+	deadLetters, err := openDeadLetters(&config.DeadLetterConfig{Path: deadLetterPath})
+	if err != nil {
+		t.Fatalf("openDeadLetters() error = %v", err)
+	}
+	w, err := newWebhook(
+		config.WebhookConfig{Name: "retry-test", URL: url, Method: http.MethodPost},
+		&http.Client{Timeout: 2 * time.Second},
+		&config.HttpConfig{Retry: &config.WebhookRetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+		}},
+		nil,
+		deadLetters,
+		zap.NewNop().Sugar(),
+	)
+	if err != nil {
+		t.Fatalf("newWebhook() error = %v", err)
+	}
+	return w
+}
+
+func testBatch(t *testing.T, w *webhook, events ...*protobuf.APIEvent) *batch {
+	t.Helper()
+	b := &batch{}
+	for _, event := range events {
+		encoded, err := w.encoder.marshal(event)
+		if err != nil {
+			t.Fatalf("marshal() error = %v", err)
+		}
+		b.jobs = append(b.jobs, job{event: event, done: func(bool) {}})
+		b.encoded = append(b.encoded, encoded)
+		b.size += len(encoded)
+	}
+	return b
+}
+
+func readDeadLetters(t *testing.T, path string) []deadLetter {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var letters []deadLetter
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var letter deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("invalid dead letter %s: %v", scanner.Text(), err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters
+}
@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package k8s
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// CreateOrUpdate creates obj, or replaces the existing object with the same
+// name and namespace by obj. existing is an empty object of the same kind as
+// obj that the existing object is read into.
+func CreateOrUpdate(ctx context.Context, k8sClient client.Client, obj client.Object, existing client.Object) error {
+	logger := util.LoggerFromCtx(ctx)
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if err := k8sClient.Create(ctx, obj); err != nil {
+			return err
+		}
+		logger.Infow("Created "+kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+		return nil
+	}
+
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if err := k8sClient.Update(ctx, obj); err != nil {
+		return err
+	}
+	logger.Infow("Updated "+kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+	return nil
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Secrets reads the keys of Kubernetes Secrets. Its clientset is only created
+// once a Secret is read, so that SentryFlow doesn't need a cluster unless it's
+// configured to read Secrets.
+type Secrets struct {
+	newClientset func() (kubernetes.Interface, error)
+
+	lock      sync.Mutex
+	clientset kubernetes.Interface
+}
+
+// NewSecrets returns Secrets reading from the cluster SentryFlow runs in or,
+// outside of it, from the cluster of kubeConfig.
+func NewSecrets(kubeConfig string) *Secrets {
+	return &Secrets{newClientset: func() (kubernetes.Interface, error) {
+		return NewClientset(kubeConfig)
+	}}
+}
+
+// NewSecretsForClientset returns Secrets reading with clientset.
+func NewSecretsForClientset(clientset kubernetes.Interface) *Secrets {
+	return &Secrets{clientset: clientset}
+}
+
+// Read returns the value of key in the Secret name of namespace.
+func (s *Secrets) Read(ctx context.Context, namespace, name, key string) ([]byte, error) {
+	clientset, err := s.client()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %w", err)
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+	}
+	value, exists := secret.Data[key]
+	if !exists {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", namespace, name, key)
+	}
+	return value, nil
+}
+
+func (s *Secrets) client() (kubernetes.Interface, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.clientset == nil {
+		clientset, err := s.newClientset()
+		if err != nil {
+			return nil, err
+		}
+		s.clientset = clientset
+	}
+	return s.clientset, nil
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecrets_Read(t *testing.T) {
+	secrets := NewSecretsForClientset(fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "sentryflow"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}))
+
+	tests := []struct {
+		name      string
+		namespace string
+		key       string
+		want      string
+		wantErr   bool
+	}{
+		{name: "existing key", namespace: "sentryflow", key: "key", want: "value"},
+		{name: "missing key", namespace: "sentryflow", key: "other", wantErr: true},
+		{name: "missing secret", namespace: "default", key: "key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := secrets.Read(context.Background(), tt.namespace, "webhook", tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ipIndex      = "ip"
+	resyncPeriod = 10 * time.Minute
+
+	// serviceNameLabel is the label of an EndpointSlice naming its Service.
+	serviceNameLabel = "kubernetes.io/service-name"
+	// podTemplateHashLabel is the label of the pods of a Deployment's
+	// ReplicaSet, which is also the suffix of the ReplicaSet's name.
+	podTemplateHashLabel = "pod-template-hash"
+)
+
+// Workload is the Kubernetes workload an IP address belongs to.
+type Workload struct {
+	Name      string
+	Namespace string
+	// Kind is the kind of the pod's controller, e.g. Deployment or
+	// StatefulSet, Pod for standalone pods, or Service.
+	Kind     string
+	Labels   map[string]string
+	NodeName string
+}
+
+// WorkloadCache resolves IP addresses to the Kubernetes workloads they belong
+// to, from informer caches of Pods, Services and EndpointSlices.
+type WorkloadCache struct {
+	factory        informers.SharedInformerFactory
+	pods           cache.SharedIndexInformer
+	services       cache.SharedIndexInformer
+	endpointSlices cache.SharedIndexInformer
+}
+
+// NewClientset returns a new clientset for the cluster SentryFlow runs in or,
+// outside of it, for the cluster of kubeConfig.
+func NewClientset(kubeConfig string) (kubernetes.Interface, error) {
+	config, err := getConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %v", err)
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// NewWorkloadCache returns a WorkloadCache watching the cluster of clientset.
+// It's empty until it's started with Start.
+func NewWorkloadCache(clientset kubernetes.Interface) (*WorkloadCache, error) {
+	factory := informers.NewSharedInformerFactory(clientset, resyncPeriod)
+	c := &WorkloadCache{
+		factory:        factory,
+		pods:           factory.Core().V1().Pods().Informer(),
+		services:       factory.Core().V1().Services().Informer(),
+		endpointSlices: factory.Discovery().V1().EndpointSlices().Informer(),
+	}
+
+	if err := c.pods.AddIndexers(cache.Indexers{ipIndex: podIPs}); err != nil {
+		return nil, err
+	}
+	if err := c.services.AddIndexers(cache.Indexers{ipIndex: serviceIPs}); err != nil {
+		return nil, err
+	}
+	if err := c.endpointSlices.AddIndexers(cache.Indexers{ipIndex: endpointIPs}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start starts watching the cluster until ctx is done, and waits for the
+// caches to be filled for at most timeout. Workloads are resolved as soon as
+// they are cached, even if the timeout expired.
+func (c *WorkloadCache) Start(ctx context.Context, timeout time.Duration) error {
+	c.factory.Start(ctx.Done())
+
+	syncCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), c.pods.HasSynced, c.services.HasSynced, c.endpointSlices.HasSynced) {
+		return fmt.Errorf("timed out waiting for the workload caches to sync")
+	}
+	return nil
+}
+
+// Lookup returns the workload ip belongs to. Pods are looked up first, then
+// Services by cluster IP, then EndpointSlices for the endpoints that aren't
+// pods, e.g. host network pods or external endpoints.
+func (c *WorkloadCache) Lookup(ip string) (Workload, bool) {
+	if pods, err := c.pods.GetIndexer().ByIndex(ipIndex, ip); err == nil && len(pods) > 0 {
+		// The IP of a completed pod can have been reused by a running one.
+		pod := pods[0].(*corev1.Pod)
+		for _, obj := range pods[1:] {
+			if other := obj.(*corev1.Pod); terminated(pod) && !terminated(other) {
+				pod = other
+			}
+		}
+		return podWorkload(pod), true
+	}
+
+	if services, err := c.services.GetIndexer().ByIndex(ipIndex, ip); err == nil && len(services) > 0 {
+		service := services[0].(*corev1.Service)
+		return Workload{
+			Name:      service.Name,
+			Namespace: service.Namespace,
+			Kind:      "Service",
+			Labels:    service.Labels,
+		}, true
+	}
+
+	endpointSlices, err := c.endpointSlices.GetIndexer().ByIndex(ipIndex, ip)
+	if err != nil || len(endpointSlices) == 0 {
+		return Workload{}, false
+	}
+	slice := endpointSlices[0].(*discoveryv1.EndpointSlice)
+	workload := Workload{
+		Name:      slice.Labels[serviceNameLabel],
+		Namespace: slice.Namespace,
+		Kind:      "Service",
+	}
+	for _, endpoint := range slice.Endpoints {
+		if endpoint.NodeName != nil && slices.Contains(endpoint.Addresses, ip) {
+			workload.NodeName = *endpoint.NodeName
+			break
+		}
+	}
+	return workload, true
+}
+
+// podWorkload returns the workload of pod, named after its controller. The
+// Deployment of a pod is derived from its ReplicaSet's name.
+func podWorkload(pod *corev1.Pod) Workload {
+	workload := Workload{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Kind:      "Pod",
+		Labels:    pod.Labels,
+		NodeName:  pod.Spec.NodeName,
+	}
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return workload
+	}
+	workload.Name, workload.Kind = owner.Name, owner.Kind
+	if hash := pod.Labels[podTemplateHashLabel]; owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+		workload.Name, workload.Kind = strings.TrimSuffix(owner.Name, "-"+hash), "Deployment"
+	}
+	return workload
+}
+
+func terminated(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+// podIPs indexes pods by IP. Host network pods share the IP of their node, so
+// they aren't indexed.
+func podIPs(obj any) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.HostNetwork {
+		return nil, nil
+	}
+	var ips []string
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips, nil
+}
+
+func serviceIPs(obj any) ([]string, error) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		return nil, nil
+	}
+	var ips []string
+	for _, ip := range service.Spec.ClusterIPs {
+		if ip != corev1.ClusterIPNone {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+func endpointIPs(obj any) ([]string, error) {
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil, nil
+	}
+	var ips []string
+	for _, endpoint := range slice.Endpoints {
+		ips = append(ips, endpoint.Addresses...)
+	}
+	return ips, nil
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWorkloadCache_Lookup(t *testing.T) {
+	controller, node := true, "node-3"
+	clientset := fake.NewClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "frontend-7d9f8b6c5d-x2k4p",
+				Namespace: "shop",
+				Labels:    map[string]string{"app": "frontend", podTemplateHashLabel: "7d9f8b6c5d"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "frontend-7d9f8b6c5d", Controller: &controller},
+				},
+			},
+			Spec:   corev1.PodSpec{NodeName: "node-1"},
+			Status: corev1.PodStatus{PodIP: "10.0.0.1", PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db-0",
+				Namespace: "data",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: "db", Controller: &controller},
+				},
+			},
+			Spec:   corev1.PodSpec{NodeName: "node-2"},
+			Status: corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.0.0.2"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+			Status:     corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.0.0.3"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-exporter", Namespace: "monitoring"},
+			Spec:       corev1.PodSpec{HostNetwork: true},
+			Status:     corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "192.168.0.10"}}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "shop", Labels: map[string]string{"app": "frontend"}},
+			Spec:       corev1.ServiceSpec{ClusterIPs: []string{"10.96.0.10"}},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "node-exporter-abcde",
+				Namespace: "monitoring",
+				Labels:    map[string]string{serviceNameLabel: "node-exporter"},
+			},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"192.168.0.10"}, NodeName: &node},
+			},
+		},
+	)
+
+	c, err := NewWorkloadCache(clientset)
+	if err != nil {
+		t.Fatalf("NewWorkloadCache() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := c.Start(ctx, 5*time.Second); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		ip        string
+		want      Workload
+		wantFound bool
+	}{
+		{
+			name: "with deployment pod IP should return the deployment",
+			ip:   "10.0.0.1",
+			want: Workload{
+				Name:      "frontend",
+				Namespace: "shop",
+				Kind:      "Deployment",
+				Labels:    map[string]string{"app": "frontend", podTemplateHashLabel: "7d9f8b6c5d"},
+				NodeName:  "node-1",
+			},
+			wantFound: true,
+		},
+		{
+			name:      "with statefulset pod IP should return the statefulset",
+			ip:        "10.0.0.2",
+			want:      Workload{Name: "db", Namespace: "data", Kind: "StatefulSet", NodeName: "node-2"},
+			wantFound: true,
+		},
+		{
+			name:      "with standalone pod IP should return the pod",
+			ip:        "10.0.0.3",
+			want:      Workload{Name: "debug", Namespace: "default", Kind: "Pod", NodeName: "node-1"},
+			wantFound: true,
+		},
+		{
+			name:      "with service cluster IP should return the service",
+			ip:        "10.96.0.10",
+			want:      Workload{Name: "frontend", Namespace: "shop", Kind: "Service", Labels: map[string]string{"app": "frontend"}},
+			wantFound: true,
+		},
+		{
+			name:      "with host network endpoint IP should return its service",
+			ip:        "192.168.0.10",
+			want:      Workload{Name: "node-exporter", Namespace: "monitoring", Kind: "Service", NodeName: "node-3"},
+			wantFound: true,
+		},
+		{
+			name: "with unknown IP should return nothing",
+			ip:   "172.16.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := c.Lookup(tt.ip)
+			if found != tt.wantFound {
+				t.Fatalf("Lookup() found = %v, want %v", found, tt.wantFound)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package match selects API events, for pipeline processors and exporters.
+package match
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// Matcher selects API events as described by config.MatchConfig. A nil Matcher
+// matches every event.
+type Matcher struct {
+	receivers             []string
+	methods               []string
+	paths                 []*regexp.Regexp
+	statuses              []statusRange
+	namespaces            []string
+	sourceNamespaces      []string
+	destinationNamespaces []string
+	headers               []string
+	minLatency            time.Duration
+	maxLatency            time.Duration
+}
+
+// statusRange is an inclusive range of response status codes.
+type statusRange struct {
+	from, to int
+}
+
+// New returns the Matcher of cfg, nil if cfg is nil.
+func New(cfg *config.MatchConfig) (*Matcher, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	m := &Matcher{
+		receivers:             cfg.Receivers,
+		methods:               cfg.Methods,
+		namespaces:            cfg.Namespaces,
+		sourceNamespaces:      cfg.SourceNamespaces,
+		destinationNamespaces: cfg.DestinationNamespaces,
+		minLatency:            cfg.MinLatency,
+		maxLatency:            cfg.MaxLatency,
+	}
+	for _, path := range cfg.Paths {
+		pattern, err := regexp.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match path, %v", err)
+		}
+		m.paths = append(m.paths, pattern)
+	}
+	for _, glob := range cfg.PathGlobs {
+		m.paths = append(m.paths, globPattern(glob))
+	}
+	for _, status := range cfg.Statuses {
+		r, err := parseStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		m.statuses = append(m.statuses, r)
+	}
+	for _, header := range cfg.Headers {
+		m.headers = append(m.headers, strings.ToLower(header))
+	}
+	return m, nil
+}
+
+// Matches returns whether event matches m.
+func (m *Matcher) Matches(event *protobuf.APIEvent) bool {
+	if m == nil {
+		return true
+	}
+
+	headers := event.GetRequest().GetHeaders()
+	latency := time.Duration(event.GetResponse().GetBackendLatencyInNanos())
+	switch {
+	case len(m.receivers) > 0 && !slices.Contains(m.receivers, event.GetMetadata().GetReceiverName()):
+		return false
+	case len(m.methods) > 0 && !slices.ContainsFunc(m.methods, func(method string) bool {
+		return strings.EqualFold(method, headers[":method"])
+	}):
+		return false
+	case len(m.paths) > 0 && !m.matchesPath(headers[":path"]):
+		return false
+	case len(m.statuses) > 0 && !m.matchesStatus(event.GetResponse().GetHeaders()[":status"]):
+		return false
+	case len(m.namespaces) > 0 && !slices.Contains(m.namespaces, event.GetSource().GetNamespace()) &&
+		!slices.Contains(m.namespaces, event.GetDestination().GetNamespace()):
+		return false
+	case len(m.sourceNamespaces) > 0 && !slices.Contains(m.sourceNamespaces, event.GetSource().GetNamespace()):
+		return false
+	case len(m.destinationNamespaces) > 0 && !slices.Contains(m.destinationNamespaces, event.GetDestination().GetNamespace()):
+		return false
+	case len(m.headers) > 0 && !m.matchesHeader(headers):
+		return false
+	case m.minLatency > 0 && latency < m.minLatency:
+		return false
+	case m.maxLatency > 0 && latency > m.maxLatency:
+		return false
+	}
+	return true
+}
+
+func (m *Matcher) matchesPath(path string) bool {
+	path = util.TrimQuery(path)
+	for _, pattern := range m.paths {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) matchesStatus(status string) bool {
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return false
+	}
+	for _, r := range m.statuses {
+		if r.from <= code && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) matchesHeader(headers map[string]string) bool {
+	for name := range headers {
+		if slices.Contains(m.headers, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatus parses a status code, e.g. `404`, class, e.g. `5xx`, or range,
+// e.g. `500-504`.
+func parseStatus(status string) (statusRange, error) {
+	if !config.MatchStatusPattern.MatchString(status) {
+		return statusRange{}, fmt.Errorf("invalid match status, %v", status)
+	}
+	if strings.HasSuffix(status, "xx") {
+		class := int(status[0]-'0') * 100
+		return statusRange{from: class, to: class + 99}, nil
+	}
+	from, to, found := strings.Cut(status, "-")
+	if !found {
+		to = from
+	}
+	r := statusRange{}
+	r.from, _ = strconv.Atoi(from)
+	r.to, _ = strconv.Atoi(to)
+	if r.from > r.to {
+		return statusRange{}, fmt.Errorf("invalid match status, %v", status)
+	}
+	return r, nil
+}
+
+// globPattern returns the regular expression of a path glob, in which `*`
+// matches within a path segment and `**` across segments.
+func globPattern(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package match
+
+import (
+	"testing"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestMatcher_Matches(t *testing.T) {
+	event := &protobuf.APIEvent{
+		Metadata:    &protobuf.Metadata{ReceiverName: "istio-sidecar"},
+		Source:      &protobuf.Workload{Name: "frontend", Namespace: "shop"},
+		Destination: &protobuf.Workload{Name: "payments", Namespace: "payments"},
+		Request: &protobuf.Request{Headers: map[string]string{
+			":method":       "GET",
+			":path":         "/api/v1/users/1?verbose=true",
+			"Authorization": "Bearer secret",
+		}},
+		Response: &protobuf.Response{
+			Headers:               map[string]string{":status": "404"},
+			BackendLatencyInNanos: uint64(300 * time.Millisecond),
+		},
+	}
+
+	tests := []struct {
+		name  string
+		match *config.MatchConfig
+		want  bool
+	}{
+		{name: "no match config", match: nil, want: true},
+		{name: "empty match config", match: &config.MatchConfig{}, want: true},
+		{name: "receiver", match: &config.MatchConfig{Receivers: []string{"nginx-webserver", "istio-sidecar"}}, want: true},
+		{name: "other receiver", match: &config.MatchConfig{Receivers: []string{"nginx-webserver"}}, want: false},
+		{name: "method in another case", match: &config.MatchConfig{Methods: []string{"get"}}, want: true},
+		{name: "other method", match: &config.MatchConfig{Methods: []string{"POST"}}, want: false},
+		{name: "path without query", match: &config.MatchConfig{Paths: []string{"^/api/v1/users/[0-9]+$"}}, want: true},
+		{name: "other path", match: &config.MatchConfig{Paths: []string{"^/orders"}}, want: false},
+		{name: "path glob", match: &config.MatchConfig{PathGlobs: []string{"/api/*/users/*"}}, want: true},
+		{name: "path glob across segments", match: &config.MatchConfig{PathGlobs: []string{"/api/**"}}, want: true},
+		{name: "path glob within a segment", match: &config.MatchConfig{PathGlobs: []string{"/api/*"}}, want: false},
+		{name: "status", match: &config.MatchConfig{Statuses: []string{"404"}}, want: true},
+		{name: "status class", match: &config.MatchConfig{Statuses: []string{"5xx", "4xx"}}, want: true},
+		{name: "other status class", match: &config.MatchConfig{Statuses: []string{"2xx"}}, want: false},
+		{name: "status range", match: &config.MatchConfig{Statuses: []string{"400-404"}}, want: true},
+		{name: "other status range", match: &config.MatchConfig{Statuses: []string{"405-499"}}, want: false},
+		{name: "source or destination namespace", match: &config.MatchConfig{Namespaces: []string{"payments"}}, want: true},
+		{name: "other namespace", match: &config.MatchConfig{Namespaces: []string{"default"}}, want: false},
+		{name: "source namespace", match: &config.MatchConfig{SourceNamespaces: []string{"shop"}}, want: true},
+		{name: "destination namespace", match: &config.MatchConfig{DestinationNamespaces: []string{"shop"}}, want: false},
+		{name: "header in another case", match: &config.MatchConfig{Headers: []string{"authorization"}}, want: true},
+		{name: "missing header", match: &config.MatchConfig{Headers: []string{"x-api-key"}}, want: false},
+		{name: "slower than min latency", match: &config.MatchConfig{MinLatency: 200 * time.Millisecond}, want: true},
+		{name: "faster than min latency", match: &config.MatchConfig{MinLatency: time.Second}, want: false},
+		{name: "slower than max latency", match: &config.MatchConfig{MaxLatency: 100 * time.Millisecond}, want: false},
+		{
+			name:  "every field must match",
+			match: &config.MatchConfig{Methods: []string{"GET"}, Statuses: []string{"2xx"}},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.match)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if got := m.Matches(event); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_invalidStatus(t *testing.T) {
+	for _, status := range []string{"600", "5x", "504-500"} {
+		if _, err := New(&config.MatchConfig{Statuses: []string{status}}); err == nil {
+			t.Errorf("New() with status %v expected error but got none", status)
+		}
+	}
+}
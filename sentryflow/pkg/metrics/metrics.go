@@ -0,0 +1,358 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package metrics holds the Prometheus metrics SentryFlow exposes about its own
+// event pipeline.
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+)
+
+const namespace = "sentryflow"
+
+// UnknownReceiver is the receiver label used for events that don't carry the
+// name of a registered receiver.
+const UnknownReceiver = "unknown"
+
+var (
+	// Registry holds all SentryFlow metrics. It's separate from the default
+	// Prometheus registry so that metrics registered by dependencies don't end
+	// up on SentryFlow's endpoint.
+	Registry = prometheus.NewRegistry()
+
+	// EventsReceived counts the API events that entered the pipeline, by the
+	// receiver that reported them.
+	EventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_received_total",
+		Help:      "Number of API events received, by receiver.",
+	}, []string{"receiver"})
+
+	// EventsDropped counts the API events dropped or rejected because a queue
+	// was full. The label is the name of the queue, i.e. the exporter's name or
+	// `ingest`.
+	EventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Number of API events dropped because a queue was full, by exporter or ingest queue.",
+	}, []string{"exporter"})
+
+	// EventsSpilled is the number of API events a queue spilled to disk and
+	// hasn't queued again yet.
+	EventsSpilled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "events_spilled",
+		Help:      "Number of API events spilled to disk, by queue.",
+	}, []string{"queue"})
+
+	// WALBytes is the size of the write-ahead log on disk.
+	WALBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "wal_bytes",
+		Help:      "Size of the write-ahead log on disk in bytes.",
+	})
+
+	// WALPending is the number of API events an exporter read from the
+	// write-ahead log and hasn't acknowledged yet.
+	WALPending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "wal_pending_events",
+		Help:      "Number of API events read from the write-ahead log and not acknowledged yet, by exporter.",
+	}, []string{"exporter"})
+
+	// GrpcClients is the number of clients currently connected to the gRPC
+	// exporter, by the stream they subscribed to, i.e. `APIEvent`,
+	// `APIMetrics` or `EnvoyMetrics`.
+	GrpcClients = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "grpc_connected_clients",
+		Help:      "Number of gRPC clients currently connected, by stream.",
+	}, []string{"stream"})
+
+	// WebhookRequests counts webhook calls by webhook and response status code.
+	// Calls that failed without a response have the code `error`.
+	WebhookRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_requests_total",
+		Help:      "Number of webhook requests, by webhook and status code.",
+	}, []string{"webhook", "code"})
+
+	// WebhookDuration observes how long webhook calls take.
+	WebhookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "webhook_request_duration_seconds",
+		Help:      "Duration of webhook requests in seconds, by webhook.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"webhook"})
+
+	// WebhookRetries counts webhook calls made again after a failure.
+	WebhookRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_retries_total",
+		Help:      "Number of webhook requests retried, by webhook.",
+	}, []string{"webhook"})
+
+	// WebhookDeadLetters counts the events that couldn't be delivered to a
+	// webhook, whether or not they were written to the dead-letter file.
+	WebhookDeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_dead_letters_total",
+		Help:      "Number of API events that couldn't be delivered to a webhook, by webhook.",
+	}, []string{"webhook"})
+
+	// WebhookCircuitOpen is 1 while the circuit breaker of a webhook is open
+	// or half-open, and 0 while it's closed.
+	WebhookCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "webhook_circuit_open",
+		Help:      "Whether the circuit breaker of a webhook is open, by webhook.",
+	}, []string{"webhook"})
+
+	// KafkaRecords counts the records produced to Kafka by topic and result,
+	// i.e. `delivered` or `failed`.
+	KafkaRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_records_total",
+		Help:      "Number of records produced to Kafka, by topic and result.",
+	}, []string{"topic", "result"})
+
+	// KafkaProduceDuration observes how long records take to be written,
+	// from the time they're produced to the broker's acknowledgement.
+	KafkaProduceDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_produce_duration_seconds",
+		Help:      "Duration between producing records to Kafka and their acknowledgement in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// KafkaBufferedRecords is the number of records waiting to be written to
+	// Kafka.
+	KafkaBufferedRecords = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_buffered_records",
+		Help:      "Number of records waiting to be written to Kafka.",
+	})
+
+	// NATSMessages counts the messages published to NATS by result, i.e.
+	// `published`, `failed` or `dropped`.
+	NATSMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nats_messages_total",
+		Help:      "Number of messages published to NATS, by result.",
+	}, []string{"result"})
+
+	// NATSConnected is 1 while the NATS exporter is connected to a server, and
+	// 0 otherwise.
+	NATSConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "nats_connected",
+		Help:      "Whether the NATS exporter is connected to a server.",
+	})
+
+	// NATSReconnects counts the times the NATS exporter reconnected after
+	// losing its connection.
+	NATSReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nats_reconnects_total",
+		Help:      "Number of times the NATS exporter reconnected to a server.",
+	})
+
+	// OTLPItems counts the spans and log records exported with OTLP by signal
+	// and result, i.e. `exported`, `rejected` by the server or `failed`.
+	OTLPItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otlp_items_total",
+		Help:      "Number of spans and log records exported with OTLP, by signal and result.",
+	}, []string{"signal", "result"})
+
+	// OTLPExportDuration observes how long OTLP exports take by signal,
+	// including their retries.
+	OTLPExportDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "otlp_export_duration_seconds",
+		Help:      "Duration of OTLP exports in seconds, by signal.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"signal"})
+
+	// OTLPRetries counts the OTLP exports retried by signal.
+	OTLPRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "otlp_retries_total",
+		Help:      "Number of OTLP exports retried, by signal.",
+	}, []string{"signal"})
+
+	// ElasticsearchDocuments counts the documents written with the bulk API by
+	// result, i.e. `indexed` or `failed`.
+	ElasticsearchDocuments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "elasticsearch_documents_total",
+		Help:      "Number of documents written to Elasticsearch, by result.",
+	}, []string{"result"})
+
+	// ElasticsearchBulkDuration observes how long bulk requests take.
+	ElasticsearchBulkDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "elasticsearch_bulk_duration_seconds",
+		Help:      "Duration of Elasticsearch bulk requests in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// ElasticsearchRetries counts the bulk requests retried, either entirely
+	// or for the documents that were rejected with 429 Too Many Requests.
+	ElasticsearchRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "elasticsearch_retries_total",
+		Help:      "Number of Elasticsearch bulk requests retried.",
+	})
+
+	// F5ParseFailures counts F5 BIG-IP log lines that couldn't be turned into
+	// API events.
+	F5ParseFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "f5_parse_failures_total",
+		Help:      "Number of F5 BIG-IP log lines that failed to parse.",
+	})
+
+	// ProcessorEvents counts the API events pipeline processors dropped or
+	// modified, by processor name and result, i.e. `dropped` or `modified`.
+	ProcessorEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pipeline_processor_events_total",
+		Help:      "Number of API events dropped or modified by a pipeline processor, by processor and result.",
+	}, []string{"processor", "result"})
+
+	// APITraffic collects the API traffic metrics derived from captured events.
+	APITraffic = &Swappable{}
+
+	channels = &channelDepths{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "channel_depth"),
+			"Number of API events queued in an internal channel.",
+			[]string{"channel"}, nil,
+		),
+		lengths: make(map[string]func() int),
+	}
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EventsReceived,
+		EventsDropped,
+		EventsSpilled,
+		WALBytes,
+		WALPending,
+		GrpcClients,
+		WebhookRequests,
+		WebhookDuration,
+		WebhookRetries,
+		WebhookDeadLetters,
+		WebhookCircuitOpen,
+		KafkaRecords,
+		KafkaProduceDuration,
+		KafkaBufferedRecords,
+		NATSMessages,
+		NATSConnected,
+		NATSReconnects,
+		OTLPItems,
+		OTLPExportDuration,
+		OTLPRetries,
+		ElasticsearchDocuments,
+		ElasticsearchBulkDuration,
+		ElasticsearchRetries,
+		F5ParseFailures,
+		ProcessorEvents,
+		APITraffic,
+		channels,
+	)
+}
+
+// Handler returns the HTTP handler serving the metrics in Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ReceiverLabel returns the receiver label for an event reported by name. Names
+// no registered receiver reports are labelled UnknownReceiver, so that clients
+// can't create arbitrary series.
+func ReceiverLabel(name string) string {
+	if name == "" || !receiver.Reports(name) {
+		return UnknownReceiver
+	}
+	return name
+}
+
+// TrackChannel reports the number of events queued in ch as the depth of the
+// named channel. Tracking a channel under a name that's already in use replaces
+// the previous one.
+func TrackChannel[T any](name string, ch chan T) {
+	channels.set(name, func() int { return len(ch) })
+}
+
+// channelDepths collects the current length of the tracked channels when
+// scraped.
+type channelDepths struct {
+	desc    *prometheus.Desc
+	lock    sync.Mutex
+	lengths map[string]func() int
+}
+
+func (c *channelDepths) set(name string, length func() int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lengths[name] = length
+}
+
+func (c *channelDepths) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *channelDepths) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	names := make([]string, 0, len(c.lengths))
+	for name := range c.lengths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(c.lengths[name]()), name)
+	}
+}
+
+// Swappable is a collector whose underlying collector can be replaced while
+// registered, e.g. to change the labels of its metrics. It doesn't describe
+// any metrics, so the registry doesn't check them for consistency.
+type Swappable struct {
+	lock      sync.RWMutex
+	collector prometheus.Collector
+}
+
+// Set replaces the underlying collector. A nil collector collects nothing.
+func (s *Swappable) Set(c prometheus.Collector) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.collector = c
+}
+
+func (s *Swappable) Describe(chan<- *prometheus.Desc) {}
+
+func (s *Swappable) Collect(ch chan<- prometheus.Metric) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.collector != nil {
+		s.collector.Collect(ch)
+	}
+}
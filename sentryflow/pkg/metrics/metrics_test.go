@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+)
+
+func TestTrackChannel(t *testing.T) {
+	// Given
+	ch := make(chan int, 10)
+	ch <- 1
+	ch <- 2
+	TrackChannel("test", ch)
+
+	// When
+	want := `
+# HELP sentryflow_channel_depth Number of API events queued in an internal channel.
+# TYPE sentryflow_channel_depth gauge
+sentryflow_channel_depth{channel="test"} 2
+`
+
+	// Then
+	if err := testutil.CollectAndCompare(channels, strings.NewReader(want)); err != nil {
+		t.Errorf("TrackChannel() unexpected metrics: %v", err)
+	}
+
+	t.Run("with same name should replace channel", func(t *testing.T) {
+		TrackChannel("test", make(chan int, 10))
+
+		want := `
+# HELP sentryflow_channel_depth Number of API events queued in an internal channel.
+# TYPE sentryflow_channel_depth gauge
+sentryflow_channel_depth{channel="test"} 0
+`
+		if err := testutil.CollectAndCompare(channels, strings.NewReader(want)); err != nil {
+			t.Errorf("TrackChannel() unexpected metrics: %v", err)
+		}
+	})
+}
+
+func TestReceiverLabel(t *testing.T) {
+	receiver.Register(receiver.Registration{
+		Name:       "metrics-test",
+		Factory:    func(*config.Config, receiver.Dependencies) receiver.Receiver { return nil },
+		EventNames: []string{"Metrics-Test"},
+	})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "metrics-test", want: "metrics-test"},
+		{name: "Metrics-Test", want: "Metrics-Test"},
+		{name: "not-registered", want: UnknownReceiver},
+		{name: "", want: UnknownReceiver},
+	}
+	for _, tt := range tests {
+		if got := ReceiverLabel(tt.name); got != tt.want {
+			t.Errorf("ReceiverLabel(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	// Given
+	F5ParseFailures.Inc()
+	EventsDropped.WithLabelValues("http").Inc()
+
+	// When
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Then
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Handler() status = %v, want %v", recorder.Code, http.StatusOK)
+	}
+	body, _ := io.ReadAll(recorder.Body)
+	for _, name := range []string{
+		"sentryflow_f5_parse_failures_total",
+		`sentryflow_events_dropped_total{exporter="http"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), name) {
+			t.Errorf("Handler() response doesn't contain %s", name)
+		}
+	}
+}
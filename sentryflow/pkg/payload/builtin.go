@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package payload
+
+// Builtin templates.
+const (
+	// BuiltinSlack renders a Slack incoming webhook message.
+	BuiltinSlack = "slack"
+	// BuiltinTeams renders a Microsoft Teams message with an Adaptive Card,
+	// as accepted by Teams workflows' webhooks.
+	BuiltinTeams = "teams"
+	// BuiltinFlat renders the flattened event, see Event, as a JSON object.
+	BuiltinFlat = "flat"
+)
+
+// Builtins lists all builtin templates.
+var Builtins = []string{BuiltinSlack, BuiltinTeams, BuiltinFlat}
+
+var builtins = map[string]string{
+	BuiltinSlack: slackTemplate,
+	BuiltinTeams: teamsTemplate,
+	BuiltinFlat:  `{{ json . }}`,
+}
+
+const slackTemplate = `{{- $title := printf "%s %s returned %d" .Method .Path .Status -}}
+{
+  "text": {{ json $title }},
+  "blocks": [
+    {
+      "type": "section",
+      "text": {"type": "mrkdwn", "text": {{ json (printf "*%s*" $title) }}}
+    },
+    {
+      "type": "section",
+      "fields": [
+        {"type": "mrkdwn", "text": {{ json (printf "*Source*\n%s/%s" .SourceNamespace .SourceName) }}},
+        {"type": "mrkdwn", "text": {{ json (printf "*Destination*\n%s/%s" .DestinationNamespace .DestinationName) }}},
+        {"type": "mrkdwn", "text": {{ json (printf "*Latency*\n%.1fms" .LatencyMs) }}},
+        {"type": "mrkdwn", "text": {{ json (printf "*Receiver*\n%s" .Receiver) }}}
+      ]
+    }
+  ]
+}`
+
+const teamsTemplate = `{{- $title := printf "%s %s returned %d" .Method .Path .Status -}}
+{
+  "type": "message",
+  "attachments": [
+    {
+      "contentType": "application/vnd.microsoft.card.adaptive",
+      "content": {
+        "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+        "type": "AdaptiveCard",
+        "version": "1.4",
+        "body": [
+          {
+            "type": "TextBlock",
+            "text": {{ json $title }},
+            "weight": "Bolder",
+            "wrap": true{{ if ge .Status 500 }},
+            "color": "Attention"{{ end }}
+          },
+          {
+            "type": "FactSet",
+            "facts": [
+              {"title": "Source", "value": {{ json (printf "%s/%s" .SourceNamespace .SourceName) }}},
+              {"title": "Destination", "value": {{ json (printf "%s/%s" .DestinationNamespace .DestinationName) }}},
+              {"title": "Latency", "value": {{ json (printf "%.1fms" .LatencyMs) }}},
+              {"title": "Receiver", "value": {{ json .Receiver }}}
+            ]
+          }
+        ]
+      }
+    }
+  ]
+}`
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+// jsonPath is a JSONPath expression selecting a single value by member names
+// and array indexes, e.g. `$.request.headers[':path']` or `$.items[0].name`.
+type jsonPath struct {
+	// steps are member names, or array indexes if they're ints.
+	steps []any
+}
+
+func parseJSONPath(expr string) (*jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("%q doesn't start with $", expr)
+	}
+
+	p := &jsonPath{}
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("%q has an empty member name", expr)
+			}
+			p.steps = append(p.steps, name)
+			rest = rest[end+1:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%q has an unclosed [", expr)
+			}
+			selector := rest[1:end]
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				p.steps = append(p.steps, selector[1:len(selector)-1])
+			} else if index, err := strconv.Atoi(selector); err == nil && index >= 0 {
+				p.steps = append(p.steps, index)
+			} else {
+				return nil, fmt.Errorf("%q has an unsupported selector [%s]", expr, selector)
+			}
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("%q has an unexpected %q", expr, rest[0])
+		}
+	}
+	return p, nil
+}
+
+// eval returns the value p selects in doc, nil if there is none.
+func (p *jsonPath) eval(doc any) any {
+	value := doc
+	for _, step := range p.steps {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil
+			}
+			value = object[step]
+		case int:
+			array, ok := value.([]any)
+			if !ok || step >= len(array) {
+				return nil
+			}
+			value = array[step]
+		}
+	}
+	return value
+}
+
+// document returns the protojson document of event JSONPath expressions are
+// evaluated against.
+func document(event *protobuf.APIEvent) (any, error) {
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
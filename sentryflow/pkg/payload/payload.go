@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package payload transforms API events into the request bodies expected by
+// webhooks, with Go templates or JSONPath expressions.
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+// Transform renders API events into payloads.
+type Transform struct {
+	template *template.Template
+	fields   map[string]*jsonPath
+}
+
+// New returns the Transform of the builtin template named builtin, of the Go
+// template text, or of fields, a JSON object's fields and the JSONPath
+// expressions their values are selected with. Exactly one of them must be set.
+func New(builtin, text string, fields map[string]string) (*Transform, error) {
+	set := 0
+	for _, isSet := range []bool{builtin != "", text != "", len(fields) > 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one of builtin, text or fields must be provided")
+	}
+
+	if builtin != "" {
+		builtinText, exists := builtins[builtin]
+		if !exists {
+			return nil, fmt.Errorf("unsupported builtin template, %v", builtin)
+		}
+		text = builtinText
+	}
+
+	if text != "" {
+		tmpl, err := template.New("payload").Funcs(funcs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template, %v", err)
+		}
+		return &Transform{template: tmpl}, nil
+	}
+
+	t := &Transform{fields: make(map[string]*jsonPath, len(fields))}
+	for name, expr := range fields {
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s field JSONPath, %v", name, err)
+		}
+		t.fields[name] = path
+	}
+	return t, nil
+}
+
+// Render returns the payload of event.
+func (t *Transform) Render(event *protobuf.APIEvent) ([]byte, error) {
+	if t.template != nil {
+		var buf bytes.Buffer
+		if err := t.template.Execute(&buf, NewEvent(event)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	doc, err := document(event)
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]any, len(t.fields))
+	for name, path := range t.fields {
+		object[name] = path.eval(doc)
+	}
+	return json.Marshal(object)
+}
+
+// Event is the flattened view of an API event templates are rendered with.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	ContextID uint32    `json:"contextId"`
+	Receiver  string    `json:"receiver"`
+
+	SourceName           string `json:"sourceName,omitempty"`
+	SourceNamespace      string `json:"sourceNamespace,omitempty"`
+	SourceIP             string `json:"sourceIp,omitempty"`
+	SourcePort           int32  `json:"sourcePort,omitempty"`
+	DestinationName      string `json:"destinationName,omitempty"`
+	DestinationNamespace string `json:"destinationNamespace,omitempty"`
+	DestinationIP        string `json:"destinationIp,omitempty"`
+	DestinationPort      int32  `json:"destinationPort,omitempty"`
+
+	Protocol  string  `json:"protocol,omitempty"`
+	Method    string  `json:"method,omitempty"`
+	Authority string  `json:"authority,omitempty"`
+	Path      string  `json:"path,omitempty"`
+	Route     string  `json:"route,omitempty"`
+	Status    int     `json:"status,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+
+	RequestHeaders  map[string]string `json:"requestHeaders,omitempty"`
+	RequestBody     string            `json:"requestBody,omitempty"`
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string            `json:"responseBody,omitempty"`
+
+	// Event is the original event, for the fields that aren't flattened.
+	Event *protobuf.APIEvent `json:"-"`
+}
+
+// NewEvent returns the flattened view of event.
+func NewEvent(event *protobuf.APIEvent) *Event {
+	requestHeaders := event.GetRequest().GetHeaders()
+	responseHeaders := event.GetResponse().GetHeaders()
+	status, _ := strconv.Atoi(responseHeaders[":status"])
+
+	e := &Event{
+		ContextID:            event.GetMetadata().GetContextId(),
+		Receiver:             event.GetMetadata().GetReceiverName(),
+		SourceName:           event.GetSource().GetName(),
+		SourceNamespace:      event.GetSource().GetNamespace(),
+		SourceIP:             event.GetSource().GetIp(),
+		SourcePort:           event.GetSource().GetPort(),
+		DestinationName:      event.GetDestination().GetName(),
+		DestinationNamespace: event.GetDestination().GetNamespace(),
+		DestinationIP:        event.GetDestination().GetIp(),
+		DestinationPort:      event.GetDestination().GetPort(),
+		Protocol:             event.GetProtocol(),
+		Method:               requestHeaders[":method"],
+		Authority:            requestHeaders[":authority"],
+		Path:                 requestHeaders[":path"],
+		Route:                event.GetRequest().GetRoute(),
+		Status:               status,
+		LatencyMs:            float64(event.GetResponse().GetBackendLatencyInNanos()) / float64(time.Millisecond),
+		RequestHeaders:       requestHeaders,
+		RequestBody:          event.GetRequest().GetBody(),
+		ResponseHeaders:      responseHeaders,
+		ResponseBody:         event.GetResponse().GetBody(),
+		Event:                event,
+	}
+	if timestamp := event.GetMetadata().GetTimestamp(); timestamp > 0 {
+		e.Timestamp = time.Unix(int64(timestamp), 0).UTC()
+	}
+	return e
+}
+
+// funcs are the functions templates can use in addition to the predefined
+// ones.
+var funcs = template.FuncMap{
+	// json encodes a value as JSON, e.g. to quote a string.
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// truncate cuts a string to at most n runes.
+	"truncate": func(n int, s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		return string(runes[:n]) + "…"
+	},
+	// upper and lower change the case of a string.
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package payload
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+func TestTransform_Render(t *testing.T) {
+	event := &protobuf.APIEvent{
+		Metadata:    &protobuf.Metadata{ContextId: 7, Timestamp: 1700000000, ReceiverName: "istio-sidecar"},
+		Source:      &protobuf.Workload{Name: "frontend", Namespace: "shop"},
+		Destination: &protobuf.Workload{Name: "payments", Namespace: "payments"},
+		Request: &protobuf.Request{Headers: map[string]string{
+			":method": "POST",
+			":path":   "/charges",
+		}},
+		Response: &protobuf.Response{
+			Headers:               map[string]string{":status": "503"},
+			BackendLatencyInNanos: uint64(1500 * time.Microsecond),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		builtin string
+		text    string
+		fields  map[string]string
+		want    map[string]any
+	}{
+		{
+			name:    "slack",
+			builtin: BuiltinSlack,
+			want:    map[string]any{"text": "POST /charges returned 503"},
+		},
+		{
+			name:    "flat",
+			builtin: BuiltinFlat,
+			want: map[string]any{
+				"timestamp":            "2023-11-14T22:13:20Z",
+				"contextId":            7.0,
+				"receiver":             "istio-sidecar",
+				"sourceName":           "frontend",
+				"sourceNamespace":      "shop",
+				"destinationName":      "payments",
+				"destinationNamespace": "payments",
+				"method":               "POST",
+				"path":                 "/charges",
+				"status":               503.0,
+				"latencyMs":            1.5,
+				"requestHeaders":       map[string]any{":method": "POST", ":path": "/charges"},
+				"responseHeaders":      map[string]any{":status": "503"},
+			},
+		},
+		{
+			name: "text",
+			text: `{"summary": {{ json (printf "%s in %s" (upper .Method) .Event.Destination.Namespace) }}}`,
+			want: map[string]any{"summary": "POST in payments"},
+		},
+		{
+			name: "fields",
+			fields: map[string]string{
+				"path":     "$.request.headers[':path']",
+				"receiver": "$.metadata.receiverName",
+				"missing":  "$.request.body",
+			},
+			want: map[string]any{"path": "/charges", "receiver": "istio-sidecar", "missing": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := New(tt.builtin, tt.text, tt.fields)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			rendered, err := transform.Render(event)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(rendered, &got); err != nil {
+				t.Fatalf("Render() = %s isn't a JSON object: %v", rendered, err)
+			}
+			for key, want := range tt.want {
+				if !reflect.DeepEqual(got[key], want) {
+					t.Errorf("Render() %s = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransform_Render_teams(t *testing.T) {
+	transform, err := New(BuiltinTeams, "", nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Given an event with a status and path that need escaping
+	rendered, err := transform.Render(&protobuf.APIEvent{
+		Request:  &protobuf.Request{Headers: map[string]string{":method": "GET", ":path": `/search?q="x"`}},
+		Response: &protobuf.Response{Headers: map[string]string{":status": "500"}},
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	// Then
+	var message struct {
+		Attachments []struct {
+			Content struct {
+				Body []struct {
+					Text  string `json:"text"`
+					Color string `json:"color"`
+				} `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(rendered, &message); err != nil {
+		t.Fatalf("Render() = %s isn't valid JSON: %v", rendered, err)
+	}
+	title := message.Attachments[0].Content.Body[0]
+	if title.Text != `GET /search?q="x" returned 500` || title.Color != "Attention" {
+		t.Errorf("Render() title = %+v, want the request in attention color", title)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name               string
+		builtin            string
+		text               string
+		fields             map[string]string
+		expectedErrMessage string
+	}{
+		{
+			name:               "without template should return error",
+			expectedErrMessage: "exactly one of builtin, text or fields must be provided",
+		},
+		{
+			name:               "with builtin and text should return error",
+			builtin:            BuiltinFlat,
+			text:               "{{ . }}",
+			expectedErrMessage: "exactly one of builtin, text or fields must be provided",
+		},
+		{
+			name:               "with unknown function should return error",
+			text:               "{{ base64 .Path }}",
+			expectedErrMessage: `invalid template, template: payload:1: function "base64" not defined`,
+		},
+		{
+			name:               "with unsupported JSONPath selector should return error",
+			fields:             map[string]string{"paths": "$.request[*]"},
+			expectedErrMessage: `invalid paths field JSONPath, "$.request[*]" has an unsupported selector [*]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.builtin, tt.text, tt.fields)
+			if err == nil || err.Error() != tt.expectedErrMessage {
+				t.Errorf("New() expected error message to be %v but got %v", tt.expectedErrMessage, err)
+			}
+		})
+	}
+}
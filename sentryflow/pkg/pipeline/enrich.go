@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"maps"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func init() {
+	Register(config.ProcessorEnrich, func(cfg *config.ProcessorConfig, deps Dependencies) (Processor, error) {
+		e := &enricher{workloads: make(map[string]config.EnrichWorkload, len(cfg.Enrich.Workloads))}
+		for _, workload := range cfg.Enrich.Workloads {
+			e.workloads[workload.IP] = workload
+		}
+		if cfg.Enrich.Kubernetes {
+			e.resolver = deps.Workloads
+		}
+		return e, nil
+	})
+}
+
+// enricher fills in the source and destination workloads of events by their
+// IP address, from the configured workloads and then from the Kubernetes
+// workloads.
+type enricher struct {
+	workloads map[string]config.EnrichWorkload
+
+	// resolver is nil if Kubernetes enrichment is disabled or unavailable, in
+	// which case only the configured workloads are used.
+	resolver WorkloadResolver
+}
+
+func (e *enricher) Process(event *protobuf.APIEvent) Result {
+	enrichedSrc := e.enrich(event.GetSource())
+	enrichedDst := e.enrich(event.GetDestination())
+	if enrichedSrc || enrichedDst {
+		return Modified
+	}
+	return Unchanged
+}
+
+func (e *enricher) enrich(workload *protobuf.Workload) bool {
+	if workload == nil || workload.Ip == "" {
+		return false
+	}
+
+	enriched := false
+	if known, exists := e.workloads[workload.Ip]; exists && workload.Name == "" {
+		workload.Name = known.Name
+		workload.Namespace = known.Namespace
+		enriched = true
+	}
+
+	if e.resolver == nil {
+		return enriched
+	}
+	known, exists := e.resolver.Lookup(workload.Ip)
+	if !exists {
+		return enriched
+	}
+	// Only the fields the receiver or the configured workloads left empty are
+	// filled in.
+	for _, field := range []struct {
+		value *string
+		known string
+	}{
+		{&workload.Name, known.Name},
+		{&workload.Namespace, known.Namespace},
+		{&workload.Kind, known.Kind},
+		{&workload.NodeName, known.NodeName},
+	} {
+		if *field.value == "" && field.known != "" {
+			*field.value = field.known
+			enriched = true
+		}
+	}
+	if len(workload.Labels) == 0 && len(known.Labels) > 0 {
+		workload.Labels = maps.Clone(known.Labels)
+		enriched = true
+	}
+	return enriched
+}
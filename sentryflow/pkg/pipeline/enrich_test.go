@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/k8s"
+)
+
+type workloads map[string]k8s.Workload
+
+func (w workloads) Lookup(ip string) (k8s.Workload, bool) {
+	workload, exists := w[ip]
+	return workload, exists
+}
+
+func Test_enricher_Process(t *testing.T) {
+	factory := factories[config.ProcessorEnrich]
+	e, err := factory(&config.ProcessorConfig{Enrich: &config.EnrichConfig{Workloads: []config.EnrichWorkload{
+		{IP: "10.0.0.1", Name: "web", Namespace: "other"},
+		{IP: "10.0.0.2", Name: "backend", Namespace: "shop"},
+	}}}, Dependencies{})
+	if err != nil {
+		t.Fatalf("factory() error = %v", err)
+	}
+
+	// Given a named source and an unnamed destination
+	event := newEvent("GET", "/", "200")
+
+	// When
+	got := e.Process(event)
+
+	// Then only the destination is named
+	if got != Modified {
+		t.Errorf("Process() = %v, want %v", got, Modified)
+	}
+	if event.Source.Name != "frontend" || event.Source.Namespace != "shop" {
+		t.Errorf("Process() source = %v, want it untouched", event.Source)
+	}
+	if event.Destination.Name != "backend" || event.Destination.Namespace != "shop" {
+		t.Errorf("Process() destination = %v, want backend.shop", event.Destination)
+	}
+
+	// Given an unknown workload
+	event = newEvent("GET", "/", "200")
+	event.Destination.Ip = "10.0.0.3"
+	if got := e.Process(event); got != Unchanged {
+		t.Errorf("Process() = %v, want %v", got, Unchanged)
+	}
+}
+
+func Test_enricher_Process_kubernetes(t *testing.T) {
+	deps := Dependencies{Workloads: workloads{
+		"10.0.0.1": {Name: "frontend", Namespace: "shop", Kind: "Deployment", Labels: map[string]string{"app": "frontend"}, NodeName: "node-1"},
+		"10.0.0.2": {Name: "backend", Namespace: "shop", Kind: "StatefulSet", NodeName: "node-2"},
+	}}
+	factory := factories[config.ProcessorEnrich]
+
+	tests := []struct {
+		name            string
+		enrich          *config.EnrichConfig
+		want            Result
+		wantDestination string
+		wantSourceKind  string
+	}{
+		{
+			name:            "with kubernetes enrichment should fill in empty fields",
+			enrich:          &config.EnrichConfig{Kubernetes: true},
+			want:            Modified,
+			wantDestination: "backend/shop/StatefulSet/node-2",
+			wantSourceKind:  "Deployment",
+		},
+		{
+			name: "with configured workload should prefer its name",
+			enrich: &config.EnrichConfig{Kubernetes: true, Workloads: []config.EnrichWorkload{
+				{IP: "10.0.0.2", Name: "db", Namespace: "data"},
+			}},
+			want:            Modified,
+			wantDestination: "db/data/StatefulSet/node-2",
+			wantSourceKind:  "Deployment",
+		},
+		{
+			name:            "without kubernetes enrichment should not look workloads up",
+			enrich:          &config.EnrichConfig{Workloads: []config.EnrichWorkload{{IP: "10.0.0.9", Name: "other"}}},
+			want:            Unchanged,
+			wantDestination: "///",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := factory(&config.ProcessorConfig{Enrich: tt.enrich}, deps)
+			if err != nil {
+				t.Fatalf("factory() error = %v", err)
+			}
+			event := newEvent("GET", "/", "200")
+
+			if got := e.Process(event); got != tt.want {
+				t.Errorf("Process() = %v, want %v", got, tt.want)
+			}
+			dst := event.Destination
+			if got := dst.Name + "/" + dst.Namespace + "/" + dst.Kind + "/" + dst.NodeName; got != tt.wantDestination {
+				t.Errorf("Process() destination = %v, want %v", got, tt.wantDestination)
+			}
+			if event.Source.Kind != tt.wantSourceKind {
+				t.Errorf("Process() source kind = %v, want %v", event.Source.Kind, tt.wantSourceKind)
+			}
+		})
+	}
+}
+
+func Test_enricher_Process_kubernetesUnavailable(t *testing.T) {
+	factory := factories[config.ProcessorEnrich]
+	e, err := factory(&config.ProcessorConfig{Enrich: &config.EnrichConfig{Kubernetes: true}}, Dependencies{})
+	if err != nil {
+		t.Fatalf("factory() error = %v", err)
+	}
+
+	if got := e.Process(newEvent("GET", "/", "200")); got != Unchanged {
+		t.Errorf("Process() = %v, want %v", got, Unchanged)
+	}
+}
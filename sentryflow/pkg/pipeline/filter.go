@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+)
+
+func init() {
+	Register(config.ProcessorFilter, newFilter)
+	Register(config.ProcessorDrop, func(*config.ProcessorConfig, Dependencies) (Processor, error) {
+		return drop{}, nil
+	})
+}
+
+// filter keeps the events matching include, if set, unless they match
+// exclude.
+type filter struct {
+	include *match.Matcher
+	exclude *match.Matcher
+}
+
+func newFilter(cfg *config.ProcessorConfig, _ Dependencies) (Processor, error) {
+	include, err := match.New(cfg.Filter.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := match.New(cfg.Filter.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &filter{include: include, exclude: exclude}, nil
+}
+
+func (f *filter) Process(event *protobuf.APIEvent) Result {
+	if !f.include.Matches(event) {
+		return Dropped
+	}
+	if f.exclude != nil && f.exclude.Matches(event) {
+		return Dropped
+	}
+	return Unchanged
+}
+
+// drop drops every event. Its match selects the events to drop.
+type drop struct{}
+
+func (drop) Process(*protobuf.APIEvent) Result {
+	return Dropped
+}
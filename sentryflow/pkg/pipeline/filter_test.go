@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func Test_filter_Process(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *config.FilterConfig
+		status string
+		want   Result
+	}{
+		{
+			name:   "included event should be kept",
+			filter: &config.FilterConfig{Include: &config.MatchConfig{Statuses: []string{"5xx"}}},
+			status: "500",
+			want:   Unchanged,
+		},
+		{
+			name:   "event not included should be dropped",
+			filter: &config.FilterConfig{Include: &config.MatchConfig{Statuses: []string{"5xx"}}},
+			status: "200",
+			want:   Dropped,
+		},
+		{
+			name:   "excluded event should be dropped",
+			filter: &config.FilterConfig{Exclude: &config.MatchConfig{Statuses: []string{"2xx"}}},
+			status: "200",
+			want:   Dropped,
+		},
+		{
+			name:   "event not excluded should be kept",
+			filter: &config.FilterConfig{Exclude: &config.MatchConfig{Statuses: []string{"2xx"}}},
+			status: "404",
+			want:   Unchanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newFilter(&config.ProcessorConfig{Type: config.ProcessorFilter, Filter: tt.filter}, Dependencies{})
+			if err != nil {
+				t.Fatalf("newFilter() error = %v", err)
+			}
+			if got := f.Process(newEvent("GET", "/", tt.status)); got != tt.want {
+				t.Errorf("Process() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/classifier"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func init() {
+	Register(config.ProcessorNormalize, func(cfg *config.ProcessorConfig, _ Dependencies) (Processor, error) {
+		normalizer, err := classifier.NewNormalizer(cfg.Normalize)
+		if err != nil {
+			return nil, err
+		}
+		return &normalize{normalizer: normalizer}, nil
+	})
+}
+
+// normalize sets the templated route of the events' request paths.
+type normalize struct {
+	normalizer *classifier.Normalizer
+}
+
+func (n *normalize) Process(event *protobuf.APIEvent) Result {
+	request := event.GetRequest()
+	if request == nil {
+		return Unchanged
+	}
+	route := n.normalizer.Route(request.GetHeaders()[":path"])
+	if route == request.Route {
+		return Unchanged
+	}
+	request.Route = route
+	return Modified
+}
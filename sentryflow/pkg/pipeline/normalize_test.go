@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"testing"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func Test_normalize_Process(t *testing.T) {
+	factory := factories[config.ProcessorNormalize]
+	n, err := factory(&config.ProcessorConfig{Normalize: &config.NormalizationConfig{
+		Rules: []config.NormalizationRule{{Pattern: `^/static/.*$`, Route: "/static/{file}"}},
+	}}, Dependencies{})
+	if err != nil {
+		t.Fatalf("factory() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		event     *protobuf.APIEvent
+		want      Result
+		wantRoute string
+	}{
+		{
+			name:      "with IDs in path should set templated route",
+			event:     newEvent("GET", "/orders/8812/items/3", "200"),
+			want:      Modified,
+			wantRoute: "/orders/{id}/items/{id}",
+		},
+		{
+			name:      "with path matching rule should set rule's route",
+			event:     newEvent("GET", "/static/css/app.css", "200"),
+			want:      Modified,
+			wantRoute: "/static/{file}",
+		},
+		{
+			name: "with route already set should leave event unchanged",
+			event: func() *protobuf.APIEvent {
+				event := newEvent("GET", "/users/1", "200")
+				event.Request.Route = "/users/{id}"
+				return event
+			}(),
+			want:      Unchanged,
+			wantRoute: "/users/{id}",
+		},
+		{
+			name:  "without request should leave event unchanged",
+			event: &protobuf.APIEvent{},
+			want:  Unchanged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got := n.Process(tt.event)
+
+			// Then
+			if got != tt.want {
+				t.Errorf("Process() = %v, want %v", got, tt.want)
+			}
+			if route := tt.event.GetRequest().GetRoute(); route != tt.wantRoute {
+				t.Errorf("Process() request.route = %q, want %q", route, tt.wantRoute)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package pipeline runs API events through the chain of processors configured
+// in the `pipeline` section between the receivers and the exporters.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/k8s"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+// Result is what a processor did with an event.
+type Result int
+
+const (
+	// Unchanged means the event is passed on as it was.
+	Unchanged Result = iota
+	// Modified means the event was changed in place and is passed on.
+	Modified
+	// Dropped means the event must not reach the exporters.
+	Dropped
+)
+
+// Processor transforms or drops API events. Processors of a pipeline are
+// called one event at a time, in order.
+type Processor interface {
+	Process(event *protobuf.APIEvent) Result
+}
+
+// Dependencies holds the resources shared by the processors of a pipeline.
+type Dependencies struct {
+	// Workloads resolves IP addresses to Kubernetes workloads. It's nil when
+	// SentryFlow doesn't run in, or can't reach, a Kubernetes cluster.
+	Workloads WorkloadResolver
+}
+
+// WorkloadResolver resolves IP addresses to the Kubernetes workloads they
+// belong to.
+type WorkloadResolver interface {
+	Lookup(ip string) (k8s.Workload, bool)
+}
+
+// Factory returns a processor for cfg. cfg has been validated by the config
+// package.
+type Factory func(cfg *config.ProcessorConfig, deps Dependencies) (Processor, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a processor type available to pipelines. It panics if the
+// type is already registered.
+func Register(processorType string, factory Factory) {
+	if _, exists := factories[processorType]; exists {
+		panic(fmt.Sprintf("pipeline processor %s registered twice", processorType))
+	}
+	factories[processorType] = factory
+}
+
+// Pipeline is an ordered chain of processors. A nil Pipeline passes events on
+// untouched.
+type Pipeline struct {
+	stages []*stage
+}
+
+type stage struct {
+	match     *match.Matcher
+	processor Processor
+	dropped   prometheus.Counter
+	modified  prometheus.Counter
+}
+
+// New returns the pipeline configured by cfg.
+func New(cfg *config.PipelineConfig, deps Dependencies) (*Pipeline, error) {
+	p := &Pipeline{}
+	if cfg == nil {
+		return p, nil
+	}
+
+	for _, procCfg := range cfg.Processors {
+		factory, exists := factories[procCfg.Type]
+		if !exists {
+			return nil, fmt.Errorf("unsupported pipeline processor type, %v", procCfg.Type)
+		}
+		m, err := match.New(procCfg.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v pipeline processor: %w", procCfg.Name, err)
+		}
+		processor, err := factory(procCfg, deps)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v pipeline processor: %w", procCfg.Name, err)
+		}
+
+		p.stages = append(p.stages, &stage{
+			match:     m,
+			processor: processor,
+			dropped:   metrics.ProcessorEvents.WithLabelValues(procCfg.Name, "dropped"),
+			modified:  metrics.ProcessorEvents.WithLabelValues(procCfg.Name, "modified"),
+		})
+	}
+	return p, nil
+}
+
+// Process runs event through the processors whose match it matches. It
+// returns nil if a processor dropped the event.
+func (p *Pipeline) Process(event *protobuf.APIEvent) *protobuf.APIEvent {
+	if p == nil {
+		return event
+	}
+
+	for _, s := range p.stages {
+		if !s.match.Matches(event) {
+			continue
+		}
+		switch s.processor.Process(event) {
+		case Dropped:
+			s.dropped.Inc()
+			return nil
+		case Modified:
+			s.modified.Inc()
+		}
+	}
+	return event
+}
+
+// Run passes the events received on in through the current pipeline and
+// publishes the ones it keeps to out, until ctx is done or in is closed. Events
+// are counted as received before they enter the pipeline, and the ones it
+// drops are counted by the processor that dropped them. The current pipeline
+// can be replaced at any time, e.g. when the configuration changes.
+func Run(ctx context.Context, in <-chan *protobuf.APIEvent, out chan<- *protobuf.APIEvent, current *atomic.Pointer[Pipeline]) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case ev, ok := <-in:
+			if !ok {
+				return
+			}
+			metrics.EventsReceived.WithLabelValues(metrics.ReceiverLabel(ev.GetMetadata().GetReceiverName())).Inc()
+			if ev = current.Load().Process(ev); ev == nil {
+				continue
+			}
+
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
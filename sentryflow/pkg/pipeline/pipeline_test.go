@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("with unsupported type should return error", func(t *testing.T) {
+		_, err := New(&config.PipelineConfig{Processors: []*config.ProcessorConfig{{Name: "encrypt", Type: "encrypt"}}}, Dependencies{})
+		if err == nil || err.Error() != "unsupported pipeline processor type, encrypt" {
+			t.Errorf("New() expected unsupported type error but got %v", err)
+		}
+	})
+
+	t.Run("without config should pass events on", func(t *testing.T) {
+		p, err := New(nil, Dependencies{})
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		event := newEvent("GET", "/users/1", "200")
+		if got := p.Process(event); got != event {
+			t.Errorf("Process() = %v, want %v", got, event)
+		}
+	})
+}
+
+func TestPipeline_Process(t *testing.T) {
+	// Given
+	p, err := New(&config.PipelineConfig{Processors: []*config.ProcessorConfig{
+		{
+			Name:  "drop-health",
+			Type:  config.ProcessorDrop,
+			Match: &config.MatchConfig{Paths: []string{"^/healthz$"}},
+		},
+		{
+			Name:      "routes",
+			Type:      config.ProcessorNormalize,
+			Normalize: &config.NormalizationConfig{},
+		},
+	}}, Dependencies{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	dropped := testutil.ToFloat64(metrics.ProcessorEvents.WithLabelValues("drop-health", "dropped"))
+	modified := testutil.ToFloat64(metrics.ProcessorEvents.WithLabelValues("routes", "modified"))
+
+	// When
+	health := p.Process(newEvent("GET", "/healthz?probe=liveness", "200"))
+	user := p.Process(newEvent("GET", "/users/1", "200"))
+
+	// Then
+	if health != nil {
+		t.Errorf("Process() kept %v, want it dropped", health)
+	}
+	if user == nil || user.GetRequest().GetRoute() != "/users/{id}" {
+		t.Errorf("Process() = %v, want route /users/{id}", user)
+	}
+	if got := testutil.ToFloat64(metrics.ProcessorEvents.WithLabelValues("drop-health", "dropped")) - dropped; got != 1 {
+		t.Errorf("Process() dropped count = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.ProcessorEvents.WithLabelValues("routes", "modified")) - modified; got != 1 {
+		t.Errorf("Process() modified count = %v, want 1", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan *protobuf.APIEvent)
+	out := make(chan *protobuf.APIEvent, 1)
+	current := &atomic.Pointer[Pipeline]{}
+	go Run(ctx, in, out, current)
+
+	// Given no pipeline
+	in <- newEvent("GET", "/users/1", "200")
+	// Then events are passed on
+	if got := receive(t, out); got.GetRequest().GetRoute() != "" {
+		t.Errorf("Run() route = %q, want none", got.GetRequest().GetRoute())
+	}
+
+	// Given a pipeline dropping errors
+	p, err := New(&config.PipelineConfig{Processors: []*config.ProcessorConfig{
+		{Name: "drop-errors", Type: config.ProcessorDrop, Match: &config.MatchConfig{Statuses: []string{"5xx"}}},
+	}}, Dependencies{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	current.Store(p)
+	label := metrics.ReceiverLabel(newEvent("GET", "/", "200").GetMetadata().GetReceiverName())
+	received := testutil.ToFloat64(metrics.EventsReceived.WithLabelValues(label))
+	in <- newEvent("GET", "/users/1", "503")
+	in <- newEvent("GET", "/users/2", "200")
+	// Then only the other events are passed on
+	if got := receive(t, out); got.GetRequest().GetHeaders()[":path"] != "/users/2" {
+		t.Errorf("Run() passed on %v, want /users/2", got)
+	}
+	// And the dropped ones are still counted as received
+	if got := testutil.ToFloat64(metrics.EventsReceived.WithLabelValues(label)) - received; got != 2 {
+		t.Errorf("Run() counted %v received events, want 2", got)
+	}
+}
+
+func receive(t *testing.T, out <-chan *protobuf.APIEvent) *protobuf.APIEvent {
+	t.Helper()
+	select {
+	case ev := <-out:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() didn't pass on the event")
+		return nil
+	}
+}
+
+func newEvent(method, path, status string) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Metadata:    &protobuf.Metadata{ReceiverName: "istio-sidecar"},
+		Source:      &protobuf.Workload{Name: "frontend", Namespace: "shop", Ip: "10.0.0.1"},
+		Destination: &protobuf.Workload{Ip: "10.0.0.2", Port: 8080},
+		Request: &protobuf.Request{Headers: map[string]string{
+			":method":       method,
+			":path":         path,
+			"authorization": "Bearer secret",
+		}},
+		Response: &protobuf.Response{Headers: map[string]string{":status": status}},
+	}
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+)
+
+func init() {
+	Register(config.ProcessorRedact, func(cfg *config.ProcessorConfig, _ Dependencies) (Processor, error) {
+		r, err := redact.New(cfg.Redact)
+		if err != nil {
+			return nil, err
+		}
+		return &redactor{redactor: r}, nil
+	})
+}
+
+// redactor removes PII and secrets from the events' headers and bodies.
+type redactor struct {
+	redactor *redact.Redactor
+}
+
+func (r *redactor) Process(event *protobuf.APIEvent) Result {
+	if r.redactor.Redact(event) {
+		return Modified
+	}
+	return Unchanged
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/redact"
+)
+
+func Test_redactor_Process(t *testing.T) {
+	factory := factories[config.ProcessorRedact]
+	r, err := factory(&config.ProcessorConfig{Redact: &config.RedactConfig{
+		Detectors: []string{config.RedactDetectorNone},
+		Headers:   []string{"Authorization", "Set-Cookie"},
+	}}, Dependencies{})
+	if err != nil {
+		t.Fatalf("factory() error = %v", err)
+	}
+
+	// Given
+	event := newEvent("GET", "/", "200")
+	event.Response.Headers["set-cookie"] = "session=1"
+
+	// When
+	got := r.Process(event)
+
+	// Then
+	if got != Modified {
+		t.Errorf("Process() = %v, want %v", got, Modified)
+	}
+	if v := event.Request.Headers["authorization"]; v != redact.Mask {
+		t.Errorf("Process() authorization = %q, want %q", v, redact.Mask)
+	}
+	if v := event.Response.Headers["set-cookie"]; v != redact.Mask {
+		t.Errorf("Process() set-cookie = %q, want %q", v, redact.Mask)
+	}
+	if v := event.Request.Headers[":path"]; v != "/" {
+		t.Errorf("Process() :path = %q, want it untouched", v)
+	}
+
+	// When processed again
+	if got := r.Process(event); got != Unchanged {
+		t.Errorf("Process() of redacted event = %v, want %v", got, Unchanged)
+	}
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/match"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// Sampling decisions and always-keep rules of the events' SamplingDecision.
+const (
+	samplingAlways  = "always"
+	samplingSampled = "sampled"
+
+	samplingRuleStatus    = "status"
+	samplingRuleLatency   = "latency"
+	samplingRuleNamespace = "namespace"
+)
+
+func init() {
+	Register(config.ProcessorSample, func(cfg *config.ProcessorConfig, _ Dependencies) (Processor, error) {
+		return newSampler(cfg.Sample)
+	})
+}
+
+// sampler keeps the events selected by its always-keep rules, and a random
+// fraction of the others within the budget of their endpoint. It annotates
+// the events it keeps with the probability they had of being kept.
+type sampler struct {
+	rate float64
+
+	budget       int
+	window       time.Duration
+	maxEndpoints int
+	endpoints    map[string]*endpointBudget
+	// overflow is the budget shared by the endpoints above maxEndpoints.
+	overflow *endpointBudget
+
+	keepStatuses   *match.Matcher
+	keepLatency    time.Duration
+	keepNamespaces []string
+
+	// random returns a number in [0, 1).
+	random func() float64
+	now    func() time.Time
+}
+
+// endpointBudget is the number of events of an endpoint seen and kept in the
+// current window.
+type endpointBudget struct {
+	start time.Time
+	seen  int
+	kept  int
+	// rate is the probability of the events of the current window being kept.
+	rate float64
+}
+
+func newSampler(cfg *config.SampleConfig) (*sampler, error) {
+	s := &sampler{
+		rate:         1,
+		budget:       cfg.EndpointBudget,
+		window:       cfg.BudgetWindow,
+		maxEndpoints: cfg.MaxEndpoints,
+		endpoints:    make(map[string]*endpointBudget),
+		overflow:     &endpointBudget{},
+		random:       rand.Float64,
+		now:          time.Now,
+	}
+	if cfg.Rate != nil {
+		s.rate = *cfg.Rate
+	}
+	if s.window <= 0 {
+		s.window = config.DefaultSampleBudgetWindow
+	}
+	if keep := cfg.AlwaysKeep; keep != nil {
+		if len(keep.Statuses) > 0 {
+			statuses, err := match.New(&config.MatchConfig{Statuses: keep.Statuses})
+			if err != nil {
+				return nil, err
+			}
+			s.keepStatuses = statuses
+		}
+		s.keepLatency = keep.MinLatency
+		s.keepNamespaces = keep.Namespaces
+	}
+	return s, nil
+}
+
+func (s *sampler) Process(event *protobuf.APIEvent) Result {
+	if rule := s.keepRule(event); rule != "" {
+		annotate(event, samplingAlways, rule, 1)
+		return Modified
+	}
+
+	if s.random() >= s.rate {
+		return Dropped
+	}
+	rate := s.rate
+	if s.budget > 0 {
+		kept, budgetRate := s.endpointBudget(event).take(s.now(), s.window, s.budget, s.random)
+		if !kept {
+			return Dropped
+		}
+		rate *= budgetRate
+	}
+	annotate(event, samplingSampled, "", rate)
+	return Modified
+}
+
+// keepRule returns the always-keep rule selecting event, if any.
+func (s *sampler) keepRule(event *protobuf.APIEvent) string {
+	switch {
+	case s.keepStatuses != nil && s.keepStatuses.Matches(event):
+		return samplingRuleStatus
+	case s.keepLatency > 0 && time.Duration(event.GetResponse().GetBackendLatencyInNanos()) >= s.keepLatency:
+		return samplingRuleLatency
+	case len(s.keepNamespaces) > 0 && (slices.Contains(s.keepNamespaces, event.GetSource().GetNamespace()) ||
+		slices.Contains(s.keepNamespaces, event.GetDestination().GetNamespace())):
+		return samplingRuleNamespace
+	}
+	return ""
+}
+
+func (s *sampler) endpointBudget(event *protobuf.APIEvent) *endpointBudget {
+	endpoint := endpointOf(event)
+	b, exists := s.endpoints[endpoint]
+	if !exists {
+		if len(s.endpoints) >= s.maxEndpoints {
+			return s.overflow
+		}
+		b = &endpointBudget{}
+		s.endpoints[endpoint] = b
+	}
+	return b
+}
+
+// take reports whether an event seen at now is kept, and the probability it
+// had of being kept. Once limit events were kept in a window, the others are
+// dropped. If more than limit events were seen in the previous window, events
+// are kept with probability limit/seen so that the kept ones are spread over
+// the window.
+func (b *endpointBudget) take(now time.Time, window time.Duration, limit int, random func() float64) (bool, float64) {
+	if elapsed := now.Sub(b.start); elapsed >= window {
+		b.rate = 1
+		if elapsed < 2*window && b.seen > limit {
+			b.rate = float64(limit) / float64(b.seen)
+		}
+		b.start, b.seen, b.kept = now, 0, 0
+	}
+
+	b.seen++
+	if b.kept >= limit || random() >= b.rate {
+		return false, b.rate
+	}
+	b.kept++
+	return true, b.rate
+}
+
+// annotate records the sampling decision of event. If event was already
+// sampled by another processor, the rates are multiplied.
+func annotate(event *protobuf.APIEvent, decision, rule string, rate float64) {
+	if event.Metadata == nil {
+		event.Metadata = &protobuf.Metadata{}
+	}
+	if previous := event.Metadata.Sampling; previous != nil {
+		rate *= previous.Rate
+	}
+	event.Metadata.Sampling = &protobuf.SamplingDecision{
+		Decision: decision,
+		Rule:     rule,
+		Rate:     rate,
+	}
+}
+
+// endpointOf returns the method and route, or path without its query, of
+// event.
+func endpointOf(event *protobuf.APIEvent) string {
+	request := event.GetRequest()
+	route := request.GetRoute()
+	if route == "" {
+		route = util.TrimQuery(request.GetHeaders()[":path"])
+	}
+	return request.GetHeaders()[":method"] + " " + route
+}
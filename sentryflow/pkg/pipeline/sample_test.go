@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func Test_sampler_Process(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		random   float64
+		want     Result
+		wantRate float64
+	}{
+		{name: "below rate should be kept", rate: 0.25, random: 0.1, want: Modified, wantRate: 0.25},
+		{name: "at rate should be dropped", rate: 0.25, random: 0.25, want: Dropped},
+		{name: "zero rate should drop everything", rate: 0, random: 0, want: Dropped},
+		{name: "full rate should keep everything", rate: 1, random: 0.999, want: Modified, wantRate: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSampler(&config.SampleConfig{Rate: &tt.rate})
+			if err != nil {
+				t.Fatalf("newSampler() error = %v", err)
+			}
+			s.random = func() float64 { return tt.random }
+			event := newEvent("GET", "/", "200")
+
+			if got := s.Process(event); got != tt.want {
+				t.Errorf("Process() = %v, want %v", got, tt.want)
+			}
+			if tt.want == Dropped {
+				return
+			}
+			if got := event.Metadata.Sampling; got.Decision != samplingSampled || got.Rate != tt.wantRate {
+				t.Errorf("Process() sampling = %v, want %v at rate %v", got, samplingSampled, tt.wantRate)
+			}
+		})
+	}
+}
+
+func Test_sampler_Process_alwaysKeep(t *testing.T) {
+	rate := 0.0
+	s, err := newSampler(&config.SampleConfig{
+		Rate: &rate,
+		AlwaysKeep: &config.SampleKeepConfig{
+			Statuses:   []string{"5xx", "429"},
+			MinLatency: time.Second,
+			Namespaces: []string{"payments"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newSampler() error = %v", err)
+	}
+	s.random = func() float64 { return 0.5 }
+
+	slow := newEvent("GET", "/", "200")
+	slow.Response.BackendLatencyInNanos = uint64(2 * time.Second)
+	payments := newEvent("GET", "/", "200")
+	payments.Destination.Namespace = "payments"
+
+	tests := []struct {
+		name     string
+		status   string
+		want     Result
+		wantRule string
+	}{
+		{name: "with error status should be kept", status: "503", want: Modified, wantRule: samplingRuleStatus},
+		{name: "with listed status should be kept", status: "429", want: Modified, wantRule: samplingRuleStatus},
+		{name: "with other status should be dropped", status: "404", want: Dropped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := newEvent("GET", "/", tt.status)
+			if got := s.Process(event); got != tt.want {
+				t.Fatalf("Process() = %v, want %v", got, tt.want)
+			}
+			if tt.want == Dropped {
+				return
+			}
+			if got := event.Metadata.Sampling; got.Decision != samplingAlways || got.Rule != tt.wantRule || got.Rate != 1 {
+				t.Errorf("Process() sampling = %v, want %v by %v", got, samplingAlways, tt.wantRule)
+			}
+		})
+	}
+
+	if got := s.Process(slow); got != Modified || slow.Metadata.Sampling.Rule != samplingRuleLatency {
+		t.Errorf("Process() of slow event = %v, %v, want kept by %v", got, slow.Metadata.Sampling, samplingRuleLatency)
+	}
+	if got := s.Process(payments); got != Modified || payments.Metadata.Sampling.Rule != samplingRuleNamespace {
+		t.Errorf("Process() of payments event = %v, %v, want kept by %v", got, payments.Metadata.Sampling, samplingRuleNamespace)
+	}
+}
+
+func Test_sampler_Process_endpointBudget(t *testing.T) {
+	rate := 0.5
+	s, err := newSampler(&config.SampleConfig{Rate: &rate, EndpointBudget: 2, BudgetWindow: time.Second, MaxEndpoints: 1})
+	if err != nil {
+		t.Fatalf("newSampler() error = %v", err)
+	}
+	s.random = func() float64 { return 0 }
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	process := func(path string, n int) (kept int, rates []float64) {
+		for i := 0; i < n; i++ {
+			event := newEvent("GET", path, "200")
+			if s.Process(event) != Dropped {
+				kept++
+				rates = append(rates, event.Metadata.Sampling.Rate)
+			}
+		}
+		return kept, rates
+	}
+
+	// Given a first window with more events than the budget
+	if kept, _ := process("/users/1?expand=true", 8); kept != 2 {
+		t.Errorf("Process() kept %d events in the first window, want the budget of 2", kept)
+	}
+
+	// When the next window starts
+	now = now.Add(time.Second)
+	kept, rates := process("/users/1", 8)
+
+	// Then events are kept with probability budget/seen
+	if kept != 2 {
+		t.Errorf("Process() kept %d events in the second window, want 2", kept)
+	}
+	for _, got := range rates {
+		if want := 0.5 * 2 / 8; got != want {
+			t.Errorf("Process() rate = %v, want %v", got, want)
+		}
+	}
+
+	// Given endpoints above maxEndpoints
+	if kept, _ := process("/orders", 4); kept != 2 {
+		t.Errorf("Process() kept %d events of an untracked endpoint, want the shared budget of 2", kept)
+	}
+	if len(s.endpoints) != 1 {
+		t.Errorf("Process() tracked %d endpoints, want 1", len(s.endpoints))
+	}
+}
+
+func Test_endpointOf(t *testing.T) {
+	event := newEvent("GET", "/users/1?expand=true", "200")
+	if got := endpointOf(event); got != "GET /users/1" {
+		t.Errorf("endpointOf() = %v, want GET /users/1", got)
+	}
+
+	event.Request.Route = "/users/{id}"
+	if got := endpointOf(event); got != "GET /users/{id}" {
+		t.Errorf("endpointOf() = %v, want GET /users/{id}", got)
+	}
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package queue implements the bounded queues API events go through between
+// SentryFlow's stages, and what they do with new events when they are full.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+var (
+	// ErrFull is returned by Push when a dropNewest or spill queue is full.
+	ErrFull = errors.New("queue full")
+
+	// ErrTimeout is returned by Push when a block queue is still full after its
+	// timeout.
+	ErrTimeout = errors.New("timed out waiting for room in queue")
+)
+
+// Queue is a buffered channel of API events with a backpressure policy. Events
+// are pushed with Push and consumed from the channel it was created with.
+type Queue struct {
+	name    string
+	events  chan *protobuf.APIEvent
+	policy  string
+	timeout time.Duration
+
+	// spill holds the events of a spill queue that didn't fit in events, it's
+	// nil for the other policies.
+	spill *spill
+
+	dropped atomic.Uint64
+}
+
+// New returns the named queue of events configured by cfg. The spill file of
+// a spill queue is created in cfg.SpillDir, it's removed by Close.
+func New(name string, events chan *protobuf.APIEvent, cfg *config.QueueConfig) (*Queue, error) {
+	q := &Queue{
+		name:    name,
+		events:  events,
+		policy:  cfg.Policy,
+		timeout: cfg.Timeout,
+	}
+	if cfg.Policy == config.QueuePolicySpill {
+		s, err := newSpill(name, cfg.SpillDir, cfg.MaxSpillBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s queue spill file: %w", name, err)
+		}
+		q.spill = s
+	}
+	return q, nil
+}
+
+// Name returns the name of q.
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Events returns the channel events are queued on.
+func (q *Queue) Events() chan *protobuf.APIEvent {
+	return q.events
+}
+
+// Dropped returns the number of events dropped by q.
+func (q *Queue) Dropped() uint64 {
+	return q.dropped.Load()
+}
+
+// Push queues event according to the policy of q. It returns ErrFull or
+// ErrTimeout if event was dropped, or ctx's error if ctx is done while
+// waiting for room. Events dropped to make room for event aren't reported.
+func (q *Queue) Push(ctx context.Context, event *protobuf.APIEvent) error {
+	// Once events are spilled, the following ones are spilled too until the
+	// spill file is drained, so that they stay in order.
+	if q.spill != nil && q.spill.pending() {
+		return q.spillEvent(event)
+	}
+
+	select {
+	case q.events <- event:
+		return nil
+	default:
+	}
+
+	switch q.policy {
+	case config.QueuePolicyBlock:
+		timer := time.NewTimer(q.timeout)
+		defer timer.Stop()
+		select {
+		case q.events <- event:
+			return nil
+		case <-timer.C:
+			q.drop()
+			return ErrTimeout
+		case <-ctx.Done():
+			q.drop()
+			return ctx.Err()
+		}
+
+	case config.QueuePolicyDropOldest:
+		for {
+			select {
+			case q.events <- event:
+				return nil
+			default:
+			}
+			select {
+			case <-q.events:
+				q.drop()
+			default:
+			}
+		}
+
+	case config.QueuePolicySpill:
+		return q.spillEvent(event)
+
+	default:
+		q.drop()
+		return ErrFull
+	}
+}
+
+func (q *Queue) spillEvent(event *protobuf.APIEvent) error {
+	if err := q.spill.push(event); err != nil {
+		q.drop()
+		return err
+	}
+	metrics.EventsSpilled.WithLabelValues(q.name).Inc()
+	return nil
+}
+
+func (q *Queue) drop() {
+	q.dropped.Add(1)
+	metrics.EventsDropped.WithLabelValues(q.name).Inc()
+}
+
+// Run queues the spilled events again, in order, as room becomes available
+// until ctx is done. It returns immediately if q doesn't spill.
+func (q *Queue) Run(ctx context.Context, logger *zap.SugaredLogger) {
+	if q.spill == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.spill.ready:
+		}
+
+		for q.spill.pending() {
+			event, size, err := q.spill.peek()
+			if err != nil {
+				// The spill file can't be trusted anymore, the events still in
+				// it are lost.
+				lost := q.spill.reset()
+				q.dropped.Add(uint64(lost))
+				metrics.EventsDropped.WithLabelValues(q.name).Add(float64(lost))
+				metrics.EventsSpilled.WithLabelValues(q.name).Sub(float64(lost))
+				logger.Errorf("Failed to read %s queue spill file, dropped %d events: %v", q.name, lost, err)
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case q.events <- event:
+				q.spill.pop(size)
+				metrics.EventsSpilled.WithLabelValues(q.name).Dec()
+			}
+		}
+	}
+}
+
+// Close removes the spill file of q, dropping the events still in it.
+func (q *Queue) Close() error {
+	if q.spill == nil {
+		return nil
+	}
+	metrics.EventsSpilled.WithLabelValues(q.name).Set(0)
+	return q.spill.close()
+}
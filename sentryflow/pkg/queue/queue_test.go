@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func TestQueue_Push(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         *config.QueueConfig
+		wantErr     error
+		wantEvents  []uint32
+		wantDropped uint64
+	}{
+		{
+			name:        "with dropNewest policy should reject the new event",
+			cfg:         &config.QueueConfig{Policy: config.QueuePolicyDropNewest},
+			wantErr:     ErrFull,
+			wantEvents:  []uint32{1, 2},
+			wantDropped: 1,
+		},
+		{
+			name:        "with dropOldest policy should drop the oldest event",
+			cfg:         &config.QueueConfig{Policy: config.QueuePolicyDropOldest},
+			wantEvents:  []uint32{2, 3},
+			wantDropped: 1,
+		},
+		{
+			name:        "with block policy should give up after the timeout",
+			cfg:         &config.QueueConfig{Policy: config.QueuePolicyBlock, Timeout: 10 * time.Millisecond},
+			wantErr:     ErrTimeout,
+			wantEvents:  []uint32{1, 2},
+			wantDropped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given a full queue
+			events := make(chan *protobuf.APIEvent, 2)
+			q, err := New("test", events, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 2; i++ {
+				if err := q.Push(context.Background(), event(i)); err != nil {
+					t.Fatalf("Push() error = %v", err)
+				}
+			}
+
+			// When
+			err = q.Push(context.Background(), event(3))
+
+			// Then
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Push() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := contextIDs(events); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("Push() queued %v, want %v", got, tt.wantEvents)
+			}
+			if got := q.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %v, want %v", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestQueue_Push_blockUntilRoom(t *testing.T) {
+	events := make(chan *protobuf.APIEvent, 1)
+	q, err := New("test", events, &config.QueueConfig{Policy: config.QueuePolicyBlock, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events <- event(1)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-events
+	}()
+
+	if err := q.Push(context.Background(), event(2)); err != nil {
+		t.Errorf("Push() error = %v, want the event queued once there is room", err)
+	}
+}
+
+func TestQueue_spill(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	events := make(chan *protobuf.APIEvent, 1)
+	q, err := New("test", events, &config.QueueConfig{Policy: config.QueuePolicySpill, SpillDir: dir, MaxSpillBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Given more events than the queue holds
+	for i := 1; i <= 4; i++ {
+		if err := q.Push(ctx, event(i)); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+	}
+
+	// When they are consumed
+	go q.Run(ctx, zap.S())
+	var got []uint32
+	for len(got) < 4 {
+		select {
+		case e := <-events:
+			got = append(got, e.Metadata.ContextId)
+		case <-ctx.Done():
+			t.Fatalf("got events %v, want 4", got)
+		}
+	}
+
+	// Then the spilled events are queued again in order
+	if want := []uint32{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run() queued %v, want %v", got, want)
+	}
+	if got := q.Dropped(); got != 0 {
+		t.Errorf("Dropped() = %v, want 0", got)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.spill")); !os.IsNotExist(err) {
+		t.Errorf("Close() didn't remove the spill file, error = %v", err)
+	}
+}
+
+func TestQueue_spill_full(t *testing.T) {
+	events := make(chan *protobuf.APIEvent, 1)
+	q, err := New("test", events, &config.QueueConfig{Policy: config.QueuePolicySpill, SpillDir: t.TempDir(), MaxSpillBytes: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if err := q.Push(context.Background(), event(1)); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if err := q.Push(context.Background(), event(2)); !errors.Is(err, ErrFull) {
+		t.Errorf("Push() error = %v, want %v once the spill file is full", err, ErrFull)
+	}
+	if got := q.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %v, want 1", got)
+	}
+}
+
+func TestQueue_spill_reclaimsReadEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan *protobuf.APIEvent, 1)
+	size := int64(recordHeaderSize + proto.Size(event(1)))
+	q, err := New("test", events, &config.QueueConfig{Policy: config.QueuePolicySpill, SpillDir: t.TempDir(), MaxSpillBytes: 3 * size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	go q.Run(ctx, zap.S())
+
+	// Given a spill file that's never drained, as under sustained pressure
+	var got []uint32
+	for i := 1; i <= 10; i++ {
+		// When
+		err := q.Push(ctx, event(i))
+
+		// Then the space of the events read from it is reused
+		if err != nil {
+			t.Fatalf("Push() of event %d error = %v", i, err)
+		}
+		if i > 3 {
+			got = append(got, (<-events).Metadata.ContextId)
+			waitSpilled(t, q, 2)
+		}
+	}
+	for len(got) < 10 {
+		got = append(got, (<-events).Metadata.ContextId)
+	}
+	if want := []uint32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Run() queued %v, want %v", got, want)
+	}
+	if got := q.Dropped(); got != 0 {
+		t.Errorf("Dropped() = %v, want 0", got)
+	}
+}
+
+// waitSpilled waits until count events are left in the spill file of q.
+func waitSpilled(t *testing.T, q *Queue, count int) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+		q.spill.lock.Lock()
+		spilled := q.spill.count
+		q.spill.lock.Unlock()
+		if spilled == count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d events spilled, want %d", spilled, count)
+		}
+	}
+}
+
+func event(contextID int) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Metadata: &protobuf.Metadata{ContextId: uint32(contextID), ReceiverName: "queue-test"},
+		Request:  &protobuf.Request{Headers: map[string]string{":path": "/"}},
+	}
+}
+
+func contextIDs(events chan *protobuf.APIEvent) []uint32 {
+	var ids []uint32
+	for len(events) > 0 {
+		ids = append(ids, (<-events).Metadata.ContextId)
+	}
+	return ids
+}
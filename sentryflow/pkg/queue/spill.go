@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package queue
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+)
+
+// recordHeaderSize is the size of the length prefix of every spilled event.
+const recordHeaderSize = 4
+
+// spill is a FIFO of events in a file. Events are appended to the file and
+// read back from its start. The file is truncated once all its events were
+// read, and the events left are moved to its start when it would grow beyond
+// maxBytes, so maxBytes bounds the events waiting to be read.
+type spill struct {
+	lock     sync.Mutex
+	file     *os.File
+	maxBytes int64
+	// read and write are the offsets of the next event to read and of the end
+	// of the file, count the number of events in between.
+	read  int64
+	write int64
+	count int
+
+	// ready is signalled when events are spilled.
+	ready chan struct{}
+}
+
+func newSpill(name, dir string, maxBytes int64) (*spill, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	// Spilled events don't survive a restart, a previous spill file is
+	// discarded.
+	file, err := os.OpenFile(filepath.Join(dir, name+".spill"), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &spill{
+		file:     file,
+		maxBytes: maxBytes,
+		ready:    make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *spill) push(event *protobuf.APIEvent) error {
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+	record := binary.BigEndian.AppendUint32(make([]byte, 0, recordHeaderSize+len(data)), uint32(len(data)))
+	record = append(record, data...)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.write+int64(len(record)) > s.maxBytes && s.read > 0 {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	if s.write+int64(len(record)) > s.maxBytes {
+		return ErrFull
+	}
+	if _, err := s.file.WriteAt(record, s.write); err != nil {
+		return err
+	}
+	s.write += int64(len(record))
+	s.count++
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *spill) pending() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.count > 0
+}
+
+// peek returns the oldest spilled event and the size of its record.
+func (s *spill) peek() (*protobuf.APIEvent, int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	header := make([]byte, recordHeaderSize)
+	if _, err := s.file.ReadAt(header, s.read); err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := s.file.ReadAt(data, s.read+recordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	event := &protobuf.APIEvent{}
+	if err := proto.Unmarshal(data, event); err != nil {
+		return nil, 0, err
+	}
+	return event, int64(recordHeaderSize + len(data)), nil
+}
+
+// pop removes the oldest spilled event, whose record is size bytes long.
+func (s *spill) pop(size int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.read += size
+	s.count--
+	if s.count == 0 {
+		s.truncate()
+	}
+}
+
+// reset removes all spilled events and returns their number.
+func (s *spill) reset() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := s.count
+	s.truncate()
+	return count
+}
+
+// compact moves the events that weren't read yet to the start of the file,
+// reclaiming the space of the ones that were.
+func (s *spill) compact() error {
+	unread := s.write - s.read
+	// The events are moved towards the start of the file, a chunk is never
+	// overwritten before it was copied.
+	if _, err := io.Copy(io.NewOffsetWriter(s.file, 0), io.NewSectionReader(s.file, s.read, unread)); err != nil {
+		return err
+	}
+	s.read, s.write = 0, unread
+	return s.file.Truncate(unread)
+}
+
+func (s *spill) truncate() {
+	s.read, s.write, s.count = 0, 0, 0
+	// Even if the file can't be truncated, the next events overwrite it from
+	// its start.
+	_ = s.file.Truncate(0)
+}
+
+func (s *spill) close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.file.Name())
+}
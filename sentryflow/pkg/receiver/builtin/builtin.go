@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package builtin links the receivers shipped with SentryFlow into the binary.
+// Every receiver registers itself with the receiver registry from its package's
+// init function, so compiling in an additional receiver only requires a blank
+// import like the ones below.
+package builtin
+
+import (
+	_ "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/other/f5-big-ip"
+	_ "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/other/konggateway"
+	_ "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/other/nginx/nginxinc"
+	_ "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/svcmesh/istio/gateway"
+	_ "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/svcmesh/istio/sidecar"
+)
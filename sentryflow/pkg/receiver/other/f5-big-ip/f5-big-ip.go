@@ -8,9 +8,13 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	pb "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 	"go.uber.org/zap"
 )
@@ -29,46 +33,138 @@ const (
 	REQPE     = "__REQPE__"
 )
 
-var logger *zap.SugaredLogger
+func init() {
+	receiver.Register(receiver.Registration{
+		Name:    util.F5BigIp,
+		Factory: New,
+		Settings: func(cfg *config.Config) any {
+			return cfg.Filters.TCPServer
+		},
+	})
+}
+
+// Receiver accepts F5 BIG-IP high speed logging (HSL) connections on the
+// configured TCP port and turns every logged request into an API event.
+type Receiver struct {
+	receiver.Lifecycle
+	port uint16
+}
+
+// New returns a new F5 BIG-IP receiver.
+func New(cfg *config.Config, _ receiver.Dependencies) receiver.Receiver {
+	r := &Receiver{}
+	if cfg.Filters != nil && cfg.Filters.TCPServer != nil {
+		r.port = cfg.Filters.TCPServer.Port
+	}
+	return r
+}
+
+func (r *Receiver) Name() string {
+	return util.F5BigIp
+}
+
+func (r *Receiver) Validate(cfg *config.Config) error {
+	if cfg.Filters == nil || cfg.Filters.TCPServer == nil || cfg.Filters.TCPServer.Port == 0 {
+		return fmt.Errorf("no tcp server port provided")
+	}
+	return nil
+}
 
-func Start(ctx context.Context, port uint16, apiEventsChan chan *pb.APIEvent) {
-	logger := util.LoggerFromCtx(ctx)
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+func (r *Receiver) Start(ctx context.Context, sink receiver.Sink) error {
+	ctx = r.Begin(ctx)
+	defer r.End()
+
+	logger := util.LoggerFromCtx(ctx).Named(util.F5BigIp)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", r.port))
 	if err != nil {
 		logger.Errorf("error starting TCP server: %v", err)
-		return
+		return r.Fail(err)
+	}
+	logger.Infof("f5-big-ip receiver listening on :%d", r.port)
+
+	conns := &connList{
+		Mutex: &sync.Mutex{},
+		conns: make(map[net.Conn]struct{}),
 	}
+	handlers := &sync.WaitGroup{}
 	defer func() {
-		listener.Close()
+		handlers.Wait()
+		logger.Info("stopped f5-big-ip receiver")
+	}()
+
+	go func() {
+		<-ctx.Done()
 		logger.Info("stopping f5-big-ip receiver")
+		_ = listener.Close()
+		conns.closeAll()
 	}()
-	logger.Info("f5-big-ip receiver listening on :5000")
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			logger.Errorf("Connection error:", err)
+			if ctx.Err() != nil {
+				return nil
+			}
+			logger.Errorf("Connection error: %v", err)
 			continue
 		}
-		go handleConn(conn, apiEventsChan)
+		conns.add(conn)
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			defer conns.remove(conn)
+			handleConn(ctx, logger, conn, sink)
+		}()
 	}
 }
 
-func handleConn(conn net.Conn, apiEventsChan chan *pb.APIEvent) {
+// connList keeps track of open connections so that they can be closed when the
+// receiver stops.
+type connList struct {
+	*sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (c *connList) add(conn net.Conn) {
+	c.Lock()
+	c.conns[conn] = struct{}{}
+	c.Unlock()
+}
+
+func (c *connList) remove(conn net.Conn) {
+	c.Lock()
+	delete(c.conns, conn)
+	c.Unlock()
+}
+
+func (c *connList) closeAll() {
+	c.Lock()
+	for conn := range c.conns {
+		_ = conn.Close()
+	}
+	c.Unlock()
+}
+
+func handleConn(ctx context.Context, logger *zap.SugaredLogger, conn net.Conn, sink receiver.Sink) {
 	defer conn.Close()
 	scanner := bufio.NewScanner(conn)
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		event := parseF5LogLine(line)
-		if event != nil {
-			apiEventsChan <- event
+		event := parseF5LogLine(logger, line)
+		if event == nil {
+			metrics.F5ParseFailures.Inc()
+			continue
+		}
+		select {
+		case sink <- event:
+		case <-ctx.Done():
+			return
 		}
-
 	}
 }
 
-func parseF5LogLine(line string) *pb.APIEvent {
+func parseF5LogLine(logger *zap.SugaredLogger, line string) *pb.APIEvent {
 
 	// 1) Extract the part between HSL_START and HSL_END
 	start := strings.Index(line, HSL_START)
@@ -77,10 +173,14 @@ func parseF5LogLine(line string) *pb.APIEvent {
 		logger.Error("missing HSL_START or HSL_END")
 		return nil
 	}
+	if end < start+len(HSL_START) {
+		logger.Error("HSL_END before HSL_START")
+		return nil
+	}
 
 	payload := strings.TrimSpace(line[start+len(HSL_START) : end])
 	parts := strings.Split(payload, " ")
-	if len(parts) < 10 {
+	if len(parts) < 12 {
 		logger.Errorf("too few fields: %v", parts)
 		return nil
 	}
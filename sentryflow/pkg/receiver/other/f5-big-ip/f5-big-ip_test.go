@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package f5bigip
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func Test_parseF5LogLine(t *testing.T) {
+	fields := []string{"https", "/api/users", "GET", "id=1", "10.0.0.1", "40000", "10.0.0.2", "443", "HTTP/1.1", "200", "1000", "1005"}
+
+	tests := []struct {
+		name string
+		line string
+		want *pb.APIEvent
+	}{
+		{
+			name: "with valid line should return its event",
+			line: getLogLine(append(fields,
+				REQHS, HEAN+"host"+HEAV+"example.com", HEAN+"x-request-id"+HEAV+"7", REQHE,
+				RESPHS, HEAN+"content-type"+HEAV+"application/json", RESPHE,
+				REQPS, base64.StdEncoding.EncodeToString([]byte(`{"name":"foo"}`)), REQPE,
+				base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)),
+			)...),
+			want: &pb.APIEvent{
+				Metadata:    &pb.Metadata{ReceiverName: "f5-big-ip", ReceiverVersion: "16.1"},
+				Source:      &pb.Workload{Ip: "10.0.0.1", Port: 40000},
+				Destination: &pb.Workload{Ip: "10.0.0.2", Port: 443},
+				Request: &pb.Request{
+					Headers: map[string]string{
+						"host":         "example.com",
+						"x-request-id": "7",
+						":scheme":      "https",
+						":path":        "/api/users",
+						":method":      "GET",
+						":query":       "id=1",
+					},
+					Body: `{"name":"foo"}`,
+				},
+				Response: &pb.Response{
+					Headers:               map[string]string{"content-type": "application/json", ":status": "200"},
+					Body:                  `{"id":1}`,
+					BackendLatencyInNanos: 5_000_000,
+				},
+				Protocol: "HTTP/1.1",
+			},
+		},
+		{
+			name: "without headers and bodies should return event with pseudo headers only",
+			line: getLogLine(fields...),
+			want: &pb.APIEvent{
+				Metadata:    &pb.Metadata{ReceiverName: "f5-big-ip", ReceiverVersion: "16.1"},
+				Source:      &pb.Workload{Ip: "10.0.0.1", Port: 40000},
+				Destination: &pb.Workload{Ip: "10.0.0.2", Port: 443},
+				Request: &pb.Request{
+					Headers: map[string]string{":scheme": "https", ":path": "/api/users", ":method": "GET", ":query": "id=1"},
+				},
+				Response: &pb.Response{
+					Headers:               map[string]string{":status": "200"},
+					BackendLatencyInNanos: 5_000_000,
+				},
+				Protocol: "HTTP/1.1",
+			},
+		},
+		{
+			name: "with empty line should return nil",
+			line: "",
+		},
+		{
+			name: "without HSL_START should return nil",
+			line: strings.Join(fields, " ") + " " + HSL_END,
+		},
+		{
+			name: "without HSL_END should return nil",
+			line: HSL_START + " " + strings.Join(fields, " "),
+		},
+		{
+			name: "with HSL_END before HSL_START should return nil",
+			line: HSL_END + " " + strings.Join(fields, " ") + " " + HSL_START,
+		},
+		{
+			name: "with 11 fields should return nil",
+			line: getLogLine(fields[:11]...),
+		},
+		{
+			name: "with 10 fields should return nil",
+			line: getLogLine(fields[:10]...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			got := parseF5LogLine(zap.NewNop().Sugar(), tt.line)
+
+			// Then
+			if got != nil {
+				got.Metadata.Timestamp = 0
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("parseF5LogLine() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiver_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		wantErr bool
+	}{
+		{
+			name: "with tcp server port should return no error",
+			cfg:  getConfig(),
+		},
+		{
+			name:    "without filters should return error",
+			cfg:     &config.Config{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			r := New(tt.cfg, receiver.Dependencies{})
+
+			// When
+			err := r.Validate(tt.cfg)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReceiver_Start(t *testing.T) {
+	t.Run("with logged requests should publish their events until stopped", func(t *testing.T) {
+		// Given
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar()))
+		defer cancel()
+		r := &Receiver{port: getFreePort(t)}
+		events := make(chan *pb.APIEvent, 2)
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- r.Start(ctx, events)
+		}()
+
+		// When
+		conn := dial(t, r.port)
+		defer conn.Close()
+		fields := []string{"http", "/healthz", "GET", "-", "10.0.0.1", "40000", "10.0.0.2", "80", "HTTP/1.1", "204", "1000", "1001"}
+		if _, err := fmt.Fprintf(conn, "%s\n%s\n", getLogLine(fields[:10]...), getLogLine(fields...)); err != nil {
+			t.Fatalf("failed to write log lines: %v", err)
+		}
+
+		// Then
+		select {
+		case ev := <-events:
+			if got := ev.Request.Headers[":path"]; got != "/healthz" {
+				t.Errorf("Start() published event with path %q, want %q", got, "/healthz")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Start() didn't publish the logged request")
+		}
+		if err := r.Health(); err != nil {
+			t.Errorf("Health() error = %v, want nil", err)
+		}
+
+		cancel()
+		select {
+		case err := <-stopped:
+			if err != nil {
+				t.Errorf("Start() error = %v, want nil", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Start() didn't return after the context was cancelled")
+		}
+		if err := r.Health(); !errors.Is(err, receiver.ErrStopped) {
+			t.Errorf("Health() error = %v, want %v", err, receiver.ErrStopped)
+		}
+	})
+
+	t.Run("when port is in use should fail", func(t *testing.T) {
+		// Given
+		listener, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer listener.Close()
+		ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.NewNop().Sugar())
+		r := &Receiver{port: uint16(listener.Addr().(*net.TCPAddr).Port)}
+
+		// When
+		err = r.Start(ctx, make(chan *pb.APIEvent))
+
+		// Then
+		if err == nil {
+			t.Fatal("Start() error = nil, want error")
+		}
+		if health := r.Health(); !errors.Is(health, err) {
+			t.Errorf("Health() error = %v, want %v", health, err)
+		}
+	})
+}
+
+func getLogLine(fields ...string) string {
+	return HSL_START + " " + strings.Join(fields, " ") + " " + HSL_END
+}
+
+func getConfig() *config.Config {
+	configFilePath, err := filepath.Abs(filepath.Join("test-configs", "default-config.yaml"))
+	if err != nil {
+		panic(fmt.Errorf("failed to get absolute path of config file: %v", err))
+	}
+
+	cfg, err := config.New(configFilePath, zap.S())
+	if err != nil {
+		panic(fmt.Errorf("failed to create config: %v", err))
+	}
+
+	return cfg
+}
+
+// getFreePort returns a TCP port nothing listens on.
+func getFreePort(t *testing.T) uint16 {
+	t.Helper()
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	return uint16(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// dial connects to port, waiting for the receiver to listen on it.
+func dial(t *testing.T, port uint16) net.Conn {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err == nil {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("failed to connect to the receiver: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
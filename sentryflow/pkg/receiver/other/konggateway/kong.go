@@ -12,24 +12,73 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 )
 
-// Start initializes the Kong Gateway receiver.
-// It validates that the Kong deployment exists and the sentryflow-log plugin is configured.
-func Start(ctx context.Context, cfg *config.Config, k8sClient client.Client) {
+func init() {
+	receiver.Register(receiver.Registration{
+		Name:        util.KongGateway,
+		RequiresK8s: true,
+		Factory:     New,
+		Settings: func(cfg *config.Config) any {
+			return []any{cfg.Receiver(util.KongGateway), cfg.Filters.KongGateway}
+		},
+		// Set by the sentryflow-log plugin.
+		EventNames: []string{"kong"},
+	})
+}
+
+// Receiver is the Kong Gateway receiver. Kong's sentryflow-log plugin posts
+// API events to SentryFlow's HTTP server; the receiver only checks that the
+// Kong deployment exists.
+type Receiver struct {
+	receiver.Lifecycle
+	cfg       *config.Config
+	k8sClient client.Client
+}
+
+// New returns a new Kong Gateway receiver.
+func New(cfg *config.Config, deps receiver.Dependencies) receiver.Receiver {
+	return &Receiver{
+		cfg:       cfg,
+		k8sClient: deps.K8sClient,
+	}
+}
+
+func (r *Receiver) Name() string {
+	return util.KongGateway
+}
+
+func (r *Receiver) Validate(cfg *config.Config) error {
+	if r.k8sClient == nil {
+		return fmt.Errorf("no kubernetes client available")
+	}
+	if getKongDeploymentNameFromConfig(cfg) == "" {
+		return fmt.Errorf("kong deployment name not configured")
+	}
+	return nil
+}
+
+// Start validates that the Kong deployment exists and the sentryflow-log
+// plugin is configured.
+func (r *Receiver) Start(ctx context.Context, _ receiver.Sink) error {
+	ctx = r.Begin(ctx)
+	defer r.End()
+
 	logger := util.LoggerFromCtx(ctx)
 
 	logger.Info("Starting Kong Gateway receiver")
-	if err := validateResources(ctx, cfg, k8sClient); err != nil {
+	if err := validateResources(ctx, r.cfg, r.k8sClient); err != nil {
 		logger.Errorf("%v. Stopped Kong Gateway receiver", err)
-		return
+		return r.Fail(err)
 	}
 	logger.Info("Started Kong Gateway receiver")
 
 	<-ctx.Done()
 	logger.Info("Shutting down Kong Gateway receiver")
 	logger.Info("Stopped Kong Gateway receiver")
+	return nil
 }
 
 func validateResources(ctx context.Context, cfg *config.Config, k8sClient client.Client) error {
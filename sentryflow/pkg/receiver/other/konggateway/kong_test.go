@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package konggateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func TestReceiver_Validate(t *testing.T) {
+	withoutDeploymentName := getConfig()
+	withoutDeploymentName.Filters.KongGateway = nil
+
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		k8sClient client.Client
+		wantErr   bool
+	}{
+		{
+			name:      "with kubernetes client and deployment name should return no error",
+			cfg:       getConfig(),
+			k8sClient: getFakeClient(),
+		},
+		{
+			name:    "without kubernetes client should return error",
+			cfg:     getConfig(),
+			wantErr: true,
+		},
+		{
+			name:      "without deployment name should return error",
+			cfg:       withoutDeploymentName,
+			k8sClient: getFakeClient(),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			r := New(tt.cfg, receiver.Dependencies{K8sClient: tt.k8sClient})
+
+			// When
+			err := r.Validate(tt.cfg)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateResources(t *testing.T) {
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		wantErr bool
+	}{
+		{
+			name:    "when kong deployment exists should return no error",
+			objects: []client.Object{getKongDeployment("kong")},
+		},
+		{
+			name:    "when kong deployment doesn't exist should return error",
+			wantErr: true,
+		},
+		{
+			name:    "when kong deployment is in another namespace should return error",
+			objects: []client.Object{getKongDeployment("default")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := validateResources(ctx, getConfig(), getFakeClient(tt.objects...))
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateResources() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReceiver_Start(t *testing.T) {
+	t.Run("when kong deployment exists should run until stopped", func(t *testing.T) {
+		// Given
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S()))
+		defer cancel()
+		r := New(getConfig(), receiver.Dependencies{K8sClient: getFakeClient(getKongDeployment("kong"))})
+		stopped := make(chan error, 1)
+
+		// When
+		go func() {
+			stopped <- r.Start(ctx, nil)
+		}()
+
+		// Then
+		waitForHealth(t, r, nil)
+		cancel()
+		select {
+		case err := <-stopped:
+			if err != nil {
+				t.Errorf("Start() error = %v, want nil", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Start() didn't return after the context was cancelled")
+		}
+		if err := r.Health(); !errors.Is(err, receiver.ErrStopped) {
+			t.Errorf("Health() error = %v, want %v", err, receiver.ErrStopped)
+		}
+	})
+
+	t.Run("when kong deployment doesn't exist should fail", func(t *testing.T) {
+		// Given
+		ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
+		r := New(getConfig(), receiver.Dependencies{K8sClient: getFakeClient()})
+
+		// When
+		err := r.Start(ctx, nil)
+
+		// Then
+		if err == nil {
+			t.Fatal("Start() error = nil, want error")
+		}
+		if health := r.Health(); !errors.Is(health, err) {
+			t.Errorf("Health() error = %v, want %v", health, err)
+		}
+	})
+}
+
+func waitForHealth(t *testing.T, r receiver.Receiver, want error) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := r.Health()
+		if errors.Is(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("%s Health() got = %v, want %v", r.Name(), got, want)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func getConfig() *config.Config {
+	configFilePath, err := filepath.Abs(filepath.Join("test-configs", "default-config.yaml"))
+	if err != nil {
+		panic(fmt.Errorf("failed to get absolute path of config file: %v", err))
+	}
+
+	cfg, err := config.New(configFilePath, zap.S())
+	if err != nil {
+		panic(fmt.Errorf("failed to create config: %v", err))
+	}
+
+	return cfg
+}
+
+func getFakeClient(objects ...client.Object) client.WithWatch {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(appsv1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		Build()
+}
+
+func getKongDeployment(namespace string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kong-gateway",
+			Namespace: namespace,
+		},
+	}
+}
@@ -14,23 +14,75 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 )
 
-func Start(ctx context.Context, cfg *config.Config, k8sClient client.Client) {
+func init() {
+	receiver.Register(receiver.Registration{
+		Name:        util.NginxIncorporationIngressController,
+		RequiresK8s: true,
+		Factory:     New,
+		Settings: func(cfg *config.Config) any {
+			return []any{cfg.Receiver(util.NginxIncorporationIngressController), cfg.Filters.NginxIngress}
+		},
+		// Set by the sentryflow njs module.
+		EventNames: []string{"nginx"},
+	})
+}
+
+// Receiver checks that the nginx-incorporation ingress controller has been
+// configured to send API events to SentryFlow. The controller itself posts the
+// events to SentryFlow's HTTP server.
+type Receiver struct {
+	receiver.Lifecycle
+	cfg       *config.Config
+	k8sClient client.Client
+}
+
+// New returns a new nginx-incorporation ingress controller receiver.
+func New(cfg *config.Config, deps receiver.Dependencies) receiver.Receiver {
+	return &Receiver{
+		cfg:       cfg,
+		k8sClient: deps.K8sClient,
+	}
+}
+
+func (r *Receiver) Name() string {
+	return util.NginxIncorporationIngressController
+}
+
+func (r *Receiver) Validate(cfg *config.Config) error {
+	if r.k8sClient == nil {
+		return fmt.Errorf("no kubernetes client available")
+	}
+	if cfg.Filters == nil || cfg.Filters.NginxIngress == nil {
+		return fmt.Errorf("no nginx-inc ingress configuration provided")
+	}
+	if getIngressControllerDeploymentNamespace(cfg) == "" {
+		return fmt.Errorf("no nginx-inc ingress controller namespace provided")
+	}
+	return nil
+}
+
+func (r *Receiver) Start(ctx context.Context, _ receiver.Sink) error {
+	ctx = r.Begin(ctx)
+	defer r.End()
+
 	logger := util.LoggerFromCtx(ctx)
 
 	logger.Info("Starting nginx-incorporation ingress controller receiver")
-	if err := validateResources(ctx, cfg, k8sClient); err != nil {
+	if err := validateResources(ctx, r.cfg, r.k8sClient); err != nil {
 		// Todo(@anurag-rajawat): Log docs link for reference on how to configure this receiver properly.
 		logger.Errorf("%v. Stopped nginx-incorporation ingress controller receiver", err)
-		return
+		return r.Fail(err)
 	}
 	logger.Info("Started nginx-incorporation ingress controller receiver")
 
 	<-ctx.Done()
 	logger.Info("Shutting down nginx-incorporation ingress controller receiver")
 	logger.Info("Stopped nginx-incorporation ingress controller receiver")
+	return nil
 }
 
 func validateResources(ctx context.Context, cfg *config.Config, k8sClient client.Client) error {
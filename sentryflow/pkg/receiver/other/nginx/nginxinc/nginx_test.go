@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package nginxinc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+const ingressNamespace = "nginx-ingress"
+
+func TestReceiver_Validate(t *testing.T) {
+	withoutIngressConfig := getConfig()
+	withoutIngressConfig.Filters.NginxIngress = nil
+	withoutNamespace := getConfig()
+	withoutNamespace.Receivers.Others[0].Namespace = ""
+
+	tests := []struct {
+		name      string
+		cfg       *config.Config
+		k8sClient client.Client
+		wantErr   bool
+	}{
+		{
+			name:      "with kubernetes client and ingress configuration should return no error",
+			cfg:       getConfig(),
+			k8sClient: getFakeClient(),
+		},
+		{
+			name:    "without kubernetes client should return error",
+			cfg:     getConfig(),
+			wantErr: true,
+		},
+		{
+			name:      "without ingress configuration should return error",
+			cfg:       withoutIngressConfig,
+			k8sClient: getFakeClient(),
+			wantErr:   true,
+		},
+		{
+			name:      "without ingress controller namespace should return error",
+			cfg:       withoutNamespace,
+			k8sClient: getFakeClient(),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			r := New(tt.cfg, receiver.Dependencies{K8sClient: tt.k8sClient})
+
+			// When
+			err := r.Validate(tt.cfg)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateResources(t *testing.T) {
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
+
+	withoutVolumeMount := getIngressDeployment()
+	withoutVolumeMount.Spec.Template.Spec.Containers[0].VolumeMounts = nil
+
+	withoutLocationSnippets := getIngressConfigMap()
+	delete(withoutLocationSnippets.Data, "location-snippets")
+
+	withMisconfiguredServerSnippets := getIngressConfigMap()
+	withMisconfiguredServerSnippets.Data["server-snippets"] = `location /sentryflow { proxy_pass http://sentryflow.sentryflow:8081/api/v1/events; }`
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		wantErr string
+	}{
+		{
+			name:    "when ingress controller is configured should return no error",
+			objects: []client.Object{getNjsConfigMap(), getIngressDeployment(), getIngressConfigMap()},
+		},
+		{
+			name:    "when sentryflow njs configmap doesn't exist should return error",
+			objects: []client.Object{getIngressDeployment(), getIngressConfigMap()},
+			wantErr: "failed to get sentryflow configmap",
+		},
+		{
+			name:    "when ingress controller deployment doesn't exist should return error",
+			objects: []client.Object{getNjsConfigMap(), getIngressConfigMap()},
+			wantErr: "failed to get nginx-incorporation ingress controller deployment",
+		},
+		{
+			name:    "when njs volume isn't mounted should return error",
+			objects: []client.Object{getNjsConfigMap(), withoutVolumeMount, getIngressConfigMap()},
+			wantErr: "sentryflow-njs volume-mount not found",
+		},
+		{
+			name:    "when ingress controller configmap doesn't exist should return error",
+			objects: []client.Object{getNjsConfigMap(), getIngressDeployment()},
+			wantErr: "failed to get nginx-incorporation ingress controller configmap",
+		},
+		{
+			name:    "when location snippets are missing should return error",
+			objects: []client.Object{getNjsConfigMap(), getIngressDeployment(), withoutLocationSnippets},
+			wantErr: "sentryflow location-snippets not found in nginx-incorporation ingress configmap",
+		},
+		{
+			name:    "when server snippets are misconfigured should return error",
+			objects: []client.Object{getNjsConfigMap(), getIngressDeployment(), withMisconfiguredServerSnippets},
+			wantErr: "sentryflow server-snippets were not properly configured in nginx-incorporation ingress configmap",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			err := validateResources(ctx, getConfig(), getFakeClient(tt.objects...))
+
+			// Then
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateResources() error = %v, wantErr = nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("validateResources() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReceiver_Start(t *testing.T) {
+	t.Run("when ingress controller isn't configured should fail", func(t *testing.T) {
+		// Given
+		ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
+		r := New(getConfig(), receiver.Dependencies{K8sClient: getFakeClient(getNjsConfigMap())})
+
+		// When
+		err := r.Start(ctx, nil)
+
+		// Then
+		if err == nil {
+			t.Fatal("Start() error = nil, want error")
+		}
+		if health := r.Health(); !errors.Is(health, err) {
+			t.Errorf("Health() error = %v, want %v", health, err)
+		}
+	})
+}
+
+func TestSnippetsExist(t *testing.T) {
+	expected := []string{`js_path "/etc/nginx/njs/"`, `js_import main from sentryflow.js`}
+
+	tests := []struct {
+		name     string
+		snippets string
+		want     bool
+	}{
+		{
+			name:     "with all expected snippets should return true",
+			snippets: `js_path "/etc/nginx/njs/"; subrequest_output_buffer_size 32k; js_import main from sentryflow.js;`,
+			want:     true,
+		},
+		{
+			name:     "with missing snippet should return false",
+			snippets: `js_path "/etc/nginx/njs/"; subrequest_output_buffer_size 32k;`,
+		},
+		{
+			name: "with empty snippets should return false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SnippetsExist(tt.snippets, expected); got != tt.want {
+				t.Errorf("SnippetsExist() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func getConfig() *config.Config {
+	configFilePath, err := filepath.Abs(filepath.Join("test-configs", "default-config.yaml"))
+	if err != nil {
+		panic(fmt.Errorf("failed to get absolute path of config file: %v", err))
+	}
+
+	cfg, err := config.New(configFilePath, zap.S())
+	if err != nil {
+		panic(fmt.Errorf("failed to create config: %v", err))
+	}
+
+	return cfg
+}
+
+func getFakeClient(objects ...client.Object) client.WithWatch {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(appsv1.AddToScheme(scheme))
+	utilruntime.Must(corev1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		Build()
+}
+
+func getNjsConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sentryflow-njs",
+			Namespace: ingressNamespace,
+		},
+	}
+}
+
+func getIngressDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-ingress-controller",
+			Namespace: ingressNamespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "nginx-ingress",
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "sentryflow-njs",
+									MountPath: "/etc/nginx/njs/sentryflow.js",
+									SubPath:   "sentryflow.js",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func getIngressConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "nginx-ingress",
+			Namespace: ingressNamespace,
+		},
+		Data: map[string]string{
+			"http-snippets": `js_path "/etc/nginx/njs/";
+subrequest_output_buffer_size 32k;
+js_import main from sentryflow.js;`,
+			"location-snippets": `js_set $body_text main.captureRequestBody;
+js_body_filter main.responseHandler buffer_type=buffer;`,
+			"server-snippets": `location /sentryflow {
+  internal;
+  proxy_pass http://sentryflow.sentryflow:8081/api/v1/events;
+  proxy_method      POST;
+  proxy_set_header accept "application/json";
+  proxy_set_header Content-Type "application/json";
+}`,
+		},
+	}
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package receiver
+
+import (
+	"context"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func init() {
+	for _, name := range []string{util.NginxWebServer, util.AzureAPIM, util.AWSApiGateway} {
+		Register(Registration{
+			Name: name,
+			Factory: func(_ *config.Config, _ Dependencies) Receiver {
+				return &passive{name: name}
+			},
+		})
+	}
+}
+
+// passive is a receiver whose data plane is configured outside SentryFlow and
+// posts API events straight to SentryFlow's HTTP server, so there is nothing to
+// set up or tear down.
+type passive struct {
+	Lifecycle
+	name string
+}
+
+func (p *passive) Name() string {
+	return p.name
+}
+
+func (p *passive) Validate(_ *config.Config) error {
+	return nil
+}
+
+func (p *passive) Start(ctx context.Context, _ Sink) error {
+	ctx = p.Begin(ctx)
+	defer p.End()
+
+	logger := util.LoggerFromCtx(ctx).Named(p.name)
+	logger.Infof("Started %s receiver", p.name)
+	<-ctx.Done()
+	logger.Infof("Stopped %s receiver", p.name)
+	return nil
+}
@@ -5,79 +5,119 @@ package receiver
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"sync"
 
 	"github.com/accuknox/SentryFlow/protobuf/golang"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
-	f5 "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/other/f5-big-ip"
-	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/other/konggateway"
-	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/other/nginx/nginxinc"
-	istiogateway "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/svcmesh/istio/gateway"
-	istiosidecar "github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/svcmesh/istio/sidecar"
-	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// Init initializes the API event sources based on the provided configuration. It
-// starts monitoring from configured sources and supports adding other sources in
-// the future.
-func Init(ctx context.Context, k8sClient client.Client, cfg *config.Config, wg *sync.WaitGroup, lock *sync.Mutex, apiEvents chan *golang.APIEvent) error {
-	logger := util.LoggerFromCtx(ctx).Named("receiver")
-
-	for _, serviceMesh := range cfg.Receivers.ServiceMeshes {
-		if serviceMesh.Name != "" {
-			switch serviceMesh.Name {
-			case util.ServiceMeshIstioSidecar:
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					istiosidecar.StartMonitoring(ctx, cfg, k8sClient, lock)
-				}()
-			case util.ServiceMeshIstioGateway:
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					istiogateway.StartMonitoring(ctx, cfg, k8sClient, lock)
-				}()
-			default:
-				return fmt.Errorf("unsupported Service Mesh, %v", serviceMesh.Name)
-			}
-		}
+var (
+	// ErrNotStarted is reported by Health before a receiver has been started.
+	ErrNotStarted = errors.New("receiver not started")
+
+	// ErrStopped is reported by Health once a receiver has stopped cleanly.
+	ErrStopped = errors.New("receiver stopped")
+)
+
+// Sink is where receivers publish the API events they capture themselves.
+// Receivers whose data plane posts events straight to SentryFlow's HTTP server
+// don't need to use it.
+type Sink chan<- *golang.APIEvent
+
+// MetricsSink is where receivers publish the stats of the proxies they observe.
+type MetricsSink chan<- *golang.EnvoyMetrics
+
+// Receiver is a source of API events.
+type Receiver interface {
+	// Name returns the receiver name as it appears in the config file.
+	Name() string
+
+	// Validate checks that cfg provides everything the receiver needs to run.
+	Validate(cfg *config.Config) error
+
+	// Start runs the receiver until ctx is cancelled or Stop is called. It
+	// returns an error if the receiver could not be started or failed while
+	// running.
+	Start(ctx context.Context, sink Sink) error
+
+	// Stop asks a running receiver to shut down and release its resources.
+	Stop() error
+
+	// Health returns nil while the receiver is running, otherwise the reason it
+	// isn't.
+	Health() error
+}
+
+// Reconfigurer is implemented by receivers that can apply a changed
+// configuration while running, instead of being stopped and started again.
+type Reconfigurer interface {
+	Reconfigure(ctx context.Context, cfg *config.Config) error
+}
+
+// Lifecycle tracks the run state of a receiver. Receivers embed it to get Stop
+// and Health implementations.
+type Lifecycle struct {
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	started bool
+	stopped bool
+	err     error
+}
+
+// Begin marks the receiver as running and returns the context it should run
+// under. The context is cancelled when ctx is or when Stop is called.
+func (l *Lifecycle) Begin(ctx context.Context) context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ctx, l.cancel = context.WithCancel(ctx)
+	if l.stopped {
+		l.cancel()
 	}
+	l.started = true
+	l.err = nil
+	return ctx
+}
+
+// Fail records err as the reason the receiver is no longer healthy and returns
+// it.
+func (l *Lifecycle) Fail(err error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err = err
+	return err
+}
+
+// End marks the receiver as stopped. Receivers defer it in Start.
+func (l *Lifecycle) End() {
+	_ = l.Stop()
+}
 
-	for _, other := range cfg.Receivers.Others {
-		if other.Name != "" {
-			switch other.Name {
-			case util.NginxWebServer:
-				logger.Info("Started nginx webserver receiver")
-			case util.AzureAPIM:
-				logger.Info("Started Azure APIM receiver")
-			case util.AWSApiGateway:
-				logger.Info("Started AWS API Gateway receiver")
-			case util.NginxIncorporationIngressController:
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					nginxinc.Start(ctx, cfg, k8sClient)
-				}()
-			case util.KongGateway:
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					konggateway.Start(ctx, cfg, k8sClient)
-				}()
-			case util.F5BigIp:
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					f5.Start(ctx, cfg.Filters.TCPServer.Port, apiEvents)
-				}()
-			default:
-				return fmt.Errorf("unsupported receiver, %v", other.Name)
-			}
-		}
+// Stop cancels the context returned by Begin. A receiver stopped before it
+// began runs under an already cancelled context.
+func (l *Lifecycle) Stop() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopped = true
+	if l.cancel != nil {
+		l.cancel()
 	}
+	return nil
+}
 
+// Health returns the error the receiver failed with, if any, or ErrNotStarted
+// before Begin and ErrStopped after Stop.
+func (l *Lifecycle) Health() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.err != nil:
+		return l.err
+	case !l.started:
+		return ErrNotStarted
+	case l.stopped:
+		return ErrStopped
+	}
 	return nil
 }
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+func TestRegister(t *testing.T) {
+	t.Run("with duplicate name should panic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Register() expected panic for duplicate receiver name")
+			}
+		}()
+		Register(Registration{
+			Name: util.NginxWebServer,
+			Factory: func(_ *config.Config, _ Dependencies) Receiver {
+				return &passive{name: util.NginxWebServer}
+			},
+		})
+	})
+
+	t.Run("with nil factory should panic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Register() expected panic for nil factory")
+			}
+		}()
+		Register(Registration{Name: "without-factory"})
+	})
+
+	t.Run("with new name should be looked up", func(t *testing.T) {
+		Register(Registration{
+			Name: "test-receiver",
+			Factory: func(_ *config.Config, _ Dependencies) Receiver {
+				return &passive{name: "test-receiver"}
+			},
+		})
+		if _, exists := Lookup("test-receiver"); !exists {
+			t.Errorf("Lookup() registered receiver not found")
+		}
+	})
+}
+
+func TestRequiresK8s(t *testing.T) {
+	if RequiresK8s(getConfig("passive-receivers.yaml")) {
+		t.Errorf("RequiresK8s() got = true, want false")
+	}
+}
+
+func TestSupervisor_Apply(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S()))
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	s := NewSupervisor(ctx, wg, make(chan *golang.APIEvent))
+
+	t.Run("with unsupported receiver should return error and start nothing", func(t *testing.T) {
+		err := s.Apply(getConfig("unsupported-receiver.yaml"), Dependencies{})
+		if err == nil || err.Error() != "unsupported receiver, does-not-exist" {
+			t.Errorf("Apply() error = %v, want unsupported receiver error", err)
+		}
+		if got := len(s.Receivers()); got != 0 {
+			t.Errorf("Apply() started %d receivers, want 0", got)
+		}
+	})
+
+	t.Run("with registered receivers should start them", func(t *testing.T) {
+		if err := s.Apply(getConfig("passive-receivers.yaml"), Dependencies{}); err != nil {
+			t.Fatalf("Apply() error = %v, wantErr = nil", err)
+		}
+		receivers := s.Receivers()
+		if len(receivers) != 2 {
+			t.Fatalf("Apply() started %d receivers, want 2", len(receivers))
+		}
+		for _, r := range receivers {
+			waitForHealth(t, r, nil)
+		}
+	})
+
+	t.Run("with changed receivers should only touch those", func(t *testing.T) {
+		before := receiversByName(s)
+
+		if err := s.Apply(getConfig("passive-receivers-changed.yaml"), Dependencies{}); err != nil {
+			t.Fatalf("Apply() error = %v, wantErr = nil", err)
+		}
+		after := receiversByName(s)
+
+		if after[util.NginxWebServer] != before[util.NginxWebServer] {
+			t.Errorf("Apply() restarted unchanged %s receiver", util.NginxWebServer)
+		}
+		if _, exists := after[util.AWSApiGateway]; exists {
+			t.Errorf("Apply() kept removed %s receiver", util.AWSApiGateway)
+		}
+		waitForHealth(t, before[util.AWSApiGateway], ErrStopped)
+		if _, exists := after[util.AzureAPIM]; !exists {
+			t.Fatalf("Apply() didn't start added %s receiver", util.AzureAPIM)
+		}
+		waitForHealth(t, after[util.AzureAPIM], nil)
+	})
+
+	t.Run("with changed receiver entry should restart it", func(t *testing.T) {
+		before := receiversByName(s)
+
+		if err := s.Apply(getConfig("passive-receivers-namespace.yaml"), Dependencies{}); err != nil {
+			t.Fatalf("Apply() error = %v, wantErr = nil", err)
+		}
+		after := receiversByName(s)
+
+		if after[util.NginxWebServer] == before[util.NginxWebServer] {
+			t.Errorf("Apply() didn't restart changed %s receiver", util.NginxWebServer)
+		}
+		waitForHealth(t, before[util.NginxWebServer], ErrStopped)
+		waitForHealth(t, after[util.NginxWebServer], nil)
+		if after[util.AzureAPIM] != before[util.AzureAPIM] {
+			t.Errorf("Apply() restarted unchanged %s receiver", util.AzureAPIM)
+		}
+	})
+
+	t.Run("with invalid config should keep running receivers", func(t *testing.T) {
+		before := receiversByName(s)
+
+		if err := s.Apply(getConfig("unsupported-receiver.yaml"), Dependencies{}); err == nil {
+			t.Errorf("Apply() error = nil, want unsupported receiver error")
+		}
+		after := receiversByName(s)
+
+		if len(after) != len(before) {
+			t.Fatalf("Apply() got %d receivers, want %d", len(after), len(before))
+		}
+		for name, r := range before {
+			if after[name] != r {
+				t.Errorf("Apply() replaced %s receiver", name)
+			}
+			waitForHealth(t, r, nil)
+		}
+	})
+
+	cancel()
+	wg.Wait()
+	for _, r := range s.Receivers() {
+		waitForHealth(t, r, ErrStopped)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Run("before start should report not started", func(t *testing.T) {
+		l := &Lifecycle{}
+		if err := l.Health(); !errors.Is(err, ErrNotStarted) {
+			t.Errorf("Health() error = %v, want %v", err, ErrNotStarted)
+		}
+	})
+
+	t.Run("with failure should report it", func(t *testing.T) {
+		l := &Lifecycle{}
+		l.Begin(context.Background())
+		want := fmt.Errorf("boom")
+		_ = l.Fail(want)
+		l.End()
+		if err := l.Health(); !errors.Is(err, want) {
+			t.Errorf("Health() error = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("when stopped before start should run with cancelled context", func(t *testing.T) {
+		l := &Lifecycle{}
+		_ = l.Stop()
+		ctx := l.Begin(context.Background())
+		if ctx.Err() == nil {
+			t.Errorf("Begin() context not cancelled after Stop()")
+		}
+	})
+}
+
+func waitForHealth(t *testing.T, r Receiver, want error) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := r.Health()
+		if errors.Is(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("%s Health() got = %v, want %v", r.Name(), got, want)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func receiversByName(s *Supervisor) map[string]Receiver {
+	receivers := make(map[string]Receiver)
+	for _, r := range s.Receivers() {
+		receivers[r.Name()] = r
+	}
+	return receivers
+}
+
+func getConfig(name string) *config.Config {
+	configFilePath, err := filepath.Abs(filepath.Join("test-configs", name))
+	if err != nil {
+		panic(fmt.Errorf("failed to get absolute path of config file: %v", err))
+	}
+
+	cfg, err := config.New(configFilePath, zap.S())
+	if err != nil {
+		panic(fmt.Errorf("failed to create config: %v", err))
+	}
+
+	return cfg
+}
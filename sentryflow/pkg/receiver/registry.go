@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package receiver
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// Dependencies holds the shared resources handed to a receiver when it is
+// built.
+type Dependencies struct {
+	// K8sClient is nil unless at least one configured receiver requires
+	// Kubernetes.
+	K8sClient client.Client
+
+	// Lock serializes changes to Kubernetes resources that are shared between
+	// receivers, e.g. the istio EnvoyFilters.
+	Lock *sync.Mutex
+
+	// EnvoyMetrics receives the proxy stats scraped by the istio receivers. It
+	// may be nil, in which case no stats are scraped.
+	EnvoyMetrics MetricsSink
+}
+
+// Factory builds a receiver for the given configuration.
+type Factory func(cfg *config.Config, deps Dependencies) Receiver
+
+// Registration describes a receiver implementation known to SentryFlow.
+type Registration struct {
+	// Name is the receiver name as it appears in the config file, e.g.
+	// `istio-sidecar`.
+	Name string
+
+	// RequiresK8s reports whether the receiver needs a Kubernetes client.
+	RequiresK8s bool
+
+	// Factory builds new instances of the receiver.
+	Factory Factory
+
+	// Settings returns the part of the configuration the receiver depends on.
+	// A running receiver is only restarted or reconfigured when its settings
+	// change. If nil, the receiver's own entry in the `receivers` section is
+	// used.
+	Settings func(cfg *config.Config) any
+
+	// EventNames are the receiver names the events reported through the
+	// receiver carry, if they differ from Name, e.g. `Istio-Sidecar` for the
+	// events of the istio sidecar filter.
+	EventNames []string
+}
+
+func (r Registration) settings(cfg *config.Config) any {
+	if r.Settings != nil {
+		return r.Settings(cfg)
+	}
+	return cfg.Receiver(r.Name)
+}
+
+var registry = struct {
+	sync.RWMutex
+	entries map[string]Registration
+}{
+	entries: make(map[string]Registration),
+}
+
+// Register makes a receiver available under its name. It is meant to be called
+// from the `init` function of the package implementing the receiver, and it
+// panics if the registration is incomplete or the name is already taken.
+func Register(reg Registration) {
+	if reg.Name == "" {
+		panic("receiver: Register called with empty name")
+	}
+	if reg.Factory == nil {
+		panic(fmt.Sprintf("receiver: Register called with nil factory for %s", reg.Name))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if _, exists := registry.entries[reg.Name]; exists {
+		panic(fmt.Sprintf("receiver: Register called twice for %s", reg.Name))
+	}
+	registry.entries[reg.Name] = reg
+}
+
+// Lookup returns the registration for the given receiver name.
+func Lookup(name string) (Registration, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	reg, exists := registry.entries[name]
+	return reg, exists
+}
+
+// Reports reports whether events carrying the receiver name name are reported
+// through a registered receiver, see Registration.EventNames.
+func Reports(name string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, reg := range registry.entries {
+		if reg.Name == name || slices.Contains(reg.EventNames, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registered returns the names of all registered receivers in sorted order.
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.entries))
+	for name := range registry.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RequiresK8s reports whether any receiver configured in cfg needs a
+// Kubernetes client.
+func RequiresK8s(cfg *config.Config) bool {
+	for _, name := range configuredNames(cfg) {
+		if reg, exists := Lookup(name); exists && reg.RequiresK8s {
+			return true
+		}
+	}
+	return false
+}
+
+func configuredNames(cfg *config.Config) []string {
+	var names []string
+	for _, serviceMesh := range cfg.Receivers.ServiceMeshes {
+		if serviceMesh.Name != "" {
+			names = append(names, serviceMesh.Name)
+		}
+	}
+	for _, other := range cfg.Receivers.Others {
+		if other.Name != "" {
+			names = append(names, other.Name)
+		}
+	}
+	return names
+}
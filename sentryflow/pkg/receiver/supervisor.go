@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+// Supervisor runs the configured receivers and keeps them in sync with the
+// configuration as it changes.
+type Supervisor struct {
+	ctx     context.Context
+	logger  *zap.SugaredLogger
+	wg      *sync.WaitGroup
+	sink    Sink
+	lock    sync.Mutex
+	running map[string]*instance
+}
+
+// instance is a started receiver along with the settings it was started or
+// last reconfigured with.
+type instance struct {
+	receiver Receiver
+	settings any
+	done     chan struct{}
+}
+
+func (i *instance) exited() bool {
+	select {
+	case <-i.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewSupervisor returns a Supervisor whose receivers run until ctx is cancelled
+// and publish their events to sink. Every receiver goroutine is tracked by wg.
+func NewSupervisor(ctx context.Context, wg *sync.WaitGroup, sink Sink) *Supervisor {
+	return &Supervisor{
+		ctx:     ctx,
+		logger:  util.LoggerFromCtx(ctx).Named("receiver"),
+		wg:      wg,
+		sink:    sink,
+		running: make(map[string]*instance),
+	}
+}
+
+// Apply brings the running receivers in line with cfg. Receivers that are no
+// longer configured are stopped and newly configured ones are started. A
+// receiver whose settings changed is reconfigured in place if it implements
+// Reconfigurer, otherwise it is stopped and started again. Receivers whose
+// settings didn't change keep running untouched, unless they have already
+// exited, in which case they are restarted.
+//
+// If cfg names an unknown receiver or a receiver rejects it, Apply returns an
+// error without changing anything.
+func (s *Supervisor) Apply(cfg *config.Config, deps Dependencies) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	regs, err := registrationsOf(cfg)
+	if err != nil {
+		return err
+	}
+
+	var toStart, toReconfigure []*instance
+	var toStop []string
+	wanted := make(map[string]bool, len(regs))
+
+	for _, reg := range regs {
+		wanted[reg.Name] = true
+		settings := reg.settings(cfg)
+		old, exists := s.running[reg.Name]
+
+		switch {
+		case exists && !old.exited() && reflect.DeepEqual(old.settings, settings):
+			continue
+		case exists && !old.exited():
+			if _, ok := old.receiver.(Reconfigurer); ok {
+				toReconfigure = append(toReconfigure, &instance{receiver: old.receiver, settings: settings, done: old.done})
+				continue
+			}
+			toStop = append(toStop, reg.Name)
+		case exists:
+			toStop = append(toStop, reg.Name)
+		}
+
+		toStart = append(toStart, &instance{
+			receiver: reg.Factory(cfg, deps),
+			settings: settings,
+			done:     make(chan struct{}),
+		})
+	}
+
+	for _, inst := range append(toStart, toReconfigure...) {
+		if err := inst.receiver.Validate(cfg); err != nil {
+			return fmt.Errorf("invalid %s receiver configuration: %w", inst.receiver.Name(), err)
+		}
+	}
+
+	for name := range s.running {
+		if !wanted[name] {
+			toStop = append(toStop, name)
+		}
+	}
+	sort.Strings(toStop)
+
+	// Wait for stopped receivers to clean up before their replacements start,
+	// so that they don't race over Kubernetes resources or listeners.
+	for _, name := range toStop {
+		s.stop(name)
+	}
+
+	var errs []error
+	for _, inst := range toReconfigure {
+		s.logger.Infof("Reconfiguring %s receiver", inst.receiver.Name())
+		if err := inst.receiver.(Reconfigurer).Reconfigure(s.ctx, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconfigure %s receiver: %w", inst.receiver.Name(), err))
+			continue
+		}
+		s.running[inst.receiver.Name()] = inst
+	}
+
+	for _, inst := range toStart {
+		s.start(inst)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Receivers returns the running receivers sorted by name.
+func (s *Supervisor) Receivers() []Receiver {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := make([]string, 0, len(s.running))
+	for name := range s.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	receivers := make([]Receiver, 0, len(names))
+	for _, name := range names {
+		receivers = append(receivers, s.running[name].receiver)
+	}
+	return receivers
+}
+
+func (s *Supervisor) start(inst *instance) {
+	name := inst.receiver.Name()
+	s.running[name] = inst
+	s.logger.Infof("Starting %s receiver", name)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(inst.done)
+		if err := inst.receiver.Start(s.ctx, s.sink); err != nil {
+			s.logger.Errorf("%s receiver stopped with error: %v", name, err)
+		}
+	}()
+}
+
+func (s *Supervisor) stop(name string) {
+	inst := s.running[name]
+	delete(s.running, name)
+
+	s.logger.Infof("Stopping %s receiver", name)
+	if err := inst.receiver.Stop(); err != nil {
+		s.logger.Errorf("failed to stop %s receiver: %v", name, err)
+	}
+	<-inst.done
+}
+
+// registrationsOf returns the registrations of the receivers configured in cfg
+// in the order they are configured.
+func registrationsOf(cfg *config.Config) ([]Registration, error) {
+	var regs []Registration
+	seen := make(map[string]bool)
+
+	add := func(name string, unsupported string) error {
+		if name == "" {
+			return nil
+		}
+		reg, exists := Lookup(name)
+		if !exists {
+			return fmt.Errorf("%s, %v", unsupported, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate receiver, %v", name)
+		}
+		seen[name] = true
+		regs = append(regs, reg)
+		return nil
+	}
+
+	for _, serviceMesh := range cfg.Receivers.ServiceMeshes {
+		if err := add(serviceMesh.Name, "unsupported Service Mesh"); err != nil {
+			return nil, err
+		}
+	}
+	for _, other := range cfg.Receivers.Others {
+		if err := add(other.Name, "unsupported receiver"); err != nil {
+			return nil, err
+		}
+	}
+
+	return regs, nil
+}
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package envoystats scrapes the Prometheus stats of the Envoy proxies in an
+// istio mesh and turns them into EnvoyMetrics.
+package envoystats
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+const (
+	scrapeTimeout  = 5 * time.Second
+	maxConcurrency = 8
+)
+
+var (
+	// Sidecars selects the pods with an injected istio sidecar, which the
+	// injector labels with their TLS mode.
+	Sidecars client.ListOption = client.HasLabels{"security.istio.io/tlsMode"}
+
+	// IngressGateways selects the istio ingress gateway pods.
+	IngressGateways client.ListOption = client.MatchingLabels{"istio": "ingressgateway"}
+)
+
+// Scraper periodically scrapes the Envoy proxies of the selected pods.
+type Scraper struct {
+	logger     *zap.SugaredLogger
+	k8sClient  client.Client
+	httpClient *http.Client
+	// selector selects the pods to scrape on the API server, so that only
+	// they are listed.
+	selector client.ListOption
+
+	lock    sync.Mutex
+	cfg     config.EnvoyMetricsConfig
+	changed chan struct{}
+}
+
+// New returns a Scraper for the pods selector selects, e.g. Sidecars.
+func New(k8sClient client.Client, selector client.ListOption, cfg config.EnvoyMetricsConfig) *Scraper {
+	return &Scraper{
+		k8sClient:  k8sClient,
+		httpClient: &http.Client{Timeout: scrapeTimeout},
+		selector:   selector,
+		cfg:        cfg,
+		changed:    make(chan struct{}, 1),
+	}
+}
+
+// Configure replaces the scrape configuration of a running Scraper.
+func (s *Scraper) Configure(cfg config.EnvoyMetricsConfig) {
+	s.lock.Lock()
+	s.cfg = cfg
+	s.lock.Unlock()
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scraper) config() config.EnvoyMetricsConfig {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cfg
+}
+
+// Run scrapes the proxies once per interval while scraping is enabled, and
+// publishes their stats to sink until ctx is cancelled. Stats are dropped if
+// sink is full.
+func (s *Scraper) Run(ctx context.Context, sink receiver.MetricsSink) {
+	if sink == nil {
+		return
+	}
+	s.logger = util.LoggerFromCtx(ctx).Named("envoy-stats")
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.changed:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(0)
+		case <-timer.C:
+			cfg := s.config()
+			if !cfg.Enabled {
+				continue
+			}
+			for _, metrics := range s.scrape(ctx, cfg) {
+				select {
+				case sink <- metrics:
+				default:
+					s.logger.Warnf("envoy metrics channel full, dropping metrics of %s/%s", metrics.Namespace, metrics.Name)
+				}
+			}
+			timer.Reset(cfg.Interval)
+		}
+	}
+}
+
+// scrape returns the stats of every selected running pod. Pods that can't be
+// scraped are skipped.
+func (s *Scraper) scrape(ctx context.Context, cfg config.EnvoyMetricsConfig) []*protobuf.EnvoyMetrics {
+	pods := &corev1.PodList{}
+	if err := s.k8sClient.List(ctx, pods, s.selector); err != nil {
+		s.logger.Errorf("failed to list pods, error: %v", err)
+		return nil
+	}
+
+	var (
+		lock    sync.Mutex
+		results []*protobuf.EnvoyMetrics
+		wg      sync.WaitGroup
+		slots   = make(chan struct{}, maxConcurrency)
+	)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			metrics, err := s.scrapePod(ctx, cfg, pod)
+			if err != nil {
+				s.logger.Debugf("failed to scrape envoy stats of %s/%s, error: %v", pod.Namespace, pod.Name, err)
+				return
+			}
+			lock.Lock()
+			results = append(results, metrics)
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Namespace != results[j].Namespace {
+			return results[i].Namespace < results[j].Namespace
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+func (s *Scraper) scrapePod(ctx context.Context, cfg config.EnvoyMetricsConfig, pod *corev1.Pod) (*protobuf.EnvoyMetrics, error) {
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(cfg.Port))), cfg.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	metrics, err := parse(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &protobuf.EnvoyMetrics{
+		TimeStamp: time.Now().UTC().Format(time.RFC3339),
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		IPAddress: pod.Status.PodIP,
+		Labels:    pod.Labels,
+		Metrics:   metrics,
+	}, nil
+}
+
+// parse converts stats in the Prometheus text format into EnvoyMetrics
+// metrics. Every series of a metric is keyed by its labels, e.g.
+// `{cluster_name="outbound|80||backend"}`, or by an empty string if it has none.
+// Histograms and summaries are split into their `_bucket`, `_sum` and `_count`
+// series.
+func parse(r io.Reader) (map[string]*protobuf.MetricValue, error) {
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]*protobuf.MetricValue)
+	set := func(name string, labels []*dto.LabelPair, value float64, extra ...*dto.LabelPair) {
+		metric, exists := metrics[name]
+		if !exists {
+			metric = &protobuf.MetricValue{Value: make(map[string]string)}
+			metrics[name] = metric
+		}
+		metric.Value[labelKey(append(append([]*dto.LabelPair{}, labels...), extra...))] = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	for name, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := m.GetLabel()
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				set(name, labels, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				set(name, labels, m.GetGauge().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					le := strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)
+					set(name+"_bucket", labels, float64(b.GetCumulativeCount()), labelPair("le", le))
+				}
+				set(name+"_sum", labels, h.GetSampleSum())
+				set(name+"_count", labels, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				sm := m.GetSummary()
+				for _, q := range sm.GetQuantile() {
+					quantile := strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)
+					set(name, labels, q.GetValue(), labelPair("quantile", quantile))
+				}
+				set(name+"_sum", labels, sm.GetSampleSum())
+				set(name+"_count", labels, float64(sm.GetSampleCount()))
+			default:
+				set(name, labels, m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: &name, Value: &value}
+}
+
+func labelKey(labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].GetName() < labels[j].GetName()
+	})
+
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = fmt.Sprintf("%s=%q", l.GetName(), l.GetValue())
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package envoystats
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
+)
+
+const envoyStats = `# TYPE envoy_cluster_upstream_rq_total counter
+envoy_cluster_upstream_rq_total{cluster_name="outbound|80||backend",response_code_class="2xx"} 42
+envoy_cluster_upstream_rq_total{cluster_name="outbound|80||backend",response_code_class="5xx"} 3
+# TYPE envoy_server_live gauge
+envoy_server_live 1
+# TYPE envoy_cluster_upstream_rq_time histogram
+envoy_cluster_upstream_rq_time_bucket{cluster_name="backend",le="10"} 5
+envoy_cluster_upstream_rq_time_bucket{cluster_name="backend",le="+Inf"} 7
+envoy_cluster_upstream_rq_time_sum{cluster_name="backend"} 85.5
+envoy_cluster_upstream_rq_time_count{cluster_name="backend"} 7
+`
+
+func Test_parse(t *testing.T) {
+	// When
+	got, err := parse(strings.NewReader(envoyStats))
+	if err != nil {
+		t.Fatalf("parse() error = %v, wantErr = nil", err)
+	}
+
+	// Then
+	want := map[string]map[string]string{
+		"envoy_cluster_upstream_rq_total": {
+			`{cluster_name="outbound|80||backend",response_code_class="2xx"}`: "42",
+			`{cluster_name="outbound|80||backend",response_code_class="5xx"}`: "3",
+		},
+		"envoy_server_live": {"": "1"},
+		"envoy_cluster_upstream_rq_time_bucket": {
+			`{cluster_name="backend",le="10"}`:   "5",
+			`{cluster_name="backend",le="+Inf"}`: "7",
+		},
+		"envoy_cluster_upstream_rq_time_sum":   {`{cluster_name="backend"}`: "85.5"},
+		"envoy_cluster_upstream_rq_time_count": {`{cluster_name="backend"}`: "7"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("parse() got %d metrics, want %d", len(got), len(want))
+	}
+	for name, values := range want {
+		if !reflect.DeepEqual(got[name].GetValue(), values) {
+			t.Errorf("parse() %s = %v, want %v", name, got[name].GetValue(), values)
+		}
+	}
+
+	t.Run("with invalid stats should return error", func(t *testing.T) {
+		if _, err := parse(strings.NewReader("envoy_server_live{ 1\n")); err == nil {
+			t.Error("parse() error = nil, want error")
+		}
+	})
+}
+
+func TestScraper_Run(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != config.DefaultEnvoyMetricsPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(envoyStats))
+	}))
+	defer server.Close()
+
+	host, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	// Given
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		getPod("productpage", map[string]string{"security.istio.io/tlsMode": "istio"}, host),
+		getPod("ingressgateway", map[string]string{"istio": "ingressgateway"}, host),
+		getPod("without-sidecar", nil, host),
+	).Build()
+
+	cfg := config.EnvoyMetricsConfig{
+		Enabled:  true,
+		Interval: time.Hour,
+		Port:     uint16(port),
+		Path:     config.DefaultEnvoyMetricsPath,
+	}
+	scraper := New(k8sClient, Sidecars, cfg)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S()))
+	defer cancel()
+	sink := make(chan *protobuf.EnvoyMetrics, 10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scraper.Run(ctx, sink)
+	}()
+
+	// When
+	var got *protobuf.EnvoyMetrics
+	select {
+	case got = <-sink:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't publish any metrics")
+	}
+
+	// Then
+	if got.Namespace != "default" || got.Name != "productpage" || got.IPAddress != host {
+		t.Errorf("Run() got metrics of %s/%s (%s), want default/productpage (%s)", got.Namespace, got.Name, got.IPAddress, host)
+	}
+	if got.Labels["app"] != "productpage" {
+		t.Errorf("Run() got labels = %v, want pod labels", got.Labels)
+	}
+	if got.Metrics["envoy_server_live"].GetValue()[""] != "1" {
+		t.Errorf("Run() got metrics = %v, want envoy stats", got.Metrics)
+	}
+	select {
+	case extra := <-sink:
+		t.Errorf("Run() published metrics of unselected pod %s", extra.Name)
+	default:
+	}
+
+	t.Run("when reconfigured should scrape again", func(t *testing.T) {
+		scraper.Configure(cfg)
+
+		select {
+		case got := <-sink:
+			if got.Name != "productpage" {
+				t.Errorf("Run() got metrics of %s, want productpage", got.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run() didn't scrape after Configure")
+		}
+	})
+
+	cancel()
+	<-done
+}
+
+func getPod(name string, labels map[string]string, ip string) *corev1.Pod {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["app"] = name
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    labels,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: ip,
+		},
+	}
+}
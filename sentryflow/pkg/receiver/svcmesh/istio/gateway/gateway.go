@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 	"text/template"
 
@@ -24,6 +25,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/k8s"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver/svcmesh/istio/envoystats"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 )
 
@@ -46,30 +50,147 @@ type envoyFilterData struct {
 	EnableRateLimiting         bool
 }
 
-// StartMonitoring begins monitoring API calls within the Istio gateway
+func init() {
+	receiver.Register(receiver.Registration{
+		Name:        util.ServiceMeshIstioGateway,
+		RequiresK8s: true,
+		Factory:     New,
+		Settings:    settings,
+		// Set by the envoy wasm filter.
+		EventNames: []string{"Istio-Gateway"},
+	})
+}
+
+// Receiver monitors API calls within the Istio gateway
 // in a Kubernetes cluster. It achieves this by creating a
 // custom EnvoyFilter resource in Kubernetes.
-func StartMonitoring(ctx context.Context, cfg *config.Config, k8sClient client.Client, lock *sync.Mutex) {
+type Receiver struct {
+	receiver.Lifecycle
+	cfg       *config.Config
+	k8sClient client.Client
+	lock      *sync.Mutex
+	metrics   receiver.MetricsSink
+	scraper   *envoystats.Scraper
+}
+
+// New returns a new istio gateway receiver.
+func New(cfg *config.Config, deps receiver.Dependencies) receiver.Receiver {
+	return &Receiver{
+		cfg:       cfg,
+		k8sClient: deps.K8sClient,
+		lock:      deps.Lock,
+		metrics:   deps.EnvoyMetrics,
+		scraper:   envoystats.New(deps.K8sClient, envoystats.IngressGateways, envoyMetricsConfig(cfg)),
+	}
+}
+
+func (r *Receiver) Name() string {
+	return util.ServiceMeshIstioGateway
+}
+
+func (r *Receiver) Validate(cfg *config.Config) error {
+	if r.k8sClient == nil {
+		return fmt.Errorf("no kubernetes client available")
+	}
+	if cfg.Filters == nil || cfg.Filters.Envoy == nil {
+		return fmt.Errorf("no envoy filter configuration provided")
+	}
+	if cfg.Filters.Envoy.GatewayTag == "" && cfg.Filters.Envoy.GatewayWithRatelimitTag == "" {
+		return fmt.Errorf("no envoy gateway tag provided")
+	}
+	if getIstioRootNamespaceFromConfig(cfg) == "" {
+		return fmt.Errorf("no istio root namespace provided")
+	}
+	return nil
+}
+
+// Start creates the EnvoyFilter and WasmPlugin resources and keeps them until
+// the receiver is stopped, at which point they are deleted.
+// While running, it also scrapes the Envoy stats of the gateways if enabled.
+func (r *Receiver) Start(ctx context.Context, _ receiver.Sink) error {
+	ctx = r.Begin(ctx)
+	defer r.End()
+
 	logger := util.LoggerFromCtx(ctx).Named("istio-gateway")
 	logger.Info("Starting istio gateway monitoring")
 
-	lock.Lock()
-	if err := createResources(ctx, cfg, k8sClient); err != nil {
+	r.lock.Lock()
+	if err := createResources(ctx, r.cfg, r.k8sClient); err != nil {
 		logger.Error(err)
-		lock.Unlock()
-		return
+		r.lock.Unlock()
+		return r.Fail(err)
 	}
 	logger.Info("Started istio gateway monitoring")
-	lock.Unlock()
+	r.lock.Unlock()
+
+	scraperDone := make(chan struct{})
+	go func() {
+		defer close(scraperDone)
+		r.scraper.Run(ctx, r.metrics)
+	}()
 
 	<-ctx.Done()
+	<-scraperDone
 	logger.Info("Shutting down istio gateway mesh monitoring")
 
-	lock.Lock()
-	doCleanup(logger, k8sClient, getIstioRootNamespaceFromConfig(cfg))
-	lock.Unlock()
+	r.lock.Lock()
+	doCleanup(logger, r.k8sClient, getIstioRootNamespaceFromConfig(r.cfg))
+	r.lock.Unlock()
 
 	logger.Info("Stopped istio gateway mesh monitoring")
+	return nil
+}
+
+// Reconfigure updates the EnvoyFilter and WasmPlugin in place rather than
+// deleting and recreating them, so that gateways keep reporting API events while
+// the configuration changes. The resources are left alone if only the Envoy
+// stats scraping changed. If they can't be updated, the receiver keeps running
+// with its previous configuration.
+func (r *Receiver) Reconfigure(ctx context.Context, cfg *config.Config) error {
+	logger := util.LoggerFromCtx(ctx).Named("istio-gateway")
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !reflect.DeepEqual(resourceSettings(r.cfg), resourceSettings(cfg)) {
+		if err := updateResources(ctx, cfg, r.k8sClient); err != nil {
+			logger.Error(err)
+			return err
+		}
+		if oldNs := getIstioRootNamespaceFromConfig(r.cfg); oldNs != getIstioRootNamespaceFromConfig(cfg) {
+			doCleanup(logger, r.k8sClient, oldNs)
+		}
+	}
+	r.cfg = cfg
+	r.scraper.Configure(envoyMetricsConfig(cfg))
+	return nil
+}
+
+func envoyMetricsConfig(cfg *config.Config) config.EnvoyMetricsConfig {
+	if receiverCfg := cfg.Receiver(util.ServiceMeshIstioGateway); receiverCfg != nil {
+		return receiverCfg.EnvoyMetrics
+	}
+	return config.EnvoyMetricsConfig{}
+}
+
+// settings returns the parts of cfg the receiver is configured with.
+func settings(cfg *config.Config) any {
+	return []any{resourceSettings(cfg), envoyMetricsConfig(cfg)}
+}
+
+// resourceSettings returns the parts of cfg the receiver's resources are built
+// from.
+func resourceSettings(cfg *config.Config) any {
+	var receiverCfg config.ReceiverConfig
+	if c := cfg.Receiver(util.ServiceMeshIstioGateway); c != nil {
+		receiverCfg = *c
+		receiverCfg.EnvoyMetrics = config.EnvoyMetricsConfig{}
+	}
+	return []any{
+		receiverCfg,
+		cfg.Filters.Envoy,
+		cfg.Filters.HttpServer,
+	}
 }
 
 func createResources(ctx context.Context, cfg *config.Config, k8sClient client.Client) error {
@@ -84,6 +205,24 @@ func createResources(ctx context.Context, cfg *config.Config, k8sClient client.C
 	return nil
 }
 
+// updateResources creates or updates the EnvoyFilter and WasmPlugin so that
+// they match cfg.
+func updateResources(ctx context.Context, cfg *config.Config, k8sClient client.Client) error {
+	envoyFilter, err := newEnvoyFilter(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build EnvoyFilter, error: %v", err)
+	}
+	if err := k8s.CreateOrUpdate(ctx, k8sClient, envoyFilter, &networkingv1alpha3.EnvoyFilter{}); err != nil {
+		return fmt.Errorf("failed to update EnvoyFilter, error: %v", err)
+	}
+
+	if err := k8s.CreateOrUpdate(ctx, k8sClient, newWasmPlugin(cfg), &v1alpha1.WasmPlugin{}); err != nil {
+		return fmt.Errorf("failed to update WasmPlugin, error: %v", err)
+	}
+
+	return nil
+}
+
 func doCleanup(logger *zap.SugaredLogger, k8sClient client.Client, istioRootNs string) {
 	if err := deleteEnvoyFilter(logger, k8sClient, istioRootNs); err != nil {
 		logger.Errorf("failed to delete EnvoyFilter, error: %v", err)
@@ -96,6 +235,23 @@ func doCleanup(logger *zap.SugaredLogger, k8sClient client.Client, istioRootNs s
 func createWasmPlugin(ctx context.Context, cfg *config.Config, k8sClient client.Client) error {
 	logger := util.LoggerFromCtx(ctx)
 
+	wasmPlugin := newWasmPlugin(cfg)
+	existingWasmPlugin := &v1alpha1.WasmPlugin{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(wasmPlugin), existingWasmPlugin); err != nil {
+		if errors.IsNotFound(err) {
+			if err := k8sClient.Create(ctx, wasmPlugin); err != nil {
+				return err
+			}
+			logger.Infow("Created WasmPlugin", "name", wasmPlugin.Name, "namespace", wasmPlugin.Namespace)
+			return nil
+		}
+		return err
+	}
+	logger.Infow("Found existing WasmPlugin", "name", wasmPlugin.Name, "namespace", wasmPlugin.Namespace)
+	return nil
+}
+
+func newWasmPlugin(cfg *config.Config) *v1alpha1.WasmPlugin {
 	tag := cfg.Filters.Envoy.GatewayTag
 	if cfg.Filters.Envoy.GatewayWithRatelimitTag != "" {
 		tag = cfg.Filters.Envoy.GatewayWithRatelimitTag
@@ -166,19 +322,7 @@ func createWasmPlugin(ctx context.Context, cfg *config.Config, k8sClient client.
 		}
 	}
 
-	existingWasmPlugin := &v1alpha1.WasmPlugin{}
-	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(wasmPlugin), existingWasmPlugin); err != nil {
-		if errors.IsNotFound(err) {
-			if err := k8sClient.Create(ctx, wasmPlugin); err != nil {
-				return err
-			}
-			logger.Infow("Created WasmPlugin", "name", wasmPlugin.Name, "namespace", wasmPlugin.Namespace)
-			return nil
-		}
-		return err
-	}
-	logger.Infow("Found existing WasmPlugin", "name", wasmPlugin.Name, "namespace", wasmPlugin.Namespace)
-	return nil
+	return wasmPlugin
 }
 
 func deleteWasmPlugin(logger *zap.SugaredLogger, k8sClient client.Client, istioRootNs string) error {
@@ -197,6 +341,30 @@ func deleteWasmPlugin(logger *zap.SugaredLogger, k8sClient client.Client, istioR
 func createEnvoyFilter(ctx context.Context, cfg *config.Config, k8sClient client.Client) error {
 	logger := util.LoggerFromCtx(ctx)
 
+	filterToCreate, err := newEnvoyFilter(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	existingFilter := &networkingv1alpha3.EnvoyFilter{}
+	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(filterToCreate), existingFilter); err != nil {
+		if errors.IsNotFound(err) {
+			if err := k8sClient.Create(ctx, filterToCreate); err != nil {
+				return err
+			}
+			logger.Infow("Created EnvoyFilter", "name", filterToCreate.Name, "namespace", filterToCreate.Namespace)
+			return nil
+		}
+		return err
+	}
+	logger.Infow("Found existing EnvoyFilter", "name", filterToCreate.Name, "namespace", filterToCreate.Namespace)
+
+	return nil
+}
+
+func newEnvoyFilter(ctx context.Context, cfg *config.Config) (*networkingv1alpha3.EnvoyFilter, error) {
+	logger := util.LoggerFromCtx(ctx)
+
 	// Istio feature stages for reference to keep trace when they plan to move their
 	// alpha APIs to beta then stable.
 	// https://istio.io/latest/docs/releases/feature-stages/#extensibility
@@ -278,13 +446,13 @@ spec:
 	tmpl, err := template.New("envoyHttpFilter").Parse(httpFilter)
 	if err != nil {
 		logger.Errorf("Failed to parse EnvoyFilter template: %v", err)
-		return err
+		return nil, err
 	}
 
 	envoyFilter := &bytes.Buffer{}
 	if err := tmpl.Execute(envoyFilter, data); err != nil {
 		logger.Errorf("Failed to execute EnvoyFilter template: %v", err)
-		return err
+		return nil, err
 	}
 
 	filterToCreate := &networkingv1alpha3.EnvoyFilter{
@@ -299,23 +467,10 @@ spec:
 	}
 	if err := yaml.UnmarshalStrict(envoyFilter.Bytes(), filterToCreate); err != nil {
 		logger.Errorf("Failed to unmarshal EnvoyFilter: %v", err)
-		return err
+		return nil, err
 	}
 
-	existingFilter := &networkingv1alpha3.EnvoyFilter{}
-	if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(filterToCreate), existingFilter); err != nil {
-		if errors.IsNotFound(err) {
-			if err := k8sClient.Create(ctx, filterToCreate); err != nil {
-				return err
-			}
-			logger.Infow("Created EnvoyFilter", "name", filterToCreate.Name, "namespace", filterToCreate.Namespace)
-			return nil
-		}
-		return err
-	}
-	logger.Infow("Found existing EnvoyFilter", "name", filterToCreate.Name, "namespace", filterToCreate.Namespace)
-
-	return nil
+	return filterToCreate, nil
 }
 
 func deleteEnvoyFilter(logger *zap.SugaredLogger, k8sClient client.Client, istioRootNs string) error {
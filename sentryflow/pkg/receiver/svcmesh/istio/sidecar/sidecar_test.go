@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"sync"
 	"testing"
 	"text/template"
 
@@ -26,6 +27,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/receiver"
 	"github.com/accuknox/SentryFlow/sentryflow/pkg/util"
 )
 
@@ -157,6 +159,121 @@ func Test_createWasmPlugin(t *testing.T) {
 	})
 }
 
+func Test_updateResources(t *testing.T) {
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
+	fakeClient := getFakeClient()
+
+	t.Run("when wasm plugin exists should update it in place", func(t *testing.T) {
+		// Given
+		cfg := getConfig()
+		if err := createResources(ctx, cfg, fakeClient); err != nil {
+			t.Fatalf("updateResources() failed to create resources = %v", err)
+		}
+		defer doCleanup(zap.S(), fakeClient, istioRootNs)
+
+		cfg.Filters.Envoy.SidecarTag = "v9.9.9"
+		want := fmt.Sprintf("%s:%s", cfg.Filters.Envoy.Uri, cfg.Filters.Envoy.SidecarTag)
+
+		// When
+		if err := updateResources(ctx, cfg, fakeClient); err != nil {
+			t.Errorf("updateResources() error = %v, wantErr = nil", err)
+		}
+
+		// Then
+		latestWasmPlugin := &v1alpha1.WasmPlugin{}
+		if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(getWasmPlugin()), latestWasmPlugin); err != nil {
+			t.Fatalf("updateResources() failed to get plugin = %v", err)
+		}
+		if got := latestWasmPlugin.Spec.Url; got != want {
+			t.Errorf("updateResources() got url = %v, want = %v", got, want)
+		}
+
+		envoyFilter := &networkingv1alpha3.EnvoyFilter{}
+		if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(getEnvoyFilter()), envoyFilter); err != nil {
+			t.Errorf("updateResources() failed to get filter = %v", err)
+		}
+	})
+}
+
+func TestReceiver_Reconfigure(t *testing.T) {
+	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
+
+	tests := []struct {
+		name        string
+		change      func(cfg *config.Config)
+		k8sClient   client.Client
+		wantUpdated bool
+		wantErr     bool
+	}{
+		{
+			name: "when only envoy metrics changed should not update resources",
+			change: func(cfg *config.Config) {
+				cfg.Receiver(util.ServiceMeshIstioSidecar).EnvoyMetrics.Enabled = true
+			},
+			k8sClient: getFakeClient(),
+		},
+		{
+			name: "when sidecar tag changed should update resources",
+			change: func(cfg *config.Config) {
+				cfg.Filters.Envoy.SidecarTag = "v9.9.9"
+			},
+			k8sClient:   getFakeClient(),
+			wantUpdated: true,
+		},
+		{
+			name: "when resources can't be updated should return error without failing",
+			change: func(cfg *config.Config) {
+				cfg.Filters.Envoy.SidecarTag = "v9.9.9"
+			},
+			k8sClient: fake.NewClientBuilder().Build(),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			r := New(getConfig(), receiver.Dependencies{K8sClient: tt.k8sClient, Lock: &sync.Mutex{}})
+			r.(*Receiver).Begin(ctx)
+			defer func() {
+				_ = r.Stop()
+			}()
+			if !tt.wantErr {
+				if err := createResources(ctx, getConfig(), tt.k8sClient); err != nil {
+					t.Fatalf("Reconfigure() failed to create resources = %v", err)
+				}
+				defer doCleanup(zap.S(), tt.k8sClient, istioRootNs)
+			}
+			before := &v1alpha1.WasmPlugin{}
+			_ = tt.k8sClient.Get(ctx, client.ObjectKeyFromObject(getWasmPlugin()), before)
+
+			cfg := getConfig()
+			tt.change(cfg)
+
+			// When
+			err := r.(receiver.Reconfigurer).Reconfigure(ctx, cfg)
+
+			// Then
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Reconfigure() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if health := r.Health(); health != nil {
+				t.Errorf("Health() error = %v, want nil", health)
+			}
+			if tt.wantErr {
+				return
+			}
+			after := &v1alpha1.WasmPlugin{}
+			if err := tt.k8sClient.Get(ctx, client.ObjectKeyFromObject(getWasmPlugin()), after); err != nil {
+				t.Fatalf("Reconfigure() failed to get plugin = %v", err)
+			}
+			if updated := after.ResourceVersion != before.ResourceVersion; updated != tt.wantUpdated {
+				t.Errorf("Reconfigure() updated resources = %v, want %v", updated, tt.wantUpdated)
+			}
+		})
+	}
+}
+
 func Test_deleteEnvoyFilter(t *testing.T) {
 	ctx := context.WithValue(context.Background(), util.LoggerContextKey{}, zap.S())
 	fakeClient := getFakeClient()
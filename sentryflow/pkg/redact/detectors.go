@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package redact
+
+import (
+	"regexp"
+
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// credentialHeaders are the headers whose whole value is redacted by the
+// credentialHeaders detector.
+var credentialHeaders = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"api-key",
+	"x-auth-token",
+	"x-amz-security-token",
+}
+
+// detector finds sensitive values in text. A match is only redacted if valid
+// accepts it.
+type detector struct {
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// detectors are the built-in detectors by name, in the order they are applied.
+// Detectors of values that may contain others, e.g. bearer tokens containing
+// JWTs, come first.
+var detectors = []struct {
+	name     string
+	detector detector
+}{
+	{
+		name:     config.RedactDetectorBearer,
+		detector: detector{pattern: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)},
+	},
+	{
+		name:     config.RedactDetectorJWT,
+		detector: detector{pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	},
+	{
+		name: config.RedactDetectorAPIKey,
+		detector: detector{pattern: regexp.MustCompile(`\b(?:` +
+			`AKIA[0-9A-Z]{16}` + // AWS access key ID
+			`|gh[pousr]_[A-Za-z0-9]{36,}` + // GitHub token
+			`|github_pat_[A-Za-z0-9_]{22,}` + // GitHub fine-grained token
+			`|xox[abprs]-[A-Za-z0-9-]{10,}` + // Slack token
+			`|(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}` + // Stripe key
+			`|AIza[0-9A-Za-z_-]{35}` + // Google API key
+			`|sk-[A-Za-z0-9_-]{32,}` + // OpenAI style secret key
+			`)`)},
+	},
+	{
+		name:     config.RedactDetectorEmail,
+		detector: detector{pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	},
+	{
+		name:     config.RedactDetectorCreditCard,
+		detector: detector{pattern: regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`), valid: luhn},
+	},
+	{
+		name:     config.RedactDetectorSSN,
+		detector: detector{pattern: regexp.MustCompile(`\b[0-9]{3}-[0-9]{2}-[0-9]{4}\b`), valid: validSSN},
+	},
+	{
+		name: config.RedactDetectorPhone,
+		detector: detector{pattern: regexp.MustCompile(
+			`\+[1-9][0-9]{7,14}\b` + // E.164
+				`|(?:\([0-9]{3}\)\s?|\b[0-9]{3}[-.\s])[0-9]{3}[-.\s][0-9]{4}\b`, // North American
+		)},
+	},
+}
+
+// luhn reports whether the digits of number pass the Luhn checksum used by
+// payment card numbers.
+func luhn(number string) bool {
+	sum, digits := 0, 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// validSSN reports whether ssn, formatted as AAA-GG-SSSS, is a US social
+// security number that can have been issued.
+func validSSN(ssn string) bool {
+	area, group, serial := ssn[0:3], ssn[4:6], ssn[7:11]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package redact
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath expression. Only child names, `*`, array
+// indices and recursive descent by name, e.g. `$..password`, are supported.
+type jsonPath []pathStep
+
+type pathStep struct {
+	// name is the object key, or "*" for every key or array element.
+	name string
+	// index is the array index if name is empty.
+	index int
+	// recursive matches name at any depth.
+	recursive bool
+}
+
+func parseJSONPath(path string) (jsonPath, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid jsonPath %s, it must start with $", path)
+	}
+
+	var steps jsonPath
+	rest := path[1:]
+	for rest != "" {
+		step := pathStep{}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step.recursive = true
+			rest = rest[2:]
+			step.name, rest = splitName(rest)
+		case strings.HasPrefix(rest, "."):
+			step.name, rest = splitName(rest[1:])
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid jsonPath %s, missing ]", path)
+			}
+			selector := strings.Trim(rest[1:end], `'"`)
+			rest = rest[end+1:]
+			if selector == "*" {
+				step.name = "*"
+			} else if index, err := strconv.Atoi(selector); err == nil {
+				step.index = index
+			} else {
+				step.name = selector
+			}
+		default:
+			return nil, fmt.Errorf("invalid jsonPath %s at %s", path, rest)
+		}
+		if step.name == "" && step.recursive {
+			return nil, fmt.Errorf("invalid jsonPath %s, missing name after ..", path)
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid jsonPath %s, it selects the whole document", path)
+	}
+	return steps, nil
+}
+
+func splitName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// apply calls redact with every value of node selected by p. The value is
+// replaced with what redact returns or, if it returns false, removed from its
+// object. Removed array elements are set to null so that the indices of the
+// others don't change. apply reports whether any value was replaced or
+// removed.
+func (p jsonPath) apply(node any, redact func(value any) (any, bool)) bool {
+	if len(p) == 0 {
+		return false
+	}
+	step, last := p[0], len(p) == 1
+	changed := false
+
+	visit := func(value any, set func(any), remove func()) {
+		if last {
+			replacement, keep := redact(value)
+			if !keep {
+				if value != nil {
+					remove()
+					changed = true
+				}
+				return
+			}
+			if s, ok := value.(string); ok && s == replacement {
+				return
+			}
+			set(replacement)
+			changed = true
+			return
+		}
+		if p[1:].apply(value, redact) {
+			changed = true
+		}
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		for key, value := range n {
+			if step.name == "*" || step.name == key {
+				visit(value, func(v any) { n[key] = v }, func() { delete(n, key) })
+			}
+		}
+	case []any:
+		for i := len(n) - 1; i >= 0; i-- {
+			if step.name == "*" || (step.name == "" && step.index == i) {
+				visit(n[i], func(v any) { n[i] = v }, func() { n[i] = nil })
+			}
+		}
+	}
+
+	// Recursive descent also looks for the name below every child.
+	if step.recursive {
+		switch n := node.(type) {
+		case map[string]any:
+			for _, value := range n {
+				if p.apply(value, redact) {
+					changed = true
+				}
+			}
+		case []any:
+			for _, value := range n {
+				if p.apply(value, redact) {
+					changed = true
+				}
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_jsonPath_apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		doc         string
+		drop        bool
+		want        string
+		wantChanged bool
+	}{
+		{
+			name:        "with field should replace it",
+			path:        "$.user.password",
+			doc:         `{"user":{"name":"jane","password":"s3cret"}}`,
+			want:        `{"user":{"name":"jane","password":"x"}}`,
+			wantChanged: true,
+		},
+		{
+			name:        "with quoted field should replace it",
+			path:        "$['user']['password']",
+			doc:         `{"user":{"password":"s3cret"}}`,
+			want:        `{"user":{"password":"x"}}`,
+			wantChanged: true,
+		},
+		{
+			name:        "with wildcard index should replace every element's field",
+			path:        "$.cards[*].number",
+			doc:         `{"cards":[{"number":"1"},{"number":"2"}]}`,
+			want:        `{"cards":[{"number":"x"},{"number":"x"}]}`,
+			wantChanged: true,
+		},
+		{
+			name:        "with index should replace only that element",
+			path:        "$.tokens[1]",
+			doc:         `{"tokens":["a","b"]}`,
+			want:        `{"tokens":["a","x"]}`,
+			wantChanged: true,
+		},
+		{
+			name:        "with recursive descent should replace fields at any depth",
+			path:        "$..token",
+			doc:         `{"token":"a","session":{"token":"b"},"items":[{"token":"c"}]}`,
+			want:        `{"items":[{"token":"x"}],"session":{"token":"x"},"token":"x"}`,
+			wantChanged: true,
+		},
+		{
+			name:        "with drop should remove the field",
+			path:        "$.user.password",
+			doc:         `{"user":{"name":"jane","password":"s3cret"}}`,
+			drop:        true,
+			want:        `{"user":{"name":"jane"}}`,
+			wantChanged: true,
+		},
+		{
+			name: "with missing field should not change the document",
+			path: "$.user.password",
+			doc:  `{"user":{"name":"jane"}}`,
+			want: `{"user":{"name":"jane"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := parseJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("parseJSONPath() error = %v", err)
+			}
+			var doc any
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			changed := path.apply(doc, func(any) (any, bool) { return "x", !tt.drop })
+
+			if changed != tt.wantChanged {
+				t.Errorf("apply() = %v, want %v", changed, tt.wantChanged)
+			}
+			if got := encodeJSON(doc); got != tt.want {
+				t.Errorf("apply() document = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseJSONPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "with dotted path should parse it", path: "$.a.b"},
+		{name: "with brackets should parse them", path: "$.a[0]['b'][*]"},
+		{name: "with recursive descent should parse it", path: "$..a"},
+		{name: "without root should fail", path: "a.b", wantErr: true},
+		{name: "with unterminated bracket should fail", path: "$.a[0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJSONPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseJSONPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
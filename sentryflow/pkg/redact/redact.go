@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package redact removes PII and secrets from the headers and bodies of API
+// events before they are exported.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+// Mask replaces sensitive values in mask mode.
+const Mask = "[REDACTED]"
+
+// hashed matches the values that replace sensitive values in hash mode.
+var hashed = regexp.MustCompile(`^\[HMAC:[0-9a-f]{32}\]$`)
+
+// Redactor redacts API events as configured by a config.RedactConfig.
+type Redactor struct {
+	mode string
+	key  []byte
+
+	// headers are the lower case names of the headers whose whole value is
+	// redacted.
+	headers   map[string]bool
+	detectors []detector
+	jsonPaths []jsonPath
+}
+
+// New returns a Redactor for cfg, which must have been validated by the config
+// package. In hash mode, it reads the key from cfg.HashKeyFile unless
+// cfg.HashKey is set.
+func New(cfg *config.RedactConfig) (*Redactor, error) {
+	r := &Redactor{
+		mode:    cfg.Mode,
+		headers: make(map[string]bool),
+	}
+	if r.mode == "" {
+		r.mode = config.RedactModeMask
+	}
+
+	if r.mode == config.RedactModeHash {
+		r.key = []byte(cfg.HashKey)
+		if len(r.key) == 0 {
+			key, err := os.ReadFile(cfg.HashKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read redact hash key: %w", err)
+			}
+			r.key = bytes.TrimSpace(key)
+		}
+		if len(r.key) == 0 {
+			return nil, fmt.Errorf("empty redact hash key")
+		}
+	}
+
+	enabled := cfg.Detectors
+	if len(enabled) == 0 {
+		enabled = config.RedactDetectors
+	}
+	if slices.Contains(enabled, config.RedactDetectorCredentialHeaders) {
+		for _, header := range credentialHeaders {
+			r.headers[header] = true
+		}
+	}
+	for _, d := range detectors {
+		if slices.Contains(enabled, d.name) {
+			r.detectors = append(r.detectors, d.detector)
+		}
+	}
+
+	for _, header := range cfg.Headers {
+		r.headers[strings.ToLower(header)] = true
+	}
+	for _, pattern := range cfg.Patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern, %v", err)
+		}
+		r.detectors = append(r.detectors, detector{pattern: compiled})
+	}
+	for _, path := range cfg.JSONPaths {
+		parsed, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		r.jsonPaths = append(r.jsonPaths, parsed)
+	}
+	return r, nil
+}
+
+// Redact redacts the sensitive values of event in place and reports whether
+// there were any.
+func (r *Redactor) Redact(event *protobuf.APIEvent) bool {
+	changed := false
+	if request := event.GetRequest(); request != nil {
+		if r.redactHeaders(request.Headers) {
+			changed = true
+		}
+		if body, redacted := r.redactBody(request.Body); redacted {
+			request.Body = body
+			changed = true
+		}
+	}
+	if response := event.GetResponse(); response != nil {
+		if r.redactHeaders(response.Headers) {
+			changed = true
+		}
+		if body, redacted := r.redactBody(response.Body); redacted {
+			response.Body = body
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (r *Redactor) redactHeaders(headers map[string]string) bool {
+	changed := false
+	for name, value := range headers {
+		if r.headers[strings.ToLower(name)] {
+			if isRedacted(value) {
+				continue
+			}
+			if r.mode == config.RedactModeDrop {
+				delete(headers, name)
+			} else {
+				headers[name] = r.replacement(value)
+			}
+			changed = true
+			continue
+		}
+		if redacted, ok := r.redactText(value); ok {
+			headers[name] = redacted
+			changed = true
+		}
+	}
+	return changed
+}
+
+// redactBody redacts the configured JSON paths and the values found by the
+// detectors in the strings of JSON bodies, or the values found by the
+// detectors in other bodies.
+func (r *Redactor) redactBody(body string) (string, bool) {
+	if body == "" {
+		return body, false
+	}
+
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var doc any
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err == nil && !decoder.More() {
+			changed := false
+			for _, path := range r.jsonPaths {
+				if path.apply(doc, r.redactValue) {
+					changed = true
+				}
+			}
+			if r.redactStrings(doc) {
+				changed = true
+			}
+			if !changed {
+				return body, false
+			}
+			return encodeJSON(doc), true
+		}
+	}
+
+	return r.redactText(body)
+}
+
+// redactStrings redacts the values found by the detectors in every string of
+// a decoded JSON document.
+func (r *Redactor) redactStrings(node any) bool {
+	changed := false
+	switch n := node.(type) {
+	case map[string]any:
+		for key, value := range n {
+			if s, ok := value.(string); ok {
+				if redacted, ok := r.redactText(s); ok {
+					n[key] = redacted
+					changed = true
+				}
+			} else if r.redactStrings(value) {
+				changed = true
+			}
+		}
+	case []any:
+		for i, value := range n {
+			if s, ok := value.(string); ok {
+				if redacted, ok := r.redactText(s); ok {
+					n[i] = redacted
+					changed = true
+				}
+			} else if r.redactStrings(value) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// redactText redacts the values found by the detectors in text.
+func (r *Redactor) redactText(text string) (string, bool) {
+	changed := false
+	for _, d := range r.detectors {
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			changed = true
+			return r.replacement(match)
+		})
+	}
+	return text, changed
+}
+
+// redactValue redacts a value selected by a JSON path.
+func (r *Redactor) redactValue(value any) (any, bool) {
+	if r.mode == config.RedactModeDrop {
+		return nil, false
+	}
+	s, ok := value.(string)
+	if !ok {
+		s = encodeJSON(value)
+	} else if isRedacted(s) {
+		return s, true
+	}
+	return r.replacement(s), true
+}
+
+func (r *Redactor) replacement(value string) string {
+	switch r.mode {
+	case config.RedactModeHash:
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(value))
+		return "[HMAC:" + hex.EncodeToString(mac.Sum(nil)[:16]) + "]"
+	case config.RedactModeDrop:
+		return ""
+	default:
+		return Mask
+	}
+}
+
+// isRedacted reports whether value already replaces a sensitive value.
+func isRedacted(value string) bool {
+	return value == Mask || hashed.MatchString(value)
+}
+
+func encodeJSON(value any) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package redact
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+)
+
+func newEvent(requestBody, responseBody string) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Request: &protobuf.Request{
+			Headers: map[string]string{
+				":path":         "/users?email=jane@example.com",
+				"authorization": "Basic amFuZTpzM2NyZXQ=",
+				"x-request-id":  "42",
+			},
+			Body: requestBody,
+		},
+		Response: &protobuf.Response{
+			Headers: map[string]string{"content-type": "application/json"},
+			Body:    responseBody,
+		},
+	}
+}
+
+func TestRedactor_Redact(t *testing.T) {
+	tests := []struct {
+		name             string
+		cfg              *config.RedactConfig
+		requestBody      string
+		responseBody     string
+		want             bool
+		wantHeaders      map[string]string
+		wantRequestBody  string
+		wantResponseBody string
+	}{
+		{
+			name:         "with default config should mask credential headers and detected values",
+			cfg:          &config.RedactConfig{},
+			requestBody:  `{"email":"jane@example.com","card":"4111-1111-1111-1111","note":"<ok>"}`,
+			responseBody: "ssn: 123-45-6789",
+			want:         true,
+			wantHeaders: map[string]string{
+				":path":         "/users?email=[REDACTED]",
+				"authorization": "[REDACTED]",
+				"x-request-id":  "42",
+			},
+			wantRequestBody:  `{"card":"[REDACTED]","email":"[REDACTED]","note":"<ok>"}`,
+			wantResponseBody: "ssn: [REDACTED]",
+		},
+		{
+			name: "with JSON paths and patterns should redact them",
+			cfg: &config.RedactConfig{
+				Detectors: []string{config.RedactDetectorNone},
+				JSONPaths: []string{"$.password", "$.items[*].id"},
+				Patterns:  []string{`order-[0-9]+`},
+			},
+			requestBody:  `{"password":"s3cret","items":[{"id":7,"qty":1}],"total":12.50}`,
+			responseBody: "created order-1234",
+			want:         true,
+			wantHeaders: map[string]string{
+				":path":         "/users?email=jane@example.com",
+				"authorization": "Basic amFuZTpzM2NyZXQ=",
+				"x-request-id":  "42",
+			},
+			wantRequestBody:  `{"items":[{"id":"[REDACTED]","qty":1}],"password":"[REDACTED]","total":12.50}`,
+			wantResponseBody: "created [REDACTED]",
+		},
+		{
+			name: "with drop mode should remove headers, fields and matches",
+			cfg: &config.RedactConfig{
+				Mode:      config.RedactModeDrop,
+				Detectors: []string{config.RedactDetectorCredentialHeaders, config.RedactDetectorEmail},
+				JSONPaths: []string{"$.password"},
+			},
+			requestBody:  `{"user":"jane","password":"s3cret"}`,
+			responseBody: "mail jane@example.com",
+			want:         true,
+			wantHeaders: map[string]string{
+				":path":        "/users?email=",
+				"x-request-id": "42",
+			},
+			wantRequestBody:  `{"user":"jane"}`,
+			wantResponseBody: "mail ",
+		},
+		{
+			name: "without sensitive values should not change the event",
+			cfg: &config.RedactConfig{
+				Detectors: []string{config.RedactDetectorSSN},
+			},
+			requestBody:  `{ "id": 1 }`,
+			responseBody: "ok",
+			wantHeaders: map[string]string{
+				":path":         "/users?email=jane@example.com",
+				"authorization": "Basic amFuZTpzM2NyZXQ=",
+				"x-request-id":  "42",
+			},
+			wantRequestBody:  `{ "id": 1 }`,
+			wantResponseBody: "ok",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			event := newEvent(tt.requestBody, tt.responseBody)
+
+			if got := r.Redact(event); got != tt.want {
+				t.Errorf("Redact() = %v, want %v", got, tt.want)
+			}
+			if len(event.Request.Headers) != len(tt.wantHeaders) {
+				t.Errorf("Redact() headers = %v, want %v", event.Request.Headers, tt.wantHeaders)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := event.Request.Headers[name]; got != want {
+					t.Errorf("Redact() header %v = %q, want %q", name, got, want)
+				}
+			}
+			if event.Request.Body != tt.wantRequestBody {
+				t.Errorf("Redact() request body = %v, want %v", event.Request.Body, tt.wantRequestBody)
+			}
+			if event.Response.Body != tt.wantResponseBody {
+				t.Errorf("Redact() response body = %v, want %v", event.Response.Body, tt.wantResponseBody)
+			}
+
+			if r.Redact(event) {
+				t.Errorf("Redact() of redacted event = true, want false")
+			}
+		})
+	}
+}
+
+func TestRedactor_Redact_hash(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromFile, err := New(&config.RedactConfig{Mode: config.RedactModeHash, HashKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	fromKey, err := New(&config.RedactConfig{Mode: config.RedactModeHash, HashKey: "s3cret"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	otherKey, err := New(&config.RedactConfig{Mode: config.RedactModeHash, HashKey: "other"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Given
+	first := newEvent(`{"email":"jane@example.com"}`, "")
+	second := newEvent(`{"email":"jane@example.com"}`, "")
+	third := newEvent(`{"email":"jane@example.com"}`, "")
+
+	// When
+	fromFile.Redact(first)
+	fromKey.Redact(second)
+	otherKey.Redact(third)
+
+	// Then
+	if !strings.HasPrefix(first.Request.Body, `{"email":"[HMAC:`) {
+		t.Errorf("Redact() body = %v, want hashed email", first.Request.Body)
+	}
+	if first.Request.Body != second.Request.Body {
+		t.Errorf("Redact() with same key = %v and %v, want the same hash", first.Request.Body, second.Request.Body)
+	}
+	if first.Request.Body == third.Request.Body {
+		t.Errorf("Redact() with other key = %v, want a different hash", third.Request.Body)
+	}
+	if !hashed.MatchString(first.Request.Headers["authorization"]) {
+		t.Errorf("Redact() authorization = %v, want it hashed", first.Request.Headers["authorization"])
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&config.RedactConfig{Mode: config.RedactModeHash, HashKeyFile: filepath.Join(t.TempDir(), "missing")})
+	if err == nil {
+		t.Errorf("New() with missing hash key file error = nil, want error")
+	}
+}
@@ -5,6 +5,7 @@ package util
 
 import (
 	"context"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -31,3 +32,11 @@ func LoggerFromCtx(ctx context.Context) *zap.SugaredLogger {
 	logger, _ := ctx.Value(LoggerContextKey{}).(*zap.SugaredLogger)
 	return logger
 }
+
+// TrimQuery returns path without its query string and fragment.
+func TrimQuery(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
@@ -37,3 +37,21 @@ func TestLoggerFromCtx(t *testing.T) {
 		})
 	}
 }
+
+func TestTrimQuery(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/users/1", want: "/users/1"},
+		{path: "/users/1?verbose=true", want: "/users/1"},
+		{path: "/users/1#details", want: "/users/1"},
+		{path: "/users?page=2#top", want: "/users"},
+		{path: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := TrimQuery(tt.path); got != tt.want {
+			t.Errorf("TrimQuery(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
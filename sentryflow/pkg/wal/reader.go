@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+// Reader reads the events of the log for an exporter, which acknowledges them
+// with Ack once it's done with them.
+type Reader struct {
+	log  *Log
+	name string
+
+	// The fields below are guarded by the log's lock. next is the position of
+	// the next event to read, committed the position up to which all events
+	// were acknowledged and saved the committed position last saved.
+	next      Position
+	committed Position
+	saved     Position
+	// pending are the events read and not acknowledged yet, in order.
+	pending []*pending
+	// file is the segment fileID, the one last read from.
+	file   *os.File
+	fileID uint64
+	// replayed wakes Run up when an event is to be replayed.
+	replayed chan struct{}
+}
+
+// pending is an event read from the log, and where its record ends. An event
+// the exporter couldn't deliver is replayed at replayAt, replays counts how
+// many times it was.
+type pending struct {
+	event    *protobuf.APIEvent
+	end      Position
+	acked    bool
+	replay   bool
+	replayAt time.Time
+	replays  int
+}
+
+// Name returns the name of the exporter r reads the log for.
+func (r *Reader) Name() string {
+	return r.name
+}
+
+// Run reads the events of the log, starting with the first one that wasn't
+// acknowledged, and sends them to out until ctx is done or the log is closed.
+// It waits for new events once it read all of them. The events passed to
+// Replay are sent again in between.
+func (r *Reader) Run(ctx context.Context, out chan<- *protobuf.APIEvent, logger *zap.SugaredLogger) {
+	for {
+		event, next := r.nextReplay()
+		var wait <-chan struct{}
+		if event == nil {
+			var err error
+			event, wait, err = r.read()
+			if err == ErrClosed {
+				return
+			}
+			if err != nil {
+				logger.Errorf("Failed to read %s write-ahead log, skipped the rest of the segment: %v", r.name, err)
+				continue
+			}
+		}
+
+		if event == nil {
+			var timer *time.Timer
+			var replay <-chan time.Time
+			if next > 0 {
+				timer = time.NewTimer(next)
+				replay = timer.C
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-wait:
+			case <-r.replayed:
+			case <-replay:
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case out <- event:
+		}
+	}
+}
+
+// read returns the next event of the log. If there is none, it returns a
+// channel that's closed once there are new events.
+func (r *Reader) read() (*protobuf.APIEvent, <-chan struct{}, error) {
+	l := r.log
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return nil, nil, ErrClosed
+	}
+
+	var seg *segment
+	for {
+		if r.next.Segment < l.segments[0].id {
+			r.next = Position{Segment: l.segments[0].id}
+		}
+		seg = l.segment(r.next.Segment)
+		if seg == nil {
+			return nil, nil, fmt.Errorf("segment %d not found", r.next.Segment)
+		}
+		if r.next.Offset < seg.size {
+			break
+		}
+		if seg == l.segments[len(l.segments)-1] {
+			return nil, l.appended, nil
+		}
+		r.next = Position{Segment: seg.id + 1}
+	}
+
+	if r.file == nil || r.fileID != seg.id {
+		if r.file != nil {
+			_ = r.file.Close()
+		}
+		file, err := os.Open(l.segmentPath(seg.id))
+		if err != nil {
+			r.file = nil
+			r.next.Offset = seg.size
+			return nil, nil, err
+		}
+		r.file, r.fileID = file, seg.id
+	}
+
+	event, size, err := readRecord(r.file, r.next.Offset, seg.size)
+	if err != nil {
+		// The records following a corrupted one can't be found, at least one
+		// event is lost.
+		offset := r.next.Offset
+		r.drop(1)
+		r.next.Offset = seg.size
+		return nil, nil, fmt.Errorf("segment %d at offset %d: %w", seg.id, offset, err)
+	}
+
+	r.next.Offset += size
+	r.pending = append(r.pending, &pending{event: event, end: r.next})
+	metrics.WALPending.WithLabelValues(r.name).Set(float64(len(r.pending)))
+	return event, nil, nil
+}
+
+// nextReplay returns the first event to replay whose time came. Otherwise, it
+// returns how long until the next one, or 0 if there is none.
+func (r *Reader) nextReplay() (*protobuf.APIEvent, time.Duration) {
+	l := r.log
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return nil, 0
+	}
+	now := time.Now()
+	var next time.Duration
+	for _, p := range r.pending {
+		if !p.replay || p.acked {
+			continue
+		}
+		wait := p.replayAt.Sub(now)
+		if wait <= 0 {
+			p.replay = false
+			return p.event, 0
+		}
+		if next == 0 || wait < next {
+			next = wait
+		}
+	}
+	return nil, next
+}
+
+// Replay tells r that the exporter couldn't deliver event, which must have
+// been read by r and not acknowledged. Run sends it again after delay. The
+// cursor of r doesn't move past event until it's acknowledged. Once event was
+// replayed the configured maximum number of times, it's acknowledged and
+// counted as dropped instead, and Replay returns false.
+func (r *Reader) Replay(event *protobuf.APIEvent, delay time.Duration) bool {
+	l := r.log
+	l.lock.Lock()
+	exhausted := false
+	for _, p := range r.pending {
+		if p.event == event && !p.acked {
+			if p.replays >= l.cfg.MaxReplays {
+				exhausted = true
+				break
+			}
+			p.replays++
+			p.replay, p.replayAt = true, time.Now().Add(delay)
+			break
+		}
+	}
+	l.lock.Unlock()
+
+	if exhausted {
+		r.drop(1)
+		r.Ack(event)
+		return false
+	}
+	select {
+	case r.replayed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Ack acknowledges that the exporter is done with event, which must have been
+// read by r. The cursor of r moves past the events that were acknowledged in
+// order, events acknowledged out of order are held back until the ones before
+// them are.
+func (r *Reader) Ack(event *protobuf.APIEvent) {
+	l := r.log
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, p := range r.pending {
+		if p.event == event && !p.acked {
+			p.acked = true
+			break
+		}
+	}
+
+	n := 0
+	for n < len(r.pending) && r.pending[n].acked {
+		r.committed = r.pending[n].end
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	clear(r.pending[:n])
+	r.pending = r.pending[n:]
+	metrics.WALPending.WithLabelValues(r.name).Set(float64(len(r.pending)))
+
+	if l.cfg.Sync == config.WALSyncAlways && !l.closed {
+		// A cursor that can't be saved now is saved again on the next sync.
+		_ = r.save()
+	}
+}
+
+// save writes the committed position of r to its cursor file if it moved.
+func (r *Reader) save() error {
+	if r.committed == r.saved {
+		return nil
+	}
+
+	data := binary.BigEndian.AppendUint64(make([]byte, 0, 16), r.committed.Segment)
+	data = binary.BigEndian.AppendUint64(data, uint64(r.committed.Offset))
+
+	path := r.log.cursorPath(r.name)
+	file, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if r.log.cfg.Sync != config.WALSyncNone {
+		if err := file.Sync(); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	r.saved = r.committed
+	return nil
+}
+
+// drop counts count events r lost.
+func (r *Reader) drop(count int) {
+	if count > 0 {
+		metrics.EventsDropped.WithLabelValues(r.name).Add(float64(count))
+	}
+}
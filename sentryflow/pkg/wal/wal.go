@@ -0,0 +1,472 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+// Package wal implements the write-ahead log API events are written to before
+// they are exported, so that they survive exporter outages and restarts. The
+// log is a directory of segment files that events are appended to. Every
+// exporter reading the log has its own cursor, which only moves past the
+// events the exporter acknowledged, and which is saved to the directory so
+// that the unacknowledged events are replayed after a restart.
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+const (
+	// recordHeaderSize is the size of the length and checksum every event
+	// record starts with.
+	recordHeaderSize = 8
+
+	segmentSuffix = ".wal"
+	cursorSuffix  = ".cursor"
+)
+
+// ErrClosed is returned when appending to or reading from a closed log.
+var ErrClosed = errors.New("write-ahead log closed")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Position is the position of an event record in the log.
+type Position struct {
+	Segment uint64
+	Offset  int64
+}
+
+// segment is a file of the log. Segments are numbered in the order they were
+// created, events are only appended to the last one.
+type segment struct {
+	id       uint64
+	size     int64
+	modified time.Time
+}
+
+// Log is a write-ahead log of API events.
+type Log struct {
+	cfg *config.WALConfig
+
+	lock     sync.Mutex
+	segments []*segment
+	active   *os.File
+	// size is the size of all segments, dirty whether events were appended
+	// since the active segment was last flushed.
+	size  int64
+	dirty bool
+	// appended is closed and replaced whenever events are appended, so that
+	// the readers waiting for events are woken up.
+	appended chan struct{}
+	readers  []*Reader
+	closed   bool
+}
+
+// Open opens the log in cfg.Dir, creating it if needed, with a reader for
+// every exporter of cfg.Exporters. An incomplete event at the end of the log,
+// e.g. one being written when SentryFlow was killed, is discarded.
+func Open(cfg *config.WALConfig) (*Log, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, err
+	}
+	l := &Log{
+		cfg:      cfg,
+		appended: make(chan struct{}),
+	}
+	if err := l.loadSegments(); err != nil {
+		return nil, err
+	}
+	for _, name := range cfg.Exporters {
+		r, err := l.loadReader(name)
+		if err != nil {
+			_ = l.active.Close()
+			return nil, err
+		}
+		l.readers = append(l.readers, r)
+	}
+	metrics.WALBytes.Set(float64(l.size))
+	return l, nil
+}
+
+func (l *Log) loadSegments() error {
+	entries, err := os.ReadDir(l.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		l.segments = append(l.segments, &segment{id: id, size: info.Size(), modified: info.ModTime()})
+		l.size += info.Size()
+	}
+	sort.Slice(l.segments, func(i, j int) bool {
+		return l.segments[i].id < l.segments[j].id
+	})
+
+	if len(l.segments) == 0 {
+		return l.create(1)
+	}
+
+	last := l.segments[len(l.segments)-1]
+	file, err := os.OpenFile(l.segmentPath(last.id), os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	_, end := scanRecords(file, 0, last.size)
+	if end < last.size {
+		if err := file.Truncate(end); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to truncate incomplete write-ahead log segment %d: %w", last.id, err)
+		}
+		l.size -= last.size - end
+		last.size = end
+	}
+	l.active = file
+	return nil
+}
+
+// create creates the segment id and makes it the active one.
+func (l *Log) create(id uint64) error {
+	file, err := os.OpenFile(l.segmentPath(id), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	l.active = file
+	l.segments = append(l.segments, &segment{id: id, modified: time.Now()})
+	return nil
+}
+
+func (l *Log) loadReader(name string) (*Reader, error) {
+	r := &Reader{log: l, name: name, replayed: make(chan struct{}, 1)}
+
+	data, err := os.ReadFile(l.cursorPath(name))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// A new exporter starts with the oldest events of the log.
+		r.committed = Position{Segment: l.segments[0].id}
+	case err != nil:
+		return nil, err
+	case len(data) != 16:
+		return nil, fmt.Errorf("invalid write-ahead log cursor of %s", name)
+	default:
+		r.committed = Position{
+			Segment: binary.BigEndian.Uint64(data),
+			Offset:  int64(binary.BigEndian.Uint64(data[8:])),
+		}
+	}
+
+	// The events the cursor points to may have been removed by the retention
+	// limits, or discarded because they were incomplete.
+	if r.committed.Segment < l.segments[0].id {
+		r.committed = Position{Segment: l.segments[0].id}
+	}
+	if seg := l.segment(r.committed.Segment); seg != nil && r.committed.Offset > seg.size {
+		r.committed.Offset = seg.size
+	}
+	r.next, r.saved = r.committed, r.committed
+	return r, nil
+}
+
+// Reader returns the reader of the named exporter, or nil if the exporter
+// doesn't read the log.
+func (l *Log) Reader(name string) *Reader {
+	for _, r := range l.readers {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Append writes event to the log. If the log grows beyond its maximum size,
+// its oldest segments are removed.
+func (l *Log) Append(event *protobuf.APIEvent) error {
+	data, err := proto.Marshal(event)
+	if err != nil {
+		l.drop(1)
+		return err
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:], crc32.Checksum(data, castagnoli))
+	record = append(record, data...)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if err := l.append(record); err != nil {
+		l.drop(1)
+		return err
+	}
+	return nil
+}
+
+func (l *Log) append(record []byte) error {
+	if l.closed {
+		return ErrClosed
+	}
+
+	last := l.segments[len(l.segments)-1]
+	if last.size > 0 && last.size+int64(len(record)) > l.cfg.SegmentBytes {
+		if err := l.roll(last.id + 1); err != nil {
+			return err
+		}
+		last = l.segments[len(l.segments)-1]
+	}
+
+	// A partially written record is overwritten by the next one.
+	if _, err := l.active.WriteAt(record, last.size); err != nil {
+		return err
+	}
+	if l.cfg.Sync == config.WALSyncAlways {
+		if err := l.active.Sync(); err != nil {
+			return err
+		}
+	} else {
+		l.dirty = true
+	}
+	last.size += int64(len(record))
+	last.modified = time.Now()
+	l.size += int64(len(record))
+
+	for l.size > l.cfg.MaxBytes && len(l.segments) > 1 {
+		l.removeOldest()
+	}
+	metrics.WALBytes.Set(float64(l.size))
+
+	close(l.appended)
+	l.appended = make(chan struct{})
+	return nil
+}
+
+// roll flushes and closes the active segment, and creates the segment id.
+func (l *Log) roll(id uint64) error {
+	if err := l.active.Sync(); err != nil {
+		return err
+	}
+	if err := l.active.Close(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return l.create(id)
+}
+
+// removeOldest removes the oldest segment. The events in it that a reader
+// didn't read yet are dropped.
+func (l *Log) removeOldest() {
+	oldest := l.segments[0]
+	l.segments = l.segments[1:]
+	l.size -= oldest.size
+
+	for _, r := range l.readers {
+		if r.fileID == oldest.id && r.file != nil {
+			if r.next.Segment == oldest.id {
+				lost, _ := scanRecords(r.file, r.next.Offset, oldest.size)
+				r.drop(lost)
+			}
+			_ = r.file.Close()
+			r.file = nil
+		} else if r.next.Segment == oldest.id {
+			r.drop(l.countRecords(oldest, r.next.Offset))
+		}
+		if r.next.Segment <= oldest.id {
+			r.next = Position{Segment: l.segments[0].id}
+		}
+	}
+	_ = os.Remove(l.segmentPath(oldest.id))
+}
+
+// countRecords returns the number of events in seg from offset on.
+func (l *Log) countRecords(seg *segment, offset int64) int {
+	file, err := os.Open(l.segmentPath(seg.id))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	count, _ := scanRecords(file, offset, seg.size)
+	return count
+}
+
+// drop counts count events that were lost for every reader.
+func (l *Log) drop(count int) {
+	for _, r := range l.readers {
+		r.drop(count)
+	}
+}
+
+// Run flushes the log, saves the readers' cursors and removes the segments
+// that every reader is done with, or that are older than the maximum age,
+// every sync interval until ctx is done.
+func (l *Log) Run(ctx context.Context, logger *zap.SugaredLogger) {
+	ticker := time.NewTicker(l.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.sync(); err != nil {
+				logger.Errorf("Failed to sync write-ahead log: %v", err)
+			}
+		}
+	}
+}
+
+func (l *Log) sync() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	err := l.flush()
+	for len(l.segments) > 1 && (l.expired(l.segments[0]) || l.consumed(l.segments[0])) {
+		l.removeOldest()
+	}
+	metrics.WALBytes.Set(float64(l.size))
+	return err
+}
+
+// flush flushes the active segment, if required by the sync policy, and saves
+// the cursors that moved.
+func (l *Log) flush() error {
+	var errs []error
+	if l.dirty && l.cfg.Sync == config.WALSyncInterval {
+		errs = append(errs, l.active.Sync())
+	}
+	l.dirty = false
+	for _, r := range l.readers {
+		errs = append(errs, r.save())
+	}
+	return errors.Join(errs...)
+}
+
+func (l *Log) expired(seg *segment) bool {
+	return l.cfg.MaxAge > 0 && time.Since(seg.modified) > l.cfg.MaxAge
+}
+
+// consumed reports whether every reader acknowledged all events of seg, which
+// mustn't be the active segment.
+func (l *Log) consumed(seg *segment) bool {
+	for _, r := range l.readers {
+		if r.committed.Segment < seg.id || (r.committed.Segment == seg.id && r.committed.Offset < seg.size) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close flushes the log and saves the readers' cursors. The readers' Run
+// return once the log is closed.
+func (l *Log) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	errs := []error{l.flush(), l.active.Close()}
+	for _, r := range l.readers {
+		if r.file != nil {
+			errs = append(errs, r.file.Close())
+			r.file = nil
+		}
+	}
+	l.closed = true
+	close(l.appended)
+	return errors.Join(errs...)
+}
+
+func (l *Log) segment(id uint64) *segment {
+	for _, seg := range l.segments {
+		if seg.id == id {
+			return seg
+		}
+	}
+	return nil
+}
+
+func (l *Log) segmentPath(id uint64) string {
+	return filepath.Join(l.cfg.Dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+func (l *Log) cursorPath(name string) string {
+	return filepath.Join(l.cfg.Dir, name+cursorSuffix)
+}
+
+// readRecord returns the event whose record starts at offset in file, and the
+// size of the record. The record must end before size.
+func readRecord(file *os.File, offset, size int64) (*protobuf.APIEvent, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := file.ReadAt(header, offset); err != nil {
+		return nil, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if offset+recordHeaderSize+length > size {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, offset+recordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(data, castagnoli) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, fmt.Errorf("checksum mismatch")
+	}
+	event := &protobuf.APIEvent{}
+	if err := proto.Unmarshal(data, event); err != nil {
+		return nil, 0, err
+	}
+	return event, recordHeaderSize + length, nil
+}
+
+// scanRecords returns the number of complete records in file between offset
+// and size, and where the last of them ends.
+func scanRecords(file *os.File, offset, size int64) (int, int64) {
+	count := 0
+	header := make([]byte, recordHeaderSize)
+	for offset+recordHeaderSize <= size {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			break
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		if offset+recordHeaderSize+length > size {
+			break
+		}
+		data := make([]byte, length)
+		if _, err := file.ReadAt(data, offset+recordHeaderSize); err != nil {
+			break
+		}
+		if crc32.Checksum(data, castagnoli) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		offset += recordHeaderSize + length
+		count++
+	}
+	return count, offset
+}
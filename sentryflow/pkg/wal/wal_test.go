@@ -0,0 +1,330 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2024 Authors of SentryFlow
+
+package wal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	protobuf "github.com/accuknox/SentryFlow/protobuf/golang"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/config"
+	"github.com/accuknox/SentryFlow/sentryflow/pkg/metrics"
+)
+
+func TestLog_replayAfterRestart(t *testing.T) {
+	cfg := walConfig(t.TempDir())
+
+	// Given events of which the first two were acknowledged out of order
+	l := openLog(t, cfg)
+	appendEvents(t, l, 1, 2, 3)
+	r := l.Reader(config.QueueHTTP)
+	read := readEvents(t, r, 3)
+	r.Ack(read[1])
+	if r.committed != (Position{Segment: 1}) {
+		t.Errorf("Ack() out of order moved the cursor to %+v", r.committed)
+	}
+	r.Ack(read[0])
+
+	// When
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	l = openLog(t, cfg)
+	defer l.Close()
+
+	// Then
+	if got := contextIDs(readEvents(t, l.Reader(config.QueueHTTP), 1)); !reflect.DeepEqual(got, []uint32{3}) {
+		t.Errorf("read %v after restart, want [3]", got)
+	}
+}
+
+func TestLog_readersHaveTheirOwnCursor(t *testing.T) {
+	cfg := walConfig(t.TempDir())
+	cfg.Exporters = []string{"first", "second"}
+	l := openLog(t, cfg)
+	defer l.Close()
+
+	// Given
+	appendEvents(t, l, 1, 2)
+
+	// When
+	first := l.Reader("first")
+	for _, event := range readEvents(t, first, 2) {
+		first.Ack(event)
+	}
+
+	// Then
+	if got := contextIDs(readEvents(t, l.Reader("second"), 2)); !reflect.DeepEqual(got, []uint32{1, 2}) {
+		t.Errorf("second reader read %v, want [1 2]", got)
+	}
+}
+
+func TestLog_Append_maxBytes(t *testing.T) {
+	cfg := walConfig(t.TempDir())
+	size := recordSize(t, event(1))
+	cfg.SegmentBytes = 2 * size
+	cfg.MaxBytes = 3 * size
+	l := openLog(t, cfg)
+	defer l.Close()
+	dropped := testutil.ToFloat64(metrics.EventsDropped.WithLabelValues(config.QueueHTTP))
+
+	// When more events than fit are appended
+	appendEvents(t, l, 1, 2, 3, 4)
+
+	// Then the oldest segment is removed
+	if got := testutil.ToFloat64(metrics.EventsDropped.WithLabelValues(config.QueueHTTP)) - dropped; got != 2 {
+		t.Errorf("Append() dropped %v events, want 2", got)
+	}
+	if got := contextIDs(readEvents(t, l.Reader(config.QueueHTTP), 2)); !reflect.DeepEqual(got, []uint32{3, 4}) {
+		t.Errorf("read %v, want [3 4]", got)
+	}
+	if _, err := os.Stat(l.segmentPath(1)); !os.IsNotExist(err) {
+		t.Errorf("oldest segment wasn't removed, stat error = %v", err)
+	}
+}
+
+func TestLog_sync_removesConsumedSegments(t *testing.T) {
+	cfg := walConfig(t.TempDir())
+	cfg.SegmentBytes = recordSize(t, event(1))
+	l := openLog(t, cfg)
+	defer l.Close()
+
+	// Given a first segment that was acknowledged
+	appendEvents(t, l, 1, 2)
+	r := l.Reader(config.QueueHTTP)
+	read := readEvents(t, r, 2)
+	r.Ack(read[0])
+
+	// When
+	if err := l.sync(); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	// Then
+	if _, err := os.Stat(l.segmentPath(1)); !os.IsNotExist(err) {
+		t.Errorf("consumed segment wasn't removed, stat error = %v", err)
+	}
+	if _, err := os.Stat(l.segmentPath(2)); err != nil {
+		t.Errorf("unacknowledged segment was removed, stat error = %v", err)
+	}
+	data, err := os.ReadFile(l.cursorPath(config.QueueHTTP))
+	if err != nil || len(data) != 16 {
+		t.Errorf("cursor wasn't saved, %v bytes, error = %v", len(data), err)
+	}
+}
+
+func TestOpen_discardsIncompleteEvent(t *testing.T) {
+	cfg := walConfig(t.TempDir())
+
+	// Given an event that was only partially written
+	l := openLog(t, cfg)
+	appendEvents(t, l, 1)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(cfg.Dir, "00000000000000000001.wal"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte{0, 0, 0, 42, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	// When
+	l = openLog(t, cfg)
+	defer l.Close()
+	appendEvents(t, l, 2)
+
+	// Then
+	if got := contextIDs(readEvents(t, l.Reader(config.QueueHTTP), 2)); !reflect.DeepEqual(got, []uint32{1, 2}) {
+		t.Errorf("read %v, want [1 2]", got)
+	}
+}
+
+func TestReader_Run(t *testing.T) {
+	l := openLog(t, walConfig(t.TempDir()))
+	out := make(chan *protobuf.APIEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Reader(config.QueueHTTP).Run(ctx, out, zap.NewNop().Sugar())
+	}()
+
+	// When events are appended while the reader waits for them
+	appendEvents(t, l, 1)
+
+	// Then
+	select {
+	case ev := <-out:
+		if ev.Metadata.ContextId != 1 {
+			t.Errorf("Run() sent event %v, want 1", ev.Metadata.ContextId)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() didn't send the appended event")
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() didn't return after Close()")
+	}
+	cancel()
+}
+
+func TestReader_Replay(t *testing.T) {
+	l := openLog(t, walConfig(t.TempDir()))
+	defer l.Close()
+	r := l.Reader(config.QueueHTTP)
+	out := make(chan *protobuf.APIEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, out, zap.NewNop().Sugar())
+
+	// Given events of which the first couldn't be delivered
+	appendEvents(t, l, 1, 2)
+	first, second := receiveEvent(t, out), receiveEvent(t, out)
+	r.Ack(second)
+
+	// When
+	r.Replay(first, 10*time.Millisecond)
+
+	// Then
+	if got := receiveEvent(t, out); got != first {
+		t.Fatalf("Run() sent event %v, want 1 again", got.Metadata.ContextId)
+	}
+	if r.committed != (Position{Segment: 1}) {
+		t.Errorf("Replay() moved the cursor to %+v", r.committed)
+	}
+	r.Ack(first)
+	if r.committed != r.next {
+		t.Errorf("Ack() of the replayed event moved the cursor to %+v, want %+v", r.committed, r.next)
+	}
+}
+
+func TestReader_Replay_maxReplays(t *testing.T) {
+	cfg := walConfig(t.TempDir())
+	cfg.MaxReplays = 2
+	l := openLog(t, cfg)
+	defer l.Close()
+	r := l.Reader(config.QueueHTTP)
+	out := make(chan *protobuf.APIEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, out, zap.NewNop().Sugar())
+
+	// Given an event that can never be delivered
+	appendEvents(t, l, 1)
+	event := receiveEvent(t, out)
+
+	// When
+	var replayed int
+	for r.Replay(event, time.Millisecond) {
+		replayed++
+		if got := receiveEvent(t, out); got != event {
+			t.Fatalf("Run() sent event %v, want 1 again", got.Metadata.ContextId)
+		}
+	}
+
+	// Then the event is given up on once replayed maxReplays times
+	if replayed != cfg.MaxReplays {
+		t.Errorf("Replay() replayed the event %d times, want %d", replayed, cfg.MaxReplays)
+	}
+	if r.committed != r.next {
+		t.Errorf("Replay() of the dropped event moved the cursor to %+v, want %+v", r.committed, r.next)
+	}
+}
+
+func walConfig(dir string) *config.WALConfig {
+	return &config.WALConfig{
+		Enabled:      true,
+		Dir:          dir,
+		Exporters:    []string{config.QueueHTTP},
+		SegmentBytes: config.DefaultWALSegmentBytes,
+		MaxBytes:     config.DefaultWALMaxBytes,
+		Sync:         config.WALSyncInterval,
+		SyncInterval: config.DefaultWALSyncInterval,
+		MaxReplays:   config.DefaultWALMaxReplays,
+	}
+}
+
+func openLog(t *testing.T, cfg *config.WALConfig) *Log {
+	t.Helper()
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return l
+}
+
+func appendEvents(t *testing.T, l *Log, contextIDs ...int) {
+	t.Helper()
+	for _, id := range contextIDs {
+		if err := l.Append(event(id)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+// readEvents reads count events with r, which must be available.
+func readEvents(t *testing.T, r *Reader, count int) []*protobuf.APIEvent {
+	t.Helper()
+	var events []*protobuf.APIEvent
+	for len(events) < count {
+		event, _, err := r.read()
+		if err != nil {
+			t.Fatalf("read() error = %v", err)
+		}
+		if event == nil {
+			t.Fatalf("read() %d events, want %d", len(events), count)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func receiveEvent(t *testing.T, out <-chan *protobuf.APIEvent) *protobuf.APIEvent {
+	t.Helper()
+	select {
+	case ev := <-out:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() didn't send an event")
+		return nil
+	}
+}
+
+func recordSize(t *testing.T, event *protobuf.APIEvent) int64 {
+	t.Helper()
+	l := openLog(t, walConfig(t.TempDir()))
+	defer l.Close()
+	appendEvents(t, l, int(event.Metadata.ContextId))
+	return l.size
+}
+
+func event(contextID int) *protobuf.APIEvent {
+	return &protobuf.APIEvent{
+		Metadata: &protobuf.Metadata{ContextId: uint32(contextID), ReceiverName: "wal-test"},
+		Request:  &protobuf.Request{Headers: map[string]string{":path": "/"}},
+	}
+}
+
+func contextIDs(events []*protobuf.APIEvent) []uint32 {
+	var ids []uint32
+	for _, event := range events {
+		ids = append(ids, event.Metadata.ContextId)
+	}
+	return ids
+}